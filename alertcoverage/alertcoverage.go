@@ -0,0 +1,347 @@
+// Package alertcoverage checks that every backup failure mode in a catalog
+// has an alert rule, and that the rule meets the mode's service level.
+//
+// The catalog (specs/failure_modes.yaml) maps each mode to the Terraform
+// addresses of the rules that cover it, with the least urgent severity and
+// the longest evaluation interval the mode allows. Rules are read from a plan
+// whatever their kind: scheduled query rules, metric alerts and activity log
+// alerts. A mode without an enabled rule, a rule outside its mode's service
+// level, and a rule no mode lists are all findings. So is a rule attached to
+// no action group: it fires, but notifies no one.
+package alertcoverage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"gopkg.in/yaml.v3"
+
+	"github.com/SwastikaAryal/azure_terraform/iso8601"
+)
+
+// Catalog lists the failure modes alerts must cover, as loaded from
+// specs/failure_modes.yaml.
+type Catalog struct {
+	Modes []Mode `yaml:"modes"`
+}
+
+// Mode is one way backups fail, and the alerting it needs.
+type Mode struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Rules are the Terraform addresses of the rules that cover the mode.
+	Rules []string `yaml:"rules"`
+	// MaxSeverity is the least urgent severity allowed, 0 to 4.
+	MaxSeverity int `yaml:"max_severity"`
+	// MaxEvaluationFrequency is the longest ISO 8601 interval allowed
+	// between evaluations.
+	MaxEvaluationFrequency string `yaml:"max_evaluation_frequency"`
+}
+
+// LoadCatalog reads a failure mode catalog.
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var c Catalog
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for _, m := range c.Modes {
+		switch {
+		case m.Name == "":
+			return nil, fmt.Errorf("%s: a mode has no name", path)
+		case seen[m.Name]:
+			return nil, fmt.Errorf("%s: mode %q is listed twice", path, m.Name)
+		case m.MaxSeverity < 0 || m.MaxSeverity > 4:
+			return nil, fmt.Errorf("%s: %s: max_severity %d is not 0 to 4", path, m.Name, m.MaxSeverity)
+		}
+		seen[m.Name] = true
+		if _, err := fixed(m.MaxEvaluationFrequency); err != nil {
+			return nil, fmt.Errorf("%s: %s: max_evaluation_frequency: %w", path, m.Name, err)
+		}
+	}
+	return &c, nil
+}
+
+// Rule is an alert rule as planned.
+type Rule struct {
+	Address string
+	Name    string
+	Type    string
+	Enabled bool
+	// Severity is 0 (critical) to 4 (verbose).
+	Severity int
+	// EvaluationFrequency is the ISO 8601 interval between evaluations;
+	// empty for activity log alerts, which fire as events arrive.
+	EvaluationFrequency string
+	// ActionGroups are the IDs of the action groups the rule notifies or,
+	// where the plan does not know them yet, the references that set them,
+	// e.g. azurerm_monitor_action_group.backup_alerts.id.
+	ActionGroups []string
+}
+
+// ruleType names the attributes of one alert rule type.
+type ruleType struct {
+	// frequency holds the evaluation interval; activity log alerts have none.
+	frequency string
+	// actionGroup is the attribute of the action blocks naming the groups.
+	actionGroup string
+}
+
+var ruleTypes = map[string]ruleType{
+	"azurerm_monitor_scheduled_query_rules_alert_v2": {"evaluation_frequency", "action_groups"},
+	"azurerm_monitor_metric_alert":                   {"frequency", "action_group_id"},
+	"azurerm_monitor_activity_log_alert":             {"", "action_group_id"},
+}
+
+// RulesFromPlan returns every alert rule in the plan, ordered by address.
+func RulesFromPlan(plan *terraform.PlanStruct) []Rule {
+	exprs := map[string]map[string]*tfjson.Expression{}
+	if config := plan.RawPlan.Config; config != nil && config.RootModule != nil {
+		for _, cr := range config.RootModule.Resources {
+			exprs[cr.Address] = cr.Expressions
+		}
+	}
+
+	var rules []Rule
+	for address, res := range plan.ResourcePlannedValuesMap {
+		rt, ok := ruleTypes[res.Type]
+		if !ok {
+			continue
+		}
+		vals := res.AttributeValues
+		r := Rule{Address: address, Type: res.Type, Enabled: true}
+		r.Name, _ = vals["name"].(string)
+		if enabled, ok := vals["enabled"].(bool); ok {
+			r.Enabled = enabled
+		}
+		if sev, ok := vals["severity"].(float64); ok {
+			r.Severity = int(sev)
+		}
+		if rt.frequency != "" {
+			r.EvaluationFrequency, _ = vals[rt.frequency].(string)
+		}
+		r.ActionGroups = actionGroups(vals, exprs[indexSuffix.ReplaceAllString(address, "")], rt.actionGroup)
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Address < rules[j].Address })
+	return rules
+}
+
+var indexSuffix = regexp.MustCompile(`\[[^\]]*\]$`)
+
+// actionGroups returns the action groups of a rule's action blocks, falling
+// back to the references of their configuration when the IDs are unknown.
+func actionGroups(vals map[string]interface{}, exprs map[string]*tfjson.Expression, attr string) []string {
+	var nested []map[string]*tfjson.Expression
+	if e := exprs["action"]; e != nil && e.ExpressionData != nil {
+		nested = e.NestedBlocks
+	}
+	var out []string
+	actions, _ := vals["action"].([]interface{})
+	for i, a := range actions {
+		a, _ := a.(map[string]interface{})
+		switch v := a[attr].(type) {
+		case string:
+			if v != "" {
+				out = append(out, v)
+			}
+			continue
+		case []interface{}:
+			for _, id := range v {
+				if id, ok := id.(string); ok && id != "" {
+					out = append(out, id)
+				}
+			}
+			continue
+		}
+		if i >= len(nested) {
+			continue
+		}
+		if e := nested[i][attr]; e != nil && e.ExpressionData != nil {
+			for _, ref := range e.References {
+				if strings.HasSuffix(ref, ".id") {
+					out = append(out, ref)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// FindingKind classifies a coverage finding.
+type FindingKind string
+
+const (
+	// Uncovered: no enabled rule in the plan covers the mode.
+	Uncovered FindingKind = "uncovered"
+	// MissingRule: a rule the catalog lists is not in the plan.
+	MissingRule FindingKind = "missing"
+	// DisabledRule: a rule the catalog lists is planned disabled.
+	DisabledRule FindingKind = "disabled"
+	// Severity: a rule is less urgent than its mode allows.
+	Severity FindingKind = "severity"
+	// Frequency: a rule is evaluated less often than its mode allows.
+	Frequency FindingKind = "frequency"
+	// Unmapped: a rule in the plan covers no mode in the catalog.
+	Unmapped FindingKind = "unmapped"
+	// Unattached: a rule notifies no action group.
+	Unattached FindingKind = "unattached"
+)
+
+// Finding is one gap between the catalog and the planned rules.
+type Finding struct {
+	Kind FindingKind
+	// Mode is empty for Unmapped and Unattached.
+	Mode string
+	// Rule is the rule's address; empty for Uncovered.
+	Rule string
+	// Want and Got are set for Severity and Frequency.
+	Want string
+	Got  string
+}
+
+func (f Finding) String() string {
+	switch f.Kind {
+	case Uncovered:
+		return fmt.Sprintf("mode %q: no enabled alert rule covers it", f.Mode)
+	case MissingRule:
+		return fmt.Sprintf("mode %q: rule %s is not in the plan", f.Mode, f.Rule)
+	case DisabledRule:
+		return fmt.Sprintf("mode %q: rule %s is disabled", f.Mode, f.Rule)
+	case Severity:
+		return fmt.Sprintf("mode %q: rule %s has severity %s, want %s", f.Mode, f.Rule, f.Got, f.Want)
+	case Frequency:
+		return fmt.Sprintf("mode %q: rule %s is evaluated every %s, want %s", f.Mode, f.Rule, f.Got, f.Want)
+	case Unmapped:
+		return fmt.Sprintf("rule %s covers no failure mode", f.Rule)
+	case Unattached:
+		return fmt.Sprintf("rule %s is not attached to an action group", f.Rule)
+	}
+	return fmt.Sprintf("%s: mode %q rule %s", f.Kind, f.Mode, f.Rule)
+}
+
+// Coverage is a mode with the planned, enabled rules that cover it.
+type Coverage struct {
+	Mode  Mode
+	Rules []Rule
+}
+
+func (c Coverage) String() string {
+	if len(c.Rules) == 0 {
+		return c.Mode.Name + ": none"
+	}
+	var rules []string
+	for _, r := range c.Rules {
+		freq := r.EvaluationFrequency
+		if freq == "" {
+			freq = "on event"
+		}
+		rules = append(rules, fmt.Sprintf("%s (Sev%d, %s)", r.Name, r.Severity, freq))
+	}
+	return c.Mode.Name + ": " + strings.Join(rules, ", ")
+}
+
+// Report is the coverage matrix and its findings.
+type Report struct {
+	// Coverage has one entry per mode, in catalog order.
+	Coverage []Coverage
+	Findings []Finding
+}
+
+// CheckPlan checks the catalog against the alert rules in the plan.
+func CheckPlan(c *Catalog, plan *terraform.PlanStruct) Report {
+	return Check(c, RulesFromPlan(plan))
+}
+
+// Check maps every mode of the catalog to its rules and reports each gap, by
+// mode in catalog order, then the rules no mode lists, then the rules that
+// notify no action group.
+func Check(c *Catalog, rules []Rule) Report {
+	byAddress := map[string]Rule{}
+	for _, r := range rules {
+		byAddress[r.Address] = r
+	}
+	listed := map[string]bool{}
+
+	var report Report
+	for _, m := range c.Modes {
+		cov := Coverage{Mode: m}
+		var findings []Finding
+		limit, _ := fixed(m.MaxEvaluationFrequency)
+		for _, address := range m.Rules {
+			listed[address] = true
+			r, ok := byAddress[address]
+			switch {
+			case !ok:
+				findings = append(findings, Finding{Kind: MissingRule, Mode: m.Name, Rule: address})
+				continue
+			case !r.Enabled:
+				findings = append(findings, Finding{Kind: DisabledRule, Mode: m.Name, Rule: address})
+				continue
+			}
+			cov.Rules = append(cov.Rules, r)
+
+			if r.Severity > m.MaxSeverity {
+				findings = append(findings, Finding{Kind: Severity, Mode: m.Name, Rule: address,
+					Want: fmt.Sprintf("Sev%d or more urgent", m.MaxSeverity), Got: fmt.Sprintf("Sev%d", r.Severity)})
+			}
+			if r.EvaluationFrequency == "" && ruleTypes[r.Type].frequency == "" {
+				continue
+			}
+			if every, err := fixed(r.EvaluationFrequency); err != nil || every > limit {
+				findings = append(findings, Finding{Kind: Frequency, Mode: m.Name, Rule: address,
+					Want: m.MaxEvaluationFrequency + " or more often", Got: orUnset(r.EvaluationFrequency)})
+			}
+		}
+		if len(cov.Rules) == 0 {
+			findings = append([]Finding{{Kind: Uncovered, Mode: m.Name}}, findings...)
+		}
+		report.Coverage = append(report.Coverage, cov)
+		report.Findings = append(report.Findings, findings...)
+	}
+
+	for _, r := range rules {
+		if !listed[r.Address] {
+			report.Findings = append(report.Findings, Finding{Kind: Unmapped, Rule: r.Address})
+		}
+	}
+	for _, r := range rules {
+		if len(r.ActionGroups) == 0 {
+			report.Findings = append(report.Findings, Finding{Kind: Unattached, Rule: r.Address})
+		}
+	}
+	return report
+}
+
+// fixed parses an ISO 8601 interval that has a fixed length.
+func fixed(s string) (time.Duration, error) {
+	d, err := iso8601.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	span, ok := d.Fixed()
+	if !ok {
+		return 0, fmt.Errorf("%s has no fixed length", s)
+	}
+	return span, nil
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return "unset"
+	}
+	return s
+}
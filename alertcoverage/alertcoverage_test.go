@@ -0,0 +1,182 @@
+package alertcoverage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/alertcoverage"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Alert coverage of failure modes (Sprint 3) ────────────────────────
+
+// TestAlertCoverage maps every failure mode in specs/failure_modes.yaml to the
+// alert rules in the seed plan and checks each mode is covered within its
+// severity and evaluation frequency, and each rule notifies the backup alerts
+// action group. It then drops, disables, slows and detaches rules, and adds
+// one no mode lists, and checks each gap is reported.
+func TestAlertCoverage(t *testing.T) {
+	t.Parallel()
+
+	catalog, err := alertcoverage.LoadCatalog(filepath.Join("..", "specs", "failure_modes.yaml"))
+	require.NoError(t, err)
+	raw, err := os.ReadFile(filepath.Join("..", "testdata", "synthetic_plan.json"))
+	require.NoError(t, err)
+	plan, err := terraform.ParsePlanJSON(string(raw))
+	require.NoError(t, err)
+	planned := alertcoverage.RulesFromPlan(plan)
+
+	// ── As planned ────────────────────────────────────────────────────────
+	report := alertcoverage.Check(catalog, planned)
+	t.Run("Planned", func(t *testing.T) {
+		traceability.Verifies(t, "every backup failure mode has an alert rule within its severity and evaluation frequency", "Sprint 3")
+
+		modes := []string{}
+		for _, c := range report.Coverage {
+			t.Log(c)
+			modes = append(modes, c.Mode.Name)
+			assert.NotEmpty(t, c.Rules, "%s is covered", c.Mode.Name)
+		}
+		assert.Equal(t, []string{
+			"job failed", "no backup in 24h", "vault health degraded",
+			"restore test failed", "snapshot policy failure", "protection stopped",
+		}, modes)
+		for _, f := range report.Findings {
+			if f.Kind != alertcoverage.Unattached {
+				t.Errorf("alert coverage: %s", f)
+			}
+		}
+	})
+
+	t.Run("ActionGroups", func(t *testing.T) {
+		traceability.Verifies(t, "every alert rule is attached to an action group", "Sprint 3")
+
+		// The group's ID is unknown until apply; the plan names it by reference.
+		for _, r := range planned {
+			assert.Equal(t, []string{"azurerm_monitor_action_group.backup_alerts.id"}, r.ActionGroups, "%s notifies the backup alerts group", r.Address)
+		}
+		for _, f := range report.Findings {
+			assert.NotEqual(t, alertcoverage.Unattached, f.Kind, f.String())
+		}
+	})
+
+	// ── Gaps ──────────────────────────────────────────────────────────────
+	const (
+		jobFailure = "azurerm_monitor_scheduled_query_rules_alert_v2.backup_job_failure"
+		stale      = "azurerm_monitor_scheduled_query_rules_alert_v2.backup_stale"
+		restore    = "azurerm_monitor_scheduled_query_rules_alert_v2.restore_failure"
+		health     = "azurerm_monitor_metric_alert.vault_health"
+	)
+	for _, tc := range []struct {
+		name string
+		edit func([]alertcoverage.Rule) []alertcoverage.Rule
+		want []string
+	}{
+		{
+			name: "rule dropped",
+			edit: func(rules []alertcoverage.Rule) []alertcoverage.Rule { return without(rules, restore) },
+			want: []string{
+				`mode "restore test failed": no enabled alert rule covers it`,
+				`mode "restore test failed": rule ` + restore + ` is not in the plan`,
+			},
+		},
+		{
+			name: "rule disabled",
+			edit: func(rules []alertcoverage.Rule) []alertcoverage.Rule {
+				return edited(rules, stale, func(r *alertcoverage.Rule) { r.Enabled = false })
+			},
+			want: []string{
+				`mode "no backup in 24h": no enabled alert rule covers it`,
+				`mode "no backup in 24h": rule ` + stale + ` is disabled`,
+			},
+		},
+		{
+			name: "severity lowered",
+			edit: func(rules []alertcoverage.Rule) []alertcoverage.Rule {
+				return edited(rules, health, func(r *alertcoverage.Rule) { r.Severity = 3 })
+			},
+			want: []string{`mode "vault health degraded": rule ` + health + ` has severity Sev3, want Sev1 or more urgent`},
+		},
+		{
+			// The job failure rule covers two modes, and misses both.
+			name: "evaluated less often",
+			edit: func(rules []alertcoverage.Rule) []alertcoverage.Rule {
+				return edited(rules, jobFailure, func(r *alertcoverage.Rule) { r.EvaluationFrequency = "PT30M" })
+			},
+			want: []string{
+				`mode "job failed": rule ` + jobFailure + ` is evaluated every PT30M, want PT15M or more often`,
+				`mode "snapshot policy failure": rule ` + jobFailure + ` is evaluated every PT30M, want PT15M or more often`,
+			},
+		},
+		{
+			name: "rule not in the catalog",
+			edit: func(rules []alertcoverage.Rule) []alertcoverage.Rule {
+				return append(rules, alertcoverage.Rule{
+					Address: "azurerm_monitor_activity_log_alert.vault_deleted", Name: "alert-vault-deleted",
+					Type: "azurerm_monitor_activity_log_alert", Enabled: true,
+				})
+			},
+			want: []string{
+				"rule azurerm_monitor_activity_log_alert.vault_deleted covers no failure mode",
+				"rule azurerm_monitor_activity_log_alert.vault_deleted is not attached to an action group",
+			},
+		},
+		{
+			name: "action group detached",
+			edit: func(rules []alertcoverage.Rule) []alertcoverage.Rule {
+				return edited(rules, health, func(r *alertcoverage.Rule) { r.ActionGroups = nil })
+			},
+			want: []string{"rule " + health + " is not attached to an action group"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := []string{}
+			for _, f := range alertcoverage.Check(catalog, tc.edit(append([]alertcoverage.Rule{}, planned...))).Findings {
+				got = append(got, f.String())
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	// ── Catalog ───────────────────────────────────────────────────────────
+	t.Run("Catalog", func(t *testing.T) {
+		for body, want := range map[string]string{
+			"modes:\n  - name: x\n    max_severity: 5\n    max_evaluation_frequency: PT5M\n":              "x: max_severity 5 is not 0 to 4",
+			"modes:\n  - name: x\n    max_severity: 1\n    max_evaluation_frequency: P1M\n":               "x: max_evaluation_frequency: P1M has no fixed length",
+			"modes:\n  - name: x\n    max_severity: 1\n    max_evaluation_frequency: PT5M\n    slo: 99\n": "field slo not found",
+		} {
+			path := filepath.Join(t.TempDir(), "modes.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+			_, err := alertcoverage.LoadCatalog(path)
+			if assert.Error(t, err, body) {
+				assert.Contains(t, err.Error(), want)
+			}
+		}
+	})
+}
+
+// without returns rules without the one at address.
+func without(rules []alertcoverage.Rule, address string) []alertcoverage.Rule {
+	var out []alertcoverage.Rule
+	for _, r := range rules {
+		if r.Address != address {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// edited returns rules with fn applied to the one at address.
+func edited(rules []alertcoverage.Rule, address string, fn func(*alertcoverage.Rule)) []alertcoverage.Rule {
+	for i := range rules {
+		if rules[i].Address == address {
+			fn(&rules[i])
+		}
+	}
+	return rules
+}
@@ -0,0 +1,306 @@
+// Package alerteval runs the criteria of a scheduled query rule over fixture
+// logs and returns the alerts one evaluation would fire.
+//
+// It follows Azure Monitor: the tables are cut to the rule's window, the
+// query runs, its rows are split by the dimension columns, each split is
+// aggregated with the time aggregation method and compared with the
+// threshold. A rule without dimensions that counts rows is one split even
+// when the query returns nothing, so a LessThan rule can fire on silence.
+//
+// One evaluation period is run, which is what rules with a single failing
+// period need. Dimension values are not filtered; the module's dimensions
+// include every value.
+//
+// Alert.Payload renders a fired alert as the Common Alert Schema
+// notification the rule's action group would post.
+package alerteval
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SwastikaAryal/azure_terraform/alertlint"
+	"github.com/SwastikaAryal/azure_terraform/commonalert"
+	"github.com/SwastikaAryal/azure_terraform/iso8601"
+	"github.com/SwastikaAryal/azure_terraform/kql"
+)
+
+// Alert is one split of a rule that fired.
+type Alert struct {
+	Rule string
+	// Criterion is the criterion the split fired on.
+	Criterion alertlint.Criterion
+	// Dimensions holds the value of each dimension column, as text.
+	Dimensions map[string]string
+	// Value is the aggregated value compared with the threshold.
+	Value float64
+}
+
+func (a Alert) String() string {
+	var dims []string
+	for k, v := range a.Dimensions {
+		dims = append(dims, k+"="+v)
+	}
+	sort.Strings(dims)
+	return fmt.Sprintf("%s[%s] = %g", a.Rule, strings.Join(dims, ", "), a.Value)
+}
+
+// Payload is the Common Alert Schema notification Azure Monitor sends for a,
+// fired at the given time by r. Dimension values that are resource IDs, such
+// as _ResourceId, name the affected resources in the configuration items.
+func (a Alert) Payload(r alertlint.Rule, fired time.Time) commonalert.Payload {
+	var dims []commonalert.Dimension
+	var items []string
+	for _, name := range a.Criterion.Dimensions {
+		v := a.Dimensions[name]
+		dims = append(dims, commonalert.Dimension{Name: name, Value: v})
+		if strings.HasPrefix(strings.ToLower(v), "/subscriptions/") {
+			items = append(items, path.Base(v))
+		}
+	}
+	if len(items) == 0 {
+		for _, s := range r.Scopes {
+			items = append(items, path.Base(s))
+		}
+	}
+
+	// Azure's IDs are GUIDs; these are stable per rule and split instead, so
+	// repeated runs compare equal.
+	id := fmt.Sprintf("%x", sha256.Sum256([]byte(a.String())))
+	subscription := ""
+	if len(r.Scopes) > 0 {
+		if parts := strings.SplitN(r.Scopes[0], "/", 4); len(parts) > 2 {
+			subscription = "/subscriptions/" + parts[2]
+		}
+	}
+	window, _ := iso8601.ParseDuration(r.WindowDuration)
+	span, _ := window.Fixed()
+
+	return commonalert.Payload{
+		SchemaID: commonalert.SchemaID,
+		Data: commonalert.Data{
+			Essentials: commonalert.Essentials{
+				AlertID:             subscription + "/providers/Microsoft.AlertsManagement/alerts/" + id[:32],
+				AlertRule:           r.Name,
+				Severity:            fmt.Sprintf("Sev%d", r.Severity),
+				SignalType:          "Log",
+				MonitorCondition:    "Fired",
+				MonitoringService:   commonalert.LogAlertsV2,
+				AlertTargetIDs:      r.Scopes,
+				ConfigurationItems:  items,
+				OriginAlertID:       id[32:],
+				FiredDateTime:       fired.UTC().Format(time.RFC3339Nano),
+				Description:         r.Description,
+				EssentialsVersion:   "1.0",
+				AlertContextVersion: "1.0",
+			},
+			AlertContext: commonalert.AlertContext{
+				ConditionType: commonalert.LogQueryCriteria,
+				Condition: commonalert.Condition{
+					WindowSize: r.WindowDuration,
+					AllOf: []commonalert.Criterion{{
+						SearchQuery:         a.Criterion.Query,
+						MetricMeasureColumn: a.Criterion.MetricMeasureColumn,
+						Operator:            a.Criterion.Operator,
+						Threshold:           fmt.Sprint(a.Criterion.Threshold),
+						TimeAggregation:     a.Criterion.TimeAggregation,
+						Dimensions:          dims,
+						MetricValue:         a.Value,
+					}},
+					WindowStartTime: fired.Add(-span).UTC().Format(time.RFC3339Nano),
+					WindowEndTime:   fired.UTC().Format(time.RFC3339Nano),
+				},
+			},
+			CustomProperties: r.CustomProperties,
+		},
+	}
+}
+
+// Evaluate runs every criterion of r against env as of env.Now, and returns
+// the alerts that fire, in the order their splits first appear.
+func Evaluate(r alertlint.Rule, env kql.Env) ([]Alert, error) {
+	window, err := iso8601.ParseDuration(r.WindowDuration)
+	if err != nil {
+		return nil, fmt.Errorf("%s: window_duration: %w", r.Name, err)
+	}
+	span, ok := window.Fixed()
+	if !ok {
+		return nil, fmt.Errorf("%s: window_duration %s has no fixed length", r.Name, r.WindowDuration)
+	}
+	env = inWindow(env, env.Now.Add(-span))
+
+	var out []Alert
+	for _, c := range r.Criteria {
+		alerts, err := evaluate(c, env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		for _, a := range alerts {
+			a.Rule = r.Name
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// inWindow drops the rows whose TimeGenerated is at or before since, or
+// after env.Now. Rows without a TimeGenerated are kept.
+func inWindow(env kql.Env, since time.Time) kql.Env {
+	out := kql.Env{Now: env.Now, Tables: map[string]kql.Table{}}
+	for name, t := range env.Tables {
+		kept := kql.Table{Columns: t.Columns}
+		for _, row := range t.Rows {
+			if ts, ok := row["TimeGenerated"].(time.Time); ok && (!ts.After(since) || ts.After(env.Now)) {
+				continue
+			}
+			kept.Rows = append(kept.Rows, row)
+		}
+		out.Tables[name] = kept
+	}
+	return out
+}
+
+func evaluate(c alertlint.Criterion, env kql.Env) ([]Alert, error) {
+	result, err := kql.Eval(c.Query, env)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range append(append([]string{}, c.Dimensions...), c.MetricMeasureColumn) {
+		if d != "" && !contains(result.Columns, d) {
+			return nil, fmt.Errorf("the query returns no column %s", d)
+		}
+	}
+
+	// Split the rows by their dimension values.
+	type split struct {
+		dims map[string]string
+		rows []kql.Row
+	}
+	var splits []*split
+	index := map[string]*split{}
+	for _, row := range result.Rows {
+		dims := map[string]string{}
+		var key []string
+		for _, d := range c.Dimensions {
+			dims[d] = text(row[d])
+			key = append(key, dims[d])
+		}
+		k := strings.Join(key, "\x00")
+		s, ok := index[k]
+		if !ok {
+			s = &split{dims: dims}
+			index[k] = s
+			splits = append(splits, s)
+		}
+		s.rows = append(s.rows, row)
+	}
+	if len(c.Dimensions) == 0 && len(splits) == 0 && c.TimeAggregation == "Count" {
+		splits = []*split{{dims: map[string]string{}}}
+	}
+
+	var out []Alert
+	for _, s := range splits {
+		value, ok, err := aggregate(c, s.rows)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		fires, err := compare(c.Operator, value, c.Threshold)
+		if err != nil {
+			return nil, err
+		}
+		if fires {
+			out = append(out, Alert{Criterion: c, Dimensions: s.dims, Value: value})
+		}
+	}
+	return out, nil
+}
+
+// aggregate applies the time aggregation method to a split's rows. It
+// reports false when the measure column has no values to aggregate.
+func aggregate(c alertlint.Criterion, rows []kql.Row) (float64, bool, error) {
+	if c.TimeAggregation == "Count" {
+		return float64(len(rows)), true, nil
+	}
+	if c.MetricMeasureColumn == "" {
+		return 0, false, fmt.Errorf("time_aggregation_method %s needs a metric_measure_column", c.TimeAggregation)
+	}
+	var vals []float64
+	for _, row := range rows {
+		switch v := row[c.MetricMeasureColumn].(type) {
+		case nil:
+		case float64:
+			vals = append(vals, v)
+		default:
+			return 0, false, fmt.Errorf("metric_measure_column %s is not a number", c.MetricMeasureColumn)
+		}
+	}
+	if len(vals) == 0 {
+		return 0, false, nil
+	}
+
+	result := vals[0]
+	switch c.TimeAggregation {
+	case "Total", "Average":
+		result = 0
+		for _, v := range vals {
+			result += v
+		}
+		if c.TimeAggregation == "Average" {
+			result /= float64(len(vals))
+		}
+	case "Minimum":
+		for _, v := range vals {
+			result = min(result, v)
+		}
+	case "Maximum":
+		for _, v := range vals {
+			result = max(result, v)
+		}
+	default:
+		return 0, false, fmt.Errorf("unknown time_aggregation_method %q", c.TimeAggregation)
+	}
+	return result, true, nil
+}
+
+// compare applies the rule's operator to a value and the threshold.
+func compare(operator string, value, threshold float64) (bool, error) {
+	switch operator {
+	case "Equal":
+		return value == threshold, nil
+	case "GreaterThan":
+		return value > threshold, nil
+	case "GreaterThanOrEqual":
+		return value >= threshold, nil
+	case "LessThan":
+		return value < threshold, nil
+	case "LessThanOrEqual":
+		return value <= threshold, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", operator)
+}
+
+func text(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return x.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
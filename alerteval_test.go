@@ -0,0 +1,114 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/alerteval"
+	"github.com/SwastikaAryal/azure_terraform/alertlint"
+	"github.com/SwastikaAryal/azure_terraform/kql"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// fixtureVault is the _ResourceId of the vault in the job fixtures.
+const fixtureVault = "/subscriptions/11111111-2222-3333-4444-555555555555/resourcegroups/rg-minitrue-test-abc123/providers/microsoft.recoveryservices/vaults/rsv-backup"
+
+// ─── Test: Alert rules against fixture logs (Sprint 3) ───────────────────────
+
+// TestAlertRulesAgainstLogs runs the planned scheduled query rules, with
+// their window, threshold and operator, over the fixture logs in
+// testdata/logs, and checks each fires on the rows it is for and stays quiet
+// on the rest. The evaluator itself is covered in the kql package.
+func TestAlertRulesAgainstLogs(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "backup alert queries fire on failed backups and stale items, and stay quiet otherwise", "Sprint 3")
+
+	raw, err := os.ReadFile("testdata/synthetic_plan.json")
+	require.NoError(t, err)
+	plan, err := terraform.ParsePlanJSON(string(raw))
+	require.NoError(t, err)
+	rules := map[string]alertlint.Rule{}
+	for _, r := range alertlint.FromPlan(plan) {
+		rules[r.Name] = r
+	}
+	rule := func(name string) alertlint.Rule {
+		r, ok := rules[name]
+		require.True(t, ok, "%s is not in the plan", name)
+		return r
+	}
+	fire := func(t *testing.T, r alertlint.Rule, fixture string) []string {
+		env, err := kql.LoadFixture("testdata/logs/" + fixture)
+		require.NoError(t, err)
+		alerts, err := alerteval.Evaluate(r, env)
+		require.NoError(t, err)
+		out := []string{}
+		for _, a := range alerts {
+			out = append(out, a.String())
+		}
+		return out
+	}
+
+	// ── As planned ────────────────────────────────────────────────────────
+	t.Run("Rules", func(t *testing.T) {
+		for _, tc := range []struct {
+			rule, fixture string
+			want          []string
+		}{
+			// One failed backup in the last 15 minutes. The failed restore and
+			// the failure 30 minutes ago are not counted.
+			{"alert-backup-job-failure", "jobs_failed.json", []string{"alert-backup-job-failure[_ResourceId=" + fixtureVault + "] = 1"}},
+			{"alert-backup-job-failure", "jobs_completed.json", []string{}},
+			// vm-app-01's latest recovery point is 34 hours old. vm-app-03 has
+			// not been reported for over a day, so it is outside the window and
+			// this rule cannot see it.
+			{"alert-backup-not-run-24h", "items_stale.json", []string{"alert-backup-not-run-24h[BackupItemUniqueId=vm-app-01] = 1"}},
+			{"alert-backup-not-run-24h", "items_fresh.json", []string{}},
+			// The failed restore of vm-app-02; the failed backups are not restores.
+			{"alert-restore-job-failure", "jobs_failed.json", []string{"alert-restore-job-failure[BackupItemUniqueId=vm-app-02] = 1"}},
+			{"alert-restore-job-failure", "jobs_completed.json", []string{}},
+			// vm-app-01 was protected again after it stopped; the latest report
+			// of each item counts.
+			{"alert-backup-protection-stopped", "items_stopped.json", []string{
+				"alert-backup-protection-stopped[BackupItemUniqueId=vm-app-02] = 1",
+				"alert-backup-protection-stopped[BackupItemUniqueId=vm-app-03] = 1",
+			}},
+			{"alert-backup-protection-stopped", "items_fresh.json", []string{}},
+		} {
+			t.Run(tc.rule+"/"+tc.fixture, func(t *testing.T) {
+				assert.Equal(t, tc.want, fire(t, rule(tc.rule), tc.fixture))
+			})
+		}
+	})
+
+	// ── Threshold and operator ────────────────────────────────────────────
+	t.Run("Threshold", func(t *testing.T) {
+		for _, tc := range []struct {
+			name      string
+			operator  string
+			threshold float64
+			fixture   string
+			// undivided drops the rule's dimensions.
+			undivided bool
+			want      []string
+		}{
+			{"one failure is not more than one", "GreaterThan", 1, "jobs_failed.json", false, []string{}},
+			{"one failure is at least one", "GreaterThanOrEqual", 1, "jobs_failed.json", false, []string{"alert-backup-job-failure[_ResourceId=" + fixtureVault + "] = 1"}},
+			{"no rows are no split", "LessThan", 1, "jobs_completed.json", false, []string{}},
+			{"no rows count as zero without dimensions", "LessThan", 1, "jobs_completed.json", true, []string{"alert-backup-job-failure[] = 0"}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				r := rule("alert-backup-job-failure")
+				r.Criteria = append([]alertlint.Criterion{}, r.Criteria...)
+				r.Criteria[0].Operator, r.Criteria[0].Threshold = tc.operator, tc.threshold
+				if tc.undivided {
+					r.Criteria[0].Dimensions = nil
+				}
+				assert.Equal(t, tc.want, fire(t, r, tc.fixture))
+			})
+		}
+	})
+}
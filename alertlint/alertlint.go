@@ -0,0 +1,526 @@
+// Package alertlint statically checks the KQL of scheduled query alert
+// rules.
+//
+// FromPlan pulls every azurerm_monitor_scheduled_query_rules_alert_v2 out of
+// a Terraform plan, and Check rejects, criterion by criterion:
+//
+//   - a query that does not parse;
+//   - a placeholder: an empty query, one marked TODO, or one that takes rows
+//     without filtering or aggregating them, which fires on any data at all;
+//   - a table outside Schema, the tables the backup vaults write;
+//   - a query that does not filter on TimeGenerated;
+//   - a dimension or metric_measure_column that is not among the query's
+//     output columns, which Azure only rejects once the rule is created.
+//
+// Output columns are followed through the operators the alert queries use;
+// after any other operator they are unknown and not checked.
+package alertlint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+
+	"github.com/SwastikaAryal/azure_terraform/kql"
+)
+
+const ruleType = "azurerm_monitor_scheduled_query_rules_alert_v2"
+
+// Checks, as named in a Problem.
+const (
+	Syntax       = "syntax"
+	Placeholder  = "placeholder"
+	UnknownTable = "unknown table"
+	TimeFilter   = "time filter"
+	Projection   = "projection"
+)
+
+// Rule is a scheduled query rule as planned.
+type Rule struct {
+	Address     string
+	Name        string
+	Description string
+	// Severity is 0 (critical) to 4 (verbose).
+	Severity int
+	// Scopes are the resources the rule queries; unknown until apply when
+	// the plan creates them.
+	Scopes []string
+	// WindowDuration is the ISO 8601 span of logs each evaluation reads.
+	WindowDuration string
+	Criteria       []Criterion
+	// CustomProperties are added to the rule's notifications.
+	CustomProperties map[string]string
+}
+
+// Criterion is one criteria block of a rule.
+type Criterion struct {
+	Query string
+	// MetricMeasureColumn is empty when the rule counts rows.
+	MetricMeasureColumn string
+	// Dimensions are the columns the alert is split by.
+	Dimensions []string
+	// TimeAggregation, Operator and Threshold decide when the rule fires.
+	TimeAggregation string
+	Operator        string
+	Threshold       float64
+}
+
+// Problem is one reason a rule's query is rejected.
+type Problem struct {
+	Rule   string
+	Check  string
+	Detail string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Rule, p.Check, p.Detail)
+}
+
+// FromPlan returns the scheduled query rules in the plan, by address.
+func FromPlan(plan *terraform.PlanStruct) []Rule {
+	var addresses []string
+	for address, r := range plan.ResourcePlannedValuesMap {
+		if r.Type == ruleType {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+
+	rules := make([]Rule, 0, len(addresses))
+	for _, address := range addresses {
+		attrs := plan.ResourcePlannedValuesMap[address].AttributeValues
+		rule := Rule{
+			Address:        address,
+			Name:           str(attrs, "name"),
+			Description:    str(attrs, "description"),
+			WindowDuration: str(attrs, "window_duration"),
+		}
+		if sev, ok := attrs["severity"].(float64); ok {
+			rule.Severity = int(sev)
+		}
+		scopes, _ := attrs["scopes"].([]interface{})
+		for _, s := range scopes {
+			if s, ok := s.(string); ok {
+				rule.Scopes = append(rule.Scopes, s)
+			}
+		}
+		for _, a := range blocks(attrs, "action") {
+			props, _ := a["custom_properties"].(map[string]interface{})
+			for k, v := range props {
+				if rule.CustomProperties == nil {
+					rule.CustomProperties = map[string]string{}
+				}
+				rule.CustomProperties[k] = fmt.Sprint(v)
+			}
+		}
+		for _, c := range blocks(attrs, "criteria") {
+			crit := Criterion{
+				Query:               str(c, "query"),
+				MetricMeasureColumn: str(c, "metric_measure_column"),
+				TimeAggregation:     str(c, "time_aggregation_method"),
+				Operator:            str(c, "operator"),
+			}
+			crit.Threshold, _ = c["threshold"].(float64)
+			for _, d := range blocks(c, "dimension") {
+				crit.Dimensions = append(crit.Dimensions, str(d, "name"))
+			}
+			rule.Criteria = append(rule.Criteria, crit)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// CheckPlan checks every scheduled query rule in the plan.
+func CheckPlan(plan *terraform.PlanStruct) []Problem {
+	var out []Problem
+	for _, r := range FromPlan(plan) {
+		out = append(out, Check(r)...)
+	}
+	return out
+}
+
+// Check checks the queries of one rule.
+func Check(r Rule) []Problem {
+	var out []Problem
+	for _, c := range r.Criteria {
+		for _, p := range checkCriterion(c) {
+			out = append(out, Problem{Rule: r.Name, Check: p.check, Detail: p.detail})
+		}
+	}
+	return out
+}
+
+type finding struct{ check, detail string }
+
+// markers flag a query someone meant to come back to.
+var markers = regexp.MustCompile(`(?i)\b(todo|fixme|tbd|placeholder|changeme)\b`)
+
+// reducing are the operators that filter or aggregate rows; a query with
+// none of them fires whenever its table has any data.
+var reducing = map[string]bool{
+	"where": true, "filter": true, "summarize": true, "count": true,
+	"distinct": true, "join": true, "lookup": true, "make-series": true,
+}
+
+func checkCriterion(c Criterion) []finding {
+	var out []finding
+	if strings.TrimSpace(c.Query) == "" {
+		return []finding{{Placeholder, "the query is empty"}}
+	}
+	stmts, err := kql.Parse(c.Query)
+	if err != nil {
+		return []finding{{Syntax, err.Error()}}
+	}
+	q := newQuery(c.Query, stmts)
+	main := q.lineage(q.pipeline())
+
+	// ── Placeholders ──────────────────────────────────────────────────────
+	if m := markers.FindString(c.Query); m != "" {
+		out = append(out, finding{Placeholder, fmt.Sprintf("the query is marked %s", m)})
+	}
+	reduces := false
+	for _, s := range main[1:] {
+		reduces = reduces || reducing[s.Operator]
+	}
+	if !reduces {
+		out = append(out, finding{Placeholder, "the query takes rows without filtering or aggregating them"})
+	}
+
+	// ── Tables ────────────────────────────────────────────────────────────
+	tables := kql.Tables(c.Query)
+	if len(tables) == 0 {
+		out = append(out, finding{UnknownTable, "the query reads no table"})
+	}
+	for _, t := range tables {
+		if _, ok := Schema[t]; !ok {
+			out = append(out, finding{UnknownTable, fmt.Sprintf("%s is not a table the backup vaults write", t)})
+		}
+	}
+
+	// ── Time filter ───────────────────────────────────────────────────────
+	if !filtersTime(main) {
+		out = append(out, finding{TimeFilter, "no where clause on TimeGenerated"})
+	}
+
+	// ── Projection ────────────────────────────────────────────────────────
+	if cols, known := q.output(main, 0); known {
+		need := append([]string{}, c.Dimensions...)
+		if c.MetricMeasureColumn != "" {
+			need = append(need, c.MetricMeasureColumn)
+		}
+		for i, col := range need {
+			if cols[col] {
+				continue
+			}
+			what := "dimension " + col
+			if i >= len(c.Dimensions) {
+				what = "metric_measure_column " + col
+			}
+			out = append(out, finding{Projection, fmt.Sprintf("%s is not an output column of the query (%s)", what, strings.Join(sorted(cols), ", "))})
+		}
+	}
+	return out
+}
+
+// ─── Query model ─────────────────────────────────────────────────────────────
+
+// maxDepth bounds how deep let bindings and subqueries are followed.
+const maxDepth = 8
+
+type query struct {
+	text  string
+	stmts []kql.Statement
+	lets  map[string][]kql.Stage
+}
+
+func newQuery(text string, stmts []kql.Statement) *query {
+	q := &query{text: text, stmts: stmts, lets: map[string][]kql.Stage{}}
+	for _, s := range stmts {
+		if s.Let != "" {
+			q.lets[s.Let] = s.Stages
+		}
+	}
+	return q
+}
+
+// pipeline is the statement the query returns: the last one that binds no
+// name.
+func (q *query) pipeline() []kql.Stage {
+	for i := len(q.stmts) - 1; i >= 0; i-- {
+		if q.stmts[i].Let == "" {
+			return q.stmts[i].Stages
+		}
+	}
+	return q.stmts[len(q.stmts)-1].Stages
+}
+
+// lineage is the pipeline preceded by the let bindings it starts from, so
+// that it starts from a table.
+func (q *query) lineage(stages []kql.Stage) []kql.Stage {
+	for depth := 0; depth < maxDepth; depth++ {
+		src, ok := q.lets[stages[0].Operator]
+		if !ok || len(stages[0].Args) > 0 {
+			break
+		}
+		stages = append(append([]kql.Stage{}, src...), stages[1:]...)
+	}
+	return stages
+}
+
+// filtersTime reports whether the pipeline filters on TimeGenerated.
+func filtersTime(stages []kql.Stage) bool {
+	for _, s := range stages[1:] {
+		if s.Operator != "where" && s.Operator != "filter" {
+			continue
+		}
+		for _, t := range s.Args {
+			if t.Kind == kql.Ident && t.Text == "TimeGenerated" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// output returns the columns the pipeline produces, and false when they
+// cannot be told.
+func (q *query) output(stages []kql.Stage, depth int) (map[string]bool, bool) {
+	if depth > maxDepth {
+		return nil, false
+	}
+	stages = q.lineage(stages)
+	columns, ok := Schema[stages[0].Operator]
+	if !ok || len(stages[0].Args) > 0 {
+		return nil, false
+	}
+	cols := set(columns)
+
+	for _, s := range stages[1:] {
+		items := kql.SplitArgs(s.Args)
+		switch s.Operator {
+		case "where", "filter", "take", "limit", "sort", "order", "top", "sample", "serialize", "project-reorder":
+		case "project", "project-keep":
+			next := map[string]bool{}
+			for _, item := range items {
+				name, ok := itemName(item)
+				if !ok {
+					return nil, false
+				}
+				next[name] = true
+			}
+			cols = next
+		case "project-away":
+			for _, item := range items {
+				name, ok := itemName(item)
+				if !ok {
+					return nil, false
+				}
+				delete(cols, name)
+			}
+		case "project-rename":
+			for _, item := range items {
+				if len(item) != 3 || item[1].Text != "=" {
+					return nil, false
+				}
+				delete(cols, item[2].Text)
+				cols[item[0].Text] = true
+			}
+		case "extend":
+			for _, item := range items {
+				name, ok := itemName(item)
+				if !ok {
+					return nil, false
+				}
+				cols[name] = true
+			}
+		case "summarize":
+			next, ok := summarized(s.Args, cols)
+			if !ok {
+				return nil, false
+			}
+			cols = next
+		case "count":
+			cols = map[string]bool{"Count": true}
+		case "distinct":
+			next := map[string]bool{}
+			for _, item := range items {
+				name, ok := itemName(item)
+				if !ok {
+					return nil, false
+				}
+				next[name] = true
+			}
+			cols = next
+		case "join", "lookup":
+			right, ok := q.operand(s.Args, depth)
+			if !ok {
+				return nil, false
+			}
+			for c := range right {
+				if !cols[c] {
+					cols[c] = true
+				} else if s.Operator == "join" {
+					// join keeps both sides; the right one is renamed.
+					cols[c+"1"] = true
+				}
+			}
+		default:
+			return nil, false
+		}
+	}
+	return cols, true
+}
+
+// operand returns the output columns of the right side of a join or lookup:
+// a table, a let name or a parenthesised subquery.
+func (q *query) operand(args []kql.Token, depth int) (map[string]bool, bool) {
+	i := 0
+	for i+2 < len(args) && args[i].Kind == kql.Ident && args[i+1].Text == "=" {
+		i += 3 // kind=inner and the like
+	}
+	if i >= len(args) {
+		return nil, false
+	}
+	if args[i].Text != "(" {
+		return q.output([]kql.Stage{{Operator: args[i].Text}}, depth+1)
+	}
+	end, level := i, 0
+	for ; end < len(args); end++ {
+		if args[end].Text == "(" {
+			level++
+		} else if args[end].Text == ")" {
+			if level--; level == 0 {
+				break
+			}
+		}
+	}
+	if end == len(args) {
+		return nil, false
+	}
+	stmts, err := kql.Parse(q.text[args[i].Pos+1 : args[end].Pos])
+	if err != nil || len(stmts) != 1 {
+		return nil, false
+	}
+	return q.output(stmts[0].Stages, depth+1)
+}
+
+// summarized returns the columns summarize produces from cols: its
+// aggregates, then its by columns.
+func summarized(args []kql.Token, cols map[string]bool) (map[string]bool, bool) {
+	aggs, keys := args, []kql.Token(nil)
+	depth := 0
+	for i, t := range args {
+		switch t.Text {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+		case "by":
+			if depth == 0 && t.Kind == kql.Ident {
+				aggs, keys = args[:i], args[i+1:]
+			}
+		}
+	}
+
+	next := map[string]bool{}
+	for _, item := range kql.SplitArgs(aggs) {
+		if name, ok := itemName(item); ok {
+			next[name] = true
+			continue
+		}
+		if len(item) < 3 || item[0].Kind != kql.Ident || item[1].Text != "(" || item[len(item)-1].Text != ")" {
+			return nil, false
+		}
+		fn, inner := item[0].Text, kql.SplitArgs(item[2:len(item)-1])
+		switch {
+		case fn == "arg_max" || fn == "arg_min":
+			for _, arg := range inner {
+				if len(arg) == 1 && arg[0].Text == "*" {
+					for c := range cols {
+						next[c] = true
+					}
+				} else if name, ok := itemName(arg); ok {
+					next[name] = true
+				}
+			}
+		case len(inner) == 1 && len(inner[0]) == 1 && inner[0][0].Kind == kql.Ident:
+			next[fn+"_"+inner[0][0].Text] = true
+		default:
+			next[fn+"_"] = true
+		}
+	}
+	for _, item := range kql.SplitArgs(keys) {
+		if name, ok := itemName(item); ok {
+			next[name] = true
+			continue
+		}
+		// bin(TimeGenerated, 1h) keeps the column's name.
+		if len(item) > 3 && (item[0].Text == "bin" || item[0].Text == "bin_at") && item[1].Text == "(" && item[2].Kind == kql.Ident {
+			next[item[2].Text] = true
+			continue
+		}
+		return nil, false
+	}
+	return next, true
+}
+
+// itemName is the column an operator argument produces: the name of a
+// name = expression or ["name"] = expression, or a bare column.
+func itemName(item []kql.Token) (string, bool) {
+	switch {
+	case len(item) == 1 && item[0].Kind == kql.Ident:
+		return item[0].Text, true
+	case len(item) > 2 && item[0].Kind == kql.Ident && item[1].Text == "=":
+		return item[0].Text, true
+	case len(item) > 4 && item[0].Text == "[" && item[1].Kind == kql.String && item[2].Text == "]" && item[3].Text == "=":
+		return unquote(item[1].Text), true
+	}
+	return "", false
+}
+
+// ─── Helpers ─────────────────────────────────────────────────────────────────
+
+func unquote(s string) string {
+	s = strings.TrimPrefix(s, "@")
+	if len(s) >= 2 {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func set(list []string) map[string]bool {
+	out := make(map[string]bool, len(list))
+	for _, s := range list {
+		out[s] = true
+	}
+	return out
+}
+
+func sorted(cols map[string]bool) []string {
+	out := make([]string, 0, len(cols))
+	for c := range cols {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func str(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func blocks(m map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := m[key].([]interface{})
+	out := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if b, ok := item.(map[string]interface{}); ok {
+			out = append(out, b)
+		}
+	}
+	return out
+}
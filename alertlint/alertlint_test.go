@@ -0,0 +1,121 @@
+package alertlint_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/SwastikaAryal/azure_terraform/alertlint"
+)
+
+// TestCheckRejected runs the static KQL checks over queries that each break
+// one check.
+func TestCheckRejected(t *testing.T) {
+	t.Parallel()
+
+	const failedJobs = "AddonAzureBackupJobs\n| where TimeGenerated > ago(15m)\n| where JobStatus == \"Failed\"\n"
+	for _, tc := range []struct {
+		name string
+		crit alertlint.Criterion
+		want []string
+	}{
+		{
+			name: "heartbeat placeholder",
+			crit: alertlint.Criterion{Query: "Heartbeat | take 1"},
+			want: []string{
+				"r: placeholder: the query takes rows without filtering or aggregating them",
+				"r: unknown table: Heartbeat is not a table the backup vaults write",
+				"r: time filter: no where clause on TimeGenerated",
+			},
+		},
+		{
+			name: "empty",
+			crit: alertlint.Criterion{Query: "  \n"},
+			want: []string{"r: placeholder: the query is empty"},
+		},
+		{
+			name: "marked",
+			crit: alertlint.Criterion{Query: failedJobs + "// TODO: narrow to VM jobs"},
+			want: []string{"r: placeholder: the query is marked TODO"},
+		},
+		{
+			name: "unbalanced",
+			crit: alertlint.Criterion{Query: failedJobs + "| summarize count() by bin(TimeGenerated, 1h"},
+			want: []string{`r: syntax: kql: offset 110: "(" is never closed`},
+		},
+		{
+			name: "empty stage",
+			crit: alertlint.Criterion{Query: failedJobs + "| | count"},
+			want: []string{"r: syntax: kql: offset 86: empty pipeline stage"},
+		},
+		{
+			name: "misspelt table",
+			crit: alertlint.Criterion{Query: "AddonAzureBackupJob | where TimeGenerated > ago(15m) | count"},
+			want: []string{"r: unknown table: AddonAzureBackupJob is not a table the backup vaults write"},
+		},
+		{
+			name: "no time filter",
+			crit: alertlint.Criterion{Query: "AddonAzureBackupJobs | where JobStatus == \"Failed\""},
+			want: []string{"r: time filter: no where clause on TimeGenerated"},
+		},
+		{
+			name: "dimension projected away",
+			crit: alertlint.Criterion{
+				Query:      failedJobs + "| project TimeGenerated, JobUniqueId",
+				Dimensions: []string{"BackupItemUniqueId"},
+			},
+			want: []string{"r: projection: dimension BackupItemUniqueId is not an output column of the query (JobUniqueId, TimeGenerated)"},
+		},
+		{
+			name: "measure not summarized",
+			crit: alertlint.Criterion{
+				Query:               failedJobs + "| summarize Failures = count() by BackupItemUniqueId",
+				MetricMeasureColumn: "count_",
+				Dimensions:          []string{"BackupItemUniqueId"},
+			},
+			want: []string{"r: projection: metric_measure_column count_ is not an output column of the query (BackupItemUniqueId, Failures)"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := []string{}
+			for _, p := range alertlint.Check(alertlint.Rule{Name: "r", Criteria: []alertlint.Criterion{tc.crit}}) {
+				got = append(got, p.String())
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// TestCheckAccepted runs the static KQL checks over queries that use joins,
+// default summarize names and arg_max, which must pass.
+func TestCheckAccepted(t *testing.T) {
+	t.Parallel()
+
+	for name, crit := range map[string]alertlint.Criterion{
+		"let and join": {
+			Query: `let failed = AddonAzureBackupJobs
+| where TimeGenerated > ago(1h)
+| where JobStatus == "Failed";
+failed
+| join kind=leftouter (
+    AddonAzureBackupAlerts
+    | project AlertJobUniqueId, AlertCode
+  ) on $left.JobUniqueId == $right.AlertJobUniqueId
+| project-rename Item = BackupItemUniqueId`,
+			Dimensions: []string{"Item", "AlertCode"},
+		},
+		"summarize default names": {
+			Query:               "AddonAzureBackupStorage | where TimeGenerated > ago(1d) | summarize sum(StorageConsumedInMBs), count() by bin(TimeGenerated, 1h), StorageType",
+			MetricMeasureColumn: "sum_StorageConsumedInMBs",
+			Dimensions:          []string{"StorageType", "TimeGenerated"},
+		},
+		"arg_max keeps the columns": {
+			Query:      "CoreAzureBackup | where TimeGenerated > ago(1d) | summarize arg_max(TimeGenerated, *) by BackupItemUniqueId | extend Age = now() - LatestRecoveryPointTime",
+			Dimensions: []string{"BackupItemFriendlyName", "Age"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Empty(t, alertlint.Check(alertlint.Rule{Name: "r", Criteria: []alertlint.Criterion{crit}}))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package alertlint
+
+// commonColumns are in every resource-specific Azure Backup table.
+var commonColumns = []string{
+	"TimeGenerated", "OperationName", "Category", "SchemaVersion", "State",
+	"SourceSystem", "TenantId", "Type", "ResourceId", "_ResourceId",
+	"BackupManagementType", "VaultUniqueId",
+}
+
+// Schema lists the Log Analytics tables the backup vaults' diagnostic
+// settings write in resource-specific mode, with their columns. A query may
+// read no other table.
+var Schema = map[string][]string{
+	"CoreAzureBackup": withCommon(
+		"AgentVersion", "AzureBackupAgentVersion", "AzureDataCenter",
+		"BackupItemAppVersion", "BackupItemFriendlyName", "BackupItemName",
+		"BackupItemProtectionState", "BackupItemType", "BackupItemUniqueId",
+		"BackupManagementServerName", "BackupManagementServerType", "BackupManagementServerUniqueId",
+		"DatasourceFriendlyName", "DatasourceResourceId", "DatasourceSetFriendlyName",
+		"DatasourceSetResourceId", "DatasourceSetType", "DatasourceType",
+		"LatestRecoveryPointLocation", "LatestRecoveryPointTime",
+		"OldestRecoveryPointLocation", "OldestRecoveryPointTime",
+		"PolicyUniqueId", "PolicyName", "ProtectedContainerFriendlyName",
+		"ProtectedContainerName", "ProtectedContainerUniqueId", "ProtectionState",
+		"ResourceGroupName", "StorageReplicationType", "SubscriptionId",
+		"VaultName", "VaultType", "VaultTag",
+	),
+	"AddonAzureBackupJobs": withCommon(
+		"AdHocOrScheduledJob", "BackupItemFriendlyName", "BackupItemId",
+		"BackupItemType", "BackupItemUniqueId", "BackupManagementServerUniqueId",
+		"DataTransferredInMB", "DatasourceFriendlyName", "DatasourceResourceId",
+		"DatasourceSetType", "DatasourceType", "JobDurationInSecs",
+		"JobFailureCode", "JobOperation", "JobOperationSubType",
+		"JobStartDateTime", "JobStatus", "JobUniqueId",
+		"ProtectedContainerName", "ProtectedContainerUniqueId",
+		"RecoveryJobDestination", "RecoveryJobRPDateTime", "RecoveryJobRPLocation",
+		"RecoveryLocationType", "StorageReplicationType", "VaultName", "VaultType",
+	),
+	"AddonAzureBackupAlerts": withCommon(
+		"AlertCode", "AlertJobUniqueId", "AlertOccurrenceDateTime",
+		"AlertRaisedOn", "AlertSeverity", "AlertStatus",
+		"AlertTimeToResolveInMinutes", "AlertType", "AlertUniqueId",
+		"BackupItemUniqueId", "BackupManagementServerUniqueId",
+		"CountOfAlertsConsolidated", "DatasourceFriendlyName", "DatasourceResourceId",
+		"DatasourceType", "ProtectedContainerUniqueId", "RecommendedAction",
+	),
+	"AddonAzureBackupPolicy": withCommon(
+		"BackupDaysOfTheWeek", "BackupFrequency", "BackupTimes",
+		"DailyRetentionDuration", "DailyRetentionTimes", "DiffBackupDaysofTheWeek",
+		"DiffBackupFormat", "DiffBackupRetentionDuration", "DiffBackupTime",
+		"LogBackupFrequency", "LogBackupRetentionDuration",
+		"MonthlyRetentionDaysOfTheMonth", "MonthlyRetentionDaysOfTheWeek",
+		"MonthlyRetentionDuration", "MonthlyRetentionFormat", "MonthlyRetentionTimes",
+		"MonthlyRetentionWeeksOfTheMonth", "PolicyName", "PolicyTimeZone",
+		"PolicyUniqueId", "RetentionDuration", "RetentionType",
+		"WeeklyRetentionDaysOfTheWeek", "WeeklyRetentionDuration", "WeeklyRetentionTimes",
+		"YearlyRetentionDaysOfTheMonth", "YearlyRetentionDaysOfTheWeek",
+		"YearlyRetentionDuration", "YearlyRetentionFormat", "YearlyRetentionMonthsOfTheYear",
+		"YearlyRetentionTimes", "YearlyRetentionWeeksOfTheMonth",
+	),
+	"AddonAzureBackupStorage": withCommon(
+		"BackupItemUniqueId", "BackupManagementServerUniqueId",
+		"PreferredWorkloadOnVolume", "ProtectedContainerUniqueId",
+		"StorageAllocatedInMBs", "StorageConsumedInMBs", "StorageName",
+		"StorageType", "StorageUniqueId", "VolumeFriendlyName",
+	),
+	"AddonAzureBackupProtectedInstance": withCommon(
+		"BackupItemUniqueId", "BackupManagementServerUniqueId",
+		"ProtectedContainerUniqueId", "ProtectedInstanceCount",
+	),
+}
+
+func withCommon(columns ...string) []string {
+	return append(append([]string{}, commonColumns...), columns...)
+}
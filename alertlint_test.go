@@ -0,0 +1,43 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/alertlint"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Alert query analysis (Sprint 3) ───────────────────────────────────
+
+// TestAlertQueries runs the static KQL checks over the scheduled query rules
+// of the seed plan, which must pass. The checks themselves are covered in the
+// alertlint package.
+func TestAlertQueries(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "planned alert queries read backup tables, filter on time and project their dimensions", "Sprint 3")
+
+	raw, err := os.ReadFile("testdata/synthetic_plan.json")
+	require.NoError(t, err)
+	plan, err := terraform.ParsePlanJSON(string(raw))
+	require.NoError(t, err)
+
+	t.Run("Plan", func(t *testing.T) {
+		rules := alertlint.FromPlan(plan)
+		names := []string{}
+		for _, r := range rules {
+			names = append(names, r.Name)
+		}
+		assert.Equal(t, []string{"alert-backup-job-failure", "alert-backup-not-run-24h", "alert-backup-protection-stopped", "alert-restore-job-failure"}, names)
+		if assert.Len(t, rules, 4) && assert.Len(t, rules[1].Criteria, 1) {
+			assert.Equal(t, []string{"BackupItemUniqueId"}, rules[1].Criteria[0].Dimensions)
+		}
+		for _, p := range alertlint.CheckPlan(plan) {
+			t.Errorf("alert query rejected: %s", p)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/azretry"
+	"github.com/SwastikaAryal/azure_terraform/fakearm"
+)
+
+// ─── ARM backend switch ──────────────────────────────────────────────────────
+
+// armBackend is everything an SDK client constructor needs. Assertions build
+// their clients from it so the same code runs against either backend:
+//
+//	TEST_ARM_BACKEND=live  (default) talk to the subscription in ARM_SUBSCRIPTION_ID
+//	TEST_ARM_BACKEND=fake  serve from an in-memory fake seeded from TEST_ARM_SEED,
+//	                       a `terraform show -json` dump of a plan or a state
+//	                       (default testdata/synthetic_plan.json, which is
+//	                       written by hand; see testdata/README.md)
+type armBackend struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+
+	// Retry classifies failures of SDK calls made with Options and of the
+	// Terraform runs that go with them.
+	Retry *azretry.Classifier
+
+	// Fake is the in-memory backend; nil when running live.
+	Fake *fakearm.Server
+}
+
+// useFakeARM reports whether TEST_ARM_BACKEND selects the fake backend.
+func useFakeARM() bool {
+	return strings.EqualFold(os.Getenv("TEST_ARM_BACKEND"), "fake")
+}
+
+// newARMBackend builds the backend selected by TEST_ARM_BACKEND.
+func newARMBackend(t *testing.T) *armBackend {
+	t.Helper()
+
+	retry := newRetryClassifier(t)
+
+	if !useFakeARM() {
+		return &armBackend{
+			SubscriptionID: subscriptionID(t),
+			Credential:     newAzureCredential(t),
+			Options:        retry.ClientOptions(nil),
+			Retry:          retry,
+		}
+	}
+
+	seed := envOrDefault("TEST_ARM_SEED", syntheticPlan)
+
+	fake := fakearm.New(os.Getenv("ARM_SUBSCRIPTION_ID"))
+	declareLocals(fake)
+	require.NoError(t, fake.SeedFile(seed), "failed to seed fake ARM backend from %s", seed)
+
+	return &armBackend{
+		SubscriptionID: fake.SubscriptionID(),
+		Credential:     fake.Credential(),
+		Options:        retry.ClientOptions(fake.ClientOptions()),
+		Retry:          retry,
+		Fake:           fake,
+	}
+}
+
+// declareLocals tells the fake how the module's locals resolve: a plan
+// records references to them but not their values.
+func declareLocals(fake *fakearm.Server) {
+	// monitoring.tf: the profile's workspace, else the one the module creates.
+	fake.Local("law_id", "var.log_analytics_workspace_id", "azurerm_log_analytics_workspace.backup[0].id")
+}
+
+// syntheticPlan is the hand-written plan the fake is seeded from by default.
+const syntheticPlan = "testdata/synthetic_plan.json"
+
+// fakeDeployment is a fake backend seeded from the synthetic plan, with the
+// Recovery Services vault the AgainstFake tests start from.
+type fakeDeployment struct {
+	*fakearm.Server
+	t *testing.T
+
+	ResourceGroup string
+	VaultID       string
+	Vault         string
+}
+
+// seededFake returns a fake backend seeded from the synthetic plan.
+func seededFake(t *testing.T) *fakeDeployment {
+	t.Helper()
+
+	fake := fakearm.New("")
+	declareLocals(fake)
+	require.NoError(t, fake.SeedFile(syntheticPlan))
+
+	d := &fakeDeployment{Server: fake, t: t}
+	d.ResourceGroup = d.Attr("azurerm_recovery_services_vault.main", "resource_group_name")
+	d.VaultID = d.Attr("azurerm_recovery_services_vault.main", "id")
+	d.Vault = resourceName(d.VaultID)
+	return d
+}
+
+// Attr returns an attribute of a planned resource, failing the test when the
+// plan does not have it.
+func (d *fakeDeployment) Attr(address, path string) string {
+	d.t.Helper()
+	v, ok := d.Attribute(address, path)
+	require.True(d.t, ok, "%s.%s is not in the plan", address, path)
+	return fmt.Sprintf("%v", v)
+}
+
+// requireLiveARM skips assertions that drive Terraform against the deployed
+// state, which the fake backend cannot provide.
+func requireLiveARM(t *testing.T) {
+	t.Helper()
+	if useFakeARM() {
+		t.Skip("needs a live deployment; skipped with TEST_ARM_BACKEND=fake")
+	}
+}
+
+// fakeDeploymentOutputs stands in for apply when the fake backend is used:
+// it points opts at the resource groups recorded in the seed and returns the
+// outputs the seed resolves.
+func fakeDeploymentOutputs(t *testing.T, opts *terraform.Options) map[string]interface{} {
+	t.Helper()
+	backend := newARMBackend(t)
+
+	if rg, ok := backend.Fake.Attribute("azurerm_recovery_services_vault.main", "resource_group_name"); ok {
+		opts.Vars["resource_group_name"] = rg
+	}
+	if rg, ok := backend.Fake.Variable("snapshot_resource_group_name"); ok {
+		opts.Vars["snapshot_resource_group_name"] = rg
+	}
+	return backend.Fake.Outputs()
+}
@@ -0,0 +1,339 @@
+// Package azretry decides whether a failed Azure operation is worth retrying.
+//
+// One Classifier serves both ways the suite talks to Azure: Terraform runs,
+// whose failures only surface as diagnostics in the command output, and
+// direct SDK calls, whose failures are *azcore.ResponseError values. Both are
+// reduced to the same signals (ARM error codes, HTTP status, Retry-After) and
+// matched against one ordered list of Rules. The classifier also picks the
+// backoff and keeps a history of every retry and why it happened.
+package azretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// Rule marks a class of failures as retryable. A rule matches when any of
+// its codes, statuses or its pattern does.
+type Rule struct {
+	// Codes are ARM error codes, compared case-insensitively.
+	Codes []string
+	// Statuses are HTTP status codes.
+	Statuses []int
+	// Pattern is matched against the error text and Terraform output, for
+	// failures that carry no code (plugin downloads, provider bugs).
+	Pattern *regexp.Regexp
+	// Reason explains the retry in the history and logs.
+	Reason string
+
+	// TerraformOnly limits the rule to codes and statuses Terraform reported
+	// in its diagnostics, for failures that are transient while the
+	// provider is applying but final anywhere else.
+	TerraformOnly bool
+	// IdempotentOnly limits the rule, for SDK responses, to requests that
+	// are safe to send again: GET, HEAD and DELETE, or a request carrying a
+	// Repeatability-Request-ID or Idempotency-Key header. A Terraform run is
+	// always safe to repeat, since it plans against what the failed run left.
+	IdempotentOnly bool
+}
+
+func (r Rule) matches(s signals) bool {
+	if r.TerraformOnly && !s.diagnostics {
+		return false
+	}
+	if r.IdempotentOnly && s.request != nil && !idempotent(s.request) {
+		return false
+	}
+	for _, c := range r.Codes {
+		for _, got := range s.codes {
+			if strings.EqualFold(c, got) {
+				return true
+			}
+		}
+	}
+	for _, st := range r.Statuses {
+		for _, got := range s.statuses {
+			if st == got {
+				return true
+			}
+		}
+	}
+	return r.Pattern != nil && r.Pattern.MatchString(s.text)
+}
+
+// DefaultRules are the transient failures seen deploying the backup module.
+var DefaultRules = []Rule{
+	{Statuses: []int{http.StatusTooManyRequests}, Codes: []string{"TooManyRequests", "SubscriptionRequestsThrottled"}, Reason: "throttled by ARM"},
+	// A role assignment the provider has just created takes minutes to
+	// propagate; an SDK call refused for lack of a role is not going to
+	// succeed by waiting.
+	{Codes: []string{"AuthorizationFailed"}, TerraformOnly: true, Reason: "waiting for RBAC propagation"},
+	{Codes: []string{"PrincipalNotFound"}, Reason: "waiting for service-principal propagation"},
+	{Codes: []string{"ResourceGroupNotFound"}, Reason: "resource group not yet visible"},
+	{Codes: []string{"VaultAlreadySoftDeletedOrExists"}, Reason: "vault is in soft-delete state"},
+	{Codes: []string{"AnotherOperationInProgress"}, Reason: "conflicting operation in progress"},
+	{
+		Statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		Codes:    []string{"InternalServerError", "ServiceUnavailable", "GatewayTimeout"},
+		// A write may have gone through before the server failed.
+		IdempotentOnly: true,
+		Reason:         "transient ARM server error",
+	},
+	{
+		Pattern: regexp.MustCompile(`unable to verify (signature|checksum)|no provider exists with the given name|registry service is unreachable|Error installing provider|Failed to query available provider packages|timeout while waiting for plugin to start|timed out waiting for server handshake|could not query provider registry for`),
+		Reason:  "transient network error retrieving a provider",
+	},
+	{Pattern: regexp.MustCompile(`read: connection reset by peer|transport is closing|TLS handshake timeout`), Reason: "transient network error"},
+	{Pattern: regexp.MustCompile(`Provider produced inconsistent result after apply`), Reason: "provider eventual consistency error"},
+}
+
+// Decision is the classifier's verdict on one failure.
+type Decision struct {
+	Retry  bool
+	Delay  time.Duration
+	Reason string
+	// Code is the ARM error code or HTTP status that decided it, if any.
+	Code string
+}
+
+// Attempt records a retry that was made.
+type Attempt struct {
+	Time      time.Time
+	Operation string
+	// Attempt is the 1-based number of the failed try.
+	Attempt int
+	Reason  string
+	Code    string
+	Delay   time.Duration
+	Err     string
+}
+
+func (a Attempt) String() string {
+	code := ""
+	if a.Code != "" {
+		code = " [" + a.Code + "]"
+	}
+	return fmt.Sprintf("%s: attempt %d failed, retrying in %s: %s%s",
+		a.Operation, a.Attempt, a.Delay.Round(time.Millisecond), a.Reason, code)
+}
+
+// Classifier is safe for concurrent use.
+type Classifier struct {
+	Rules []Rule
+
+	// MaxRetries is how many times an operation is retried; 0 disables
+	// retries.
+	MaxRetries int
+	// BaseDelay is the first backoff, doubled on each retry up to MaxDelay.
+	// A Retry-After from the server takes precedence, capped at MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// OnRetry, if set, is called for every retry as it happens.
+	OnRetry func(Attempt)
+
+	mu      sync.Mutex
+	history []Attempt
+}
+
+// New returns a classifier with DefaultRules and the backoff the suite
+// used before: up to 5 retries, starting at 10s and capped at 2m.
+func New() *Classifier {
+	return &Classifier{
+		Rules:      DefaultRules,
+		MaxRetries: 5,
+		BaseDelay:  10 * time.Second,
+		MaxDelay:   2 * time.Minute,
+	}
+}
+
+// Classify decides whether to retry after the given failed attempt (1-based).
+// err may be an *azcore.ResponseError from an SDK call or the error of a
+// Terraform run, with output holding the run's combined output.
+func (c *Classifier) Classify(err error, output string, attempt int) Decision {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return Decision{}
+	}
+	s := collect(err, output)
+
+	var d Decision
+	for _, rule := range c.Rules {
+		if rule.matches(s) {
+			d = Decision{Retry: true, Reason: rule.Reason, Code: s.code()}
+			break
+		}
+	}
+	if !d.Retry {
+		d.Reason = "not retryable"
+		d.Code = s.code()
+		return d
+	}
+	if attempt > c.MaxRetries {
+		d.Retry = false
+		d.Reason = fmt.Sprintf("%s; giving up after %d retries", d.Reason, c.MaxRetries)
+		return d
+	}
+	d.Delay = c.backoff(attempt, s.retryAfter)
+	return d
+}
+
+func (c *Classifier) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := retryAfter
+	if delay <= 0 {
+		delay = time.Duration(float64(c.BaseDelay) * math.Pow(2, float64(attempt-1)))
+	}
+	if c.MaxDelay > 0 && delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	return delay
+}
+
+// record appends a retry to the history.
+func (c *Classifier) record(op string, attempt int, d Decision, err error) {
+	a := Attempt{
+		Time:      time.Now(),
+		Operation: op,
+		Attempt:   attempt,
+		Reason:    d.Reason,
+		Code:      d.Code,
+		Delay:     d.Delay,
+	}
+	if err != nil {
+		a.Err = err.Error()
+	}
+	c.mu.Lock()
+	c.history = append(c.history, a)
+	onRetry := c.OnRetry
+	c.mu.Unlock()
+	if onRetry != nil {
+		onRetry(a)
+	}
+}
+
+// History returns every retry made so far, oldest first.
+func (c *Classifier) History() []Attempt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Attempt(nil), c.history...)
+}
+
+// Do runs fn until it succeeds, fails with an error the classifier does not
+// retry, or ctx is done. op names the operation in the history.
+func (c *Classifier) Do(ctx context.Context, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		d := c.Classify(err, "", attempt)
+		if !d.Retry {
+			return err
+		}
+		c.record(op, attempt, d, err)
+		if err := sleep(ctx, d.Delay); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ─── Signals ──────────────────────────────────────────────────────────────────
+
+// signals is what a failure tells us, whichever way it was reported.
+type signals struct {
+	codes      []string
+	statuses   []int
+	retryAfter time.Duration
+	text       string
+	// diagnostics is set when the codes and statuses come from Terraform
+	// diagnostics rather than an SDK response.
+	diagnostics bool
+	// request is the request an SDK response answered, if known.
+	request *http.Request
+}
+
+func (s signals) code() string {
+	if len(s.codes) > 0 {
+		return s.codes[0]
+	}
+	if len(s.statuses) > 0 {
+		return strconv.Itoa(s.statuses[0])
+	}
+	return ""
+}
+
+func collect(err error, output string) signals {
+	s := signals{text: err.Error() + "\n" + output}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		if respErr.ErrorCode != "" {
+			s.codes = append(s.codes, respErr.ErrorCode)
+		}
+		s.statuses = append(s.statuses, respErr.StatusCode)
+		if respErr.RawResponse != nil {
+			s.retryAfter = RetryAfter(respErr.RawResponse.Header)
+			s.request = respErr.RawResponse.Request
+		}
+		return s
+	}
+
+	// terratest puts stderr in the error text, so diagnostics may come from
+	// either.
+	for _, diag := range Diagnostics(s.text) {
+		s.codes = append(s.codes, diag.Codes...)
+		s.statuses = append(s.statuses, diag.Statuses...)
+		s.diagnostics = true
+	}
+	return s
+}
+
+// idempotent reports whether req can be sent again without repeating a side
+// effect.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Repeatability-Request-ID") != "" || req.Header.Get("Idempotency-Key") != ""
+}
+
+// RetryAfter reads the delay a throttled ARM response asks for, from
+// retry-after-ms, x-ms-retry-after-ms or Retry-After (seconds or HTTP date).
+func RetryAfter(h http.Header) time.Duration {
+	for _, name := range []string{"retry-after-ms", "x-ms-retry-after-ms"} {
+		if ms, err := strconv.Atoi(h.Get(name)); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
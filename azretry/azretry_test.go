@@ -0,0 +1,157 @@
+package azretry_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/azretry"
+	"github.com/SwastikaAryal/azure_terraform/fakearm"
+)
+
+// TestClassifierAgainstFake checks that one classifier drives both
+// kinds of retry: an SDK call throttled by the fake backend is re-sent after
+// the Retry-After it asked for, and a Terraform run is retried only for
+// transient diagnostics.
+func TestClassifierAgainstFake(t *testing.T) {
+	t.Parallel()
+
+	newClassifier := func() *azretry.Classifier {
+		c := azretry.New()
+		c.BaseDelay = time.Millisecond
+		c.MaxDelay = time.Second
+		return c
+	}
+
+	t.Run("SDK", func(t *testing.T) {
+		c := newClassifier()
+		fake := fakearm.New("")
+		require.NoError(t, fake.SeedFile(filepath.Join("..", "testdata", "synthetic_plan.json")))
+		attr, ok := fake.Attribute("azurerm_recovery_services_vault.main", "resource_group_name")
+		require.True(t, ok)
+		rg := fmt.Sprintf("%v", attr)
+
+		var throttled atomic.Int32
+		fake.Handle(http.MethodGet, "/vaults/rsv-minitrue-abc123", func(_ *fakearm.Server, req *http.Request) (*http.Response, error) {
+			if throttled.Add(1) > 2 {
+				return nil, nil
+			}
+			resp := fakearm.ErrorResponse(req, http.StatusTooManyRequests, "SubscriptionRequestsThrottled", "Number of requests exceeded the limit.")
+			resp.Header.Set("retry-after-ms", "20")
+			return resp, nil
+		})
+
+		client, err := armrecoveryservices.NewVaultsClient(fake.SubscriptionID(), fake.Credential(), c.ClientOptions(fake.ClientOptions()))
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = client.Get(t.Context(), rg, "rsv-minitrue-abc123", nil)
+		require.NoError(t, err, "throttled GET should succeed once the backend lets it through")
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "Retry-After must be honoured")
+
+		history := c.History()
+		require.Len(t, history, 2)
+		for _, a := range history {
+			assert.Equal(t, "SubscriptionRequestsThrottled", a.Code)
+			assert.Equal(t, "throttled by ARM", a.Reason)
+			assert.Equal(t, 20*time.Millisecond, a.Delay)
+		}
+
+		// A missing vault is an answer, not something to wait for.
+		_, err = client.Get(t.Context(), rg, "rsv-does-not-exist", nil)
+		var respErr *azcore.ResponseError
+		require.True(t, errors.As(err, &respErr))
+		assert.Equal(t, http.StatusNotFound, respErr.StatusCode)
+		assert.Len(t, c.History(), 2, "a 404 on read must not be retried")
+	})
+
+	t.Run("Terraform", func(t *testing.T) {
+		c := newClassifier()
+		const propagating = `
+╷
+│ Error: creating Role Assignment: unexpected status 403 (403 Forbidden) with error: AuthorizationFailed: The client does not have authorization to perform action 'Microsoft.Authorization/roleAssignments/write'
+│
+│   with azurerm_role_assignment.automation_backup_contributor,
+│   on automation.tf line 42:
+╵`
+		const invalid = `
+╷
+│ Error: Invalid value for variable
+│
+│ retention_daily_count must be between 7 and 9999.
+╵`
+		runs := 0
+		out, err := c.Terraform(t.Context(), "terraform apply", func() (string, error) {
+			runs++
+			if runs == 1 {
+				return propagating, errors.New("exit status 1")
+			}
+			return "Apply complete!", nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Apply complete!", out)
+		assert.Equal(t, 2, runs)
+		history := c.History()
+		require.Len(t, history, 1)
+		assert.Equal(t, "AuthorizationFailed", history[0].Code)
+		assert.Equal(t, "waiting for RBAC propagation", history[0].Reason)
+
+		runs = 0
+		_, err = c.Terraform(t.Context(), "terraform apply", func() (string, error) {
+			runs++
+			return invalid, errors.New("exit status 1")
+		})
+		require.Error(t, err)
+		assert.Equal(t, 1, runs, "a configuration error must fail without retrying")
+	})
+}
+
+// TestClassifyResponse checks which SDK failures are retried: a refused
+// authorization never is, since only a role assignment Terraform is still
+// propagating gets better by waiting, and a server error only when the
+// request is safe to send again.
+func TestClassifyResponse(t *testing.T) {
+	t.Parallel()
+
+	c := azretry.New()
+	for _, tc := range []struct {
+		name   string
+		method string
+		header string
+		status int
+		code   string
+		want   bool
+	}{
+		{name: "authorization on read", method: http.MethodGet, status: http.StatusForbidden, code: "AuthorizationFailed", want: false},
+		{name: "authorization on write", method: http.MethodPut, status: http.StatusForbidden, code: "AuthorizationFailed", want: false},
+		{name: "server error on read", method: http.MethodGet, status: http.StatusInternalServerError, code: "InternalServerError", want: true},
+		{name: "server error on head", method: http.MethodHead, status: http.StatusBadGateway, want: true},
+		{name: "server error on delete", method: http.MethodDelete, status: http.StatusServiceUnavailable, code: "ServiceUnavailable", want: true},
+		{name: "server error on put", method: http.MethodPut, status: http.StatusInternalServerError, code: "InternalServerError", want: false},
+		{name: "server error on post", method: http.MethodPost, status: http.StatusGatewayTimeout, want: false},
+		{name: "server error on repeatable put", method: http.MethodPut, header: "Repeatability-Request-ID", status: http.StatusInternalServerError, want: true},
+		{name: "server error on post with idempotency key", method: http.MethodPost, header: "Idempotency-Key", status: http.StatusServiceUnavailable, want: true},
+		{name: "throttled write", method: http.MethodPut, status: http.StatusTooManyRequests, code: "TooManyRequests", want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, "https://management.azure.com/subscriptions/s/resourceGroups/rg", nil)
+			require.NoError(t, err)
+			if tc.header != "" {
+				req.Header.Set(tc.header, "5b1c0a4e-8f2d-4f43-9a57-2d8f7f0d3e11")
+			}
+			resp := fakearm.ErrorResponse(req, tc.status, tc.code, "failed")
+			d := c.Classify(runtime.NewResponseError(resp), "", 1)
+			assert.Equal(t, tc.want, d.Retry, d.Reason)
+		})
+	}
+}
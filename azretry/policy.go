@@ -0,0 +1,75 @@
+package azretry
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// ClientOptions returns a copy of base, which may be nil, whose pipeline
+// retries through the classifier instead of azcore's built-in retry policy.
+// The transport and any other settings in base are kept.
+func (c *Classifier) ClientOptions(base *arm.ClientOptions) *arm.ClientOptions {
+	var opts arm.ClientOptions
+	if base != nil {
+		opts = *base
+	}
+	// A negative MaxRetries turns the built-in policy into a single try.
+	opts.Retry.MaxRetries = -1
+	opts.PerCallPolicies = append(append([]policy.Policy(nil), opts.PerCallPolicies...), &retryPolicy{c: c})
+	return &opts
+}
+
+// retryPolicy re-sends a request while the classifier says so. Failures
+// come from the pipeline either as a transport error or as a response the
+// SDK will later turn into an *azcore.ResponseError; the policy builds that
+// error itself so both paths are classified the same way.
+type retryPolicy struct {
+	c *Classifier
+}
+
+func (p *retryPolicy) Do(req *policy.Request) (*http.Response, error) {
+	ctx := req.Raw().Context()
+	op := fmt.Sprintf("%s %s", req.Raw().Method, req.Raw().URL.Path)
+
+	for attempt := 1; ; attempt++ {
+		if err := req.RewindBody(); err != nil {
+			return nil, err
+		}
+		resp, err := req.Clone(ctx).Next()
+
+		classified := err
+		if err == nil && resp.StatusCode >= http.StatusBadRequest {
+			// A 404 only means "not visible yet" to a write; to a read or a
+			// delete it is the answer.
+			if resp.StatusCode == http.StatusNotFound && !isWrite(req.Raw().Method) {
+				return resp, nil
+			}
+			classified = runtime.NewResponseError(resp)
+		}
+		d := p.c.Classify(classified, "", attempt)
+		if !d.Retry {
+			return resp, err
+		}
+		p.c.record(op, attempt, d, classified)
+
+		if resp != nil && resp.Body != nil {
+			// Release the connection before trying again.
+			_ = resp.Body.Close()
+		}
+		if err := sleep(ctx, d.Delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodPatch, http.MethodPost:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package azretry
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is one "Error:" block from Terraform output with the codes the
+// azurerm provider embedded in its text.
+type Diagnostic struct {
+	Summary  string
+	Detail   string
+	Codes    []string
+	Statuses []int
+}
+
+var (
+	// The azurerm provider reports ARM failures in a handful of shapes, e.g.
+	//   unexpected status 429 (429 Too Many Requests) with error: TooManyRequests: ...
+	//   StatusCode=403 -- Original Error: Code="AuthorizationFailed" Message=...
+	diagCodePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`Code="([A-Za-z][A-Za-z0-9]+)"`),
+		regexp.MustCompile(`"code":\s*"([A-Za-z][A-Za-z0-9]+)"`),
+		regexp.MustCompile(`with error: ([A-Za-z][A-Za-z0-9]+):`),
+		regexp.MustCompile(`ERROR CODE: ([A-Za-z][A-Za-z0-9]+)`),
+	}
+	diagStatusPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`StatusCode=(\d{3})`),
+		regexp.MustCompile(`unexpected status (\d{3})`),
+		regexp.MustCompile(`RESPONSE (\d{3})`),
+	}
+	diagBorder = regexp.MustCompile(`^[│╷╵]\s?`)
+)
+
+// Diagnostics extracts the error diagnostics from Terraform output. The
+// box-drawing borders Terraform draws around them are stripped.
+func Diagnostics(output string) []Diagnostic {
+	var (
+		diags []Diagnostic
+		cur   *Diagnostic
+		body  []string
+	)
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		cur.Detail = strings.TrimSpace(strings.Join(body, "\n"))
+		text := cur.Summary + "\n" + cur.Detail
+		for _, re := range diagCodePatterns {
+			for _, m := range re.FindAllStringSubmatch(text, -1) {
+				cur.Codes = appendUnique(cur.Codes, m[1])
+			}
+		}
+		for _, re := range diagStatusPatterns {
+			for _, m := range re.FindAllStringSubmatch(text, -1) {
+				if n, err := strconv.Atoi(m[1]); err == nil && !containsInt(cur.Statuses, n) {
+					cur.Statuses = append(cur.Statuses, n)
+				}
+			}
+		}
+		diags = append(diags, *cur)
+		cur, body = nil, nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = diagBorder.ReplaceAllString(strings.TrimRight(line, "\r"), "")
+		if rest, ok := strings.CutPrefix(line, "Error: "); ok {
+			flush()
+			cur = &Diagnostic{Summary: strings.TrimSpace(rest)}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if strings.HasPrefix(line, "Warning: ") {
+			flush()
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return diags
+}
+
+// Terraform runs a Terraform command through the classifier. fn is one of
+// terratest's *E functions, which return the command output alongside the
+// error; the output is what carries the diagnostics.
+func (c *Classifier) Terraform(ctx context.Context, op string, fn func() (string, error)) (string, error) {
+	for attempt := 1; ; attempt++ {
+		out, err := fn()
+		d := c.Classify(err, out, attempt)
+		if !d.Retry {
+			return out, err
+		}
+		c.record(op, attempt, d, err)
+		if err := sleep(ctx, d.Delay); err != nil {
+			return out, err
+		}
+	}
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, have := range list {
+		if have == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func containsInt(list []int, n int) bool {
+	for _, have := range list {
+		if have == n {
+			return true
+		}
+	}
+	return false
+}
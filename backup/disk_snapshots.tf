@@ -12,6 +12,10 @@ resource "azurerm_data_protection_backup_vault" "disk_vault" {
   identity {
     type = "SystemAssigned"
   }
+
+  # azurerm ~> 3.90 cannot set a customer-managed key on a Data Protection
+  # vault. Where one is required it is set outside Terraform, and the
+  # profile's encryption.vaults lists data_protection so the tests check it.
 }
 
 # -----------------------------------------------------------------
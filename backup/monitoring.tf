@@ -28,42 +28,67 @@ locals {
   )
 }
 
-# # -----------------------------------------------------------------
-# # Send RSV diagnostics to Log Analytics
-# # -----------------------------------------------------------------
-# resource "azurerm_monitor_diagnostic_setting" "vault_diagnostics" {
-#   name                       = "diag-rsv-to-law"
-#   target_resource_id         = azurerm_recovery_services_vault.main.id
-#   log_analytics_workspace_id = local.law_id
-
-#   # Backup jobs, alerts, and policy compliance
-#   enabled_log {
-#     category = "AzureBackupReport"
-#   }
-#   enabled_log {
-#     category = "CoreAzureBackup"
-#   }
-#   enabled_log {
-#     category = "AddonAzureBackupJobs"
-#   }
-#   enabled_log {
-#     category = "AddonAzureBackupAlerts"
-#   }
-#   enabled_log {
-#     category = "AddonAzureBackupPolicy"
-#   }
-#   enabled_log {
-#     category = "AddonAzureBackupStorage"
-#   }
-#   enabled_log {
-#     category = "AddonAzureBackupProtectedInstance"
-#   }
+# -----------------------------------------------------------------
+# Send vault diagnostics to Log Analytics
+# -----------------------------------------------------------------
+# The alert queries below read the resource-specific tables
+# (AddonAzureBackupJobs, ...), which only exist in "Dedicated" mode; the
+# default mode puts every category into the AzureDiagnostics table instead.
+# AzureBackupReport is left out: it is the legacy category and always lands
+# in AzureDiagnostics.
+resource "azurerm_monitor_diagnostic_setting" "vault_diagnostics" {
+  name                           = "diag-rsv-to-law"
+  target_resource_id             = azurerm_recovery_services_vault.main.id
+  log_analytics_workspace_id     = local.law_id
+  log_analytics_destination_type = "Dedicated"
+
+  # Backup jobs, alerts, and policy compliance
+  enabled_log {
+    category = "CoreAzureBackup"
+  }
+  enabled_log {
+    category = "AddonAzureBackupJobs"
+  }
+  enabled_log {
+    category = "AddonAzureBackupAlerts"
+  }
+  enabled_log {
+    category = "AddonAzureBackupPolicy"
+  }
+  enabled_log {
+    category = "AddonAzureBackupStorage"
+  }
+  enabled_log {
+    category = "AddonAzureBackupProtectedInstance"
+  }
 
-#   metric {
-#     category = "AllMetrics"
-#     enabled  = true
-#   }
-# }
+  metric {
+    category = "AllMetrics"
+    enabled  = true
+  }
+}
+
+# Disk backup jobs run in the Data Protection vault, which reports into the
+# same tables.
+resource "azurerm_monitor_diagnostic_setting" "disk_vault_diagnostics" {
+  name                           = "diag-dpbv-to-law"
+  target_resource_id             = azurerm_data_protection_backup_vault.disk_vault.id
+  log_analytics_workspace_id     = local.law_id
+  log_analytics_destination_type = "Dedicated"
+
+  enabled_log {
+    category = "CoreAzureBackup"
+  }
+  enabled_log {
+    category = "AddonAzureBackupJobs"
+  }
+  enabled_log {
+    category = "AddonAzureBackupPolicy"
+  }
+  enabled_log {
+    category = "AddonAzureBackupProtectedInstance"
+  }
+}
 
 # -----------------------------------------------------------------
 # Task 2: Action Group – email notification for backup failures
@@ -82,6 +107,15 @@ resource "azurerm_monitor_action_group" "backup_alerts" {
       use_common_alert_schema = true
     }
   }
+
+  dynamic "webhook_receiver" {
+    for_each = var.alert_webhook_receivers
+    content {
+      name                    = webhook_receiver.value.name
+      service_uri             = webhook_receiver.value.service_uri
+      use_common_alert_schema = true
+    }
+  }
 }
 
 # -----------------------------------------------------------------
@@ -104,15 +138,23 @@ resource "azurerm_monitor_scheduled_query_rules_alert_v2" "backup_job_failure" {
   criteria {
     query = <<-KQL
       AddonAzureBackupJobs
+      | where TimeGenerated > ago(15m)
       | where JobOperation == "Backup"
       | where JobStatus == "Failed"
-      | project TimeGenerated, JobUniqueId, BackupItemUniqueId, JobStatus, JobFailureCode
+      | project TimeGenerated, JobUniqueId, BackupItemUniqueId, JobStatus, JobFailureCode, _ResourceId
     KQL
 
     time_aggregation_method = "Count"
     threshold               = 0
     operator                = "GreaterThan"
 
+    # One alert per vault; _ResourceId names the vault in the notification.
+    dimension {
+      name     = "_ResourceId"
+      operator = "Include"
+      values   = ["*"]
+    }
+
     failing_periods {
       minimum_failing_periods_to_trigger_alert = 1
       number_of_evaluation_periods             = 1
@@ -148,11 +190,27 @@ resource "azurerm_monitor_scheduled_query_rules_alert_v2" "backup_stale" {
   severity             = 2     # Warning
 
   criteria {
-    query = "Heartbeat | take 1"
+    # Vaults report each backup item to CoreAzureBackup daily, with the time
+    # of its latest recovery point.
+    query = <<-KQL
+      CoreAzureBackup
+      | where TimeGenerated > ago(1d)
+      | where OperationName == "BackupItem"
+      | summarize LatestRecoveryPointTime = max(LatestRecoveryPointTime) by BackupItemUniqueId
+      | where LatestRecoveryPointTime < ago(24h)
+    KQL
+
     time_aggregation_method = "Count"
     threshold               = 0
     operator                = "GreaterThan"
 
+    # One alert per stale item
+    dimension {
+      name     = "BackupItemUniqueId"
+      operator = "Include"
+      values   = ["*"]
+    }
+
     failing_periods {
       minimum_failing_periods_to_trigger_alert = 1
       number_of_evaluation_periods             = 1
@@ -166,6 +224,115 @@ resource "azurerm_monitor_scheduled_query_rules_alert_v2" "backup_stale" {
   auto_mitigation_enabled = false
 }
 
+# -----------------------------------------------------------------
+# Task 1: Alert – Restore job failures (restore tests included)
+# -----------------------------------------------------------------
+resource "azurerm_monitor_scheduled_query_rules_alert_v2" "restore_failure" {
+  name                = "alert-restore-job-failure"
+  location            = local.location
+  resource_group_name = local.resource_group_name
+  description         = "MINITRUE-9414: Alert when a restore, including a restore test, fails"
+  display_name        = "Restore Job Failure Alert"
+  enabled             = true
+  tags                = local.tags
+
+  scopes               = [local.law_id]
+  evaluation_frequency = "PT1H"
+  window_duration      = "PT1H"
+  severity             = 2 # Warning
+
+  criteria {
+    query = <<-KQL
+      AddonAzureBackupJobs
+      | where TimeGenerated > ago(1h)
+      | where JobOperation == "Restore"
+      | where JobStatus == "Failed"
+      | project TimeGenerated, JobUniqueId, BackupItemUniqueId, JobStatus, JobFailureCode
+    KQL
+
+    time_aggregation_method = "Count"
+    threshold               = 0
+    operator                = "GreaterThan"
+
+    # One alert per item whose restore failed
+    dimension {
+      name     = "BackupItemUniqueId"
+      operator = "Include"
+      values   = ["*"]
+    }
+
+    failing_periods {
+      minimum_failing_periods_to_trigger_alert = 1
+      number_of_evaluation_periods             = 1
+    }
+  }
+
+  action {
+    action_groups = [azurerm_monitor_action_group.backup_alerts.id]
+    custom_properties = {
+      "AlertType" = "RestoreFailure"
+      "Severity"  = "Warning"
+    }
+  }
+
+  auto_mitigation_enabled = true
+}
+
+# -----------------------------------------------------------------
+# Task 1: Alert – Protection stopped on a backup item
+# -----------------------------------------------------------------
+resource "azurerm_monitor_scheduled_query_rules_alert_v2" "protection_stopped" {
+  name                = "alert-backup-protection-stopped"
+  location            = local.location
+  resource_group_name = local.resource_group_name
+  description         = "Backup protection stopped or paused on a backup item"
+  display_name        = "Backup Protection Stopped"
+  enabled             = true
+  tags                = local.tags
+
+  scopes               = [local.law_id]
+  evaluation_frequency = "PT1H"
+  window_duration      = "P1D" # CoreAzureBackup reports items daily
+  severity             = 2     # Warning
+
+  criteria {
+    # The latest report of each item says whether it is still protected.
+    query = <<-KQL
+      CoreAzureBackup
+      | where TimeGenerated > ago(1d)
+      | where OperationName == "BackupItem"
+      | summarize arg_max(TimeGenerated, BackupItemProtectionState) by BackupItemUniqueId
+      | where BackupItemProtectionState in ("ProtectionStopped", "ProtectionPaused")
+    KQL
+
+    time_aggregation_method = "Count"
+    threshold               = 0
+    operator                = "GreaterThan"
+
+    # One alert per unprotected item
+    dimension {
+      name     = "BackupItemUniqueId"
+      operator = "Include"
+      values   = ["*"]
+    }
+
+    failing_periods {
+      minimum_failing_periods_to_trigger_alert = 1
+      number_of_evaluation_periods             = 1
+    }
+  }
+
+  action {
+    action_groups = [azurerm_monitor_action_group.backup_alerts.id]
+    custom_properties = {
+      "AlertType" = "ProtectionStopped"
+      "Severity"  = "Warning"
+    }
+  }
+
+  auto_mitigation_enabled = false
+}
+
 # -----------------------------------------------------------------
 # Task 1: Alert – Recovery Services Vault health degraded
 # -----------------------------------------------------------------
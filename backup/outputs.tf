@@ -12,10 +12,10 @@ output "recovery_services_vault_name" {
   value       = azurerm_recovery_services_vault.main.name
 }
 
-# output "data_protection_backup_vault_id" {
-#   description = "Resource ID of the Data Protection Backup Vault for disk snapshots (MINITRUE-9416)"
-#   value       = azurerm_data_protection_backup_vault.disk_vault.id
-# }
+output "data_protection_backup_vault_id" {
+  description = "Resource ID of the Data Protection Backup Vault for disk snapshots (MINITRUE-9416)"
+  value       = azurerm_data_protection_backup_vault.disk_vault.id
+}
 
 output "automation_account_name" {
   description = "Automation Account name for restore runbooks (MINITRUE-9414)"
@@ -45,3 +45,8 @@ output "enhanced_backup_policy_id" {
   description = "Resource ID of the Enhanced VM backup policy"
   value       = azurerm_backup_policy_vm.enhanced.id
 }
+
+output "management_lock_id" {
+  description = "Resource ID of the CanNotDelete lock on the Recovery Services Vault"
+  value       = azurerm_management_lock.rsv_vault.id
+}
@@ -15,9 +15,50 @@ resource "azurerm_recovery_services_vault" "main" {
 
   storage_mode_type = "GeoRedundant" # Geo-redundant for DR
 
+  # Customer-managed key. A user-assigned identity is used because it can be
+  # granted access to the key before the vault exists; a system-assigned one
+  # only appears after creation, too late for the encryption setting.
+  dynamic "identity" {
+    for_each = var.cmk_key_id == "" ? [] : [1]
+    content {
+      type         = "UserAssigned"
+      identity_ids = [var.cmk_identity_id]
+    }
+  }
+
+  dynamic "encryption" {
+    for_each = var.cmk_key_id == "" ? [] : [1]
+    content {
+      key_id                            = var.cmk_key_id
+      infrastructure_encryption_enabled = false
+      use_system_assigned_identity      = false
+      user_assigned_identity_id         = var.cmk_identity_id
+    }
+  }
+
   tags = local.tags
 }
 
+# Multi-user authorization: link the vault to an existing Resource Guard so
+# that critical operations (disabling soft delete, reducing retention,
+# stopping protection with delete) need approval from the guard's owners.
+resource "azurerm_recovery_services_vault_resource_guard_association" "main" {
+  count = var.resource_guard_id == "" ? 0 : 1
+
+  vault_id          = azurerm_recovery_services_vault.main.id
+  resource_guard_id = var.resource_guard_id
+}
+
+# Management lock: the vault, its policies and everything else below it
+# cannot be deleted, through the portal, the SDK or a targeted destroy,
+# until the lock is removed (see teardown.PurgeVault).
+resource "azurerm_management_lock" "rsv_vault" {
+  name       = "rsv-vault-delete-lock"
+  scope      = azurerm_recovery_services_vault.main.id
+  lock_level = "CanNotDelete"
+  notes      = "Protects the backup vault and its policies from deletion"
+}
+
 # -----------------------------------------------------------------
 # Task 2: Standard backup policy – daily with 30-day retention
 #         (Used as the default / "Standard" policy)
@@ -155,6 +155,30 @@ resource "azurerm_key_vault_key" "backup_vault_cmk" {
   }
 }
 
+// Identity the backup vaults reach the key with; it must hold Get, WrapKey
+// and UnwrapKey before a vault is created with the key.
+resource "azurerm_user_assigned_identity" "backup_vault_cmk" {
+  name                = "id-backup-cmk-${random_string.suffix.result}"
+  location            = azurerm_resource_group.test.location
+  resource_group_name = azurerm_resource_group.test.name
+
+  tags = {
+    Purpose = "BackupVaultEncryption"
+  }
+}
+
+resource "azurerm_key_vault_access_policy" "backup_vault_cmk" {
+  key_vault_id = azurerm_key_vault.test.id
+  tenant_id    = azurerm_user_assigned_identity.backup_vault_cmk.tenant_id
+  object_id    = azurerm_user_assigned_identity.backup_vault_cmk.principal_id
+
+  key_permissions = [
+    "Get",
+    "UnwrapKey",
+    "WrapKey"
+  ]
+}
+
 // ============================================================================
 // Storage Account for Blob Backup Testing
 // ============================================================================
@@ -322,6 +346,16 @@ output "key_vault_key_name" {
   description = "Key Vault key name for CMK"
 }
 
+output "key_vault_key_id" {
+  value       = azurerm_key_vault_key.backup_vault_cmk.versionless_id
+  description = "Versionless key ID for CMK (cmk_key_id, TEST_CMK_KEY_ID)"
+}
+
+output "cmk_identity_id" {
+  value       = azurerm_user_assigned_identity.backup_vault_cmk.id
+  description = "User-assigned identity with wrap/unwrap on the CMK (cmk_identity_id, TEST_CMK_IDENTITY_ID)"
+}
+
 output "virtual_network_id" {
   value       = azurerm_virtual_network.test.id
   description = "Virtual Network ID for private endpoints"
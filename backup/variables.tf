@@ -80,6 +80,20 @@ variable "alert_email_addresses" {
   default     = ["ops-team@example.com"]
 }
 
+variable "alert_webhook_receivers" {
+  description = "Webhooks the backup alert action group posts to, in the Common Alert Schema"
+  type = list(object({
+    name        = string
+    service_uri = string
+  }))
+  default = []
+
+  validation {
+    condition     = alltrue([for r in var.alert_webhook_receivers : startswith(r.service_uri, "https://")])
+    error_message = "Every alert_webhook_receivers service_uri must be an https:// URL."
+  }
+}
+
 variable "log_analytics_workspace_id" {
   description = "Log Analytics Workspace resource ID for backup diagnostics"
   type        = string
@@ -91,3 +105,26 @@ variable "log_analytics_workspace_name" {
   type        = string
   default     = "law-minitrue-backup"
 }
+
+variable "resource_guard_id" {
+  description = "Resource Guard to link the Recovery Services vault to for multi-user authorization; empty leaves the vault unguarded"
+  type        = string
+  default     = ""
+}
+
+variable "cmk_key_id" {
+  description = "Key Vault key the Recovery Services vault encrypts backup data with; a versionless ID follows key rotation. Empty keeps platform-managed keys"
+  type        = string
+  default     = ""
+}
+
+variable "cmk_identity_id" {
+  description = "User-assigned identity the vault reaches cmk_key_id with; it needs Get, WrapKey and UnwrapKey on the key before the vault is created"
+  type        = string
+  default     = ""
+
+  validation {
+    condition     = var.cmk_identity_id != "" || var.cmk_key_id == ""
+    error_message = "cmk_identity_id is required when cmk_key_id is set."
+  }
+}
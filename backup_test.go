@@ -0,0 +1,1275 @@
+// Package test contains Terratest integration tests for the MINITRUE
+// Backup & Recovery Terraform module (MINITRUE-9348, 9418, 9414, 9416, Sprint 3).
+//
+// The apply-based assertions share a single deployment. TestBackupModule
+// runs in three stages: deploy applies the module once and persists its
+// options and outputs, validate runs each of backupAssertions as a subtest
+// against it, and teardown removes the vault's management lock, purges the
+// vault and destroys the rest. A deployment left behind by SKIP_teardown
+// keeps its lock until the next teardown or the sweeper. The plan-only tests
+// and the AgainstFake tests need no deployment.
+//
+// The environment a run reads is described in profiles/README.md, and the
+// commands built on these packages in their cmd/ doc comments.
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/automation/armautomation"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor/armmonitor"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/alertcoverage"
+	"github.com/SwastikaAryal/azure_terraform/alertlint"
+	"github.com/SwastikaAryal/azure_terraform/cmk"
+	"github.com/SwastikaAryal/azure_terraform/diagnostics"
+	"github.com/SwastikaAryal/azure_terraform/planassert"
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+	"github.com/SwastikaAryal/azure_terraform/profile"
+	"github.com/SwastikaAryal/azure_terraform/rbac"
+	"github.com/SwastikaAryal/azure_terraform/teardown"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+	"github.com/SwastikaAryal/azure_terraform/vaultsecurity"
+)
+
+// ─── Helpers ─────────────────────────────────────────────────────────────────
+
+// envOrDefault returns the value of the env-var or the supplied default.
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// subscriptionID reads ARM_SUBSCRIPTION_ID (required).
+func subscriptionID(t *testing.T) string {
+	t.Helper()
+	sub := os.Getenv("ARM_SUBSCRIPTION_ID")
+	require.NotEmpty(t, sub, "ARM_SUBSCRIPTION_ID must be set")
+	return sub
+}
+
+// newAzureCredential creates a DefaultAzureCredential for direct SDK assertions.
+func newAzureCredential(t *testing.T) *azidentity.DefaultAzureCredential {
+	t.Helper()
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	require.NoError(t, err, "failed to create Azure credential")
+	return cred
+}
+
+// uniqueSuffix returns a short random suffix safe for Azure resource names.
+func uniqueSuffix() string {
+	return strings.ToLower(random.UniqueId())[:6]
+}
+
+// resourceName returns the last segment of an Azure resource ID.
+func resourceName(id string) string {
+	parts := strings.Split(id, "/")
+	return parts[len(parts)-1]
+}
+
+// ─── Shared fixture ───────────────────────────────────────────────────────────
+
+// terraformOptions builds a *terraform.Options pointing at the module root with
+// a randomised name suffix so parallel test runs don't clash. Everything that
+// varies between landing zones comes from the test profile.
+func terraformOptions(t *testing.T, suffix string) *terraform.Options {
+	t.Helper()
+	return profileOptions(testProfile(t), suffix)
+}
+
+// profileOptions maps a profile onto the module's input variables. Resource
+// groups the profile leaves empty get harness names, created at deploy time.
+func profileOptions(prof *profile.Profile, suffix string) *terraform.Options {
+	rg := prof.ResourceGroups.Main
+	if rg == "" {
+		rg = fmt.Sprintf("rg-minitrue-test-%s", suffix)
+	}
+	snapshotRG := prof.ResourceGroups.Snapshot
+	if snapshotRG == "" {
+		snapshotRG = fmt.Sprintf("rg-minitrue-snaps-%s", suffix)
+	}
+
+	return &terraform.Options{
+		// Point at the module root (one level up from this test directory).
+		TerraformDir: "../",
+
+		Vars: map[string]interface{}{
+			"resource_group_name":          rg,
+			"location":                     prof.Regions.Primary,
+			"secondary_location":           prof.Regions.Secondary,
+			"environment":                  prof.Environment,
+			"vault_name":                   fmt.Sprintf("rsv-minitrue-%s", suffix),
+			"snapshot_resource_group_name": snapshotRG,
+			"alert_email_addresses":        prof.AlertEmailAddresses,
+			"alert_webhook_receivers":      webhookVars(prof.AlertWebhooks),
+			// An empty workspace_id makes the module create one.
+			"log_analytics_workspace_id":   prof.LogAnalyticsWorkspaceID,
+			"log_analytics_workspace_name": fmt.Sprintf("law-minitrue-%s", suffix),
+			"resource_guard_id":            prof.ResourceGuardID,
+			"cmk_key_id":                   prof.Encryption.KeyID,
+			"cmk_identity_id":              prof.Encryption.IdentityID,
+			"app_vm_ids":                   nonNil(prof.VMs.App.IDs),
+			"web_vm_ids":                   nonNil(prof.VMs.Web.IDs),
+			"app_vm_os_disk_ids":           nonNil(prof.VMs.App.OSDiskIDs),
+			"web_vm_os_disk_ids":           nonNil(prof.VMs.Web.OSDiskIDs),
+			"app_vm_data_disk_ids":         nonNil(prof.VMs.App.DataDiskIDs),
+			"web_vm_data_disk_ids":         nonNil(prof.VMs.Web.DataDiskIDs),
+		},
+
+		// Capture plan/apply output for assertions.
+		PlanFilePath: fmt.Sprintf("/tmp/tfplan-%s", suffix),
+
+		// Transient failures are retried by the azretry classifier (see
+		// retry_test.go), not by terratest's substring matching.
+	}
+}
+
+// fixtureDir is where the deploy stage persists its state so that later runs
+// can skip straight to the assertion stages.
+func fixtureDir() string {
+	return envOrDefault("TEST_FIXTURE_DIR", ".test-data")
+}
+
+// backupFixture is the read-only handle each assertion receives for the
+// shared deployment. Callers must not mutate the deployment through it.
+type backupFixture struct {
+	suffix  string
+	opts    *terraform.Options
+	outputs map[string]interface{}
+	profile *profile.Profile
+	arm     *armBackend
+}
+
+// loadBackupFixture reads back what the deploy stage saved in dir.
+func loadBackupFixture(t *testing.T, dir string) *backupFixture {
+	t.Helper()
+
+	fx := &backupFixture{
+		suffix: test_structure.LoadString(t, dir, "suffix"),
+		opts:   test_structure.LoadTerraformOptions(t, dir),
+		arm:    newARMBackend(t),
+	}
+	test_structure.LoadTestData(t, test_structure.FormatTestDataPath(dir, "outputs.json"), &fx.outputs)
+	test_structure.LoadTestData(t, test_structure.FormatTestDataPath(dir, "profile.json"), &fx.profile)
+	return fx
+}
+
+// Options returns a copy of the Terraform options used for the deployment, so
+// plan-based assertions can tweak Vars without affecting other subtests.
+func (fx *backupFixture) Options(t *testing.T) *terraform.Options {
+	t.Helper()
+	opts, err := fx.opts.Clone()
+	require.NoError(t, err, "failed to clone fixture terraform options")
+	return opts
+}
+
+// Output returns a string output captured after apply.
+func (fx *backupFixture) Output(t *testing.T, name string) string {
+	t.Helper()
+	val, ok := fx.outputs[name]
+	require.True(t, ok, "output %q was not captured by the deploy stage", name)
+	if val == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// ResourceGroup is the resource group the module was deployed into.
+func (fx *backupFixture) ResourceGroup() string {
+	return fmt.Sprintf("%v", fx.opts.Vars["resource_group_name"])
+}
+
+// SnapshotResourceGroup is the resource group used for disk snapshots.
+func (fx *backupFixture) SnapshotResourceGroup() string {
+	return fmt.Sprintf("%v", fx.opts.Vars["snapshot_resource_group_name"])
+}
+
+// backupAssertions are run, in order, as subtests of TestBackupModule.
+var backupAssertions = []struct {
+	name string
+	fn   func(t *testing.T, fx *backupFixture)
+}{
+	{"RSVVaultCreation", testRSVVaultCreation},
+	{"BackupPolicies", testBackupPolicies},
+	{"DiskSnapshotVault", testDiskSnapshotVault},
+	{"DiskSnapshotPolicy", testDiskSnapshotPolicy},
+	{"AutomationAccountAndRunbooks", testAutomationAccountAndRunbooks},
+	{"MonitoringAndAlerts", testMonitoringAndAlerts},
+	{"VaultHealthAlert", testVaultHealthAlert},
+	{"AlertActionGroups", testAlertActionGroups},
+	{"DiagnosticSettings", testDiagnosticSettings},
+	{"AutomationRoleAssignments", testAutomationRoleAssignments},
+	{"LeastPrivilege", testLeastPrivilege},
+	{"OutputsCompleteness", testOutputsCompleteness},
+	{"CrossRegionRestore", testCrossRegionRestore},
+	{"BackupJobEventualConsistency", testBackupJobEventualConsistency},
+	{"Idempotency", testIdempotency},
+	{"SoftDeleteProtection", testSoftDeleteProtection},
+	{"VaultSecurity", testVaultSecurity},
+	{"Encryption", testEncryption},
+	// Last: it tries to delete the vault and its policies, which only the
+	// lock stops.
+	{"ManagementLock", testManagementLock},
+}
+
+// TestBackupModule applies the module once and runs every apply-based
+// assertion against that deployment. Each stage can be skipped with
+// SKIP_<stage>=true (deploy, validate, teardown) to reuse a deployment
+// persisted in fixtureDir by an earlier run.
+func TestBackupModule(t *testing.T) {
+	dir := fixtureDir()
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		if !useFakeARM() {
+			// Registered before destroy so the groups go even if it fails.
+			var ownedGroups []string
+			test_structure.LoadTestData(t, test_structure.FormatTestDataPath(dir, "resource_groups.json"), &ownedGroups)
+			backend := newARMBackend(t)
+			deleteResourceGroupsOnCleanup(t, backend, ownedGroups)
+
+			// Soft delete keeps the vault alive through destroy, so purge it
+			// first; otherwise the next run collides with it.
+			opts := test_structure.LoadTerraformOptions(t, dir)
+			purgeVault(t, backend, opts)
+			runTerraform(t, backend.Retry, "terraform destroy", func() (string, error) {
+				return terraform.DestroyE(t, opts)
+			})
+		}
+		test_structure.CleanupTestDataFolder(t, dir)
+	})
+
+	test_structure.RunTestStage(t, "deploy", func() {
+		suffix := uniqueSuffix()
+		prof := testProfile(t)
+		opts := profileOptions(prof, suffix)
+		t.Logf("Deploying with profile %q", prof.Name)
+
+		// With the fake backend there is nothing to apply; the seed stands in
+		// for the deployment.
+		var outputs map[string]interface{}
+		if useFakeARM() {
+			outputs = fakeDeploymentOutputs(t, opts)
+		}
+
+		// Persist the options before applying so teardown can still run when
+		// the apply itself fails half-way.
+		test_structure.SaveString(t, dir, "suffix", suffix)
+		test_structure.SaveTerraformOptions(t, dir, opts)
+		test_structure.SaveTestData(t, test_structure.FormatTestDataPath(dir, "profile.json"), true, prof)
+
+		if !useFakeARM() {
+			backend := newARMBackend(t)
+			ownedGroups := provisionResourceGroups(t, backend, prof, opts.Vars)
+			test_structure.SaveTestData(t, test_structure.FormatTestDataPath(dir, "resource_groups.json"), true, ownedGroups)
+
+			runTerraform(t, backend.Retry, "terraform apply", func() (string, error) {
+				return terraform.InitAndApplyE(t, opts)
+			})
+			outputs = terraform.OutputAll(t, opts)
+		}
+
+		test_structure.SaveTestData(t, test_structure.FormatTestDataPath(dir, "outputs.json"), true, outputs)
+	})
+
+	test_structure.RunTestStage(t, "validate", func() {
+		fx := loadBackupFixture(t, dir)
+		for _, a := range backupAssertions {
+			t.Run(a.name, func(t *testing.T) {
+				a.fn(t, fx)
+			})
+		}
+	})
+}
+
+// ─── Test: Plan-only (fast, no real Azure resources) ─────────────────────────
+
+// TestBackupRecoveryPlan verifies that the module produces a valid Terraform
+// plan without actually creating any resources. Runs in CI on every PR. Each
+// requirement is checked in its own subtest, so the traceability matrix
+// reports it with its own outcome.
+func TestBackupRecoveryPlan(t *testing.T) {
+	t.Parallel()
+	suffix := uniqueSuffix()
+	prof := testProfile(t)
+	opts := profileOptions(prof, suffix)
+
+	planStruct := initAndPlanWithStruct(t, newRetryClassifier(t), opts)
+	want := prof.Expect.Vault
+	const vault = "azurerm_recovery_services_vault.main"
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		traceability.Verifies(t, "planned vault enables soft delete", "MINITRUE-9348")
+		planassert.New(t, planStruct).Resource(vault).
+			Count(1).
+			Action(planassert.Create).
+			Attr("sku", want.SKU).
+			Attr("soft_delete_enabled", want.SoftDelete)
+	})
+
+	t.Run("CrossRegionRestore", func(t *testing.T) {
+		traceability.Verifies(t, "planned vault enables cross-region restore and VM policies have the required retention", "MINITRUE-9418")
+		plan := planassert.New(t, planStruct)
+		plan.Resource(vault).
+			Count(1).
+			Attr("cross_region_restore_enabled", want.CrossRegionRestore).
+			Attr("storage_mode_type", want.StorageMode)
+		// Every VM backup policy must match the profile's policy spec.
+		for _, pol := range prof.Expect.Policies.Policies {
+			plan.Resource(pol.Address).Count(1).Action(planassert.Create)
+		}
+		assertPolicySpec(t, policyspec.CheckPlan(prof.Expect.Policies, planStruct))
+	})
+
+	t.Run("DiskPolicies", func(t *testing.T) {
+		traceability.Verifies(t, "planned disk snapshot policies have the required schedule and retention", "MINITRUE-9416")
+		plan := planassert.New(t, planStruct)
+		for _, pol := range prof.Expect.Policies.DiskPolicies {
+			plan.Resource(pol.Address).Count(1).Action(planassert.Create)
+		}
+		assertPolicySpec(t, policyspec.CheckDiskPlan(prof.Expect.Policies, planStruct))
+	})
+
+	t.Run("ManagementLock", func(t *testing.T) {
+		traceability.Verifies(t, "planned vault carries a CanNotDelete management lock", "MINITRUE-9348")
+		// Dropping the lock would leave the vault and its policies deletable.
+		planassert.New(t, planStruct).Resource("azurerm_management_lock.rsv_vault").
+			Count(1).
+			Action(planassert.Create).
+			Attr("lock_level", "CanNotDelete")
+	})
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		traceability.Verifies(t, "planned vault diagnostics write the resource-specific tables", "Sprint 3")
+		plan := planassert.New(t, planStruct)
+		// In AzureDiagnostics mode the tables the alert queries read stay empty.
+		for _, address := range []string{
+			"azurerm_monitor_diagnostic_setting.vault_diagnostics",
+			"azurerm_monitor_diagnostic_setting.disk_vault_diagnostics",
+		} {
+			plan.Resource(address).
+				Count(1).
+				Action(planassert.Create).
+				Attr("log_analytics_destination_type", "Dedicated")
+		}
+	})
+
+	t.Run("AlertQueries", func(t *testing.T) {
+		traceability.Verifies(t, "planned alert queries read backup tables, filter on time and project their dimensions", "Sprint 3")
+		for _, p := range alertlint.CheckPlan(planStruct) {
+			t.Errorf("alert query rejected: %s", p)
+		}
+	})
+
+	catalog, err := alertcoverage.LoadCatalog(failureModesPath)
+	require.NoError(t, err)
+	findings := alertcoverage.CheckPlan(catalog, planStruct).Findings
+
+	t.Run("AlertCoverage", func(t *testing.T) {
+		traceability.Verifies(t, "every backup failure mode has an alert rule within its severity and evaluation frequency", "Sprint 3")
+		for _, f := range findings {
+			if f.Kind != alertcoverage.Unattached {
+				t.Errorf("alert coverage: %s", f)
+			}
+		}
+	})
+
+	t.Run("ActionGroups", func(t *testing.T) {
+		traceability.Verifies(t, "every alert rule is attached to an action group", "Sprint 3")
+		for _, f := range findings {
+			if f.Kind == alertcoverage.Unattached {
+				t.Errorf("alert coverage: %s", f)
+			}
+		}
+	})
+}
+
+// ─── Test: Full apply / destroy cycle ────────────────────────────────────────
+
+// testRSVVaultCreation verifies the Recovery Services Vault exists with the
+// expected configuration (MINITRUE-9348).
+func testRSVVaultCreation(t *testing.T, fx *backupFixture) {
+	// ── Output assertions ─────────────────────────────────────────────────
+	vaultID := fx.Output(t, "recovery_services_vault_id")
+	vaultName := fx.Output(t, "recovery_services_vault_name")
+
+	assert.NotEmpty(t, vaultID, "recovery_services_vault_id output must not be empty")
+	assert.NotEmpty(t, vaultName, "recovery_services_vault_name output must not be empty")
+	assert.Contains(t, vaultID, "Microsoft.RecoveryServices/vaults",
+		"vault ID should contain the correct resource type")
+
+	// ── Direct SDK assertion via Azure API ────────────────────────────────
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+
+	client, err := armrecoveryservices.NewVaultsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	vault, err := client.Get(t.Context(), rg, vaultName, nil)
+	require.NoError(t, err, "vault should be reachable via Azure SDK")
+	want := fx.profile.Expect.Vault
+
+	require.NotNil(t, vault.Properties)
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		traceability.Verifies(t, "vault SKU and soft delete match the profile", "MINITRUE-9348")
+
+		// SKU
+		require.NotNil(t, vault.SKU)
+		assert.Equal(t, armrecoveryservices.SKUName(want.SKU), *vault.SKU.Name,
+			"vault SKU should be %s", want.SKU)
+
+		// Soft-delete
+		require.NotNil(t, vault.Properties.SecuritySettings)
+		assert.Equal(t,
+			softDeleteState(want.SoftDelete),
+			*vault.Properties.SecuritySettings.SoftDeleteSettings.SoftDeleteState,
+			"soft-delete state must match the profile (MINITRUE-9348)")
+	})
+
+	t.Run("CrossRegionRestore", func(t *testing.T) {
+		traceability.Verifies(t, "vault cross-region restore and storage redundancy match the profile", "MINITRUE-9418")
+
+		// Cross-region restore (required by MINITRUE-9418)
+		require.NotNil(t, vault.Properties.RedundancySettings)
+		assert.Equal(t,
+			crossRegionRestoreState(want.CrossRegionRestore),
+			*vault.Properties.RedundancySettings.CrossRegionRestore,
+			"cross-region restore state must match the profile (MINITRUE-9418)")
+
+		// Storage mode
+		assert.Equal(t,
+			armrecoveryservices.StandardTierStorageRedundancy(want.StorageMode),
+			*vault.Properties.RedundancySettings.StandardTierStorageRedundancy,
+			"storage mode should be %s", want.StorageMode)
+	})
+}
+
+// ─── Test: Backup policies (MINITRUE-9418) ───────────────────────────────────
+
+// testBackupPolicies asserts that every VM backup policy in the profile's
+// policy spec is created with the expected type, schedule, retention and
+// instant restore settings.
+func testBackupPolicies(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "standard and enhanced VM backup policies have the required schedule and retention", "MINITRUE-9418")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+	vaultName := fx.Output(t, "recovery_services_vault_name")
+
+	stdPolicyID := fx.Output(t, "standard_backup_policy_id")
+	enhPolicyID := fx.Output(t, "enhanced_backup_policy_id")
+
+	assert.NotEmpty(t, stdPolicyID, "standard_backup_policy_id must not be empty")
+	assert.NotEmpty(t, enhPolicyID, "enhanced_backup_policy_id must not be empty")
+
+	client, err := armrecoveryservicesbackup.NewProtectionPoliciesClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	devs, err := policyspec.CheckLive(t.Context(), client, rg, vaultName, fx.profile.Expect.Policies)
+	require.NoError(t, err, "backup policies should be readable via Azure SDK")
+	assertPolicySpec(t, devs)
+}
+
+// ─── Test: Disk snapshot vault (MINITRUE-9416) ───────────────────────────────
+
+// testDiskSnapshotVault verifies that the Data Protection Backup Vault for
+// managed disk snapshots exists with the correct redundancy setting.
+func testDiskSnapshotVault(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "disk snapshot backup vault exists with geo-redundant storage", "MINITRUE-9416")
+
+	vaultID := fx.Output(t, "data_protection_backup_vault_id")
+	assert.NotEmpty(t, vaultID, "data_protection_backup_vault_id must not be empty")
+	assert.Contains(t, vaultID, "Microsoft.DataProtection/backupVaults",
+		"vault ID should contain the correct resource type (MINITRUE-9416)")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+
+	dpClient, err := armdataprotection.NewBackupVaultsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	// The vault lives beside the Recovery Services vault; only the snapshots
+	// go to the snapshot resource group.
+	dpVault, err := dpClient.Get(t.Context(), fx.ResourceGroup(), resourceName(vaultID), nil)
+	require.NoError(t, err, "Data Protection vault should be reachable")
+
+	assert.Equal(t,
+		armdataprotection.StorageSettingTypesGeoRedundant,
+		*dpVault.Properties.StorageSettings[0].Type,
+		"disk snapshot vault should use geo-redundant storage (MINITRUE-9416)")
+}
+
+// ─── Test: Disk snapshot policy retention (MINITRUE-9416) ────────────────────
+
+// testDiskSnapshotPolicy verifies that the OS and data disk snapshot policies
+// match the policy spec: exact retention, backup interval, start time and
+// time zone, with the ISO 8601 values compared by value rather than by text.
+func testDiskSnapshotPolicy(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "disk snapshot policy retains snapshots for 7 days", "MINITRUE-9416")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	vaultID := fx.Output(t, "data_protection_backup_vault_id")
+
+	policyClient, err := armdataprotection.NewBackupPoliciesClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	devs, err := policyspec.CheckDiskLive(t.Context(), policyClient, fx.ResourceGroup(), resourceName(vaultID), fx.profile.Expect.Policies)
+	require.NoError(t, err, "disk snapshot policies should be readable via Azure SDK")
+	assertPolicySpec(t, devs)
+}
+
+// ─── Test: Automation account & runbooks (MINITRUE-9414) ─────────────────────
+
+// testAutomationAccountAndRunbooks validates that the Automation Account used
+// for restore testing is created and that required runbooks are present.
+func testAutomationAccountAndRunbooks(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "restore automation account and runbooks are provisioned", "MINITRUE-9414")
+
+	aaName := fx.Output(t, "automation_account_name")
+	assert.NotEmpty(t, aaName, "automation_account_name output must not be empty")
+	assert.Equal(t, "aa-minitrue-backup-restore", aaName,
+		"automation account name should match expected value (MINITRUE-9414)")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+
+	aaClient, err := armautomation.NewAccountClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	aa, err := aaClient.Get(t.Context(), rg, aaName, nil)
+	require.NoError(t, err, "automation account should be reachable")
+
+	// System-assigned managed identity required for restore runbooks
+	require.NotNil(t, aa.Identity, "automation account should have a managed identity")
+	assert.Equal(t,
+		armautomation.ResourceIdentityTypeSystemAssigned,
+		*aa.Identity.Type,
+		"automation account should use SystemAssigned identity (MINITRUE-9414)")
+
+	// Verify runbooks are published.
+	rbClient, err := armautomation.NewRunbookClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	expectedRunbooks := []string{
+		"Invoke-FullVMRestore",
+		"Invoke-DiskRestore",
+		"Invoke-FileLevelRecovery",
+	}
+
+	for _, rbName := range expectedRunbooks {
+		rb, err := rbClient.Get(t.Context(), rg, aaName, rbName, nil)
+		require.NoError(t, err, "runbook %q should exist (MINITRUE-9414)", rbName)
+		assert.Equal(t,
+			armautomation.RunbookTypeEnumPowerShell,
+			*rb.Properties.RunbookType,
+			"runbook %q should be PowerShell type", rbName)
+	}
+}
+
+// ─── Test: Monitoring & alerting (Sprint 3) ───────────────────────────────────
+
+// testMonitoringAndAlerts verifies that the Action Group and Log Analytics
+// workspace used for backup alerts are correctly provisioned.
+func testMonitoringAndAlerts(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "backup alert action group and Log Analytics workspace are provisioned", "Sprint 3")
+
+	agID := fx.Output(t, "action_group_id")
+	lawID := fx.Output(t, "log_analytics_workspace_id")
+
+	assert.NotEmpty(t, agID, "action_group_id output must not be empty")
+	assert.NotEmpty(t, lawID, "log_analytics_workspace_id output must not be empty")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+
+	// ── Action Group ──────────────────────────────────────────────────────
+	agClient, err := armmonitor.NewActionGroupsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	ag, err := agClient.Get(t.Context(), rg, resourceName(agID), nil)
+	require.NoError(t, err, "action group should be reachable (Sprint 3)")
+
+	assert.True(t, *ag.Properties.Enabled, "action group should be enabled")
+
+	// At least one email receiver should be configured.
+	assert.NotEmpty(t, ag.Properties.EmailReceivers,
+		"action group should have at least one email receiver for backup alerts")
+
+	// Every address in the profile should receive the alerts.
+	emails := map[string]bool{}
+	for _, er := range ag.Properties.EmailReceivers {
+		emails[strings.ToLower(*er.EmailAddress)] = true
+	}
+	for _, want := range fx.profile.AlertEmailAddresses {
+		assert.True(t, emails[strings.ToLower(want)],
+			"action group should have an email receiver for %s", want)
+	}
+
+	// Every webhook in the profile should receive Common Alert Schema posts.
+	webhooks := map[string]*armmonitor.WebhookReceiver{}
+	for _, wr := range ag.Properties.WebhookReceivers {
+		webhooks[*wr.Name] = wr
+	}
+	for _, want := range fx.profile.AlertWebhooks {
+		wr, ok := webhooks[want.Name]
+		if !assert.True(t, ok, "action group should have webhook receiver %s", want.Name) {
+			continue
+		}
+		assert.Equal(t, want.ServiceURI, *wr.ServiceURI, "webhook receiver %s service URI", want.Name)
+		assert.True(t, wr.UseCommonAlertSchema != nil && *wr.UseCommonAlertSchema,
+			"webhook receiver %s should use the common alert schema", want.Name)
+	}
+
+	// ── Log Analytics Workspace ───────────────────────────────────────────
+	assert.Contains(t, lawID, "Microsoft.OperationalInsights/workspaces",
+		"log_analytics_workspace_id should reference a Log Analytics workspace (Sprint 3)")
+}
+
+// testVaultHealthAlert verifies the vault health metric alert watches the
+// vault's BackupHealthEvent metric and notifies ag-backup-failure-alerts
+// (Sprint 3).
+func testVaultHealthAlert(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "vault health metric alert watches BackupHealthEvent on the vault and notifies the backup alert action group", "Sprint 3")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+	vaultID := fx.Output(t, "recovery_services_vault_id")
+	agID := fx.Output(t, "action_group_id")
+
+	// ── Metric alert ──────────────────────────────────────────────────────
+	metricAlerts, err := armmonitor.NewMetricAlertsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+	resp, err := metricAlerts.Get(t.Context(), rg, "alert-rsv-health", nil)
+	require.NoError(t, err, "vault health alert should be reachable (Sprint 3)")
+	p := resp.Properties
+	require.NotNil(t, p, "vault health alert has no properties")
+
+	assert.True(t, p.Enabled != nil && *p.Enabled, "vault health alert should be enabled")
+	if assert.NotNil(t, p.Severity) {
+		assert.EqualValues(t, 1, *p.Severity, "vault health alert severity")
+	}
+	if assert.NotNil(t, p.EvaluationFrequency) && assert.NotNil(t, p.WindowSize) {
+		assert.Equal(t, "PT5M", *p.EvaluationFrequency, "vault health alert frequency")
+		assert.Equal(t, "PT15M", *p.WindowSize, "vault health alert window")
+	}
+	if assert.Len(t, p.Scopes, 1, "vault health alert should watch one resource") {
+		assert.True(t, strings.EqualFold(*p.Scopes[0], vaultID), "vault health alert scope is %s, want the vault %s", *p.Scopes[0], vaultID)
+	}
+
+	crit, ok := p.Criteria.(*armmonitor.MetricAlertSingleResourceMultipleMetricCriteria)
+	require.True(t, ok, "vault health alert criteria are %T, want single-resource static criteria", p.Criteria)
+	require.Len(t, crit.AllOf, 1, "vault health alert should have one criterion")
+	c := crit.AllOf[0]
+	require.NotNil(t, c)
+	assert.True(t, c.MetricNamespace != nil && strings.EqualFold(*c.MetricNamespace, "Microsoft.RecoveryServices/vaults"),
+		"vault health alert should read the Recovery Services vault namespace")
+	require.NotNil(t, c.MetricName)
+	require.NotNil(t, c.TimeAggregation)
+	require.NotNil(t, c.Operator)
+	require.NotNil(t, c.Threshold)
+	assert.Equal(t, "BackupHealthEvent", *c.MetricName, "vault health alert metric")
+	assert.Equal(t, armmonitor.AggregationTypeEnumCount, *c.TimeAggregation, "vault health alert aggregation")
+	assert.Equal(t, armmonitor.OperatorGreaterThan, *c.Operator, "vault health alert operator")
+	assert.Equal(t, 0.0, *c.Threshold, "vault health alert threshold")
+
+	// ── Action group ──────────────────────────────────────────────────────
+	if assert.Len(t, p.Actions, 1, "vault health alert should notify one action group") {
+		require.NotNil(t, p.Actions[0].ActionGroupID)
+		got := *p.Actions[0].ActionGroupID
+		assert.True(t, strings.EqualFold(got, agID), "vault health alert notifies %s, want %s", got, agID)
+	}
+	agClient, err := armmonitor.NewActionGroupsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+	ag, err := agClient.Get(t.Context(), rg, resourceName(agID), nil)
+	require.NoError(t, err, "action group should be reachable (Sprint 3)")
+	assert.Equal(t, "ag-backup-failure-alerts", *ag.Name, "vault health alert action group")
+}
+
+// testAlertActionGroups verifies every alert rule on the module's vault or
+// workspace notifies an action group. Only rules on the module's resources
+// are checked; a reused resource group may hold others (Sprint 3).
+func testAlertActionGroups(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "every alert rule is attached to an action group", "Sprint 3")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+	vaultID := fx.Output(t, "recovery_services_vault_id")
+	lawID := fx.Output(t, "log_analytics_workspace_id")
+
+	onModule := func(scopes []*string) bool {
+		for _, s := range scopes {
+			if s != nil && (strings.EqualFold(*s, vaultID) || strings.EqualFold(*s, lawID)) {
+				return true
+			}
+		}
+		return false
+	}
+	checked := 0
+	metricAlerts, err := armmonitor.NewMetricAlertsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+	metricPager := metricAlerts.NewListByResourceGroupPager(rg, nil)
+	for metricPager.More() {
+		page, err := metricPager.NextPage(t.Context())
+		require.NoError(t, err, "metric alerts should be listable")
+		for _, a := range page.Value {
+			if a.Properties == nil || !onModule(a.Properties.Scopes) {
+				continue
+			}
+			checked++
+			groups := 0
+			for _, ac := range a.Properties.Actions {
+				if ac != nil && ac.ActionGroupID != nil && *ac.ActionGroupID != "" {
+					groups++
+				}
+			}
+			assert.NotZero(t, groups, "metric alert %s is not attached to an action group", *a.Name)
+		}
+	}
+	queryRules, err := armmonitor.NewScheduledQueryRulesClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+	queryPager := queryRules.NewListByResourceGroupPager(rg, nil)
+	for queryPager.More() {
+		page, err := queryPager.NextPage(t.Context())
+		require.NoError(t, err, "scheduled query rules should be listable")
+		for _, r := range page.Value {
+			if r.Properties == nil || !onModule(r.Properties.Scopes) {
+				continue
+			}
+			checked++
+			var groups []*string
+			if r.Properties.Actions != nil {
+				groups = r.Properties.Actions.ActionGroups
+			}
+			assert.NotEmpty(t, groups, "scheduled query rule %s is not attached to an action group", *r.Name)
+		}
+	}
+	assert.NotZero(t, checked, "no alert rules found on the vault or workspace")
+	t.Logf("%d alert rules on the vault and workspace checked for an action group", checked)
+}
+
+// testDiagnosticSettings checks that both vaults send every backup log
+// category to Log Analytics in resource-specific mode, and that every table
+// the scheduled query rules read is fed into the workspace they query.
+func testDiagnosticSettings(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "vault diagnostics feed every table the backup alert queries read", "Sprint 3")
+
+	cfg := diagnostics.Config{SubscriptionID: fx.arm.SubscriptionID, Credential: fx.arm.Credential, Options: fx.arm.Options}
+	report, err := diagnostics.Check(t.Context(), cfg, map[string]string{
+		diagnostics.RecoveryServices: fx.Output(t, "recovery_services_vault_id"),
+		diagnostics.DataProtection:   fx.Output(t, "data_protection_backup_vault_id"),
+	}, fx.ResourceGroup())
+	require.NoError(t, err, "diagnostic settings and query rules should be readable via Azure SDK")
+	for _, s := range report.Settings {
+		t.Logf("%s vault setting %s: %s mode to %s, categories %v", s.Vault, s.Name, s.DestinationType, s.WorkspaceID, s.Categories)
+	}
+	for _, r := range report.Rules {
+		t.Logf("rule %s reads %v", r.Name, r.Tables)
+	}
+	for _, f := range report.Findings {
+		assert.Fail(t, "vault diagnostics (Sprint 3)", f.String())
+	}
+}
+
+// ─── Test: RBAC role assignments (MINITRUE-9414) ─────────────────────────────
+
+// testAutomationRoleAssignments verifies the role matrix of the module's
+// managed identities: the Automation Account holds Backup Contributor on the
+// vault and Virtual Machine Contributor on the subscription so it can trigger
+// restore jobs, and the disk backup vault holds the snapshot roles on the
+// resource group. Roles are resolved by name, and an assignment outside the
+// matrix fails the test as surely as a missing one.
+func testAutomationRoleAssignments(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "automation identity holds Backup Contributor and Virtual Machine Contributor", "MINITRUE-9414")
+
+	automation, diskVault := identityPrincipals(t, fx)
+
+	checker, err := rbac.New(rbac.Config{SubscriptionID: fx.arm.SubscriptionID, Credential: fx.arm.Credential, Options: fx.arm.Options})
+	require.NoError(t, err)
+
+	result, err := checker.Check(t.Context(), roleMatrix(
+		fx.arm.SubscriptionID, fx.ResourceGroup(), fx.Output(t, "recovery_services_vault_id"),
+		automation, diskVault,
+	))
+	require.NoError(t, err)
+	for _, a := range result.Missing {
+		assert.Fail(t, "missing role assignment (MINITRUE-9414)", a.String())
+	}
+	for _, a := range result.Unexpected {
+		assert.Fail(t, "unexpected role assignment (MINITRUE-9414)", "%s (%s)", a, a.ID)
+	}
+}
+
+// identityPrincipals returns the principal IDs of the Automation Account's
+// and the disk backup vault's managed identities.
+func identityPrincipals(t *testing.T, fx *backupFixture) (automation, diskVault string) {
+	t.Helper()
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+
+	aaClient, err := armautomation.NewAccountClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	aa, err := aaClient.Get(t.Context(), rg, fx.Output(t, "automation_account_name"), nil)
+	require.NoError(t, err)
+	require.NotNil(t, aa.Identity)
+	require.NotNil(t, aa.Identity.PrincipalID)
+
+	dpClient, err := armdataprotection.NewBackupVaultsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	dpVault, err := dpClient.Get(t.Context(), rg, resourceName(fx.Output(t, "data_protection_backup_vault_id")), nil)
+	require.NoError(t, err)
+	require.NotNil(t, dpVault.Identity)
+	require.NotNil(t, dpVault.Identity.PrincipalID)
+
+	return *aa.Identity.PrincipalID, *dpVault.Identity.PrincipalID
+}
+
+// roleMatrix is every role assignment the module grants its identities.
+func roleMatrix(sub, rg, vaultID, automationPrincipal, diskVaultPrincipal string) []rbac.Assignment {
+	rgID := "/subscriptions/" + sub + "/resourceGroups/" + rg
+	automation := func(role, scope string) rbac.Assignment {
+		return rbac.Assignment{Principal: "automation account", PrincipalID: automationPrincipal, Role: role, Scope: scope}
+	}
+	diskVault := func(role string) rbac.Assignment {
+		return rbac.Assignment{Principal: "disk backup vault", PrincipalID: diskVaultPrincipal, Role: role, Scope: rgID}
+	}
+	return []rbac.Assignment{
+		automation("Backup Contributor", vaultID),
+		automation("Virtual Machine Contributor", "/subscriptions/"+sub),
+		diskVault("Disk Snapshot Contributor"),
+		diskVault("Disk Backup Reader"),
+		diskVault("Contributor"),
+	}
+}
+
+// ─── Test: Least-privilege audit (MINITRUE-9414) ─────────────────────────────
+
+// testLeastPrivilege audits the effective permissions of the module's managed
+// identities against the minimal action sets in specs/least_privilege.yaml,
+// and fails on any role or scope broader than the runbooks need. The module
+// does not meet that bar yet, so the audit only runs with TEST_RBAC_AUDIT=1.
+func testLeastPrivilege(t *testing.T, fx *backupFixture) {
+	if os.Getenv("TEST_RBAC_AUDIT") == "" {
+		t.Skip("least-privilege audit runs with TEST_RBAC_AUDIT=1")
+	}
+	traceability.Verifies(t, "managed identities hold no role or scope broader than the runbooks need", "MINITRUE-9414")
+
+	spec, err := rbac.LoadSpec("specs/least_privilege.yaml")
+	require.NoError(t, err)
+
+	automation, diskVault := identityPrincipals(t, fx)
+	scopes := identityScopes(fx.arm.SubscriptionID, fx.ResourceGroup(), fx.SnapshotResourceGroup(), fx.Output(t, "recovery_services_vault_id"))
+
+	checker, err := rbac.New(rbac.Config{SubscriptionID: fx.arm.SubscriptionID, Credential: fx.arm.Credential, Options: fx.arm.Options})
+	require.NoError(t, err)
+
+	identities := []struct{ name, principalID string }{
+		{"automation account", automation},
+		{"disk backup vault", diskVault},
+	}
+	for _, id := range identities {
+		needs, err := spec.Needs(id.name, scopes)
+		require.NoError(t, err)
+
+		report, err := checker.Audit(t.Context(), id.name, id.principalID, needs)
+		require.NoError(t, err)
+		for _, g := range report.Grants {
+			t.Logf("%s (%d actions, %d not-actions)", g.Assignment, len(g.Actions), len(g.NotActions))
+		}
+		for _, f := range report.Findings {
+			assert.Fail(t, "least privilege (MINITRUE-9414)", f.String())
+		}
+	}
+}
+
+// identityScopes maps the scope names used by specs/least_privilege.yaml to
+// resource IDs.
+func identityScopes(sub, rg, snapshotRG, vaultID string) map[string]string {
+	return map[string]string{
+		"vault":                   vaultID,
+		"resource_group":          "/subscriptions/" + sub + "/resourceGroups/" + rg,
+		"snapshot_resource_group": "/subscriptions/" + sub + "/resourceGroups/" + snapshotRG,
+		"subscription":            "/subscriptions/" + sub,
+	}
+}
+
+// ─── Test: Backup exclusion (disk LUN) (MINITRUE-9418) ───────────────────────
+
+// TestDiskExclusionOutputs is a lightweight plan-level test that validates
+// the selective-disk-backup (LUN exclusion) resource is planned once per app
+// VM with the temp/cache disk excluded. No VM is read at plan time, so
+// placeholder IDs are enough.
+func TestDiskExclusionOutputs(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "selective VM protection excludes data disk LUN 1", "MINITRUE-9418")
+	suffix := uniqueSuffix()
+	opts := terraformOptions(t, suffix)
+
+	vmIDs := []string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-app/providers/Microsoft.Compute/virtualMachines/vm-app-0",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-app/providers/Microsoft.Compute/virtualMachines/vm-app-1",
+	}
+	opts.Vars = copyVarsWithOverride(opts.Vars, "app_vm_ids", vmIDs)
+
+	// Plan only – fast, no infrastructure cost.
+	planStruct := initAndPlanWithStruct(t, newRetryClassifier(t), opts)
+	plan := planassert.New(t, planStruct)
+
+	selective := plan.Resource("azurerm_backup_protected_vm.app_vms_selective").
+		Count(len(vmIDs)).
+		Keys("0", "1").
+		Action(planassert.Create).
+		Attr("exclude_disk_luns", []int{1}).
+		Attr("recovery_vault_name", opts.Vars["vault_name"])
+	for i, id := range vmIDs {
+		selective.Key(i).Attr("source_vm_id", id)
+	}
+}
+
+// ─── Test: Idempotency ────────────────────────────────────────────────────────
+
+// testIdempotency re-plans the applied module and asserts it produces no
+// changes – a fundamental infrastructure-as-code hygiene check.
+func testIdempotency(t *testing.T, fx *backupFixture) {
+	requireLiveARM(t)
+
+	// Second plan – must be a no-op.
+	exitCode := terraform.PlanExitCode(t, fx.Options(t))
+	assert.Equal(t, 0, exitCode,
+		"second plan should produce no changes (idempotency check)")
+}
+
+// ─── Test: Outputs completeness ───────────────────────────────────────────────
+
+// testOutputsCompleteness checks that every documented output is present
+// and non-empty after a successful apply.
+func testOutputsCompleteness(t *testing.T, fx *backupFixture) {
+	requiredOutputs := []string{
+		"recovery_services_vault_id",
+		"recovery_services_vault_name",
+		"data_protection_backup_vault_id",
+		"automation_account_name",
+		"action_group_id",
+		"log_analytics_workspace_id",
+		"standard_backup_policy_id",
+		"enhanced_backup_policy_id",
+		"management_lock_id",
+	}
+
+	for _, outputName := range requiredOutputs {
+		val := fx.Output(t, outputName)
+		assert.NotEmpty(t, val, "output %q must not be empty", outputName)
+	}
+}
+
+// ─── Test: Soft-delete cannot be disabled without lifecycle safeguard ─────────
+
+// testSoftDeleteProtection asserts that a plan to disable soft-delete on the
+// vault does NOT silently succeed – the provider requires an explicit lifecycle
+// block. This is a regression guard for MINITRUE-9348 security requirements.
+// Profiles that do not expect soft delete skip it.
+func testSoftDeleteProtection(t *testing.T, fx *backupFixture) {
+	want := fx.profile.Expect.Vault
+	if !want.SoftDelete {
+		t.Skip("the profile does not expect soft delete")
+	}
+	traceability.Verifies(t, "soft delete cannot be switched off through Terraform", "MINITRUE-9348")
+
+	requireLiveARM(t)
+	opts := fx.Options(t)
+
+	// Attempt to override soft_delete_enabled to false and verify the plan
+	// requires a destroy/recreate (change will not be in-place).
+	noSoftDeleteOpts := &terraform.Options{
+		TerraformDir: opts.TerraformDir,
+		Vars:         copyVarsWithOverride(opts.Vars, "soft_delete_enabled", false),
+	}
+
+	// If the module exposes soft_delete_enabled as a variable; if not, the plan
+	// diff should be empty (variable not exposed = immutable from Terraform).
+	// Either way the vault must retain soft-delete; assert it via SDK.
+	runTerraform(t, fx.arm.Retry, "terraform plan", func() (string, error) {
+		return terraform.PlanE(t, noSoftDeleteOpts)
+	})
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+	vaultName := fx.Output(t, "recovery_services_vault_name")
+
+	client, err := armrecoveryservices.NewVaultsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	vault, err := client.Get(t.Context(), rg, vaultName, nil)
+	require.NoError(t, err)
+
+	require.NotNil(t, vault.Properties.SecuritySettings)
+	assert.Equal(t,
+		softDeleteState(want.SoftDelete),
+		*vault.Properties.SecuritySettings.SoftDeleteSettings.SoftDeleteState,
+		"soft-delete must remain as the profile sets it; disabling it should require explicit override (MINITRUE-9348)")
+}
+
+// ─── Test: Immutability and multi-user authorization (MINITRUE-9348) ────────
+
+// testVaultSecurity checks the vault's immutability, soft-delete retention,
+// enhanced security and Resource Guard link against the profile, and, when
+// the vault is expected to be immutable, that shortening the retention of
+// the standard policy is refused. With TEST_STOP_PROTECTION_PROBE=1 it also
+// checks that deleting the backup data of a protected item is refused.
+func testVaultSecurity(t *testing.T, fx *backupFixture) {
+	cfg := vaultsecurity.Config{SubscriptionID: fx.arm.SubscriptionID, Credential: fx.arm.Credential, Options: fx.arm.Options}
+	rg := fx.ResourceGroup()
+	vaultName := fx.Output(t, "recovery_services_vault_name")
+
+	posture, err := vaultsecurity.Read(t.Context(), cfg, rg, vaultName)
+	require.NoError(t, err, "vault security settings should be readable via Azure SDK")
+	t.Logf("immutability %s, soft delete %s for %d days, enhanced security %s, resource guard %q",
+		posture.Immutability, posture.SoftDelete, posture.SoftDeleteRetentionDays, posture.EnhancedSecurity, posture.ResourceGuardID)
+
+	t.Run("Posture", func(t *testing.T) {
+		traceability.Verifies(t, "vault immutability, soft-delete retention, enhanced security and MUA match the profile", "MINITRUE-9348")
+		for _, f := range vaultsecurity.Check(posture, vaultsecurity.ExpectationFor(fx.profile)) {
+			assert.Fail(t, "vault security (MINITRUE-9348)", f.String())
+		}
+	})
+
+	// ── A protected operation is refused ──────────────────────────────────
+	immutability := fx.profile.Expect.Vault.Immutability
+	if immutability == "" || strings.EqualFold(immutability, string(armrecoveryservices.ImmutabilityStateDisabled)) {
+		return
+	}
+	t.Run("RetentionReduction", func(t *testing.T) {
+		traceability.Verifies(t, "an immutable vault refuses to reduce backup retention", "MINITRUE-9348")
+		policy := resourceName(fx.Output(t, "standard_backup_policy_id"))
+		probe, err := vaultsecurity.ProbeRetentionReduction(t.Context(), cfg, rg, vaultName, policy)
+		require.NoError(t, err)
+		assert.True(t, probe.Blocked, "%s; an %s vault must refuse it (MINITRUE-9348)", probe, immutability)
+	})
+	t.Run("StopProtection", func(t *testing.T) {
+		if os.Getenv("TEST_STOP_PROTECTION_PROBE") == "" {
+			t.Skip("the stop-protection probe runs with TEST_STOP_PROTECTION_PROBE=1")
+		}
+		traceability.Verifies(t, "an immutable vault refuses to delete backup data", "MINITRUE-9348")
+
+		items, err := armrecoveryservicesbackup.NewBackupProtectedItemsClient(fx.arm.SubscriptionID, fx.arm.Credential, fx.arm.Options)
+		require.NoError(t, err)
+		page, err := items.NewListPager(vaultName, rg, nil).NextPage(t.Context())
+		require.NoError(t, err, "protected items should be listable via Azure SDK")
+		if len(page.Value) == 0 {
+			t.Skip("the vault protects no items to probe")
+		}
+		probe, err := vaultsecurity.ProbeStopProtection(t.Context(), cfg, rg, vaultName, *page.Value[0].ID)
+		require.NoError(t, err)
+		assert.True(t, probe.Blocked, "%s; an %s vault must refuse it (MINITRUE-9348)", probe, immutability)
+	})
+}
+
+// ─── Test: Customer-managed key (MINITRUE-9348, 9416) ───────────────────────
+
+// testEncryption checks that the vaults the profile lists encrypt with its
+// key, through its identity, that the identity can get, wrap and unwrap the
+// key, and that the Key Vault has purge protection on. Profiles without a
+// key skip it.
+func testEncryption(t *testing.T, fx *backupFixture) {
+	want := cmk.ExpectationFor(fx.profile)
+	if want.KeyID == "" {
+		t.Skip("the profile sets no customer-managed key")
+	}
+	traceability.Verifies(t, "backup vaults encrypt with the profile's customer-managed key from a purge-protected Key Vault", "MINITRUE-9348", "MINITRUE-9416")
+
+	cfg := cmk.Config{SubscriptionID: fx.arm.SubscriptionID, Credential: fx.arm.Credential, Options: fx.arm.Options}
+	report, err := cmk.Check(t.Context(), cfg, map[string]string{
+		cmk.RecoveryServices: fx.Output(t, "recovery_services_vault_id"),
+		cmk.DataProtection:   fx.Output(t, "data_protection_backup_vault_id"),
+	}, want)
+	require.NoError(t, err, "vault encryption should be readable via Azure SDK")
+	for _, v := range report.Vaults {
+		t.Logf("%s vault: key %s, identity %s", v.Vault, v.KeyURI, v.IdentityID)
+	}
+	t.Logf("key vault purge protection %t, identity key permissions %v", report.KeyVault.PurgeProtection, report.KeyPermissions)
+	for _, f := range report.Findings {
+		assert.Fail(t, "customer-managed key", f.String())
+	}
+}
+
+// ─── Test: Management lock (MINITRUE-9348) ───────────────────────────────────
+
+// testManagementLock checks that the CanNotDelete lock on the vault refuses
+// to delete the vault and its policies through the SDK and, against a live
+// subscription, through a targeted terraform destroy. Teardown removes the
+// lock when it purges the vault, and checks both are gone (see purgeVault).
+func testManagementLock(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "the management lock refuses deletion of the vault and its policies", "MINITRUE-9348")
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+	vaultID := fx.Output(t, "recovery_services_vault_id")
+	vault := fx.Output(t, "recovery_services_vault_name")
+	lockID := fx.Output(t, "management_lock_id")
+
+	// ── The lock is in place ──────────────────────────────────────────────
+	locks, err := teardown.Locks(t.Context(), teardown.Config{SubscriptionID: sub, Credential: cred, Options: fx.arm.Options}, vaultID)
+	require.NoError(t, err, "locks should be readable via Azure SDK")
+	var lock *teardown.Lock
+	for i := range locks {
+		if strings.EqualFold(locks[i].ID, lockID) {
+			lock = &locks[i]
+		}
+	}
+	// Without the lock the deletes below would go through.
+	require.NotNil(t, lock, "lock %s must apply to the vault", lockID)
+	assert.Equal(t, "CanNotDelete", lock.Level)
+	assert.True(t, strings.EqualFold(lock.Scope, vaultID), "lock is on %s, want the vault", lock.Scope)
+
+	// ── SDK deletes are refused ───────────────────────────────────────────
+	policies, err := armrecoveryservicesbackup.NewProtectionPoliciesClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+	for _, output := range []string{"standard_backup_policy_id", "enhanced_backup_policy_id"} {
+		policy := resourceName(fx.Output(t, output))
+		poller, err := policies.BeginDelete(t.Context(), vault, rg, policy, nil)
+		if err == nil {
+			_, err = poller.PollUntilDone(t.Context(), nil)
+		}
+		assertScopeLocked(t, err, "deleting policy %s", policy)
+	}
+	vaults, err := armrecoveryservices.NewVaultsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+	_, err = vaults.Delete(t.Context(), rg, vault, nil)
+	assertScopeLocked(t, err, "deleting vault %s", vault)
+
+	// ── A targeted destroy is refused ─────────────────────────────────────
+	// Targeting the vault would destroy the lock first, since the lock
+	// depends on it; a policy leaves the lock in place.
+	if useFakeARM() {
+		t.Log("skipping the targeted terraform destroy: there is nothing to destroy with the fake backend")
+	} else {
+		opts := fx.Options(t)
+		opts.Targets = []string{"azurerm_backup_policy_vm.standard"}
+		out, err := terraform.DestroyE(t, opts)
+		require.Error(t, err, "terraform destroy -target=azurerm_backup_policy_vm.standard must be refused by the lock")
+		assert.Contains(t, out, "ScopeLocked")
+	}
+}
+
+// ─── Test: Cross-region restore is enabled (MINITRUE-9418) ───────────────────
+
+// testCrossRegionRestore is a focused assertion that the vault's CRR setting
+// and storage redundancy are what the profile expects. Extracted separately
+// so it can be run as a fast smoke-test.
+func testCrossRegionRestore(t *testing.T, fx *backupFixture) {
+	traceability.Verifies(t, "cross-region restore on the deployed vault matches the profile", "MINITRUE-9418")
+	want := fx.profile.Expect.Vault
+
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+	vaultName := fx.Output(t, "recovery_services_vault_name")
+
+	client, err := armrecoveryservices.NewVaultsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	vault, err := client.Get(t.Context(), rg, vaultName, nil)
+	require.NoError(t, err)
+
+	require.NotNil(t, vault.Properties.RedundancySettings)
+	assert.Equal(t,
+		crossRegionRestoreState(want.CrossRegionRestore),
+		*vault.Properties.RedundancySettings.CrossRegionRestore,
+		"cross-region restore must be %s (MINITRUE-9418)", crossRegionRestoreState(want.CrossRegionRestore))
+
+	assert.Equal(t,
+		armrecoveryservices.StandardTierStorageRedundancy(want.StorageMode),
+		*vault.Properties.RedundancySettings.StandardTierStorageRedundancy,
+		"storage must be %s, as the profile expects (MINITRUE-9418)", want.StorageMode)
+}
+
+// ─── Test: Retry loop for eventual-consistency checks ────────────────────────
+
+// testBackupJobEventualConsistency uses Terratest's retry helper to wait for
+// the Recovery Services Vault to reach a "Succeeded" provisioning state,
+// mirroring how you'd poll for a real backup job's completion.
+func testBackupJobEventualConsistency(t *testing.T, fx *backupFixture) {
+	sub := fx.arm.SubscriptionID
+	cred := fx.arm.Credential
+	rg := fx.ResourceGroup()
+	vaultName := fx.Output(t, "recovery_services_vault_name")
+
+	client, err := armrecoveryservices.NewVaultsClient(sub, cred, fx.arm.Options)
+	require.NoError(t, err)
+
+	// Poll up to 5 minutes for the vault to fully provision.
+	description := fmt.Sprintf("Waiting for vault %q to reach Succeeded state", vaultName)
+	maxRetries := 10
+	sleepBetween := 30 * time.Second
+
+	retry.DoWithRetry(t, description, maxRetries, sleepBetween, func() (string, error) {
+		vault, err := client.Get(t.Context(), rg, vaultName, nil)
+		if err != nil {
+			return "", err
+		}
+		state := string(*vault.Properties.ProvisioningState)
+		if state != "Succeeded" {
+			return "", fmt.Errorf("vault provisioning state is %q, expected Succeeded", state)
+		}
+		return state, nil
+	})
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────────
+
+// nonNil turns a missing list into an empty one, which Terraform accepts for
+// a list(string) variable where it would reject null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// webhookVars is the alert_webhook_receivers variable for a profile's
+// webhooks.
+func webhookVars(hooks []profile.Webhook) []map[string]string {
+	out := []map[string]string{}
+	for _, h := range hooks {
+		out = append(out, map[string]string{"name": h.Name, "service_uri": h.ServiceURI})
+	}
+	return out
+}
+
+// copyVarsWithOverride returns a shallow copy of vars with one key overridden.
+func copyVarsWithOverride(vars map[string]interface{}, key string, val interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		out[k] = v
+	}
+	out[key] = val
+	return out
+}
@@ -0,0 +1,105 @@
+// Command retention forecasts what the backup policies in the policy spec
+// keep at steady state: recovery points per tier, the dates of the vault
+// points, and the storage they take for a given churn. Run it before editing
+// a policy to see what the change costs.
+//
+//	go run ./cmd/retention -policy bkpol-standard-daily-30d -protected-gb 128 -churn-gb 2 -vms 40
+//
+// Without -policy every VM and disk policy in the spec is forecast. -at
+// replays the schedules up to a given instant (RFC 3339) instead of now.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+	"github.com/SwastikaAryal/azure_terraform/retention"
+)
+
+func main() {
+	specPath := flag.String("spec", "specs/backup_policies.yaml", "policy spec to read")
+	name := flag.String("policy", "", "forecast only this policy")
+	atFlag := flag.String("at", "", "forecast at this RFC 3339 instant instead of now")
+	protected := flag.Float64("protected-gb", 128, "used disk size of one protected VM or disk, in GB")
+	churn := flag.Float64("churn-gb", 2, "data changed per VM or disk per day, in GB")
+	count := flag.Int("vms", 1, "number of VMs or disks the policy protects")
+	dates := flag.Bool("dates", false, "list the date of every retained point")
+	flag.Parse()
+
+	at := time.Now()
+	if *atFlag != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, *atFlag); err != nil {
+			fmt.Fprintln(os.Stderr, "retention: -at:", err)
+			os.Exit(2)
+		}
+	}
+	c := retention.Churn{ProtectedGB: *protected, DailyChangeGB: *churn}
+	if err := run(os.Stdout, *specPath, *name, at, c, *count, *dates); err != nil {
+		fmt.Fprintln(os.Stderr, "retention:", err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer, specPath, name string, at time.Time, c retention.Churn, count int, dates bool) error {
+	spec, err := policyspec.Load(specPath)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, p := range spec.Policies {
+		if name != "" && p.Name != name {
+			continue
+		}
+		found = true
+		points, err := retention.VM(p, at)
+		if err != nil {
+			return err
+		}
+		report(w, p.Name, points, []retention.Tier{
+			retention.Snapshot, retention.Daily, retention.Weekly, retention.Monthly, retention.Yearly,
+		}, c, count, dates)
+	}
+	for _, p := range spec.DiskPolicies {
+		if name != "" && p.Name != name {
+			continue
+		}
+		found = true
+		points, err := retention.Disk(p, at)
+		if err != nil {
+			return err
+		}
+		tiers := []retention.Tier{"Default"}
+		for _, r := range p.Retention.Rules {
+			tiers = append(tiers, retention.Tier(r.Name))
+		}
+		report(w, p.Name, points, tiers, c, count, dates)
+	}
+	if !found {
+		return fmt.Errorf("no policy %q in %s", name, specPath)
+	}
+	return nil
+}
+
+func report(w io.Writer, name string, points retention.Points, tiers []retention.Tier, c retention.Churn, count int, dates bool) {
+	fmt.Fprintf(w, "%s: %d recovery points\n", name, len(points))
+	for _, t := range tiers {
+		in := points.In(t)
+		if len(in) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-9s %4d  %s .. %s\n", t, len(in), in[0].Time.Format(time.DateOnly), in[len(in)-1].Time.Format(time.DateOnly))
+		if dates {
+			fmt.Fprintf(w, "            %s\n", strings.Join(in.Dates(), " "))
+		}
+	}
+	proj := retention.Project(points, c).Scale(count)
+	fmt.Fprintf(w, "  storage for %d x %.0f GB at %.1f GB/day: vault %.0f GB (%d points), snapshots %.0f GB (%d points)\n",
+		count, c.ProtectedGB, c.DailyChangeGB, proj.VaultGB, proj.VaultPoints, proj.SnapshotGB, proj.SnapshotPoints)
+}
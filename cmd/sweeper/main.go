@@ -0,0 +1,63 @@
+// Command sweeper finds resources orphaned by aborted Terratest runs of the
+// backup module and, with -delete, removes them in dependency order.
+//
+// Dry run (the default) prints what would be deleted:
+//
+//	go run ./cmd/sweeper -ttl 24h
+//
+// Delete for real:
+//
+//	go run ./cmd/sweeper -ttl 24h -delete
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	"github.com/SwastikaAryal/azure_terraform/sweeper"
+)
+
+func main() {
+	subscription := flag.String("subscription", os.Getenv("ARM_SUBSCRIPTION_ID"), "subscription to sweep (default $ARM_SUBSCRIPTION_ID)")
+	ttl := flag.Duration("ttl", sweeper.DefaultTTL, "minimum age of a harness resource before it is swept")
+	del := flag.Bool("delete", false, "delete the orphans instead of only reporting them")
+	flag.Parse()
+
+	if err := run(*subscription, *ttl, *del); err != nil {
+		fmt.Fprintln(os.Stderr, "sweeper:", err)
+		os.Exit(1)
+	}
+}
+
+func run(subscription string, ttl time.Duration, del bool) error {
+	if subscription == "" {
+		return fmt.Errorf("no subscription: set -subscription or ARM_SUBSCRIPTION_ID")
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return fmt.Errorf("creating credential: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg := sweeper.Config{SubscriptionID: subscription, Credential: cred, TTL: ttl}
+	plan, err := sweeper.Find(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := plan.Report(os.Stdout); err != nil {
+		return err
+	}
+	if !del {
+		fmt.Println("dry run; re-run with -delete to remove the resources above")
+		return nil
+	}
+	return sweeper.Execute(ctx, cfg, plan, os.Stdout)
+}
@@ -0,0 +1,126 @@
+// Command traceability runs the tests and writes the requirement
+// traceability matrix: which test verifies which ticket, with its result and
+// when it ran, plus the tickets no test covers.
+//
+// Run the plan-level tests and write both formats:
+//
+//	go run ./cmd/traceability -md matrix.md -csv matrix.csv -- -run 'Plan|Disk' ./...
+//
+// Build the matrix from a saved run instead:
+//
+//	go test -json ./... > run.json
+//	go run ./cmd/traceability -in run.json -md matrix.md
+//
+// Arguments after the flags are passed to `go test -json` (default ./...).
+// The exit status is non-zero when a test failed, when the run declared no
+// requirement at all (no tests ran, e.g. a filter matched nothing), or with
+// -strict when a catalog ticket is not covered.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+func main() {
+	in := flag.String("in", "", "read `go test -json` output from this file instead of running the tests")
+	md := flag.String("md", "", "write the Markdown matrix to this file (- for stdout)")
+	csvOut := flag.String("csv", "", "write the CSV matrix to this file (- for stdout)")
+	strict := flag.Bool("strict", false, "fail when a catalog ticket is not covered by any test")
+	flag.Parse()
+
+	if *md == "" && *csvOut == "" {
+		*md = "-"
+	}
+	if err := run(*in, *md, *csvOut, *strict, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "traceability:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, md, csvOut string, strict bool, testArgs []string) error {
+	var (
+		events  io.Reader
+		testErr error
+	)
+	if in != "" {
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		events = f
+	} else {
+		if len(testArgs) == 0 {
+			testArgs = []string{"./..."}
+		}
+		var out bytes.Buffer
+		cmd := exec.Command("go", append([]string{"test", "-json"}, testArgs...)...)
+		cmd.Stdout = &out
+		cmd.Stderr = os.Stderr
+		// A failing run still yields a matrix; its status is reported below.
+		var exitErr *exec.ExitError
+		if err := cmd.Run(); err != nil && !errors.As(err, &exitErr) {
+			return fmt.Errorf("running go test: %w", err)
+		} else if err != nil {
+			testErr = fmt.Errorf("go test: %w", err)
+		}
+		events = &out
+	}
+
+	m, err := traceability.Parse(events, traceability.Catalog)
+	if err != nil {
+		return fmt.Errorf("parsing test events: %w", err)
+	}
+
+	if err := write(md, m.WriteMarkdown); err != nil {
+		return err
+	}
+	if err := write(csvOut, m.WriteCSV); err != nil {
+		return err
+	}
+
+	for _, req := range m.Uncovered {
+		fmt.Fprintf(os.Stderr, "uncovered: %s %s\n", req.ID, req.Title)
+	}
+	for _, id := range m.Unknown {
+		fmt.Fprintf(os.Stderr, "not in catalog: %s\n", id)
+	}
+
+	switch {
+	case testErr != nil:
+		return testErr
+	case len(m.Rows) == 0:
+		return errors.New("no test declared a requirement; check that the tests ran")
+	case m.Failed():
+		return errors.New("assertions failed")
+	case strict && len(m.Uncovered) > 0:
+		return fmt.Errorf("%d requirements are not covered by any test", len(m.Uncovered))
+	}
+	return nil
+}
+
+func write(path string, fn func(io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		return fn(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
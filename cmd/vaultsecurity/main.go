@@ -0,0 +1,121 @@
+// Command vaultsecurity reports the immutability, soft delete, enhanced
+// security and multi-user authorization settings of a deployed Recovery
+// Services vault, and checks them against an environment profile.
+//
+//	go run ./cmd/vaultsecurity -profile uat -resource-group rg-backup-uat -vault rsv-backup-uat
+//
+// -probe-policy also tries to shorten the daily retention of that VM backup
+// policy, which an immutable vault must refuse; if the vault allows it, the
+// policy is put back and the report says so.
+//
+// -probe-item also tries to stop protection of that protected item and
+// delete its data, which an immutable vault must also refuse; if the vault
+// allows it, soft delete keeps the item and the probe undeletes it. The
+// probe does not run unless soft delete is on.
+//
+// The exit status is 2 when a setting does not meet the profile or a probe
+// was not blocked, and 1 when the check itself failed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	"github.com/SwastikaAryal/azure_terraform/profile"
+	"github.com/SwastikaAryal/azure_terraform/vaultsecurity"
+)
+
+func main() {
+	subscription := flag.String("subscription", os.Getenv("ARM_SUBSCRIPTION_ID"), "subscription the vault is in (default $ARM_SUBSCRIPTION_ID)")
+	profileRef := flag.String("profile", "dev", "profile to check against: a name under profiles/ or a path")
+	resourceGroup := flag.String("resource-group", "", "resource group of the vault")
+	vault := flag.String("vault", "", "name of the Recovery Services vault")
+	probePolicy := flag.String("probe-policy", "", "try to reduce the retention of this VM backup policy")
+	probeItem := flag.String("probe-item", "", "try to stop protection of this protected item, by resource ID, and delete its data")
+	jsonOut := flag.String("json", "", "write the JSON report to this file (- for stdout)")
+	md := flag.String("md", "", "write the Markdown report to this file (- for stdout)")
+	flag.Parse()
+
+	if *md == "" && *jsonOut == "" {
+		*md = "-"
+	}
+	ok, err := run(*subscription, *profileRef, *resourceGroup, *vault, *probePolicy, *probeItem, *jsonOut, *md)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vaultsecurity:", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(2)
+	}
+}
+
+func run(subscription, profileRef, resourceGroup, vault, probePolicy, probeItem, jsonOut, md string) (bool, error) {
+	if subscription == "" {
+		return false, fmt.Errorf("no subscription: set -subscription or ARM_SUBSCRIPTION_ID")
+	}
+	if resourceGroup == "" || vault == "" {
+		return false, fmt.Errorf("-resource-group and -vault are required")
+	}
+	p, err := profile.Load(profile.Resolve(profileRef), os.Getenv)
+	if err != nil {
+		return false, err
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return false, fmt.Errorf("creating credential: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg := vaultsecurity.Config{SubscriptionID: subscription, Credential: cred}
+	report, err := vaultsecurity.NewReport(ctx, cfg, resourceGroup, vault, vaultsecurity.ExpectationFor(p))
+	if err != nil {
+		return false, err
+	}
+	if probePolicy != "" {
+		probe, err := vaultsecurity.ProbeRetentionReduction(ctx, cfg, resourceGroup, vault, probePolicy)
+		if err != nil {
+			return false, err
+		}
+		report.Probes = append(report.Probes, probe)
+	}
+	if probeItem != "" {
+		probe, err := vaultsecurity.ProbeStopProtection(ctx, cfg, resourceGroup, vault, probeItem)
+		if err != nil {
+			return false, err
+		}
+		report.Probes = append(report.Probes, probe)
+	}
+	if err := write(jsonOut, report.WriteJSON); err != nil {
+		return false, err
+	}
+	if err := write(md, report.WriteMarkdown); err != nil {
+		return false, err
+	}
+	return report.OK(), nil
+}
+
+func write(path string, fn func(io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		return fn(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,415 @@
+// Package cmk checks that the backup vaults encrypt with the expected
+// customer-managed key (CMK), and that the key can be relied on.
+//
+// Check reads the encryption settings of the Recovery Services and Data
+// Protection vaults, the Key Vault holding the key and the identity the
+// vaults reach it with, and reports every way they fall short of the
+// profile: a vault on another key or identity, a pinned key version where
+// the vault should follow rotation (or the reverse), a Key Vault without
+// purge protection, and an identity that cannot get, wrap and unwrap the
+// key. Key access is read from the Key Vault's access policies or, for a
+// vault using Azure RBAC, from the data actions of the identity's roles.
+//
+// The module depends on no Key Vault or managed identity client, and
+// armdataprotection v1.0.0 does not model vault encryption, so those
+// resources are read as generic resources at a fixed API version.
+package cmk
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+
+	"github.com/SwastikaAryal/azure_terraform/profile"
+	"github.com/SwastikaAryal/azure_terraform/rbac"
+)
+
+// API versions of the resources read generically.
+const (
+	dataProtectionAPIVersion  = "2024-04-01"
+	keyVaultAPIVersion        = "2023-07-01"
+	managedIdentityAPIVersion = "2023-01-31"
+)
+
+// Names of the vaults, as used in the profile's encryption.vaults.
+const (
+	RecoveryServices = "recovery_services"
+	DataProtection   = "data_protection"
+)
+
+// Key version policies.
+const (
+	Versionless = "versionless"
+	Pinned      = "pinned"
+)
+
+// keyPermissions are the key permissions a vault identity needs, with the
+// RBAC data action granting each.
+var keyPermissions = []struct{ permission, dataAction string }{
+	{"get", "Microsoft.KeyVault/vaults/keys/read"},
+	{"wrapKey", "Microsoft.KeyVault/vaults/keys/wrap/action"},
+	{"unwrapKey", "Microsoft.KeyVault/vaults/keys/unwrap/action"},
+}
+
+// Config selects the subscription the vaults live in.
+type Config struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+}
+
+// Expectation is the key the vaults must use.
+type Expectation struct {
+	// KeyID is the Key Vault key URL, with a version when pinned.
+	KeyID            string
+	KeyVersionPolicy string
+	KeyVaultID       string
+	IdentityID       string
+	// Vaults are the names of the vaults that must use the key.
+	Vaults []string
+}
+
+// ExpectationFor builds the expectation of the profile's encryption
+// section. Its KeyID is empty when the profile sets no key.
+func ExpectationFor(p *profile.Profile) Expectation {
+	e := p.Encryption
+	return Expectation{
+		KeyID:            e.KeyID,
+		KeyVersionPolicy: e.KeyVersionPolicy,
+		KeyVaultID:       e.KeyVaultID,
+		IdentityID:       e.IdentityID,
+		Vaults:           e.Vaults,
+	}
+}
+
+// VaultEncryption is the encryption setting of one backup vault.
+type VaultEncryption struct {
+	Vault string `json:"vault"`
+	ID    string `json:"id"`
+	// KeyURI is empty when the vault uses platform-managed keys.
+	KeyURI string `json:"key_uri"`
+	// IdentityID is the user-assigned identity the vault reaches the key
+	// with, or SystemAssigned.
+	IdentityID string `json:"identity_id"`
+}
+
+// KeyVault is the part of a Key Vault the checks use.
+type KeyVault struct {
+	ID                      string `json:"id"`
+	URI                     string `json:"uri"`
+	PurgeProtection         bool   `json:"purge_protection"`
+	SoftDeleteRetentionDays int    `json:"soft_delete_retention_days"`
+	RBACAuthorization       bool   `json:"rbac_authorization"`
+	// accessPolicies maps object IDs to their key permissions.
+	accessPolicies map[string][]string
+}
+
+// Finding is one way the encryption falls short of the expectation.
+type Finding struct {
+	Subject string `json:"subject"`
+	Setting string `json:"setting"`
+	Want    string `json:"want"`
+	Got     string `json:"got"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s is %s, want %s", f.Subject, f.Setting, f.Got, f.Want)
+}
+
+// Report is what Check read and found.
+type Report struct {
+	Vaults   []VaultEncryption `json:"vaults"`
+	KeyVault KeyVault          `json:"key_vault"`
+	// KeyPermissions are the permissions the identity holds on the key.
+	KeyPermissions []string  `json:"key_permissions"`
+	Findings       []Finding `json:"findings"`
+}
+
+// OK reports whether the vaults use the key as expected.
+func (r *Report) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// Check reads the encryption of the vaults named in want.Vaults, given
+// their resource IDs in vaultIDs, and checks it and the key against want.
+func Check(ctx context.Context, cfg Config, vaultIDs map[string]string, want Expectation) (*Report, error) {
+	resources, err := armresources.NewClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{KeyPermissions: []string{}}
+	add := func(subject, setting, want, got string) {
+		r.Findings = append(r.Findings, Finding{Subject: subject, Setting: setting, Want: want, Got: orUnset(got)})
+	}
+
+	// ── Vaults ────────────────────────────────────────────────────────────
+	for _, name := range want.Vaults {
+		id, ok := vaultIDs[name]
+		if !ok {
+			return nil, fmt.Errorf("cmk: no resource ID for the %s vault", name)
+		}
+		var v VaultEncryption
+		switch name {
+		case RecoveryServices:
+			v, err = readRecoveryServicesVault(ctx, cfg, id)
+		case DataProtection:
+			v, err = readDataProtectionVault(ctx, resources, id)
+		default:
+			return nil, fmt.Errorf("cmk: unknown vault %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Vaults = append(r.Vaults, v)
+
+		subject := name + " vault"
+		if v.KeyURI == "" {
+			add(subject, "key", want.KeyID, "platform-managed")
+			continue
+		}
+		if !sameKey(v.KeyURI, want.KeyID) {
+			add(subject, "key", want.KeyID, v.KeyURI)
+		}
+		if policy := versionPolicy(v.KeyURI); policy != want.KeyVersionPolicy {
+			add(subject, "key version policy", want.KeyVersionPolicy, policy)
+		}
+		if !strings.EqualFold(v.IdentityID, want.IdentityID) {
+			add(subject, "identity", want.IdentityID, v.IdentityID)
+		}
+	}
+
+	// ── Key Vault ─────────────────────────────────────────────────────────
+	kv, err := readKeyVault(ctx, resources, want.KeyVaultID)
+	if err != nil {
+		return nil, err
+	}
+	r.KeyVault = kv
+	if !kv.PurgeProtection {
+		add("key vault", "purge protection", "Enabled", "Disabled")
+	}
+	if host := keyHost(want.KeyID); host != "" && !strings.EqualFold(host, keyHost(kv.URI)) {
+		add("key vault", "host", host+", the host of the key", keyHost(kv.URI))
+	}
+
+	// ── Identity ──────────────────────────────────────────────────────────
+	principalID, err := readPrincipalID(ctx, resources, want.IdentityID)
+	if err != nil {
+		return nil, err
+	}
+	if kv.RBACAuthorization {
+		r.KeyPermissions, err = rbacPermissions(ctx, cfg, principalID, want.KeyVaultID+"/keys/"+keyName(want.KeyID))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		r.KeyPermissions = append(r.KeyPermissions, kv.accessPolicies[strings.ToLower(principalID)]...)
+	}
+	for _, p := range keyPermissions {
+		if !containsFold(r.KeyPermissions, p.permission) {
+			add("identity "+want.IdentityID, p.permission+" on the key", "granted", "not granted")
+		}
+	}
+	return r, nil
+}
+
+// ─── Readers ─────────────────────────────────────────────────────────────────
+
+func readRecoveryServicesVault(ctx context.Context, cfg Config, id string) (VaultEncryption, error) {
+	v := VaultEncryption{Vault: RecoveryServices, ID: id}
+	rid, err := arm.ParseResourceID(id)
+	if err != nil {
+		return v, fmt.Errorf("cmk: parsing vault ID %s: %w", id, err)
+	}
+	vaults, err := armrecoveryservices.NewVaultsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return v, err
+	}
+	resp, err := vaults.Get(ctx, rid.ResourceGroupName, rid.Name, nil)
+	if err != nil {
+		return v, fmt.Errorf("cmk: reading vault %s: %w", rid.Name, err)
+	}
+	if resp.Properties == nil || resp.Properties.Encryption == nil {
+		return v, nil
+	}
+	e := resp.Properties.Encryption
+	if e.KeyVaultProperties != nil {
+		v.KeyURI = deref(e.KeyVaultProperties.KeyURI)
+	}
+	if e.KekIdentity != nil {
+		v.IdentityID = deref(e.KekIdentity.UserAssignedIdentity)
+		if deref(e.KekIdentity.UseSystemAssignedIdentity) {
+			v.IdentityID = "SystemAssigned"
+		}
+	}
+	return v, nil
+}
+
+func readDataProtectionVault(ctx context.Context, resources *armresources.Client, id string) (VaultEncryption, error) {
+	v := VaultEncryption{Vault: DataProtection, ID: id}
+	props, err := properties(ctx, resources, id, dataProtectionAPIVersion)
+	if err != nil {
+		return v, err
+	}
+	security, _ := props["securitySettings"].(map[string]interface{})
+	settings, _ := security["encryptionSettings"].(map[string]interface{})
+	if !strings.EqualFold(str(settings, "state"), "Enabled") {
+		return v, nil
+	}
+	keyVault, _ := settings["keyVaultProperties"].(map[string]interface{})
+	v.KeyURI = str(keyVault, "keyUri")
+	kek, _ := settings["kekIdentity"].(map[string]interface{})
+	v.IdentityID = str(kek, "identityId")
+	if strings.EqualFold(str(kek, "identityType"), "SystemAssigned") {
+		v.IdentityID = "SystemAssigned"
+	}
+	return v, nil
+}
+
+func readKeyVault(ctx context.Context, resources *armresources.Client, id string) (KeyVault, error) {
+	kv := KeyVault{ID: id, accessPolicies: map[string][]string{}}
+	props, err := properties(ctx, resources, id, keyVaultAPIVersion)
+	if err != nil {
+		return kv, err
+	}
+	kv.URI = str(props, "vaultUri")
+	kv.PurgeProtection, _ = props["enablePurgeProtection"].(bool)
+	kv.RBACAuthorization, _ = props["enableRbacAuthorization"].(bool)
+	if days, ok := props["softDeleteRetentionInDays"].(float64); ok {
+		kv.SoftDeleteRetentionDays = int(days)
+	}
+	policies, _ := props["accessPolicies"].([]interface{})
+	for _, item := range policies {
+		policy, _ := item.(map[string]interface{})
+		permissions, _ := policy["permissions"].(map[string]interface{})
+		keys, _ := permissions["keys"].([]interface{})
+		objectID := strings.ToLower(str(policy, "objectId"))
+		for _, k := range keys {
+			kv.accessPolicies[objectID] = append(kv.accessPolicies[objectID], fmt.Sprintf("%v", k))
+		}
+	}
+	return kv, nil
+}
+
+func readPrincipalID(ctx context.Context, resources *armresources.Client, identityID string) (string, error) {
+	props, err := properties(ctx, resources, identityID, managedIdentityAPIVersion)
+	if err != nil {
+		return "", err
+	}
+	id := str(props, "principalId")
+	if id == "" {
+		return "", fmt.Errorf("cmk: identity %s has no principal ID", identityID)
+	}
+	return id, nil
+}
+
+// rbacPermissions lists the key permissions the principal's roles grant on
+// the key.
+func rbacPermissions(ctx context.Context, cfg Config, principalID, keyScope string) ([]string, error) {
+	checker, err := rbac.New(rbac.Config{SubscriptionID: cfg.SubscriptionID, Credential: cfg.Credential, Options: cfg.Options})
+	if err != nil {
+		return nil, err
+	}
+	grants, err := checker.Grants(ctx, "vault identity", principalID)
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, p := range keyPermissions {
+		for _, g := range grants {
+			if g.AllowsData(p.dataAction, keyScope) {
+				out = append(out, p.permission)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func properties(ctx context.Context, resources *armresources.Client, id, apiVersion string) (map[string]interface{}, error) {
+	resp, err := resources.GetByID(ctx, id, apiVersion, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cmk: reading %s: %w", id, err)
+	}
+	props, _ := resp.Properties.(map[string]interface{})
+	return props, nil
+}
+
+// ─── Key URLs ────────────────────────────────────────────────────────────────
+
+// keyParts splits a key URL into host, key name and version.
+func keyParts(keyURL string) (host, name, version string) {
+	u, err := url.Parse(keyURL)
+	if err != nil {
+		return "", "", ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "keys" {
+		return u.Hostname(), "", ""
+	}
+	if len(parts) > 2 {
+		version = parts[2]
+	}
+	return u.Hostname(), parts[1], version
+}
+
+func keyHost(keyURL string) string {
+	host, _, _ := keyParts(keyURL)
+	return host
+}
+
+func keyName(keyURL string) string {
+	_, name, _ := keyParts(keyURL)
+	return name
+}
+
+func versionPolicy(keyURL string) string {
+	if _, _, version := keyParts(keyURL); version != "" {
+		return Pinned
+	}
+	return Versionless
+}
+
+// sameKey reports whether two key URLs name the same key and version. Hosts
+// compare without a port, which Azure adds to some URIs it returns.
+func sameKey(a, b string) bool {
+	ha, na, va := keyParts(a)
+	hb, nb, vb := keyParts(b)
+	return na != "" && strings.EqualFold(ha, hb) && strings.EqualFold(na, nb) && strings.EqualFold(va, vb)
+}
+
+// ─── Helpers ─────────────────────────────────────────────────────────────────
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func str(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return "unset"
+	}
+	return s
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
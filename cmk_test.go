@@ -0,0 +1,169 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/cmk"
+	"github.com/SwastikaAryal/azure_terraform/fakearm"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Customer-managed key ──────────────────────────────────────────────
+
+// TestEncryptionAgainstFake seeds the fake backend from the plan with the
+// vault set to a versionless customer-managed key through a user-assigned
+// identity, as tests_setup_main.tf provisions them, and checks the key
+// passes. It then breaks one thing at a time: purge protection, the
+// identity's wrap permission, a pinned key version and an unencrypted Data
+// Protection vault, and checks each is reported. Key access is checked both
+// through access policies and through Azure RBAC.
+func TestEncryptionAgainstFake(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "backup vaults encrypt with the profile's customer-managed key from a purge-protected Key Vault", "MINITRUE-9348", "MINITRUE-9416")
+
+	// Seed the plan as saved first: the fake takes its subscription from it.
+	fake := seededFake(t)
+	plan, err := os.ReadFile(syntheticPlan)
+	require.NoError(t, err)
+	sub := fake.SubscriptionID()
+
+	keyVaultID := "/subscriptions/" + sub + "/resourceGroups/rg-backup-vault-test/providers/Microsoft.KeyVault/vaults/kv-backup-test"
+	identityID := "/subscriptions/" + sub + "/resourceGroups/rg-backup-vault-test/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id-backup-cmk"
+	keyID := "https://kv-backup-test.vault.azure.net/keys/backup-vault-key"
+	principalID := "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+
+	require.NoError(t, fake.Seed(withCMK(t, plan, keyID, identityID)))
+	vaultIDs := map[string]string{
+		cmk.RecoveryServices: fake.VaultID,
+		cmk.DataProtection:   fake.Attr("azurerm_data_protection_backup_vault.disk_vault", "id"),
+	}
+
+	fake.Put(identityID, map[string]interface{}{"properties": map[string]interface{}{"principalId": principalID}})
+	keyVault := func(purgeProtection, rbac bool, keys ...string) {
+		permissions := make([]interface{}, len(keys))
+		for i, k := range keys {
+			permissions[i] = k
+		}
+		fake.Put(keyVaultID, map[string]interface{}{"properties": map[string]interface{}{
+			"vaultUri":                  "https://kv-backup-test.vault.azure.net/",
+			"enablePurgeProtection":     purgeProtection,
+			"enableRbacAuthorization":   rbac,
+			"softDeleteRetentionInDays": 90,
+			"accessPolicies": []interface{}{map[string]interface{}{
+				"objectId":    principalID,
+				"permissions": map[string]interface{}{"keys": permissions},
+			}},
+		}})
+	}
+
+	cfg := cmk.Config{SubscriptionID: sub, Credential: fake.Credential(), Options: fake.ClientOptions()}
+	want := cmk.Expectation{
+		KeyID:            keyID,
+		KeyVersionPolicy: cmk.Versionless,
+		KeyVaultID:       keyVaultID,
+		IdentityID:       identityID,
+		Vaults:           []string{cmk.RecoveryServices},
+	}
+	findings := func(want cmk.Expectation) []string {
+		report, err := cmk.Check(t.Context(), cfg, vaultIDs, want)
+		require.NoError(t, err)
+		out := []string{}
+		for _, f := range report.Findings {
+			out = append(out, f.String())
+		}
+		return out
+	}
+
+	// ── As tests_setup_main.tf provisions it ──────────────────────────────
+	keyVault(true, false, "Get", "UnwrapKey", "WrapKey")
+	report, err := cmk.Check(t.Context(), cfg, vaultIDs, want)
+	require.NoError(t, err)
+	if assert.Len(t, report.Vaults, 1) {
+		assert.Equal(t, keyID, report.Vaults[0].KeyURI)
+		assert.Equal(t, identityID, report.Vaults[0].IdentityID)
+	}
+	assert.True(t, report.OK(), "%v", report.Findings)
+
+	// ── Key Vault and key access ──────────────────────────────────────────
+	keyVault(false, false, "Get", "UnwrapKey")
+	assert.Equal(t, []string{
+		"key vault: purge protection is Disabled, want Enabled",
+		"identity " + identityID + ": wrapKey on the key is not granted, want granted",
+	}, findings(want))
+
+	keyVault(true, true)
+	assert.Len(t, findings(want), 3, "with RBAC authorization, access policies no longer count")
+	fake.Put(keyVaultID+"/providers/Microsoft.Authorization/roleAssignments/"+fakearm.BuiltInRoleID("assignment/cmk"),
+		map[string]interface{}{"properties": map[string]interface{}{
+			"scope":            keyVaultID,
+			"principalId":      principalID,
+			"roleDefinitionId": "/subscriptions/" + sub + "/providers/Microsoft.Authorization/roleDefinitions/" + fakearm.BuiltInRoleID("Key Vault Crypto Service Encryption User"),
+		}})
+	assert.Empty(t, findings(want), "Key Vault Crypto Service Encryption User grants get, wrap and unwrap")
+
+	// ── Key version and vaults ────────────────────────────────────────────
+	pinned := want
+	pinned.KeyID, pinned.KeyVersionPolicy = keyID+"/0123456789abcdef0123456789abcdef", cmk.Pinned
+	assert.Equal(t, []string{
+		"recovery_services vault: key is " + keyID + ", want " + pinned.KeyID,
+		"recovery_services vault: key version policy is versionless, want pinned",
+	}, findings(pinned))
+
+	both := want
+	both.Vaults = []string{cmk.RecoveryServices, cmk.DataProtection}
+	assert.Equal(t, []string{
+		"data_protection vault: key is platform-managed, want " + keyID,
+	}, findings(both))
+
+	doc, ok := fake.Get(vaultIDs[cmk.DataProtection])
+	require.True(t, ok)
+	doc["properties"].(map[string]interface{})["securitySettings"] = map[string]interface{}{
+		"encryptionSettings": map[string]interface{}{
+			"state":              "Enabled",
+			"keyVaultProperties": map[string]interface{}{"keyUri": keyID},
+			"kekIdentity":        map[string]interface{}{"identityType": "UserAssigned", "identityId": identityID},
+		},
+	}
+	fake.Put(vaultIDs[cmk.DataProtection], doc)
+	assert.Empty(t, findings(both))
+}
+
+// withCMK returns the plan with the Recovery Services vault set to encrypt
+// with keyID through the user-assigned identityID, as the module plans it
+// when cmk_key_id and cmk_identity_id are set.
+func withCMK(t *testing.T, raw []byte, keyID, identityID string) []byte {
+	t.Helper()
+	var plan map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &plan))
+
+	root := plan["planned_values"].(map[string]interface{})["root_module"].(map[string]interface{})
+	found := false
+	for _, r := range root["resources"].([]interface{}) {
+		r := r.(map[string]interface{})
+		if r["address"] != "azurerm_recovery_services_vault.main" {
+			continue
+		}
+		values := r["values"].(map[string]interface{})
+		values["identity"] = []interface{}{map[string]interface{}{
+			"type":         "UserAssigned",
+			"identity_ids": []interface{}{identityID},
+		}}
+		values["encryption"] = []interface{}{map[string]interface{}{
+			"key_id":                            keyID,
+			"infrastructure_encryption_enabled": false,
+			"use_system_assigned_identity":      false,
+			"user_assigned_identity_id":         identityID,
+		}}
+		found = true
+	}
+	require.True(t, found, "the plan has no Recovery Services vault")
+
+	out, err := json.Marshal(plan)
+	require.NoError(t, err)
+	return out
+}
@@ -0,0 +1,336 @@
+// Package commonalert reads and checks the Common Alert Schema payloads an
+// Azure Monitor action group posts to its webhook receivers, and runs a
+// local HTTP receiver for them.
+//
+// Parse rejects a payload Azure would not send: a missing field, a severity
+// outside Sev0 to Sev4, a target that is not a resource ID, a resolved alert
+// without its resolution time, or a log search or metric condition without
+// its criteria. Receiver accepts valid payloads with 200 and rejects others
+// with 400, recording both, so a test can post notifications to it and
+// assert on what arrived without an email inbox.
+package commonalert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaID identifies a Common Alert Schema payload.
+const SchemaID = "azureMonitorCommonAlertSchema"
+
+// Monitoring services, as named in Essentials.
+const (
+	// LogAlertsV2 raises scheduled query rule alerts.
+	LogAlertsV2 = "Log Alerts V2"
+	// Platform raises metric alerts.
+	Platform = "Platform"
+)
+
+// Condition types of an AlertContext.
+const (
+	LogQueryCriteria = "LogQueryCriteria"
+	MetricCriteria   = "SingleResourceMultipleMetricCriteria"
+)
+
+// Payload is one notification.
+type Payload struct {
+	SchemaID string `json:"schemaId"`
+	Data     Data   `json:"data"`
+}
+
+// Data is the body of a notification.
+type Data struct {
+	Essentials   Essentials   `json:"essentials"`
+	AlertContext AlertContext `json:"alertContext"`
+	// CustomProperties are the rule's custom properties.
+	CustomProperties map[string]string `json:"customProperties,omitempty"`
+}
+
+// Essentials are the fields every alert carries, whatever raised it.
+type Essentials struct {
+	AlertID string `json:"alertId"`
+	// AlertRule is the name of the rule.
+	AlertRule string `json:"alertRule"`
+	// Severity is Sev0 to Sev4.
+	Severity string `json:"severity"`
+	// SignalType is Metric, Log or Activity Log.
+	SignalType string `json:"signalType"`
+	// MonitorCondition is Fired or Resolved.
+	MonitorCondition  string `json:"monitorCondition"`
+	MonitoringService string `json:"monitoringService"`
+	// AlertTargetIDs are the resources the rule watches: the workspace of a
+	// log search rule, the vault of a metric rule.
+	AlertTargetIDs []string `json:"alertTargetIDs"`
+	// ConfigurationItems name the affected resources.
+	ConfigurationItems  []string `json:"configurationItems"`
+	OriginAlertID       string   `json:"originAlertId"`
+	FiredDateTime       string   `json:"firedDateTime"`
+	ResolvedDateTime    string   `json:"resolvedDateTime,omitempty"`
+	Description         string   `json:"description"`
+	EssentialsVersion   string   `json:"essentialsVersion"`
+	AlertContextVersion string   `json:"alertContextVersion"`
+}
+
+// AlertContext is the condition that raised a log search or metric alert.
+type AlertContext struct {
+	ConditionType string    `json:"conditionType"`
+	Condition     Condition `json:"condition"`
+}
+
+// Condition is the evaluation that fired.
+type Condition struct {
+	// WindowSize is an ISO 8601 duration.
+	WindowSize      string      `json:"windowSize"`
+	AllOf           []Criterion `json:"allOf"`
+	WindowStartTime string      `json:"windowStartTime,omitempty"`
+	WindowEndTime   string      `json:"windowEndTime,omitempty"`
+}
+
+// Criterion is one criterion of a condition, with the value it evaluated
+// to. Log search criteria carry the query, metric criteria the metric.
+type Criterion struct {
+	SearchQuery         string `json:"searchQuery,omitempty"`
+	MetricMeasureColumn string `json:"metricMeasureColumn,omitempty"`
+	TargetResourceTypes string `json:"targetResourceTypes,omitempty"`
+	MetricName          string `json:"metricName,omitempty"`
+	MetricNamespace     string `json:"metricNamespace,omitempty"`
+
+	Operator        string      `json:"operator"`
+	Threshold       string      `json:"threshold"`
+	TimeAggregation string      `json:"timeAggregation"`
+	Dimensions      []Dimension `json:"dimensions"`
+	MetricValue     float64     `json:"metricValue"`
+}
+
+// Dimension is the value of one dimension of the alert's split.
+type Dimension struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Dimension returns the value of the named dimension of the payload's first
+// criterion.
+func (p Payload) Dimension(name string) (string, bool) {
+	for _, c := range p.Data.AlertContext.Condition.AllOf {
+		for _, d := range c.Dimensions {
+			if d.Name == name {
+				return d.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Parse decodes a payload and validates it.
+func Parse(body []byte) (Payload, error) {
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Payload{}, fmt.Errorf("commonalert: %w", err)
+	}
+	return p, p.Validate()
+}
+
+// Validate returns every way p differs from what Azure sends, joined.
+func (p Payload) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+	require := func(field, val string) {
+		if strings.TrimSpace(val) == "" {
+			fail(field, "is required")
+		}
+	}
+	oneOf := func(field, val string, allowed ...string) {
+		for _, a := range allowed {
+			if val == a {
+				return
+			}
+		}
+		fail(field, "%q is not one of %s", val, strings.Join(allowed, ", "))
+	}
+	timestamp := func(field, val string) {
+		if _, err := time.Parse(time.RFC3339Nano, val); err != nil {
+			fail(field, "%q is not an RFC 3339 time", val)
+		}
+	}
+
+	if p.SchemaID != SchemaID {
+		fail("schemaId", "%q is not %s", p.SchemaID, SchemaID)
+	}
+
+	e := p.Data.Essentials
+	const ess = "data.essentials."
+	require(ess+"alertId", e.AlertID)
+	require(ess+"alertRule", e.AlertRule)
+	require(ess+"originAlertId", e.OriginAlertID)
+	oneOf(ess+"severity", e.Severity, "Sev0", "Sev1", "Sev2", "Sev3", "Sev4")
+	oneOf(ess+"signalType", e.SignalType, "Metric", "Log", "Activity Log")
+	oneOf(ess+"monitorCondition", e.MonitorCondition, "Fired", "Resolved")
+	require(ess+"monitoringService", e.MonitoringService)
+	oneOf(ess+"essentialsVersion", e.EssentialsVersion, "1.0")
+	oneOf(ess+"alertContextVersion", e.AlertContextVersion, "1.0")
+	if len(e.AlertTargetIDs) == 0 {
+		fail(ess+"alertTargetIDs", "is empty")
+	}
+	for i, id := range e.AlertTargetIDs {
+		if !strings.HasPrefix(strings.ToLower(id), "/subscriptions/") {
+			fail(fmt.Sprintf("%salertTargetIDs[%d]", ess, i), "%q is not a resource ID", id)
+		}
+	}
+	timestamp(ess+"firedDateTime", e.FiredDateTime)
+	switch {
+	case e.MonitorCondition == "Resolved":
+		timestamp(ess+"resolvedDateTime", e.ResolvedDateTime)
+	case e.ResolvedDateTime != "":
+		fail(ess+"resolvedDateTime", "is set on an alert that has not resolved")
+	}
+
+	// The context is only checked for the services the module's rules use.
+	ctx := p.Data.AlertContext
+	const ac = "data.alertContext."
+	want := map[string]string{LogAlertsV2: LogQueryCriteria, Platform: MetricCriteria}[e.MonitoringService]
+	if want == "" {
+		return errors.Join(errs...)
+	}
+	if ctx.ConditionType != want {
+		fail(ac+"conditionType", "%q is not %s, which %s sends", ctx.ConditionType, want, e.MonitoringService)
+	}
+	require(ac+"condition.windowSize", ctx.Condition.WindowSize)
+	if len(ctx.Condition.AllOf) == 0 {
+		fail(ac+"condition.allOf", "is empty")
+	}
+	for i, c := range ctx.Condition.AllOf {
+		field := fmt.Sprintf("%scondition.allOf[%d].", ac, i)
+		if want == LogQueryCriteria {
+			require(field+"searchQuery", c.SearchQuery)
+		} else {
+			require(field+"metricName", c.MetricName)
+			require(field+"metricNamespace", c.MetricNamespace)
+		}
+		require(field+"operator", c.Operator)
+		require(field+"threshold", c.Threshold)
+		require(field+"timeAggregation", c.TimeAggregation)
+	}
+	return errors.Join(errs...)
+}
+
+// ─── Receiver ────────────────────────────────────────────────────────────────
+
+// Delivery is one request a Receiver answered.
+type Delivery struct {
+	// Status is the HTTP status the receiver answered with.
+	Status  int
+	Payload Payload
+	// Err is why the request was rejected; nil when it was accepted.
+	Err error
+}
+
+// Receiver is a local webhook endpoint for action group notifications.
+type Receiver struct {
+	// URL is the endpoint to post to.
+	URL string
+
+	srv        *httptest.Server
+	mu         sync.Mutex
+	deliveries []Delivery
+	arrived    chan struct{}
+}
+
+// NewReceiver starts a receiver on a loopback port. Close it when done.
+func NewReceiver() *Receiver {
+	r := &Receiver{arrived: make(chan struct{}, 1)}
+	r.srv = httptest.NewServer(http.HandlerFunc(r.serve))
+	r.URL = r.srv.URL
+	return r
+}
+
+// Close stops the receiver.
+func (r *Receiver) Close() {
+	r.srv.Close()
+}
+
+func (r *Receiver) serve(w http.ResponseWriter, req *http.Request) {
+	d := Delivery{Status: http.StatusOK}
+	switch body, err := io.ReadAll(req.Body); {
+	case req.Method != http.MethodPost:
+		d.Status, d.Err = http.StatusMethodNotAllowed, fmt.Errorf("method %s, want POST", req.Method)
+	case !strings.HasPrefix(req.Header.Get("Content-Type"), "application/json"):
+		d.Status, d.Err = http.StatusUnsupportedMediaType, fmt.Errorf("content type %q, want application/json", req.Header.Get("Content-Type"))
+	case err != nil:
+		d.Status, d.Err = http.StatusBadRequest, err
+	default:
+		if d.Payload, d.Err = Parse(body); d.Err != nil {
+			d.Status = http.StatusBadRequest
+		}
+	}
+
+	r.mu.Lock()
+	r.deliveries = append(r.deliveries, d)
+	r.mu.Unlock()
+	select {
+	case r.arrived <- struct{}{}:
+	default:
+	}
+
+	if d.Err != nil {
+		http.Error(w, d.Err.Error(), d.Status)
+		return
+	}
+	w.WriteHeader(d.Status)
+}
+
+// Deliveries returns every request answered so far, in order.
+func (r *Receiver) Deliveries() []Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Delivery{}, r.deliveries...)
+}
+
+// Wait returns the deliveries once there are at least n, or fails when ctx
+// is done first.
+func (r *Receiver) Wait(ctx context.Context, n int) ([]Delivery, error) {
+	for {
+		if d := r.Deliveries(); len(d) >= n {
+			return d, nil
+		}
+		select {
+		case <-r.arrived:
+		case <-ctx.Done():
+			return r.Deliveries(), fmt.Errorf("commonalert: %d of %d deliveries arrived: %w", len(r.Deliveries()), n, ctx.Err())
+		}
+	}
+}
+
+// Post sends p to a webhook as an action group does, and fails unless the
+// webhook answers 2xx.
+func Post(ctx context.Context, url string, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("commonalert: %s answered %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+// Package diagnostics checks that the backup vaults send the logs the alert
+// queries read to Log Analytics.
+//
+// Check lists the diagnostic settings on the Recovery Services and Data
+// Protection vaults and reports every required log category that no setting
+// sends to a workspace, and every setting that sends them in the default
+// AzureDiagnostics mode rather than into the resource-specific tables. It
+// then reads the queries of the scheduled query rules in the resource group
+// and reports every table a rule reads that no diagnostic setting feeds
+// into the rule's scope.
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor/armmonitor"
+
+	"github.com/SwastikaAryal/azure_terraform/kql"
+)
+
+// Names of the vaults, as in package cmk.
+const (
+	RecoveryServices = "recovery_services"
+	DataProtection   = "data_protection"
+)
+
+// Destination table modes of a diagnostic setting.
+const (
+	// Dedicated sends each category to the table of the same name.
+	Dedicated = "Dedicated"
+	// AzureDiagnostics sends every category to the AzureDiagnostics table;
+	// it is the mode of a setting that names none.
+	AzureDiagnostics = "AzureDiagnostics"
+)
+
+// allLogs is the category group that covers every log category.
+const allLogs = "allLogs"
+
+// Required are the log categories each vault must send. AzureBackupReport is
+// not among them: it is the legacy category and only ever lands in
+// AzureDiagnostics.
+var Required = map[string][]string{
+	RecoveryServices: {
+		"CoreAzureBackup",
+		"AddonAzureBackupJobs",
+		"AddonAzureBackupAlerts",
+		"AddonAzureBackupPolicy",
+		"AddonAzureBackupStorage",
+		"AddonAzureBackupProtectedInstance",
+	},
+	DataProtection: {
+		"CoreAzureBackup",
+		"AddonAzureBackupJobs",
+		"AddonAzureBackupPolicy",
+		"AddonAzureBackupProtectedInstance",
+	},
+}
+
+// Config selects the subscription the vaults and rules live in.
+type Config struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+}
+
+// Setting is one diagnostic setting on a vault.
+type Setting struct {
+	Vault string `json:"vault"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	// WorkspaceID is empty when the setting sends to no workspace.
+	WorkspaceID     string `json:"workspace_id"`
+	DestinationType string `json:"destination_type"`
+	// Categories are the enabled log categories and category groups.
+	Categories []string `json:"categories"`
+	target     string
+}
+
+// sends reports whether the setting sends the log category to a workspace.
+func (s Setting) sends(category string) bool {
+	if s.WorkspaceID == "" {
+		return false
+	}
+	for _, c := range s.Categories {
+		if strings.EqualFold(c, category) || strings.EqualFold(c, allLogs) {
+			return true
+		}
+	}
+	return false
+}
+
+// tables are the workspace tables the setting feeds.
+func (s Setting) tables() []string {
+	if s.WorkspaceID == "" || len(s.Categories) == 0 {
+		return nil
+	}
+	if s.DestinationType != Dedicated {
+		return []string{AzureDiagnostics}
+	}
+	var out []string
+	for _, c := range s.Categories {
+		if strings.EqualFold(c, allLogs) {
+			out = append(out, Required[s.Vault]...)
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// Rule is a scheduled query rule and the tables its queries read.
+type Rule struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Tables []string `json:"tables"`
+}
+
+// Finding is one way the diagnostics fall short of what the alerts need.
+type Finding struct {
+	Subject string `json:"subject"`
+	Setting string `json:"setting"`
+	Want    string `json:"want"`
+	Got     string `json:"got"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s is %s, want %s", f.Subject, f.Setting, f.Got, f.Want)
+}
+
+// Report is what Check read and found.
+type Report struct {
+	Settings []Setting `json:"settings"`
+	Rules    []Rule    `json:"rules"`
+	Findings []Finding `json:"findings"`
+}
+
+// OK reports whether every required category reaches Log Analytics and
+// every table the rules read is fed.
+func (r *Report) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// Check reads the diagnostic settings of the vaults in vaultIDs, keyed by
+// vault name, and the scheduled query rules in resourceGroup, and checks
+// them against each other.
+func Check(ctx context.Context, cfg Config, vaultIDs map[string]string, resourceGroup string) (*Report, error) {
+	settings, err := armmonitor.NewDiagnosticSettingsClient(cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := armmonitor.NewScheduledQueryRulesClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{Settings: []Setting{}, Rules: []Rule{}}
+	add := func(subject, setting, want, got string) {
+		r.Findings = append(r.Findings, Finding{Subject: subject, Setting: setting, Want: want, Got: got})
+	}
+
+	// ── Vaults ────────────────────────────────────────────────────────────
+	names := make([]string, 0, len(vaultIDs))
+	for name := range vaultIDs {
+		if _, ok := Required[name]; !ok {
+			return nil, fmt.Errorf("diagnostics: unknown vault %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// fed maps a lower-cased scope, a workspace or a vault, to the tables
+	// the settings feed into it.
+	fed := map[string]map[string]bool{}
+	feed := func(scope string, tables []string) {
+		scope = strings.ToLower(scope)
+		if fed[scope] == nil {
+			fed[scope] = map[string]bool{}
+		}
+		for _, t := range tables {
+			fed[scope][t] = true
+		}
+	}
+
+	for _, name := range names {
+		id := vaultIDs[name]
+		list, err := listSettings(ctx, settings, name, id)
+		if err != nil {
+			return nil, err
+		}
+		r.Settings = append(r.Settings, list...)
+
+		subject := name + " vault"
+		for _, category := range Required[name] {
+			sent := false
+			for _, s := range list {
+				sent = sent || s.sends(category)
+			}
+			if !sent {
+				add(subject, "log category "+category, "sent to Log Analytics", "not sent")
+			}
+		}
+		for _, s := range list {
+			if s.WorkspaceID != "" && s.DestinationType != Dedicated {
+				add(subject+" setting "+s.Name, "destination table mode", Dedicated, s.DestinationType)
+			}
+			feed(s.WorkspaceID, s.tables())
+			feed(s.target, s.tables())
+		}
+	}
+
+	// ── Rules ─────────────────────────────────────────────────────────────
+	pager := rules.NewListByResourceGroupPager(resourceGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("diagnostics: listing scheduled query rules in %s: %w", resourceGroup, err)
+		}
+		for _, item := range page.Value {
+			if item == nil || item.Properties == nil {
+				continue
+			}
+			rule := readRule(item)
+			r.Rules = append(r.Rules, rule)
+
+			subject := "rule " + rule.Name
+			if len(rule.Scopes) == 0 {
+				add(subject, "scope", "a Log Analytics workspace", "unset")
+				continue
+			}
+			for _, table := range rule.Tables {
+				ok := false
+				for _, scope := range rule.Scopes {
+					ok = ok || fed[strings.ToLower(scope)][table]
+				}
+				if !ok {
+					add(subject, "table "+table, "fed by a vault diagnostic setting", "not fed into its scope")
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// ─── Readers ─────────────────────────────────────────────────────────────────
+
+func listSettings(ctx context.Context, client *armmonitor.DiagnosticSettingsClient, vault, id string) ([]Setting, error) {
+	var out []Setting
+	pager := client.NewListPager(id, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("diagnostics: listing diagnostic settings of %s: %w", id, err)
+		}
+		for _, item := range page.Value {
+			if item == nil || item.Properties == nil {
+				continue
+			}
+			p := item.Properties
+			s := Setting{
+				Vault:           vault,
+				ID:              deref(item.ID),
+				Name:            deref(item.Name),
+				WorkspaceID:     deref(p.WorkspaceID),
+				DestinationType: deref(p.LogAnalyticsDestinationType),
+				Categories:      []string{},
+				target:          id,
+			}
+			if s.DestinationType == "" {
+				s.DestinationType = AzureDiagnostics
+			}
+			for _, l := range p.Logs {
+				if l == nil || !deref(l.Enabled) {
+					continue
+				}
+				if c := deref(l.Category); c != "" {
+					s.Categories = append(s.Categories, c)
+				} else if g := deref(l.CategoryGroup); g != "" {
+					s.Categories = append(s.Categories, g)
+				}
+			}
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func readRule(item *armmonitor.ScheduledQueryRuleResource) Rule {
+	rule := Rule{Name: deref(item.Name), Scopes: []string{}, Tables: []string{}}
+	p := item.Properties
+	for _, s := range p.Scopes {
+		if s != nil && *s != "" {
+			rule.Scopes = append(rule.Scopes, *s)
+		}
+	}
+	if p.Criteria == nil {
+		return rule
+	}
+	seen := map[string]bool{}
+	for _, c := range p.Criteria.AllOf {
+		if c == nil {
+			continue
+		}
+		for _, t := range kql.Tables(deref(c.Query)) {
+			if !seen[t] {
+				seen[t] = true
+				rule.Tables = append(rule.Tables, t)
+			}
+		}
+	}
+	return rule
+}
+
+// ─── Helpers ─────────────────────────────────────────────────────────────────
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/diagnostics"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Vault diagnostics ─────────────────────────────────────────────────
+
+// TestDiagnosticsAgainstFake seeds the fake backend from the plan and checks
+// that both vaults send every backup log category to the workspace in
+// resource-specific mode, and that the alert queries read only tables those
+// settings feed. It then drops a category and switches a setting to the
+// AzureDiagnostics table, and checks each is reported, along with the alert
+// query left without its table.
+func TestDiagnosticsAgainstFake(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "vault diagnostics feed every table the backup alert queries read", "Sprint 3")
+
+	fake := seededFake(t)
+	rg := fake.ResourceGroup
+	vaultIDs := map[string]string{
+		diagnostics.RecoveryServices: fake.VaultID,
+		diagnostics.DataProtection:   fake.Attr("azurerm_data_protection_backup_vault.disk_vault", "id"),
+	}
+	vaultSetting := fake.Attr("azurerm_monitor_diagnostic_setting.vault_diagnostics", "id")
+	diskSetting := fake.Attr("azurerm_monitor_diagnostic_setting.disk_vault_diagnostics", "id")
+
+	cfg := diagnostics.Config{SubscriptionID: fake.SubscriptionID(), Credential: fake.Credential(), Options: fake.ClientOptions()}
+	findings := func() []string {
+		report, err := diagnostics.Check(t.Context(), cfg, vaultIDs, rg)
+		require.NoError(t, err)
+		out := []string{}
+		for _, f := range report.Findings {
+			out = append(out, f.String())
+		}
+		return out
+	}
+	// edit changes the properties of a seeded diagnostic setting.
+	edit := func(id string, fn func(props map[string]interface{})) {
+		doc, ok := fake.Get(id)
+		require.True(t, ok, "%s is not seeded", id)
+		fn(doc["properties"].(map[string]interface{}))
+		fake.Put(id, doc)
+	}
+
+	// ── As planned ────────────────────────────────────────────────────────
+	report, err := diagnostics.Check(t.Context(), cfg, vaultIDs, rg)
+	require.NoError(t, err)
+	assert.Len(t, report.Settings, 2)
+	for _, s := range report.Settings {
+		assert.Equal(t, fake.Attr("azurerm_log_analytics_workspace.backup[0]", "id"), s.WorkspaceID, "%s sends to the module's workspace", s.Name)
+		assert.Equal(t, diagnostics.Dedicated, s.DestinationType, s.Name)
+	}
+	tables := map[string][]string{}
+	for _, r := range report.Rules {
+		tables[r.Name] = r.Tables
+	}
+	assert.Equal(t, map[string][]string{
+		"alert-backup-job-failure":        {"AddonAzureBackupJobs"},
+		"alert-backup-not-run-24h":        {"CoreAzureBackup"},
+		"alert-backup-protection-stopped": {"CoreAzureBackup"},
+		"alert-restore-job-failure":       {"AddonAzureBackupJobs"},
+	}, tables)
+	assert.Empty(t, findings())
+
+	// ── Categories ────────────────────────────────────────────────────────
+	edit(vaultSetting, func(props map[string]interface{}) {
+		var logs []interface{}
+		for _, l := range props["logs"].([]interface{}) {
+			if l.(map[string]interface{})["category"] != "AddonAzureBackupJobs" {
+				logs = append(logs, l)
+			}
+		}
+		props["logs"] = logs
+	})
+	assert.Equal(t, []string{
+		"recovery_services vault: log category AddonAzureBackupJobs is not sent, want sent to Log Analytics",
+	}, findings(), "the disk vault still feeds AddonAzureBackupJobs, so the job failure alert keeps its table")
+
+	edit(vaultSetting, func(props map[string]interface{}) {
+		props["logs"] = []interface{}{map[string]interface{}{"categoryGroup": "allLogs", "enabled": true}}
+	})
+	assert.Empty(t, findings(), "the allLogs group sends every category")
+
+	// ── Destination table mode ────────────────────────────────────────────
+	for _, id := range []string{vaultSetting, diskSetting} {
+		edit(id, func(props map[string]interface{}) { delete(props, "logAnalyticsDestinationType") })
+	}
+	assert.Equal(t, []string{
+		"data_protection vault setting diag-dpbv-to-law: destination table mode is AzureDiagnostics, want Dedicated",
+		"recovery_services vault setting diag-rsv-to-law: destination table mode is AzureDiagnostics, want Dedicated",
+		"rule alert-backup-job-failure: table AddonAzureBackupJobs is not fed into its scope, want fed by a vault diagnostic setting",
+		"rule alert-backup-not-run-24h: table CoreAzureBackup is not fed into its scope, want fed by a vault diagnostic setting",
+		"rule alert-backup-protection-stopped: table CoreAzureBackup is not fed into its scope, want fed by a vault diagnostic setting",
+		"rule alert-restore-job-failure: table AddonAzureBackupJobs is not fed into its scope, want fed by a vault diagnostic setting",
+	}, findings())
+}
@@ -0,0 +1,288 @@
+// Package drift compares the backup module's Terraform state with what is
+// deployed in Azure, so edits made in the portal are caught before the next
+// apply silently reverts them.
+//
+// Detect reads every resource the state records for the Recovery Services and
+// Data Protection vaults, their VM and disk backup policies, the protected
+// VMs and the alert rules, fetches the live resource through the SDK and
+// reports each field whose live value no longer matches the state. Fields the
+// state leaves unset are not compared. Backup policies are compared through
+// package policyspec, so retention and schedules are checked the same way the
+// tests check them against the policy spec.
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Config selects the subscription the deployment lives in.
+type Config struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+
+	// Now returns the current time; time.Now when nil.
+	Now func() time.Time
+}
+
+func (c Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Drift is one field whose live value differs from the state.
+type Drift struct {
+	Address string `json:"address"`
+	ID      string `json:"id"`
+	Field   string `json:"field"`
+	State   string `json:"state"`
+	Live    string `json:"live"`
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: %s is %s in Azure, %s in state", d.Address, d.Field, d.Live, d.State)
+}
+
+// Report is the outcome of one drift check.
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Checked lists the addresses of the resources compared, in state order.
+	Checked []string `json:"checked"`
+	Drifts  []Drift  `json:"drifts"`
+}
+
+// HasDrift reports whether any resource has drifted.
+func (r *Report) HasDrift() bool {
+	return len(r.Drifts) > 0
+}
+
+// Values that stand in for a whole resource in a Drift.
+const (
+	fieldResource = "resource"
+	present       = "present"
+	absent        = "absent"
+)
+
+// ReadState parses `terraform show -json` output for a state.
+func ReadState(r io.Reader) (*tfjson.State, error) {
+	var state tfjson.State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, fmt.Errorf("drift: parsing state: %w", err)
+	}
+	if state.Values == nil || state.Values.RootModule == nil {
+		return nil, errors.New("drift: state has no resources; is this `terraform show -json` output for an applied state?")
+	}
+	return &state, nil
+}
+
+// ReadStateFile is ReadState for a file.
+func ReadStateFile(path string) (*tfjson.State, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadState(f)
+}
+
+// Detect compares every resource in state that drift knows how to read with
+// its live counterpart.
+func Detect(ctx context.Context, cfg Config, state *tfjson.State) (*Report, error) {
+	c, err := newClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+	resources := managed(state.Values.RootModule)
+
+	report := &Report{CheckedAt: cfg.now().UTC(), Drifts: []Drift{}}
+	for _, r := range resources {
+		check, ok := checkers[r.Type]
+		if !ok {
+			continue
+		}
+		drifts, err := check(ctx, c, r)
+		if err != nil {
+			return nil, fmt.Errorf("drift: %s: %w", r.Address, err)
+		}
+		report.Checked = append(report.Checked, r.Address)
+		report.Drifts = append(report.Drifts, drifts...)
+	}
+
+	// Protected VMs are compared per vault, so items protected outside
+	// Terraform show up too.
+	for _, r := range resources {
+		if r.Type != "azurerm_recovery_services_vault" {
+			continue
+		}
+		drifts, checked, err := protectedItems(ctx, c, r, resources)
+		if err != nil {
+			return nil, fmt.Errorf("drift: protected items of %s: %w", r.Address, err)
+		}
+		report.Checked = append(report.Checked, checked...)
+		report.Drifts = append(report.Drifts, drifts...)
+	}
+	return report, nil
+}
+
+func managed(m *tfjson.StateModule) []*tfjson.StateResource {
+	var out []*tfjson.StateResource
+	for _, r := range m.Resources {
+		if r.Mode == tfjson.ManagedResourceMode {
+			out = append(out, r)
+		}
+	}
+	for _, child := range m.ChildModules {
+		out = append(out, managed(child)...)
+	}
+	return out
+}
+
+// ─── Comparison ──────────────────────────────────────────────────────────────
+
+// fields is a resource flattened to comparable strings, keyed by the
+// Terraform attribute name.
+type fields map[string]string
+
+// compare reports the fields of want that got does not match. Fields want
+// leaves empty are unset in state and skipped.
+func compare(r *tfjson.StateResource, want, got fields) []Drift {
+	keys := make([]string, 0, len(want))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out []Drift
+	for _, k := range keys {
+		if want[k] == "" || want[k] == got[k] {
+			continue
+		}
+		out = append(out, Drift{Address: r.Address, ID: str(r.AttributeValues, "id"), Field: k, State: want[k], Live: orUnset(got[k])})
+	}
+	return out
+}
+
+// missing is the drift of a resource deleted outside Terraform.
+func missing(r *tfjson.StateResource) []Drift {
+	return []Drift{{Address: r.Address, ID: str(r.AttributeValues, "id"), Field: fieldResource, State: present, Live: absent}}
+}
+
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return "unset"
+	}
+	return s
+}
+
+// str formats a state attribute; lists of scalars become a sorted,
+// comma-separated set.
+func str(a map[string]interface{}, key string) string {
+	return format(a[key])
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, format(item))
+		}
+		return set(items)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// set renders a list whose order does not matter. ARM IDs differ in case
+// between Terraform and the API, so items are lower-cased.
+func set(items []string) string {
+	out := make([]string, 0, len(items))
+	for _, s := range items {
+		if s != "" {
+			out = append(out, strings.ToLower(s))
+		}
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+func blocks(a map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := a[key].([]interface{})
+	var out []map[string]interface{}
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// ─── Reports ─────────────────────────────────────────────────────────────────
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the report as a Markdown table, one row per drifted
+// field.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Backup configuration drift\n\n")
+	fmt.Fprintf(&b, "Checked %d resources against Azure at %s.\n\n", len(r.Checked), r.CheckedAt.Format(time.RFC3339))
+	if !r.HasDrift() {
+		b.WriteString("No drift: every checked resource matches the Terraform state.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	b.WriteString("| Resource | Field | State | Azure |\n")
+	b.WriteString("|---|---|---|---|\n")
+	for _, d := range r.Drifts {
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", d.Address, mdEscape(d.Field), mdEscape(d.State), mdEscape(d.Live))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func mdEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
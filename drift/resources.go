@@ -0,0 +1,406 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor/armmonitor"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+	tfjson "github.com/hashicorp/terraform-json"
+
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+)
+
+type clients struct {
+	vaults       *armrecoveryservices.VaultsClient
+	policies     *armrecoveryservicesbackup.ProtectionPoliciesClient
+	items        *armrecoveryservicesbackup.BackupProtectedItemsClient
+	dpVaults     *armdataprotection.BackupVaultsClient
+	dpPolicies   *armdataprotection.BackupPoliciesClient
+	metricAlerts *armmonitor.MetricAlertsClient
+	queryRules   *armmonitor.ScheduledQueryRulesClient
+}
+
+func newClients(cfg Config) (*clients, error) {
+	var (
+		c   clients
+		err error
+	)
+	sub, cred, opts := cfg.SubscriptionID, cfg.Credential, cfg.Options
+	if c.vaults, err = armrecoveryservices.NewVaultsClient(sub, cred, opts); err != nil {
+		return nil, err
+	}
+	if c.policies, err = armrecoveryservicesbackup.NewProtectionPoliciesClient(sub, cred, opts); err != nil {
+		return nil, err
+	}
+	if c.items, err = armrecoveryservicesbackup.NewBackupProtectedItemsClient(sub, cred, opts); err != nil {
+		return nil, err
+	}
+	if c.dpVaults, err = armdataprotection.NewBackupVaultsClient(sub, cred, opts); err != nil {
+		return nil, err
+	}
+	if c.dpPolicies, err = armdataprotection.NewBackupPoliciesClient(sub, cred, opts); err != nil {
+		return nil, err
+	}
+	if c.metricAlerts, err = armmonitor.NewMetricAlertsClient(sub, cred, opts); err != nil {
+		return nil, err
+	}
+	if c.queryRules, err = armmonitor.NewScheduledQueryRulesClient(sub, cred, opts); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// checker compares one state resource with its live counterpart.
+type checker func(ctx context.Context, c *clients, r *tfjson.StateResource) ([]Drift, error)
+
+var checkers = map[string]checker{
+	"azurerm_recovery_services_vault":                checkVault,
+	"azurerm_backup_policy_vm":                       checkVMPolicy,
+	"azurerm_data_protection_backup_vault":           checkDiskVault,
+	"azurerm_data_protection_backup_policy_disk":     checkDiskPolicy,
+	"azurerm_monitor_metric_alert":                   checkMetricAlert,
+	"azurerm_monitor_scheduled_query_rules_alert_v2": checkQueryRule,
+}
+
+// ─── Vaults ──────────────────────────────────────────────────────────────────
+
+func checkVault(ctx context.Context, c *clients, r *tfjson.StateResource) ([]Drift, error) {
+	a := r.AttributeValues
+	resp, err := c.vaults.Get(ctx, str(a, "resource_group_name"), str(a, "name"), nil)
+	if isNotFound(err) {
+		return missing(r), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	live := fields{}
+	if resp.SKU != nil {
+		live["sku"] = string(deref(resp.SKU.Name))
+	}
+	if p := resp.Properties; p != nil {
+		live["public_network_access_enabled"] = strconv.FormatBool(deref(p.PublicNetworkAccess) == armrecoveryservices.PublicNetworkAccessEnabled)
+		if rs := p.RedundancySettings; rs != nil {
+			live["storage_mode_type"] = string(deref(rs.StandardTierStorageRedundancy))
+			live["cross_region_restore_enabled"] = strconv.FormatBool(deref(rs.CrossRegionRestore) == armrecoveryservices.CrossRegionRestoreEnabled)
+		}
+		live["immutability"] = "Disabled"
+		if ss := p.SecuritySettings; ss != nil {
+			if sd := ss.SoftDeleteSettings; sd != nil {
+				state := deref(sd.SoftDeleteState)
+				live["soft_delete_enabled"] = strconv.FormatBool(state == armrecoveryservices.SoftDeleteStateEnabled || state == armrecoveryservices.SoftDeleteStateAlwaysON)
+			}
+			if im := ss.ImmutabilitySettings; im != nil && im.State != nil {
+				live["immutability"] = string(*im.State)
+			}
+		}
+	}
+
+	want := fields{}
+	for _, k := range []string{"sku", "storage_mode_type", "cross_region_restore_enabled", "soft_delete_enabled", "immutability", "public_network_access_enabled"} {
+		want[k] = str(a, k)
+	}
+	return compare(r, want, live), nil
+}
+
+func checkDiskVault(ctx context.Context, c *clients, r *tfjson.StateResource) ([]Drift, error) {
+	a := r.AttributeValues
+	resp, err := c.dpVaults.Get(ctx, str(a, "resource_group_name"), str(a, "name"), nil)
+	if isNotFound(err) {
+		return missing(r), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	live := fields{}
+	if p := resp.Properties; p != nil && len(p.StorageSettings) > 0 && p.StorageSettings[0] != nil {
+		live["datastore_type"] = string(deref(p.StorageSettings[0].DatastoreType))
+		live["redundancy"] = string(deref(p.StorageSettings[0].Type))
+	}
+	want := fields{
+		"datastore_type": str(a, "datastore_type"),
+		"redundancy":     str(a, "redundancy"),
+	}
+	return compare(r, want, live), nil
+}
+
+// ─── Backup policies ─────────────────────────────────────────────────────────
+
+func checkVMPolicy(ctx context.Context, c *clients, r *tfjson.StateResource) ([]Drift, error) {
+	a := r.AttributeValues
+	resp, err := c.policies.Get(ctx, str(a, "recovery_vault_name"), str(a, "resource_group_name"), str(a, "name"), nil)
+	if isNotFound(err) {
+		return missing(r), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	props, ok := resp.Properties.(*armrecoveryservicesbackup.AzureIaaSVMProtectionPolicy)
+	if !ok {
+		return nil, fmt.Errorf("properties are %T, want an Azure VM policy", resp.Properties)
+	}
+	got, err := policyspec.FromARM(str(a, "name"), props)
+	if err != nil {
+		return nil, err
+	}
+	return deviations(r, policyspec.Diff(policyspec.FromPlan(r.Address, a), got)), nil
+}
+
+func checkDiskPolicy(ctx context.Context, c *clients, r *tfjson.StateResource) ([]Drift, error) {
+	a := r.AttributeValues
+	vault, err := arm.ParseResourceID(str(a, "vault_id"))
+	if err != nil {
+		return nil, fmt.Errorf("vault_id: %w", err)
+	}
+	resp, err := c.dpPolicies.Get(ctx, vault.ResourceGroupName, vault.Name, str(a, "name"), nil)
+	if isNotFound(err) {
+		return missing(r), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	props, ok := resp.Properties.(*armdataprotection.BackupPolicy)
+	if !ok {
+		return nil, fmt.Errorf("properties are %T, want a BackupPolicy", resp.Properties)
+	}
+
+	want, err := policyspec.DiskFromPlan(r.Address, a)
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	var drifts []Drift
+	got, err := policyspec.DiskFromARM(want.Name, props)
+	if err != nil {
+		drifts = append(drifts, Drift{Address: r.Address, ID: str(a, "id"), Field: "schedule", State: want.Schedule.Every, Live: err.Error()})
+	}
+	return append(drifts, deviations(r, policyspec.DiffDisk(want, got))...), nil
+}
+
+// deviations turns a policy comparison of state (want) against Azure (got)
+// into drifts.
+func deviations(r *tfjson.StateResource, devs []policyspec.Deviation) []Drift {
+	var out []Drift
+	for _, d := range devs {
+		out = append(out, Drift{Address: r.Address, ID: str(r.AttributeValues, "id"), Field: d.Field, State: d.Want, Live: d.Got})
+	}
+	return out
+}
+
+// ─── Protected VMs ───────────────────────────────────────────────────────────
+
+// protectedItems compares the VMs the state protects in vault with the
+// vault's live protected items. Items protected outside Terraform are
+// reported against the vault; soft-deleted ones are left to the vault's
+// soft-delete retention.
+func protectedItems(ctx context.Context, c *clients, vault *tfjson.StateResource, resources []*tfjson.StateResource) ([]Drift, []string, error) {
+	va := vault.AttributeValues
+	live := map[string]*armrecoveryservicesbackup.ProtectedItemResource{}
+	pager := c.items.NewListPager(str(va, "name"), str(va, "resource_group_name"), nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if isNotFound(err) {
+			// The vault itself is gone, which checkVault reports.
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, item := range page.Value {
+			if item.Properties == nil {
+				continue
+			}
+			live[strings.ToLower(deref(item.Properties.GetProtectedItem().SourceResourceID))] = item
+		}
+	}
+
+	var (
+		drifts  []Drift
+		checked []string
+		managed = map[string]bool{}
+	)
+	for _, r := range resources {
+		a := r.AttributeValues
+		if r.Type != "azurerm_backup_protected_vm" ||
+			!strings.EqualFold(str(a, "recovery_vault_name"), str(va, "name")) ||
+			!strings.EqualFold(str(a, "resource_group_name"), str(va, "resource_group_name")) {
+			continue
+		}
+		checked = append(checked, r.Address)
+		vm := strings.ToLower(str(a, "source_vm_id"))
+		managed[vm] = true
+
+		item, ok := live[vm]
+		if !ok {
+			drifts = append(drifts, missing(r)...)
+			continue
+		}
+		base := item.Properties.GetProtectedItem()
+		if deref(base.IsScheduledForDeferredDelete) {
+			drifts = append(drifts, Drift{Address: r.Address, ID: str(a, "id"), Field: fieldResource, State: present, Live: "soft-deleted"})
+			continue
+		}
+		got := fields{"backup_policy_id": strings.ToLower(deref(base.PolicyID))}
+		if vmItem, ok := item.Properties.(armrecoveryservicesbackup.AzureIaaSVMProtectedItemClassification); ok {
+			got["protection_state"] = string(deref(vmItem.GetAzureIaaSVMProtectedItem().ProtectionState))
+		}
+		want := fields{
+			"backup_policy_id": strings.ToLower(str(a, "backup_policy_id")),
+			"protection_state": str(a, "protection_state"),
+		}
+		drifts = append(drifts, compare(r, want, got)...)
+	}
+
+	for vm, item := range live {
+		base := item.Properties.GetProtectedItem()
+		if managed[vm] || deref(base.IsScheduledForDeferredDelete) {
+			continue
+		}
+		drifts = append(drifts, Drift{
+			Address: vault.Address, ID: deref(item.ID), Field: "protected_item",
+			State: absent, Live: deref(base.SourceResourceID) + " (" + deref(base.PolicyName) + ")",
+		})
+	}
+	return drifts, checked, nil
+}
+
+// ─── Alert rules ─────────────────────────────────────────────────────────────
+
+func checkMetricAlert(ctx context.Context, c *clients, r *tfjson.StateResource) ([]Drift, error) {
+	a := r.AttributeValues
+	resp, err := c.metricAlerts.Get(ctx, str(a, "resource_group_name"), str(a, "name"), nil)
+	if isNotFound(err) {
+		return missing(r), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	want := fields{
+		"enabled":       str(a, "enabled"),
+		"severity":      str(a, "severity"),
+		"frequency":     str(a, "frequency"),
+		"window_size":   str(a, "window_size"),
+		"auto_mitigate": str(a, "auto_mitigate"),
+		"scopes":        str(a, "scopes"),
+	}
+	var actions []string
+	for _, ac := range blocks(a, "action") {
+		actions = append(actions, str(ac, "action_group_id"))
+	}
+	want["action.action_group_id"] = set(actions)
+	for i, cr := range blocks(a, "criteria") {
+		for _, k := range []string{"metric_namespace", "metric_name", "aggregation", "operator", "threshold"} {
+			want[fmt.Sprintf("criteria[%d].%s", i, k)] = str(cr, k)
+		}
+	}
+
+	live := fields{}
+	if p := resp.Properties; p != nil {
+		live["enabled"] = strconv.FormatBool(deref(p.Enabled))
+		live["severity"] = strconv.Itoa(int(deref(p.Severity)))
+		live["frequency"] = deref(p.EvaluationFrequency)
+		live["window_size"] = deref(p.WindowSize)
+		live["auto_mitigate"] = strconv.FormatBool(deref(p.AutoMitigate))
+		live["scopes"] = set(derefAll(p.Scopes))
+		actions = nil
+		for _, ac := range p.Actions {
+			if ac != nil {
+				actions = append(actions, deref(ac.ActionGroupID))
+			}
+		}
+		live["action.action_group_id"] = set(actions)
+		// Terraform's criteria blocks are single-resource criteria; any
+		// other kind leaves the live criteria unset.
+		if crit, ok := p.Criteria.(*armmonitor.MetricAlertSingleResourceMultipleMetricCriteria); ok {
+			for i, cr := range crit.AllOf {
+				if cr == nil {
+					continue
+				}
+				prefix := fmt.Sprintf("criteria[%d].", i)
+				live[prefix+"metric_namespace"] = deref(cr.MetricNamespace)
+				live[prefix+"metric_name"] = deref(cr.MetricName)
+				live[prefix+"aggregation"] = string(deref(cr.TimeAggregation))
+				live[prefix+"operator"] = string(deref(cr.Operator))
+				live[prefix+"threshold"] = format(deref(cr.Threshold))
+			}
+		}
+	}
+	return compare(r, want, live), nil
+}
+
+func checkQueryRule(ctx context.Context, c *clients, r *tfjson.StateResource) ([]Drift, error) {
+	a := r.AttributeValues
+	resp, err := c.queryRules.Get(ctx, str(a, "resource_group_name"), str(a, "name"), nil)
+	if isNotFound(err) {
+		return missing(r), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	want := fields{
+		"enabled":                 str(a, "enabled"),
+		"severity":                str(a, "severity"),
+		"evaluation_frequency":    str(a, "evaluation_frequency"),
+		"window_duration":         str(a, "window_duration"),
+		"auto_mitigation_enabled": str(a, "auto_mitigation_enabled"),
+		"scopes":                  str(a, "scopes"),
+	}
+	if ac := blocks(a, "action"); len(ac) > 0 {
+		want["action.action_groups"] = str(ac[0], "action_groups")
+	}
+	for i, cr := range blocks(a, "criteria") {
+		prefix := fmt.Sprintf("criteria[%d].", i)
+		want[prefix+"query"] = query(str(cr, "query"))
+		for _, k := range []string{"operator", "threshold", "time_aggregation_method", "metric_measure_column"} {
+			want[prefix+k] = str(cr, k)
+		}
+	}
+
+	live := fields{}
+	if p := resp.Properties; p != nil {
+		live["enabled"] = strconv.FormatBool(deref(p.Enabled))
+		live["severity"] = strconv.FormatInt(int64(deref(p.Severity)), 10)
+		live["evaluation_frequency"] = deref(p.EvaluationFrequency)
+		live["window_duration"] = deref(p.WindowSize)
+		live["auto_mitigation_enabled"] = strconv.FormatBool(deref(p.AutoMitigate))
+		live["scopes"] = set(derefAll(p.Scopes))
+		if p.Actions != nil {
+			live["action.action_groups"] = set(derefAll(p.Actions.ActionGroups))
+		}
+		if p.Criteria != nil {
+			for i, cr := range p.Criteria.AllOf {
+				if cr == nil {
+					continue
+				}
+				prefix := fmt.Sprintf("criteria[%d].", i)
+				live[prefix+"query"] = query(deref(cr.Query))
+				live[prefix+"operator"] = string(deref(cr.Operator))
+				live[prefix+"threshold"] = format(deref(cr.Threshold))
+				live[prefix+"time_aggregation_method"] = string(deref(cr.TimeAggregation))
+				live[prefix+"metric_measure_column"] = deref(cr.MetricMeasureColumn)
+			}
+		}
+	}
+	return compare(r, want, live), nil
+}
+
+// query collapses whitespace, which the portal and heredocs change freely.
+func query(q string) string {
+	return strings.Join(strings.Fields(q), " ")
+}
+
+func derefAll(in []*string) []string {
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		out = append(out, deref(s))
+	}
+	return out
+}
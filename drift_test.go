@@ -0,0 +1,139 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/drift"
+	"github.com/SwastikaAryal/azure_terraform/fakearm"
+)
+
+// ─── Test: Drift report ──────────────────────────────────────────────────────
+
+// TestDriftAgainstFake seeds the fake backend from an applied state, checks
+// that an untouched deployment reports no drift, then makes the kind of
+// edits people make in the portal and checks each is reported at field level.
+func TestDriftAgainstFake(t *testing.T) {
+	t.Parallel()
+
+	const statePath = "testdata/backup_state.json"
+	state, err := drift.ReadStateFile(statePath)
+	require.NoError(t, err)
+
+	fake := fakearm.New("")
+	require.NoError(t, fake.SeedFile(statePath))
+	cfg := drift.Config{
+		SubscriptionID: fake.SubscriptionID(),
+		Credential:     fake.Credential(),
+		Options:        fake.ClientOptions(),
+		Now:            func() time.Time { return time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC) },
+	}
+
+	report, err := drift.Detect(t.Context(), cfg, state)
+	require.NoError(t, err)
+	assert.False(t, report.HasDrift(), "a deployment matching its state has no drift: %v", report.Drifts)
+	assert.Contains(t, report.Checked, "azurerm_recovery_services_vault.main")
+	assert.Contains(t, report.Checked, "azurerm_data_protection_backup_policy_disk.os_disk")
+	assert.Contains(t, report.Checked, "azurerm_monitor_metric_alert.vault_health")
+	assert.Len(t, report.Checked, 12, "two vaults, four policies, five alert rules and one protected VM")
+
+	var md bytes.Buffer
+	require.NoError(t, report.WriteMarkdown(&md))
+	assert.Contains(t, md.String(), "No drift")
+
+	// ── Portal edits ──────────────────────────────────────────────────────
+	id := func(address string) string {
+		v, ok := fake.Attribute(address, "id")
+		require.True(t, ok, "%s is not in the state", address)
+		return fmt.Sprintf("%v", v)
+	}
+	edit := func(address string, fn func(props map[string]interface{})) {
+		doc, ok := fake.Get(id(address))
+		require.True(t, ok)
+		fn(doc["properties"].(map[string]interface{}))
+		fake.Put(id(address), doc)
+	}
+	vaultID := id("azurerm_recovery_services_vault.main")
+	enhancedID := id("azurerm_backup_policy_vm.enhanced")
+
+	edit("azurerm_recovery_services_vault.main", func(p map[string]interface{}) {
+		p["redundancySettings"].(map[string]interface{})["crossRegionRestore"] = "Disabled"
+	})
+	edit("azurerm_backup_policy_vm.standard", func(p map[string]interface{}) {
+		daily := p["retentionPolicy"].(map[string]interface{})["dailySchedule"].(map[string]interface{})
+		daily["retentionDuration"].(map[string]interface{})["count"] = 14
+	})
+	edit("azurerm_data_protection_backup_policy_disk.os_disk", func(p map[string]interface{}) {
+		rules := p["policyRules"].([]interface{})
+		lifecycle := rules[1].(map[string]interface{})["lifecycles"].([]interface{})[0].(map[string]interface{})
+		lifecycle["deleteAfter"].(map[string]interface{})["duration"] = "P3D"
+	})
+	edit("azurerm_monitor_metric_alert.vault_health", func(p map[string]interface{}) {
+		p["severity"] = 3
+		p["actions"] = []interface{}{}
+	})
+	require.True(t, fake.Delete(id("azurerm_monitor_scheduled_query_rules_alert_v2.backup_stale")))
+
+	// The app VM moved to the enhanced policy, and a VM protected by hand.
+	protected := `azurerm_backup_protected_vm.app_vms["/subscriptions/` + fake.SubscriptionID() +
+		`/resourceGroups/rg-app/providers/Microsoft.Compute/virtualMachines/vm-app-0"]`
+	edit(protected, func(p map[string]interface{}) { p["policyId"] = enhancedID })
+	manual := fmt.Sprintf("/subscriptions/%s/resourceGroups/rg-app/providers/Microsoft.Compute/virtualMachines/vm-app-9", fake.SubscriptionID())
+	fake.Put(vaultID+"/backupFabrics/Azure/protectionContainers/IaasVMContainer;iaasvmcontainerv2;rg-app;vm-app-9"+
+		"/protectedItems/VM;iaasvmcontainerv2;rg-app;vm-app-9", map[string]interface{}{"properties": map[string]interface{}{
+		"protectedItemType": "Microsoft.Compute/virtualMachines",
+		"sourceResourceId":  manual,
+		"policyName":        "bkpol-adhoc",
+		"protectionState":   "Protected",
+	}})
+
+	report, err = drift.Detect(t.Context(), cfg, state)
+	require.NoError(t, err)
+	require.True(t, report.HasDrift())
+
+	got := map[string]drift.Drift{}
+	for _, d := range report.Drifts {
+		t.Log(d)
+		got[d.Address+" "+d.Field] = d
+	}
+	want := []drift.Drift{
+		{Address: "azurerm_recovery_services_vault.main", Field: "cross_region_restore_enabled", State: "true", Live: "false"},
+		{Address: "azurerm_backup_policy_vm.standard", Field: "retention.daily.count", State: "30", Live: "14"},
+		{Address: "azurerm_data_protection_backup_policy_disk.os_disk", Field: "retention.default", State: "P7D", Live: "P3D"},
+		{Address: "azurerm_monitor_metric_alert.vault_health", Field: "severity", State: "1", Live: "3"},
+		{Address: "azurerm_monitor_scheduled_query_rules_alert_v2.backup_stale", Field: "resource", State: "present", Live: "absent"},
+		{Address: protected, Field: "backup_policy_id", State: strings.ToLower(id("azurerm_backup_policy_vm.standard")), Live: strings.ToLower(enhancedID)},
+		{Address: "azurerm_recovery_services_vault.main", Field: "protected_item", State: "absent", Live: manual + " (bkpol-adhoc)"},
+	}
+	for _, w := range want {
+		d, ok := got[w.Address+" "+w.Field]
+		if assert.True(t, ok, "no drift reported for %s %s", w.Address, w.Field) {
+			assert.Equal(t, w.State, d.State, "%s %s in state", w.Address, w.Field)
+			assert.Equal(t, w.Live, d.Live, "%s %s in Azure", w.Address, w.Field)
+		}
+	}
+	d, ok := got["azurerm_monitor_metric_alert.vault_health action.action_group_id"]
+	if assert.True(t, ok, "the detached action group is reported") {
+		assert.Equal(t, "unset", d.Live)
+	}
+	assert.Len(t, report.Drifts, len(want)+1, "only the edited fields drift")
+
+	// ── Reports ───────────────────────────────────────────────────────────
+	var js bytes.Buffer
+	require.NoError(t, report.WriteJSON(&js))
+	var decoded drift.Report
+	require.NoError(t, json.Unmarshal(js.Bytes(), &decoded))
+	assert.Equal(t, report.Drifts, decoded.Drifts)
+	assert.Equal(t, "2026-10-18T09:00:00Z", decoded.CheckedAt.Format(time.RFC3339))
+
+	md.Reset()
+	require.NoError(t, report.WriteMarkdown(&md))
+	assert.Contains(t, md.String(), "| `azurerm_monitor_metric_alert.vault_health` | severity | 1 | 3 |")
+}
@@ -0,0 +1,346 @@
+package fakearm
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	roleAssignmentsSuffix = "/providers/microsoft.authorization/roleassignments"
+	roleDefinitionsSuffix = "/providers/microsoft.authorization/roledefinitions"
+	locksSuffix           = "/providers/microsoft.authorization/locks"
+	permissionsSuffix     = "/providers/microsoft.authorization/permissions"
+)
+
+var (
+	principalFilter = regexp.MustCompile(`(?i)principalId\s+eq\s+'([^']+)'`)
+	roleNameFilter  = regexp.MustCompile(`(?i)roleName\s+eq\s+'([^']+)'`)
+)
+
+// authorization models the Microsoft.Authorization reads that are not plain
+// children of their path:
+//
+//   - listing role assignments for a scope returns those at, above and below
+//     it, narrowed by a principalId eq '...' filter or to the scope and above
+//     by atScope();
+//   - built-in role definitions (see builtInRoles and builtInPermissions)
+//     exist at every scope and can be read by ID or listed with a
+//     roleName eq '...' filter;
+//   - listing management locks for a scope returns those at, above and
+//     below it;
+//   - the caller's permissions at any scope are those of Owner.
+//
+// It is called with s.mu held and reports whether it answered the request.
+func (s *Server) authorization(req *http.Request, path string) (*http.Response, bool) {
+	if req.Method != http.MethodGet {
+		return nil, false
+	}
+	lower := strings.ToLower(path)
+	filter := req.URL.Query().Get("$filter")
+
+	switch {
+	case strings.HasSuffix(lower, roleAssignmentsSuffix):
+		scope := strings.TrimSuffix(lower, roleAssignmentsSuffix)
+		var principal string
+		if m := principalFilter.FindStringSubmatch(filter); m != nil {
+			principal = m[1]
+		}
+		atScope := strings.Contains(strings.ToLower(filter), "atscope()")
+
+		var out []map[string]interface{}
+		for _, k := range s.sortedKeys() {
+			if !strings.HasSuffix(strings.ToLower(ResourceType(k)), "microsoft.authorization/roleassignments") {
+				continue
+			}
+			doc := s.resources[k]
+			props, _ := doc["properties"].(map[string]interface{})
+			assigned := strings.ToLower(str(props, "scope"))
+			if principal != "" && !strings.EqualFold(str(props, "principalId"), principal) {
+				continue
+			}
+			above, below := within(assigned, scope), within(scope, assigned)
+			if !above && (atScope || !below) {
+				continue
+			}
+			out = append(out, deepCopy(doc))
+		}
+		return JSONResponse(req, http.StatusOK, map[string]interface{}{"value": out}), true
+
+	case strings.HasSuffix(lower, roleDefinitionsSuffix):
+		scope := path[:len(path)-len(roleDefinitionsSuffix)]
+		names := make([]string, 0, len(builtInRoles))
+		for name := range builtInRoles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var out []map[string]interface{}
+		for _, name := range names {
+			if m := roleNameFilter.FindStringSubmatch(filter); m != nil && !strings.EqualFold(m[1], name) {
+				continue
+			}
+			out = append(out, builtInRoleDefinition(scope, name))
+		}
+		return JSONResponse(req, http.StatusOK, map[string]interface{}{"value": out}), true
+
+	case strings.HasSuffix(lower, locksSuffix):
+		scope := strings.TrimSuffix(lower, locksSuffix)
+		var out []map[string]interface{}
+		for _, k := range s.sortedKeys() {
+			if i := strings.LastIndex(k, locksSuffix+"/"); i >= 0 && (within(k[:i], scope) || within(scope, k[:i])) {
+				out = append(out, deepCopy(s.resources[k]))
+			}
+		}
+		return JSONResponse(req, http.StatusOK, map[string]interface{}{"value": out}), true
+
+	case strings.HasSuffix(lower, permissionsSuffix):
+		owner := builtInPermissions["Owner"]
+		return JSONResponse(req, http.StatusOK, map[string]interface{}{"value": []interface{}{map[string]interface{}{
+			"actions":     list(owner.actions),
+			"notActions":  list(owner.notActions),
+			"dataActions": list(owner.dataActions),
+		}}}), true
+
+	case strings.Contains(lower, roleDefinitionsSuffix+"/"):
+		if _, ok := s.resources[lower]; ok {
+			// A custom role stored by a test.
+			return nil, false
+		}
+		i := strings.LastIndex(lower, roleDefinitionsSuffix+"/")
+		guid := lower[i+len(roleDefinitionsSuffix)+1:]
+		for name, id := range builtInRoles {
+			if id == guid {
+				return JSONResponse(req, http.StatusOK, builtInRoleDefinition(path[:i], name)), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// scopeLocked refuses, as ARM does with ScopeLocked, a write below a
+// ReadOnly lock and a delete at, above or below any lock. The locks
+// themselves stay writable so that they can be removed.
+//
+// It is called with s.mu held and reports whether it answered the request.
+func (s *Server) scopeLocked(req *http.Request, path string) (*http.Response, bool) {
+	lower := strings.ToLower(path)
+	if req.Method == http.MethodGet || strings.Contains(lower, locksSuffix+"/") {
+		return nil, false
+	}
+	for _, k := range s.sortedKeys() {
+		i := strings.LastIndex(k, locksSuffix+"/")
+		if i < 0 {
+			continue
+		}
+		props, _ := s.resources[k]["properties"].(map[string]interface{})
+		level, scope := str(props, "level"), k[:i]
+		var refused bool
+		switch req.Method {
+		case http.MethodDelete:
+			refused = within(scope, lower) || within(lower, scope)
+		case http.MethodPut, http.MethodPatch, http.MethodPost:
+			refused = strings.EqualFold(level, "ReadOnly") && within(scope, lower)
+		}
+		if refused {
+			operation := "write"
+			if req.Method == http.MethodDelete {
+				operation = "delete"
+			}
+			return ErrorResponse(req, http.StatusConflict, "ScopeLocked",
+				fmt.Sprintf("The scope '%s' cannot perform %s operation because following scope(s) are locked: '%s'. Please remove the lock and try again.",
+					path, operation, s.resources[k]["id"])), true
+		}
+	}
+	return nil, false
+}
+
+// within reports whether inner is scope itself or a scope below it.
+func within(scope, inner string) bool {
+	scope, inner = strings.ToLower(strings.TrimSuffix(scope, "/")), strings.ToLower(strings.TrimSuffix(inner, "/"))
+	return inner == scope || strings.HasPrefix(inner, scope+"/")
+}
+
+func builtInRoleDefinition(scope, name string) map[string]interface{} {
+	id := BuiltInRoleID(name)
+	return map[string]interface{}{
+		"id":   scope + "/providers/Microsoft.Authorization/roleDefinitions/" + id,
+		"name": id,
+		"type": "Microsoft.Authorization/roleDefinitions",
+		"properties": map[string]interface{}{
+			"roleName":         name,
+			"type":             "BuiltInRole",
+			"assignableScopes": []interface{}{"/"},
+			"permissions": []interface{}{map[string]interface{}{
+				"actions":     list(builtInPermissions[name].actions),
+				"notActions":  list(builtInPermissions[name].notActions),
+				"dataActions": list(builtInPermissions[name].dataActions),
+			}},
+		},
+	}
+}
+
+func list(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// builtInPermissions are the control-plane and data actions of the built-in
+// roles in builtInRoles, as published by Azure, less those that only concern
+// other services (Support, DevTestLab and the like).
+var builtInPermissions = map[string]struct{ actions, notActions, dataActions []string }{
+	"Owner": {actions: []string{"*"}},
+	"Contributor": {
+		actions: []string{"*"},
+		notActions: []string{
+			"Microsoft.Authorization/*/Delete",
+			"Microsoft.Authorization/*/Write",
+			"Microsoft.Authorization/elevateAccess/Action",
+			"Microsoft.Blueprint/blueprintAssignments/write",
+			"Microsoft.Blueprint/blueprintAssignments/delete",
+			"Microsoft.Compute/galleries/share/action",
+		},
+	},
+	"Reader": {actions: []string{"*/read"}},
+	"Backup Contributor": {actions: []string{
+		"Microsoft.Authorization/*/read",
+		"Microsoft.Network/virtualNetworks/read",
+		"Microsoft.RecoveryServices/locations/*",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/operationResults/*",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/*",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/refreshContainers/action",
+		"Microsoft.RecoveryServices/Vaults/backupJobs/*",
+		"Microsoft.RecoveryServices/Vaults/backupJobsExport/action",
+		"Microsoft.RecoveryServices/Vaults/backupOperationResults/*",
+		"Microsoft.RecoveryServices/Vaults/backupPolicies/*",
+		"Microsoft.RecoveryServices/Vaults/backupProtectableItems/*",
+		"Microsoft.RecoveryServices/Vaults/backupProtectedItems/*",
+		"Microsoft.RecoveryServices/Vaults/backupProtectionContainers/*",
+		"Microsoft.RecoveryServices/Vaults/backupUsageSummaries/*",
+		"Microsoft.RecoveryServices/Vaults/certificates/*",
+		"Microsoft.RecoveryServices/Vaults/extendedInformation/*",
+		"Microsoft.RecoveryServices/Vaults/monitoringAlerts/read",
+		"Microsoft.RecoveryServices/Vaults/monitoringConfigurations/*",
+		"Microsoft.RecoveryServices/Vaults/read",
+		"Microsoft.RecoveryServices/Vaults/registeredIdentities/*",
+		"Microsoft.RecoveryServices/Vaults/usages/*",
+		"Microsoft.Resources/deployments/*",
+		"Microsoft.Resources/subscriptions/resourceGroups/read",
+		"Microsoft.Storage/storageAccounts/read",
+		"Microsoft.RecoveryServices/Vaults/backupstorageconfig/*",
+		"Microsoft.RecoveryServices/Vaults/backupconfig/*",
+		"Microsoft.RecoveryServices/Vaults/backupValidateOperation/action",
+		"Microsoft.RecoveryServices/Vaults/write",
+		"Microsoft.RecoveryServices/Vaults/backupOperations/read",
+		"Microsoft.RecoveryServices/Vaults/backupEngines/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/backupProtectionIntent/*",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectableContainers/read",
+		"Microsoft.RecoveryServices/locations/backupStatus/action",
+		"Microsoft.RecoveryServices/locations/backupPreValidateProtection/action",
+		"Microsoft.RecoveryServices/locations/backupValidateFeatures/action",
+	}},
+	"Backup Operator": {actions: []string{
+		"Microsoft.Authorization/*/read",
+		"Microsoft.Network/virtualNetworks/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/*/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/recoveryPoints/restore/action",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/recoveryPoints/provisionInstantItemRecovery/action",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/recoveryPoints/revokeInstantItemRecovery/action",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/backup/action",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/read",
+		"Microsoft.RecoveryServices/Vaults/backupJobs/*",
+		"Microsoft.RecoveryServices/Vaults/backupPolicies/read",
+		"Microsoft.RecoveryServices/Vaults/read",
+		"Microsoft.Resources/deployments/*",
+		"Microsoft.Resources/subscriptions/resourceGroups/read",
+		"Microsoft.Storage/storageAccounts/read",
+	}},
+	"Backup Reader": {actions: []string{
+		"Microsoft.Authorization/*/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/*/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/read",
+		"Microsoft.RecoveryServices/Vaults/backupJobs/read",
+		"Microsoft.RecoveryServices/Vaults/backupPolicies/read",
+		"Microsoft.RecoveryServices/Vaults/read",
+		"Microsoft.Resources/subscriptions/resourceGroups/read",
+	}},
+	"Virtual Machine Contributor": {actions: []string{
+		"Microsoft.Authorization/*/read",
+		"Microsoft.Compute/availabilitySets/*",
+		"Microsoft.Compute/locations/*",
+		"Microsoft.Compute/virtualMachines/*",
+		"Microsoft.Compute/virtualMachineScaleSets/*",
+		"Microsoft.Compute/disks/write",
+		"Microsoft.Compute/disks/read",
+		"Microsoft.Compute/disks/delete",
+		"Microsoft.Insights/alertRules/*",
+		"Microsoft.Network/applicationGateways/backendAddressPools/join/action",
+		"Microsoft.Network/loadBalancers/backendAddressPools/join/action",
+		"Microsoft.Network/loadBalancers/read",
+		"Microsoft.Network/locations/*",
+		"Microsoft.Network/networkInterfaces/*",
+		"Microsoft.Network/networkSecurityGroups/join/action",
+		"Microsoft.Network/networkSecurityGroups/read",
+		"Microsoft.Network/publicIPAddresses/join/action",
+		"Microsoft.Network/publicIPAddresses/read",
+		"Microsoft.Network/virtualNetworks/read",
+		"Microsoft.Network/virtualNetworks/subnets/join/action",
+		"Microsoft.RecoveryServices/locations/*",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/backupProtectionIntent/write",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/*/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/read",
+		"Microsoft.RecoveryServices/Vaults/backupFabrics/protectionContainers/protectedItems/write",
+		"Microsoft.RecoveryServices/Vaults/backupPolicies/read",
+		"Microsoft.RecoveryServices/Vaults/backupPolicies/write",
+		"Microsoft.RecoveryServices/Vaults/read",
+		"Microsoft.RecoveryServices/Vaults/usages/read",
+		"Microsoft.RecoveryServices/Vaults/write",
+		"Microsoft.Resources/deployments/*",
+		"Microsoft.Resources/subscriptions/resourceGroups/read",
+		"Microsoft.Storage/storageAccounts/listKeys/action",
+		"Microsoft.Storage/storageAccounts/read",
+	}},
+	"Disk Snapshot Contributor": {actions: []string{
+		"Microsoft.Authorization/*/read",
+		"Microsoft.Compute/snapshots/delete",
+		"Microsoft.Compute/snapshots/write",
+		"Microsoft.Compute/snapshots/read",
+		"Microsoft.Compute/snapshots/beginGetAccess/action",
+		"Microsoft.Compute/snapshots/endGetAccess/action",
+		"Microsoft.Compute/disks/beginGetAccess/action",
+		"Microsoft.Resources/subscriptions/resourceGroups/read",
+		"Microsoft.Storage/storageAccounts/listkeys/action",
+		"Microsoft.Storage/storageAccounts/write",
+		"Microsoft.Storage/storageAccounts/read",
+		"Microsoft.Storage/storageAccounts/delete",
+	}},
+	"Disk Backup Reader": {actions: []string{
+		"Microsoft.Authorization/*/read",
+		"Microsoft.Compute/disks/read",
+		"Microsoft.Compute/disks/beginGetAccess/action",
+	}},
+	"Disk Restore Operator": {actions: []string{
+		"Microsoft.Authorization/*/read",
+		"Microsoft.Compute/disks/write",
+		"Microsoft.Compute/disks/read",
+		"Microsoft.Resources/subscriptions/resourceGroups/read",
+	}},
+	"Key Vault Crypto Service Encryption User": {
+		actions: []string{
+			"Microsoft.EventGrid/eventSubscriptions/write",
+			"Microsoft.EventGrid/eventSubscriptions/read",
+			"Microsoft.EventGrid/eventSubscriptions/delete",
+		},
+		dataActions: []string{
+			"Microsoft.KeyVault/vaults/keys/read",
+			"Microsoft.KeyVault/vaults/keys/wrap/action",
+			"Microsoft.KeyVault/vaults/keys/unwrap/action",
+		},
+	},
+}
@@ -0,0 +1,199 @@
+package fakearm
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// softDeleteRetention is how long ARM keeps a soft-deleted backup item.
+const softDeleteRetention = 14 * 24 * time.Hour
+
+// resourceGuardProxyName is the only name a vault's Resource Guard proxy can
+// have.
+const resourceGuardProxyName = "VaultProxy"
+
+// errImmutableVault is the code the fake refuses operations on an immutable
+// vault with.
+const errImmutableVault = "UserErrorOperationNotAllowedOnImmutableVault"
+
+// recoveryServices models the soft-delete and immutability behaviour of
+// backup protected items and policies, which the generic verbs cannot
+// express:
+//
+//   - while the vault is immutable (Unlocked or Locked), DELETE of a
+//     protected item (stop protection with delete data) and a policy PUT
+//     that shortens or drops a retention tier are refused;
+//   - DELETE of a protected item while its vault has soft delete on only
+//     marks it isScheduledForDeferredDelete; deleting it again is refused.
+//   - PUT with isRehydrate undeletes a soft-deleted item in place.
+//
+// It is called with s.mu held and reports whether it answered the request.
+func (s *Server) recoveryServices(req *http.Request, path string) (*http.Response, bool) {
+	resourceType := strings.ToLower(ResourceType(path))
+	if strings.HasSuffix(resourceType, "microsoft.recoveryservices/vaults/backuppolicies") && req.Method == http.MethodPut {
+		return s.backupPolicyPut(req, path)
+	}
+	if !strings.HasSuffix(resourceType, "/protecteditems") {
+		return nil, false
+	}
+	item, ok := s.resources[strings.ToLower(path)]
+	if !ok {
+		return nil, false
+	}
+	props, _ := item["properties"].(map[string]interface{})
+	if props == nil {
+		props = map[string]interface{}{}
+		item["properties"] = props
+	}
+	deferred, _ := props["isScheduledForDeferredDelete"].(bool)
+
+	switch req.Method {
+	case http.MethodDelete:
+		if s.immutable(vaultOf(path)) {
+			return ErrorResponse(req, http.StatusBadRequest, errImmutableVault,
+				"Stop protection with delete data is not allowed while the vault is immutable."), true
+		}
+		if !s.softDeleteEnabled(vaultOf(path)) {
+			return nil, false
+		}
+		if deferred {
+			return ErrorResponse(req, http.StatusBadRequest, "UserErrorBackupItemAlreadySoftDeleted",
+				fmt.Sprintf("'%s' is already soft-deleted.", path)), true
+		}
+		props["isScheduledForDeferredDelete"] = true
+		props["deferredDeleteTimeInUTC"] = time.Now().UTC().Add(softDeleteRetention).Format(time.RFC3339)
+		props["protectionState"] = "ProtectionStopped"
+		return emptyResponse(req, http.StatusOK), true
+
+	case http.MethodPut:
+		body, err := readBody(req)
+		if err != nil {
+			return ErrorResponse(req, http.StatusBadRequest, "InvalidRequestContent", err.Error()), true
+		}
+		bodyProps, _ := body["properties"].(map[string]interface{})
+		if rehydrate, _ := bodyProps["isRehydrate"].(bool); !rehydrate {
+			return nil, false
+		}
+		if !deferred {
+			return ErrorResponse(req, http.StatusBadRequest, "UserErrorBackupItemNotSoftDeleted",
+				fmt.Sprintf("'%s' is not soft-deleted.", path)), true
+		}
+		delete(props, "isScheduledForDeferredDelete")
+		delete(props, "deferredDeleteTimeInUTC")
+		props["protectionState"] = "ProtectionStopped"
+		return JSONResponse(req, http.StatusOK, item), true
+	}
+	return nil, false
+}
+
+// backupPolicyPut refuses a policy update that reduces retention in an
+// immutable vault.
+func (s *Server) backupPolicyPut(req *http.Request, path string) (*http.Response, bool) {
+	existing, ok := s.resources[strings.ToLower(path)]
+	if !ok || !s.immutable(vaultOf(path)) {
+		return nil, false
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return ErrorResponse(req, http.StatusBadRequest, "InvalidRequestContent", err.Error()), true
+	}
+	oldProps, _ := existing["properties"].(map[string]interface{})
+	newProps, _ := body["properties"].(map[string]interface{})
+	oldRetention, _ := oldProps["retentionPolicy"].(map[string]interface{})
+	newRetention, _ := newProps["retentionPolicy"].(map[string]interface{})
+	for _, tier := range []string{"dailySchedule", "weeklySchedule", "monthlySchedule", "yearlySchedule"} {
+		before, ok := retentionCount(oldRetention, tier)
+		if !ok {
+			continue
+		}
+		if after, ok := retentionCount(newRetention, tier); !ok || after < before {
+			return ErrorResponse(req, http.StatusBadRequest, errImmutableVault,
+				fmt.Sprintf("Retention of %s cannot be reduced while the vault is immutable.", tier)), true
+		}
+	}
+	return nil, false
+}
+
+func retentionCount(retention map[string]interface{}, tier string) (float64, bool) {
+	schedule, ok := retention[tier].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	duration, _ := schedule["retentionDuration"].(map[string]interface{})
+	count, ok := duration["count"].(float64)
+	if !ok {
+		// Seeded documents hold ints; requests decode to float64.
+		n, isInt := duration["count"].(int)
+		return float64(n), isInt
+	}
+	return count, true
+}
+
+// immutable reports whether the vault with the given ID has immutability
+// enabled, locked or not.
+func (s *Server) immutable(vaultID string) bool {
+	vault, ok := s.resources[strings.ToLower(vaultID)]
+	if !ok {
+		return false
+	}
+	props, _ := vault["properties"].(map[string]interface{})
+	security, _ := props["securitySettings"].(map[string]interface{})
+	settings, _ := security["immutabilitySettings"].(map[string]interface{})
+	state, _ := settings["state"].(string)
+	return strings.EqualFold(state, "Unlocked") || strings.EqualFold(state, "Locked")
+}
+
+// ResourceGuardProxy is the document linking a vault to a Resource Guard,
+// stored at id (<vault>/backupResourceGuardProxies/VaultProxy), listing the
+// critical operations the guard protects.
+func ResourceGuardProxy(id, resourceGuardID string) map[string]interface{} {
+	var ops []interface{}
+	for _, op := range []string{
+		"Microsoft.RecoveryServices/vaults/backupconfig/write",
+		"Microsoft.RecoveryServices/vaults/backupPolicies/write",
+		"Microsoft.RecoveryServices/vaults/backupFabrics/protectionContainers/protectedItems/delete",
+		"Microsoft.RecoveryServices/vaults/backupResourceGuardProxies/delete",
+		"Microsoft.RecoveryServices/vaults/write#reduceImmutabilityState",
+	} {
+		ops = append(ops, map[string]interface{}{
+			"vaultCriticalOperation": op,
+			"defaultResourceRequest": resourceGuardID + "/" + op,
+		})
+	}
+	return map[string]interface{}{
+		"id":   id,
+		"name": resourceGuardProxyName,
+		"type": "Microsoft.RecoveryServices/vaults/backupResourceGuardProxies",
+		"properties": map[string]interface{}{
+			"resourceGuardResourceId":       resourceGuardID,
+			"resourceGuardOperationDetails": ops,
+		},
+	}
+}
+
+// softDeleteEnabled reports the soft-delete state of the vault with the given
+// ID, as set through its securitySettings.
+func (s *Server) softDeleteEnabled(vaultID string) bool {
+	vault, ok := s.resources[strings.ToLower(vaultID)]
+	if !ok {
+		return false
+	}
+	props, _ := vault["properties"].(map[string]interface{})
+	security, _ := props["securitySettings"].(map[string]interface{})
+	settings, _ := security["softDeleteSettings"].(map[string]interface{})
+	state, _ := settings["softDeleteState"].(string)
+	return strings.EqualFold(state, "Enabled") || strings.EqualFold(state, "AlwaysON")
+}
+
+// vaultOf returns the ID of the Recovery Services vault that path lives in.
+func vaultOf(path string) string {
+	segs := segments(path)
+	for i := 0; i+1 < len(segs); i++ {
+		if strings.EqualFold(segs[i], "vaults") {
+			return "/" + strings.Join(segs[:i+2], "/")
+		}
+	}
+	return ""
+}
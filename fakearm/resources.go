@@ -0,0 +1,665 @@
+package fakearm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// idBuilder synthesises the ARM ID of a planned resource from its attributes,
+// returning "" while an attribute it needs is still unknown.
+type idBuilder func(s *Server, attrs map[string]interface{}) string
+
+// converter renders the ARM documents for one Terraform resource. Most
+// resources produce a single document; some also produce children.
+type converter func(s *Server, id string, attrs map[string]interface{}) []map[string]interface{}
+
+var idBuilders = map[string]idBuilder{
+	"azurerm_resource_group": func(s *Server, a map[string]interface{}) string {
+		return join("/subscriptions", s.subscriptionID, "resourceGroups", str(a, "name"))
+	},
+	"azurerm_recovery_services_vault":                rgScoped("Microsoft.RecoveryServices/vaults"),
+	"azurerm_data_protection_backup_vault":           rgScoped("Microsoft.DataProtection/backupVaults"),
+	"azurerm_automation_account":                     rgScoped("Microsoft.Automation/automationAccounts"),
+	"azurerm_log_analytics_workspace":                rgScoped("Microsoft.OperationalInsights/workspaces"),
+	"azurerm_monitor_action_group":                   rgScoped("Microsoft.Insights/actionGroups"),
+	"azurerm_monitor_metric_alert":                   rgScoped("Microsoft.Insights/metricAlerts"),
+	"azurerm_monitor_scheduled_query_rules_alert_v2": rgScoped("Microsoft.Insights/scheduledQueryRules"),
+	"azurerm_key_vault":                              rgScoped("Microsoft.KeyVault/vaults"),
+	"azurerm_data_protection_backup_policy_disk":     childOf("vault_id", "backupPolicies"),
+	"azurerm_data_protection_backup_instance_disk":   childOf("vault_id", "backupInstances"),
+	"azurerm_monitor_diagnostic_setting":             extensionOf("target_resource_id", "Microsoft.Insights/diagnosticSettings"),
+	"azurerm_management_lock":                        extensionOf("scope", "Microsoft.Authorization/locks"),
+	"azurerm_role_assignment":                        extensionOf("scope", "Microsoft.Authorization/roleAssignments"),
+	"azurerm_backup_policy_vm": func(s *Server, a map[string]interface{}) string {
+		if str(a, "recovery_vault_name") == "" || str(a, "resource_group_name") == "" {
+			return ""
+		}
+		return join("/subscriptions", s.subscriptionID, "resourceGroups", str(a, "resource_group_name"),
+			"providers/Microsoft.RecoveryServices/vaults", str(a, "recovery_vault_name"),
+			"backupPolicies", str(a, "name"))
+	},
+	"azurerm_backup_protected_vm": func(s *Server, a map[string]interface{}) string {
+		vm := segments(str(a, "source_vm_id"))
+		if len(vm) < 4 || str(a, "recovery_vault_name") == "" || str(a, "resource_group_name") == "" {
+			return ""
+		}
+		// Containers and items are named after the VM's resource group and name.
+		name := "iaasvmcontainerv2;" + vm[3] + ";" + vm[len(vm)-1]
+		return join("/subscriptions", s.subscriptionID, "resourceGroups", str(a, "resource_group_name"),
+			"providers/Microsoft.RecoveryServices/vaults", str(a, "recovery_vault_name"),
+			"backupFabrics/Azure/protectionContainers", "IaasVMContainer;"+name, "protectedItems", "VM;"+name)
+	},
+	"azurerm_recovery_services_vault_resource_guard_association": func(s *Server, a map[string]interface{}) string {
+		if str(a, "vault_id") == "" {
+			return ""
+		}
+		return join(str(a, "vault_id"), "backupResourceGuardProxies", resourceGuardProxyName)
+	},
+	"azurerm_automation_runbook": func(s *Server, a map[string]interface{}) string {
+		if str(a, "automation_account_name") == "" || str(a, "resource_group_name") == "" {
+			return ""
+		}
+		return join("/subscriptions", s.subscriptionID, "resourceGroups", str(a, "resource_group_name"),
+			"providers/Microsoft.Automation/automationAccounts", str(a, "automation_account_name"),
+			"runbooks", str(a, "name"))
+	},
+}
+
+// rgScoped builds IDs for top-level resources in a resource group.
+func rgScoped(resourceType string) idBuilder {
+	return func(s *Server, a map[string]interface{}) string {
+		if str(a, "resource_group_name") == "" || str(a, "name") == "" {
+			return ""
+		}
+		return join("/subscriptions", s.subscriptionID, "resourceGroups", str(a, "resource_group_name"),
+			"providers", resourceType, str(a, "name"))
+	}
+}
+
+// childOf builds IDs for resources nested under the resource in parentAttr.
+func childOf(parentAttr, childType string) idBuilder {
+	return func(s *Server, a map[string]interface{}) string {
+		if str(a, parentAttr) == "" || str(a, "name") == "" {
+			return ""
+		}
+		return join(str(a, parentAttr), childType, str(a, "name"))
+	}
+}
+
+// extensionOf builds IDs for extension resources attached to scopeAttr.
+func extensionOf(scopeAttr, extensionType string) idBuilder {
+	return func(s *Server, a map[string]interface{}) string {
+		if str(a, scopeAttr) == "" || str(a, "name") == "" {
+			return ""
+		}
+		return join(str(a, scopeAttr), "providers", extensionType, str(a, "name"))
+	}
+}
+
+var converters = map[string]converter{
+	"azurerm_resource_group": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return one(id, a, map[string]interface{}{"provisioningState": "Succeeded"})
+	},
+
+	"azurerm_recovery_services_vault": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		security := map[string]interface{}{
+			"softDeleteSettings": map[string]interface{}{
+				"softDeleteState":                 enabled(boolean(a, "soft_delete_enabled", true)),
+				"softDeleteRetentionPeriodInDays": 14,
+			},
+		}
+		if v := str(a, "immutability"); v != "" {
+			security["immutabilitySettings"] = map[string]interface{}{"state": v}
+		}
+		security["multiUserAuthorization"] = "Disabled"
+		if _, ok := s.resources[strings.ToLower(join(id, "backupResourceGuardProxies", resourceGuardProxyName))]; ok {
+			security["multiUserAuthorization"] = "Enabled"
+		}
+		props := map[string]interface{}{
+			"provisioningState": "Succeeded",
+			"securitySettings":  security,
+			"redundancySettings": map[string]interface{}{
+				"crossRegionRestore":            enabled(boolean(a, "cross_region_restore_enabled", false)),
+				"standardTierStorageRedundancy": strOr(a, "storage_mode_type", "GeoRedundant"),
+			},
+			"publicNetworkAccess": enabled(boolean(a, "public_network_access_enabled", true)),
+		}
+		if e := first(a, "encryption"); e != nil {
+			kek := map[string]interface{}{"useSystemAssignedIdentity": true}
+			if !boolean(e, "use_system_assigned_identity", true) {
+				kek = map[string]interface{}{"userAssignedIdentity": str(e, "user_assigned_identity_id")}
+			}
+			props["encryption"] = map[string]interface{}{
+				"keyVaultProperties":       map[string]interface{}{"keyUri": str(e, "key_id")},
+				"kekIdentity":              kek,
+				"infrastructureEncryption": enabled(boolean(e, "infrastructure_encryption_enabled", false)),
+			}
+		}
+		doc := one(id, a, props)
+		doc[0]["sku"] = map[string]interface{}{"name": strOr(a, "sku", "Standard")}
+		// New vaults have enhanced security on; it cannot be set through
+		// Terraform.
+		return append(doc, map[string]interface{}{
+			"id":   id + "/backupconfig/vaultconfig",
+			"name": "vaultconfig",
+			"properties": map[string]interface{}{
+				"enhancedSecurityState":            "Enabled",
+				"softDeleteFeatureState":           enabled(boolean(a, "soft_delete_enabled", true)),
+				"isSoftDeleteFeatureStateEditable": true,
+			},
+		})
+	},
+
+	"azurerm_recovery_services_vault_resource_guard_association": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		if vault, ok := s.resources[strings.ToLower(str(a, "vault_id"))]; ok {
+			props, _ := vault["properties"].(map[string]interface{})
+			if security, ok := props["securitySettings"].(map[string]interface{}); ok {
+				security["multiUserAuthorization"] = "Enabled"
+			}
+		}
+		return []map[string]interface{}{ResourceGuardProxy(id, str(a, "resource_guard_id"))}
+	},
+
+	"azurerm_backup_policy_vm": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return []map[string]interface{}{{
+			"id":         id,
+			"name":       str(a, "name"),
+			"properties": backupPolicyVMProperties(a),
+		}}
+	},
+
+	"azurerm_backup_protected_vm": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return []map[string]interface{}{{
+			"id":   id,
+			"name": lastSegment(id),
+			"properties": map[string]interface{}{
+				"protectedItemType": "Microsoft.Compute/virtualMachines",
+				"workloadType":      "VM",
+				"friendlyName":      lastSegment(str(a, "source_vm_id")),
+				"sourceResourceId":  str(a, "source_vm_id"),
+				"policyId":          str(a, "backup_policy_id"),
+				"protectionState":   strOr(a, "protection_state", "Protected"),
+			},
+		}}
+	},
+
+	"azurerm_data_protection_backup_vault": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		doc := one(id, a, map[string]interface{}{
+			"provisioningState": "Succeeded",
+			"storageSettings": []interface{}{map[string]interface{}{
+				"datastoreType": strOr(a, "datastore_type", "VaultStore"),
+				"type":          strOr(a, "redundancy", "LocallyRedundant"),
+			}},
+		})
+		return doc
+	},
+
+	"azurerm_data_protection_backup_policy_disk": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return []map[string]interface{}{{
+			"id":         id,
+			"name":       str(a, "name"),
+			"properties": diskPolicyProperties(a),
+		}}
+	},
+
+	"azurerm_automation_account": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return one(id, a, map[string]interface{}{
+			"sku":   map[string]interface{}{"name": strOr(a, "sku_name", "Basic")},
+			"state": "Ok",
+		})
+	},
+
+	"azurerm_automation_runbook": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return one(id, a, map[string]interface{}{
+			"runbookType": str(a, "runbook_type"),
+			"state":       "Published",
+			"logVerbose":  boolean(a, "log_verbose", false),
+			"logProgress": boolean(a, "log_progress", false),
+			"description": str(a, "description"),
+		})
+	},
+
+	"azurerm_log_analytics_workspace": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return one(id, a, map[string]interface{}{
+			"provisioningState": "Succeeded",
+			"sku":               map[string]interface{}{"name": strOr(a, "sku", "PerGB2018")},
+			"retentionInDays":   a["retention_in_days"],
+		})
+	},
+
+	"azurerm_monitor_action_group": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		var emails []interface{}
+		for _, r := range blocks(a, "email_receiver") {
+			emails = append(emails, map[string]interface{}{
+				"name":                 str(r, "name"),
+				"emailAddress":         str(r, "email_address"),
+				"useCommonAlertSchema": boolean(r, "use_common_alert_schema", false),
+				"status":               "Enabled",
+			})
+		}
+		var webhooks []interface{}
+		for _, r := range blocks(a, "webhook_receiver") {
+			webhooks = append(webhooks, map[string]interface{}{
+				"name":                 str(r, "name"),
+				"serviceUri":           str(r, "service_uri"),
+				"useCommonAlertSchema": boolean(r, "use_common_alert_schema", false),
+			})
+		}
+		doc := one(id, a, map[string]interface{}{
+			"groupShortName":   str(a, "short_name"),
+			"enabled":          boolean(a, "enabled", true),
+			"emailReceivers":   emails,
+			"webhookReceivers": webhooks,
+		})
+		doc[0]["location"] = "global"
+		return doc
+	},
+
+	"azurerm_monitor_metric_alert": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		var criteria []interface{}
+		for i, c := range blocks(a, "criteria") {
+			criteria = append(criteria, map[string]interface{}{
+				"criterionType":   "StaticThresholdCriterion",
+				"name":            fmt.Sprintf("Metric%d", i+1),
+				"metricNamespace": str(c, "metric_namespace"),
+				"metricName":      str(c, "metric_name"),
+				"timeAggregation": str(c, "aggregation"),
+				"operator":        str(c, "operator"),
+				"threshold":       c["threshold"],
+				"dimensions":      []interface{}{},
+			})
+		}
+		var actions []interface{}
+		for _, ac := range blocks(a, "action") {
+			actions = append(actions, map[string]interface{}{"actionGroupId": str(ac, "action_group_id")})
+		}
+		doc := one(id, a, map[string]interface{}{
+			"description":         str(a, "description"),
+			"severity":            a["severity"],
+			"enabled":             boolean(a, "enabled", true),
+			"scopes":              a["scopes"],
+			"evaluationFrequency": strOr(a, "frequency", "PT1M"),
+			"windowSize":          strOr(a, "window_size", "PT5M"),
+			"autoMitigate":        boolean(a, "auto_mitigate", true),
+			"criteria": map[string]interface{}{
+				"odata.type": "Microsoft.Azure.Monitor.SingleResourceMultipleMetricCriteria",
+				"allOf":      criteria,
+			},
+			"actions": actions,
+		})
+		doc[0]["location"] = "global"
+		return doc
+	},
+
+	"azurerm_monitor_scheduled_query_rules_alert_v2": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		var allOf []interface{}
+		for _, c := range blocks(a, "criteria") {
+			crit := map[string]interface{}{
+				"query":               str(c, "query"),
+				"timeAggregation":     str(c, "time_aggregation_method"),
+				"operator":            str(c, "operator"),
+				"threshold":           c["threshold"],
+				"metricMeasureColumn": str(c, "metric_measure_column"),
+			}
+			if fp := blocks(c, "failing_periods"); len(fp) == 1 {
+				crit["failingPeriods"] = map[string]interface{}{
+					"minFailingPeriodsToAlert":  fp[0]["minimum_failing_periods_to_trigger_alert"],
+					"numberOfEvaluationPeriods": fp[0]["number_of_evaluation_periods"],
+				}
+			}
+			var dims []interface{}
+			for _, d := range blocks(c, "dimension") {
+				dims = append(dims, map[string]interface{}{
+					"name": str(d, "name"), "operator": str(d, "operator"), "values": d["values"],
+				})
+			}
+			crit["dimensions"] = dims
+			allOf = append(allOf, crit)
+		}
+		actions := map[string]interface{}{}
+		if ac := blocks(a, "action"); len(ac) == 1 {
+			actions["actionGroups"] = ac[0]["action_groups"]
+			actions["customProperties"] = ac[0]["custom_properties"]
+		}
+		return one(id, a, map[string]interface{}{
+			"displayName":         str(a, "display_name"),
+			"description":         str(a, "description"),
+			"severity":            a["severity"],
+			"enabled":             boolean(a, "enabled", true),
+			"scopes":              a["scopes"],
+			"evaluationFrequency": str(a, "evaluation_frequency"),
+			"windowSize":          str(a, "window_duration"),
+			"autoMitigate":        boolean(a, "auto_mitigation_enabled", false),
+			"criteria":            map[string]interface{}{"allOf": allOf},
+			"actions":             actions,
+		})
+	},
+
+	"azurerm_monitor_diagnostic_setting": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		var logs []interface{}
+		for _, l := range blocks(a, "enabled_log") {
+			log := map[string]interface{}{"enabled": true}
+			if c := str(l, "category"); c != "" {
+				log["category"] = c
+			}
+			if g := str(l, "category_group"); g != "" {
+				log["categoryGroup"] = g
+			}
+			logs = append(logs, log)
+		}
+		var metrics []interface{}
+		for _, m := range blocks(a, "metric") {
+			metrics = append(metrics, map[string]interface{}{
+				"category": str(m, "category"),
+				"enabled":  boolean(m, "enabled", true),
+			})
+		}
+		props := map[string]interface{}{"logs": logs, "metrics": metrics}
+		if ws := str(a, "log_analytics_workspace_id"); ws != "" {
+			props["workspaceId"] = ws
+		}
+		// ARM leaves the destination type out unless it is Dedicated.
+		if t := str(a, "log_analytics_destination_type"); t != "" && t != "AzureDiagnostics" {
+			props["logAnalyticsDestinationType"] = t
+		}
+		return []map[string]interface{}{{"id": id, "name": str(a, "name"), "properties": props}}
+	},
+
+	"azurerm_role_assignment": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		defID := str(a, "role_definition_id")
+		roleName := str(a, "role_definition_name")
+		if defID == "" {
+			defID = join("/subscriptions", s.subscriptionID,
+				"providers/Microsoft.Authorization/roleDefinitions", BuiltInRoleID(roleName))
+		}
+		return []map[string]interface{}{{
+			"id":   id,
+			"name": str(a, "name"),
+			"properties": map[string]interface{}{
+				"scope":            str(a, "scope"),
+				"roleDefinitionId": defID,
+				"principalId":      str(a, "principal_id"),
+				"principalType":    strOr(a, "principal_type", "ServicePrincipal"),
+			},
+		}}
+	},
+
+	"azurerm_management_lock": func(s *Server, id string, a map[string]interface{}) []map[string]interface{} {
+		return []map[string]interface{}{{
+			"id":   id,
+			"name": str(a, "name"),
+			"properties": map[string]interface{}{
+				"level": str(a, "lock_level"),
+				"notes": str(a, "notes"),
+			},
+		}}
+	},
+}
+
+// ─── Recovery Services policy shapes ──────────────────────────────────────────
+
+// backupPolicyVMProperties renders azurerm_backup_policy_vm as an
+// AzureIaasVM protection policy.
+func backupPolicyVMProperties(a map[string]interface{}) map[string]interface{} {
+	policyType := strOr(a, "policy_type", "V1")
+	tz := strOr(a, "timezone", "UTC")
+	backup := first(a, "backup")
+	runTime := "2024-01-01T" + strOr(backup, "time", "00:00") + ":00Z"
+
+	schedule := map[string]interface{}{"scheduleRunFrequency": str(backup, "frequency")}
+	if policyType == "V2" {
+		schedule["schedulePolicyType"] = "SimpleSchedulePolicyV2"
+		switch str(backup, "frequency") {
+		case "Hourly":
+			schedule["hourlySchedule"] = map[string]interface{}{
+				"interval":                backup["hour_interval"],
+				"scheduleWindowStartTime": runTime,
+				"scheduleWindowDuration":  backup["hour_duration"],
+			}
+		case "Weekly":
+			schedule["weeklySchedule"] = map[string]interface{}{
+				"scheduleRunDays":  backup["weekdays"],
+				"scheduleRunTimes": []interface{}{runTime},
+			}
+		default:
+			schedule["dailySchedule"] = map[string]interface{}{"scheduleRunTimes": []interface{}{runTime}}
+		}
+	} else {
+		schedule["schedulePolicyType"] = "SimpleSchedulePolicy"
+		schedule["scheduleRunTimes"] = []interface{}{runTime}
+		if days, ok := backup["weekdays"]; ok && days != nil {
+			schedule["scheduleRunDays"] = days
+		}
+	}
+
+	retention := map[string]interface{}{"retentionPolicyType": "LongTermRetentionPolicy"}
+	times := []interface{}{runTime}
+	if r := first(a, "retention_daily"); r != nil {
+		retention["dailySchedule"] = map[string]interface{}{
+			"retentionTimes":    times,
+			"retentionDuration": map[string]interface{}{"count": r["count"], "durationType": "Days"},
+		}
+	}
+	if r := first(a, "retention_weekly"); r != nil {
+		retention["weeklySchedule"] = map[string]interface{}{
+			"daysOfTheWeek":     r["weekdays"],
+			"retentionTimes":    times,
+			"retentionDuration": map[string]interface{}{"count": r["count"], "durationType": "Weeks"},
+		}
+	}
+	if r := first(a, "retention_monthly"); r != nil {
+		retention["monthlySchedule"] = map[string]interface{}{
+			"retentionScheduleFormatType": "Weekly",
+			"retentionScheduleWeekly": map[string]interface{}{
+				"daysOfTheWeek":   r["weekdays"],
+				"weeksOfTheMonth": r["weeks"],
+			},
+			"retentionTimes":    times,
+			"retentionDuration": map[string]interface{}{"count": r["count"], "durationType": "Months"},
+		}
+	}
+	if r := first(a, "retention_yearly"); r != nil {
+		retention["yearlySchedule"] = map[string]interface{}{
+			"retentionScheduleFormatType": "Weekly",
+			"monthsOfYear":                r["months"],
+			"retentionScheduleWeekly": map[string]interface{}{
+				"daysOfTheWeek":   r["weekdays"],
+				"weeksOfTheMonth": r["weeks"],
+			},
+			"retentionTimes":    times,
+			"retentionDuration": map[string]interface{}{"count": r["count"], "durationType": "Years"},
+		}
+	}
+
+	props := map[string]interface{}{
+		"backupManagementType":          "AzureIaasVM",
+		"policyType":                    policyType,
+		"timeZone":                      tz,
+		"instantRpRetentionRangeInDays": a["instant_restore_retention_days"],
+		"schedulePolicy":                schedule,
+		"retentionPolicy":               retention,
+		"protectedItemsCount":           0,
+	}
+	return props
+}
+
+// ─── Data Protection policy shapes ────────────────────────────────────────────
+
+// diskPolicyProperties renders azurerm_data_protection_backup_policy_disk the
+// way the Data Protection RP returns it: one AzureBackupRule carrying the
+// schedule and one AzureRetentionRule per retention tier.
+func diskPolicyProperties(a map[string]interface{}) map[string]interface{} {
+	opStore := map[string]interface{}{"dataStoreType": "OperationalStore", "objectType": "DataStoreInfoBase"}
+
+	tagging := []interface{}{map[string]interface{}{
+		"isDefault":       true,
+		"taggingPriority": 99,
+		"tagInfo":         map[string]interface{}{"tagName": "Default"},
+	}}
+	rules := []interface{}{}
+
+	retentionRule := func(name string, isDefault bool, duration string) map[string]interface{} {
+		return map[string]interface{}{
+			"objectType": "AzureRetentionRule",
+			"name":       name,
+			"isDefault":  isDefault,
+			"lifecycles": []interface{}{map[string]interface{}{
+				"deleteAfter":                 map[string]interface{}{"objectType": "AbsoluteDeleteOption", "duration": duration},
+				"sourceDataStore":             opStore,
+				"targetDataStoreCopySettings": []interface{}{},
+			}},
+		}
+	}
+
+	rules = append(rules, retentionRule("Default", true, str(a, "default_retention_duration")))
+	for _, r := range blocks(a, "retention_rule") {
+		crit := first(r, "criteria")
+		tagging = append(tagging, map[string]interface{}{
+			"isDefault":       false,
+			"taggingPriority": r["priority"],
+			"tagInfo":         map[string]interface{}{"tagName": str(r, "name")},
+			"criteria": []interface{}{map[string]interface{}{
+				"objectType":       "ScheduleBasedBackupCriteria",
+				"absoluteCriteria": []interface{}{str(crit, "absolute_criteria")},
+			}},
+		})
+		rules = append(rules, retentionRule(str(r, "name"), false, str(r, "duration")))
+	}
+
+	backupRule := map[string]interface{}{
+		"objectType":       "AzureBackupRule",
+		"name":             "BackupIntervals",
+		"backupParameters": map[string]interface{}{"objectType": "AzureBackupParams", "backupType": "Incremental"},
+		"dataStore":        opStore,
+		"trigger": map[string]interface{}{
+			"objectType": "ScheduleBasedTriggerContext",
+			"schedule": map[string]interface{}{
+				"repeatingTimeIntervals": a["backup_repeating_time_intervals"],
+				"timeZone":               strOr(a, "time_zone", "UTC"),
+			},
+			"taggingCriteria": tagging,
+		},
+	}
+
+	return map[string]interface{}{
+		"objectType":      "BackupPolicy",
+		"datasourceTypes": []interface{}{"Microsoft.Compute/disks"},
+		"policyRules":     append([]interface{}{backupRule}, rules...),
+	}
+}
+
+// ─── Built-in roles ───────────────────────────────────────────────────────────
+
+// builtInRoles maps the built-in role names used by the module to their
+// well-known role definition GUIDs.
+var builtInRoles = map[string]string{
+	"Owner":                       "8e3af657-a8ff-443c-a75c-2fe8c4bcb635",
+	"Contributor":                 "b24988ac-6180-42a0-ab88-20f7382dd24c",
+	"Reader":                      "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+	"Backup Contributor":          "5e467623-bb1f-42f4-a55d-6e525e11384b",
+	"Backup Operator":             "00c29273-979b-4161-815c-10b084fb9324",
+	"Backup Reader":               "a795c7a0-d4a2-40c1-ae25-d81f01202912",
+	"Virtual Machine Contributor": "9980e02c-c2be-4d73-94e8-173b1dc7cf3c",
+	"Disk Snapshot Contributor":   "7efff54f-a5b4-42b5-a1c5-5411624893ce",
+	"Disk Backup Reader":          "3e5e47e6-65f7-47ef-90b5-e5dd4d455f24",
+	"Disk Restore Operator":       "b50d9833-a0cb-478e-945f-707fcc997c13",
+
+	"Key Vault Crypto Service Encryption User": "e147488a-f6f5-4113-8e2d-b22465e65bf6",
+}
+
+// BuiltInRoleID returns the role definition GUID for a built-in role name,
+// or a synthetic GUID for names the fake does not know.
+func BuiltInRoleID(name string) string {
+	if id, ok := builtInRoles[name]; ok {
+		return id
+	}
+	return syntheticGUID("role/" + name)
+}
+
+// ─── Attribute helpers ────────────────────────────────────────────────────────
+
+// one wraps the common tracked-resource envelope around props.
+func one(id string, a map[string]interface{}, props map[string]interface{}) []map[string]interface{} {
+	doc := map[string]interface{}{
+		"id":         id,
+		"name":       str(a, "name"),
+		"properties": props,
+	}
+	if loc := str(a, "location"); loc != "" {
+		doc["location"] = loc
+	}
+	if tags, ok := a["tags"].(map[string]interface{}); ok {
+		doc["tags"] = tags
+	}
+	if ident := first(a, "identity"); ident != nil {
+		identity := map[string]interface{}{
+			"type":        str(ident, "type"),
+			"principalId": str(ident, "principal_id"),
+			"tenantId":    str(ident, "tenant_id"),
+		}
+		if ids, _ := ident["identity_ids"].([]interface{}); len(ids) > 0 {
+			assigned := map[string]interface{}{}
+			for _, id := range ids {
+				assigned[fmt.Sprintf("%v", id)] = map[string]interface{}{}
+			}
+			identity["userAssignedIdentities"] = assigned
+		}
+		doc["identity"] = identity
+	}
+	return []map[string]interface{}{doc}
+}
+
+func join(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+func str(a map[string]interface{}, key string) string {
+	if a == nil {
+		return ""
+	}
+	if v, ok := a[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func strOr(a map[string]interface{}, key, def string) string {
+	if v := str(a, key); v != "" {
+		return v
+	}
+	return def
+}
+
+func boolean(a map[string]interface{}, key string, def bool) bool {
+	if v, ok := a[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+func enabled(b bool) string {
+	if b {
+		return "Enabled"
+	}
+	return "Disabled"
+}
+
+// blocks returns a nested block list attribute as maps.
+func blocks(a map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := a[key].([]interface{})
+	out := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// first returns the single element of a MaxItems=1 block, or nil.
+func first(a map[string]interface{}, key string) map[string]interface{} {
+	if b := blocks(a, key); len(b) > 0 {
+		return b[0]
+	}
+	return nil
+}
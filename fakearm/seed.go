@@ -0,0 +1,365 @@
+package fakearm
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// SeedFile seeds the fake from a file written by `terraform show -json`.
+func (s *Server) SeedFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return s.Seed(raw)
+}
+
+// Seed loads the managed resources described by `terraform show -json`
+// output, which may be either a saved plan or a state. Values that are only
+// known after apply (IDs, managed identity principals) are synthesised
+// deterministically and references between resources are resolved through
+// the plan configuration, so the seeded documents are self-consistent.
+func (s *Server) Seed(raw []byte) error {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return fmt.Errorf("fakearm: seed is not JSON: %w", err)
+	}
+
+	var (
+		values  *tfjson.StateValues
+		prior   *tfjson.StateValues
+		config  *tfjson.Config
+		unknown = map[string]interface{}{}
+	)
+	if _, ok := probe["planned_values"]; ok {
+		var plan tfjson.Plan
+		if err := json.Unmarshal(raw, &plan); err != nil {
+			return fmt.Errorf("fakearm: parsing plan: %w", err)
+		}
+		values, config = plan.PlannedValues, plan.Config
+		if plan.PriorState != nil {
+			prior = plan.PriorState.Values
+		}
+		for _, rc := range plan.ResourceChanges {
+			if rc.Change != nil {
+				unknown[rc.Address] = rc.Change.AfterUnknown
+			}
+		}
+		for name, v := range plan.Variables {
+			if v != nil {
+				s.variables[name] = v.Value
+			}
+		}
+	} else {
+		var state tfjson.State
+		if err := json.Unmarshal(raw, &state); err != nil {
+			return fmt.Errorf("fakearm: parsing state: %w", err)
+		}
+		values = state.Values
+	}
+	if values == nil || values.RootModule == nil {
+		return fmt.Errorf("fakearm: seed has no resources")
+	}
+
+	var resources []*tfjson.StateResource
+	collectResources(values.RootModule, &resources)
+	if prior != nil && prior.RootModule != nil {
+		var data []*tfjson.StateResource
+		collectResources(prior.RootModule, &data)
+		for _, r := range data {
+			if r.Mode == tfjson.DataResourceMode {
+				resources = append(resources, r)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range resources {
+		attrs := map[string]interface{}{}
+		for k, v := range r.AttributeValues {
+			attrs[k] = v
+		}
+		s.attrs[r.Address] = attrs
+		if r.Type == "azurerm_client_config" {
+			if sub, ok := attrs["subscription_id"].(string); ok && sub != "" {
+				s.subscriptionID = sub
+			}
+		}
+	}
+
+	s.resolve(resources, config, unknown)
+
+	for _, r := range resources {
+		if r.Mode == tfjson.DataResourceMode {
+			continue
+		}
+		conv, ok := converters[r.Type]
+		if !ok {
+			continue
+		}
+		attrs := s.attrs[r.Address]
+		id, _ := attrs["id"].(string)
+		if id == "" {
+			continue
+		}
+		for _, doc := range conv(s, id, attrs) {
+			s.put(fmt.Sprintf("%v", doc["id"]), doc)
+		}
+	}
+
+	if values.Outputs != nil {
+		for name, out := range values.Outputs {
+			if out != nil && out.Value != nil {
+				s.outputs[name] = out.Value
+			}
+		}
+	}
+	if config != nil && config.RootModule != nil {
+		for name, out := range config.RootModule.Outputs {
+			if _, ok := s.outputs[name]; ok || out.Expression == nil || out.Expression.ExpressionData == nil {
+				continue
+			}
+			for _, ref := range out.Expression.References {
+				if v, ok := s.lookupRef(ref); ok {
+					s.outputs[name] = v
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Outputs returns the Terraform outputs known from the seed.
+func (s *Server) Outputs() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]interface{}, len(s.outputs))
+	for k, v := range s.outputs {
+		out[k] = v
+	}
+	return out
+}
+
+// Variable returns an input variable recorded in a plan seed.
+func (s *Server) Variable(name string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.variables[name]
+	return v, ok
+}
+
+// Local declares how local.<name> resolves, which a plan does not record: to
+// the first of refs with a non-empty value, as a conditional in the module's
+// locals block would pick it. Declare locals before seeding.
+func (s *Server) Local(name string, refs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locals[name] = refs
+}
+
+// Attribute returns a (possibly synthesised) attribute of a seeded resource,
+// e.g. Attribute("azurerm_recovery_services_vault.main", "resource_group_name").
+func (s *Server) Attribute(address, path string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attrs, ok := s.attrs[address]
+	if !ok {
+		return nil, false
+	}
+	return lookupPath(attrs, path)
+}
+
+func collectResources(m *tfjson.StateModule, out *[]*tfjson.StateResource) {
+	*out = append(*out, m.Resources...)
+	for _, child := range m.ChildModules {
+		collectResources(child, out)
+	}
+}
+
+// resolve fills in unknown attributes: first from references in the plan
+// configuration, then by synthesising IDs and identities. It iterates until
+// nothing changes because IDs of child resources depend on their parents.
+// unknown holds each resource's after_unknown tree, which tells list-valued
+// attributes (scopes = [x.id]) apart from scalar ones.
+func (s *Server) resolve(resources []*tfjson.StateResource, config *tfjson.Config, unknown map[string]interface{}) {
+	exprs := map[string]map[string]*tfjson.Expression{}
+	if config != nil && config.RootModule != nil {
+		for _, cr := range config.RootModule.Resources {
+			exprs[cr.Address] = cr.Expressions
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, r := range resources {
+			attrs := s.attrs[r.Address]
+			shape, _ := unknown[r.Address].(map[string]interface{})
+			if s.resolveExpressions(attrs, exprs[configAddress(r.Address)], shape) {
+				changed = true
+			}
+			if r.Mode == tfjson.DataResourceMode {
+				continue
+			}
+			if ids, ok := idBuilders[r.Type]; ok && attrs["id"] == nil {
+				if id := ids(s, attrs); id != "" {
+					attrs["id"] = id
+					changed = true
+				}
+			}
+			if attrs["name"] == nil && r.Type == "azurerm_role_assignment" {
+				attrs["name"] = syntheticGUID(r.Address)
+				changed = true
+			}
+			if ident, ok := attrs["identity"].([]interface{}); ok && len(ident) == 1 {
+				if m, ok := ident[0].(map[string]interface{}); ok && m["principal_id"] == nil {
+					m["principal_id"] = syntheticGUID(r.Address + "/identity")
+					m["tenant_id"] = DefaultTenantID
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// resolveExpressions fills unknown attributes of one object (a resource or a
+// nested block) from the references of their configuration expressions.
+func (s *Server) resolveExpressions(attrs map[string]interface{}, exprs map[string]*tfjson.Expression, shape map[string]interface{}) bool {
+	changed := false
+	for name, expr := range exprs {
+		if expr == nil || expr.ExpressionData == nil {
+			continue
+		}
+		if len(expr.NestedBlocks) > 0 {
+			nested, _ := attrs[name].([]interface{})
+			nestedShape, _ := shape[name].([]interface{})
+			for i, block := range expr.NestedBlocks {
+				if i >= len(nested) {
+					break
+				}
+				obj, ok := nested[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				var sub map[string]interface{}
+				if i < len(nestedShape) {
+					sub, _ = nestedShape[i].(map[string]interface{})
+				}
+				if s.resolveExpressions(obj, block, sub) {
+					changed = true
+				}
+			}
+			continue
+		}
+		if attrs[name] != nil {
+			continue
+		}
+
+		if list, ok := shape[name].([]interface{}); ok {
+			var vals []interface{}
+			for _, ref := range expr.References {
+				if v, ok := s.lookupRef(ref); ok && v != nil && len(vals) < len(list) {
+					vals = append(vals, v)
+				}
+			}
+			if len(vals) == len(list) {
+				attrs[name] = vals
+				changed = true
+			}
+			continue
+		}
+		for _, ref := range expr.References {
+			if v, ok := s.lookupRef(ref); ok && v != nil {
+				attrs[name] = v
+				changed = true
+				break
+			}
+		}
+	}
+	return changed
+}
+
+var indexSuffix = regexp.MustCompile(`\[[^\]]*\]$`)
+
+// configAddress maps an instance address (foo.bar["x"]) to its config
+// address (foo.bar).
+func configAddress(addr string) string {
+	return indexSuffix.ReplaceAllString(addr, "")
+}
+
+// lookupRef resolves a Terraform reference such as
+// "azurerm_automation_account.backup_restore.identity[0].principal_id".
+func (s *Server) lookupRef(ref string) (interface{}, bool) {
+	parts := strings.Split(ref, ".")
+	if parts[0] == "var" && len(parts) == 2 {
+		v, ok := s.variables[parts[1]]
+		return v, ok
+	}
+	if parts[0] == "local" && len(parts) == 2 {
+		for _, r := range s.locals[parts[1]] {
+			if v, ok := s.lookupRef(r); ok && v != nil && v != "" {
+				return v, true
+			}
+		}
+		return nil, false
+	}
+	for i := len(parts); i >= 2; i-- {
+		addr := strings.Join(parts[:i], ".")
+		attrs, ok := s.attrs[addr]
+		if !ok {
+			continue
+		}
+		if i == len(parts) {
+			return nil, false
+		}
+		return lookupPath(attrs, strings.Join(parts[i:], "."))
+	}
+	return nil, false
+}
+
+var pathStep = regexp.MustCompile(`^([^\[]+)((?:\[\d+\])*)$`)
+
+// lookupPath walks a dotted attribute path with optional list indexes.
+func lookupPath(attrs map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = attrs
+	for _, step := range strings.Split(path, ".") {
+		m := pathStep.FindStringSubmatch(step)
+		if m == nil {
+			return nil, false
+		}
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[m[1]]; !ok {
+			return nil, false
+		}
+		for _, idx := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if idx == "" {
+				continue
+			}
+			n, _ := strconv.Atoi(idx)
+			list, ok := cur.([]interface{})
+			if !ok || n >= len(list) {
+				return nil, false
+			}
+			cur = list[n]
+		}
+	}
+	return cur, cur != nil
+}
+
+// syntheticGUID derives a stable GUID-shaped value from seed.
+func syntheticGUID(seed string) string {
+	h := sha1.Sum([]byte(seed))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
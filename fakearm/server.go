@@ -0,0 +1,509 @@
+// Package fakearm is an in-memory stand-in for Azure Resource Manager that the
+// Terratest suite can point its SDK clients at instead of a live subscription.
+//
+// The fake is plugged in as the azcore transport, so the generated ARM clients
+// (armrecoveryservices, armrecoveryservicesbackup, armdataprotection,
+// armautomation, armmonitor, ...) run unchanged: requests are answered from a
+// store of ARM JSON documents keyed by resource ID. The store is normally
+// seeded from `terraform show -json` output for a plan or a state, see Seed.
+//
+// Only the generic ARM verbs are modelled: GET of an item or a collection,
+// PUT, PATCH (JSON merge-patch) and DELETE, plus the few cross-cutting reads
+// the suite relies on (the subscription and resource-group wide /resources
+// listings, a vault's backupProtectedItems, role assignments and management
+// locks across scopes and built-in role definitions), the soft delete of
+// backup protected items and the refusal of deletes under a management
+// lock. Other resource-provider specific behaviour can be layered
+// on with Server.Handle.
+package fakearm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// DefaultSubscriptionID is used when the seed does not reveal a subscription.
+const DefaultSubscriptionID = "00000000-0000-0000-0000-000000000000"
+
+// DefaultTenantID is reported for synthesised managed identities.
+const DefaultTenantID = "00000000-0000-0000-0000-000000000001"
+
+// HandlerFunc answers a request that a hook has claimed. Returning a nil
+// response hands the request back to the generic store handling.
+type HandlerFunc func(s *Server, req *http.Request) (*http.Response, error)
+
+type hook struct {
+	method string
+	suffix string
+	fn     HandlerFunc
+}
+
+// Server is the fake ARM endpoint. It implements policy.Transporter.
+type Server struct {
+	mu             sync.Mutex
+	subscriptionID string
+	resources      map[string]map[string]interface{}
+	hooks          []hook
+
+	// seed bookkeeping, see Seed.
+	attrs     map[string]map[string]interface{}
+	outputs   map[string]interface{}
+	variables map[string]interface{}
+	locals    map[string][]string
+}
+
+// New returns an empty fake for the given subscription.
+func New(subscriptionID string) *Server {
+	if subscriptionID == "" {
+		subscriptionID = DefaultSubscriptionID
+	}
+	return &Server{
+		subscriptionID: subscriptionID,
+		resources:      map[string]map[string]interface{}{},
+		attrs:          map[string]map[string]interface{}{},
+		outputs:        map[string]interface{}{},
+		variables:      map[string]interface{}{},
+		locals:         map[string][]string{},
+	}
+}
+
+// SubscriptionID is the subscription all seeded resources live in.
+func (s *Server) SubscriptionID() string {
+	return s.subscriptionID
+}
+
+// ClientOptions wires an ARM client to the fake.
+func (s *Server) ClientOptions() *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Transport: s,
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+		},
+		DisableRPRegistration: true,
+	}
+}
+
+// Credential returns a token credential that is accepted by the fake.
+func (s *Server) Credential() azcore.TokenCredential {
+	return staticCredential{}
+}
+
+type staticCredential struct{}
+
+func (staticCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fakearm", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// Handle registers fn for requests with the given method whose path ends with
+// suffix (case-insensitive). Hooks run in registration order before the
+// generic store handling.
+func (s *Server) Handle(method, suffix string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook{method: method, suffix: strings.ToLower(suffix), fn: fn})
+}
+
+// ─── Store ────────────────────────────────────────────────────────────────────
+
+// Put stores doc under id, filling in id, name, type and systemData.createdAt
+// when absent.
+func (s *Server) Put(id string, doc map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(id, doc)
+}
+
+func (s *Server) put(id string, doc map[string]interface{}) {
+	if _, ok := doc["id"]; !ok {
+		doc["id"] = id
+	}
+	if _, ok := doc["name"]; !ok {
+		doc["name"] = lastSegment(id)
+	}
+	if _, ok := doc["type"]; !ok {
+		doc["type"] = ResourceType(id)
+	}
+	sys, _ := doc["systemData"].(map[string]interface{})
+	if sys == nil {
+		sys = map[string]interface{}{}
+		doc["systemData"] = sys
+	}
+	if _, ok := sys["createdAt"]; !ok {
+		sys["createdAt"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	s.resources[strings.ToLower(id)] = doc
+}
+
+// Get returns a copy of the document stored under id.
+func (s *Server) Get(id string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.resources[strings.ToLower(id)]
+	if !ok {
+		return nil, false
+	}
+	return deepCopy(doc), true
+}
+
+// Delete removes id and everything nested below it.
+func (s *Server) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delete(id)
+}
+
+func (s *Server) delete(id string) bool {
+	key := strings.ToLower(id)
+	_, found := s.resources[key]
+	for k := range s.resources {
+		if strings.HasPrefix(k, key+"/") {
+			delete(s.resources, k)
+		}
+	}
+	delete(s.resources, key)
+	return found
+}
+
+// List returns copies of the documents directly inside the collection path,
+// sorted by ID.
+func (s *Server) List(collection string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list(collection)
+}
+
+func (s *Server) list(collection string) []map[string]interface{} {
+	prefix := strings.ToLower(strings.TrimSuffix(collection, "/")) + "/"
+	var keys []string
+	for k := range s.resources {
+		if strings.HasPrefix(k, prefix) && !strings.Contains(k[len(prefix):], "/") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := make([]map[string]interface{}, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, deepCopy(s.resources[k]))
+	}
+	return out
+}
+
+// listSpecial answers the collection reads that are not plain children of
+// their path: /resources below a subscription or resource group, which lists
+// every top-level resource in scope with its createdTime, and a vault's
+// backupProtectedItems, which lists protected items across containers.
+func (s *Server) listSpecial(path string) ([]map[string]interface{}, bool) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, "/resources"):
+		scope := strings.TrimSuffix(lower, "/resources") + "/"
+		var out []map[string]interface{}
+		for _, k := range s.sortedKeys() {
+			doc := s.resources[k]
+			id := fmt.Sprintf("%v", doc["id"])
+			if !strings.HasPrefix(k, scope) || !isTopLevel(id) {
+				continue
+			}
+			doc = deepCopy(doc)
+			if sys, ok := doc["systemData"].(map[string]interface{}); ok {
+				doc["createdTime"] = sys["createdAt"]
+			}
+			out = append(out, doc)
+		}
+		return out, true
+
+	case strings.HasSuffix(lower, "/backupprotecteditems"):
+		vault := strings.TrimSuffix(lower, "/backupprotecteditems") + "/"
+		var out []map[string]interface{}
+		for _, k := range s.sortedKeys() {
+			if strings.HasPrefix(k, vault) && strings.HasSuffix(strings.ToLower(ResourceType(k)), "/protecteditems") {
+				out = append(out, deepCopy(s.resources[k]))
+			}
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// protectedItemUnder returns the ID of a backup protected item or a Data
+// Protection backup instance stored below id, if any. ARM refuses to delete
+// a vault, or a resource group holding one, while it still protects items.
+func (s *Server) protectedItemUnder(id string) string {
+	key := strings.ToLower(id)
+	for _, k := range s.sortedKeys() {
+		if !strings.HasPrefix(k, key+"/") {
+			continue
+		}
+		switch t := strings.ToLower(ResourceType(k)); {
+		case strings.HasSuffix(t, "/protecteditems"), t == "microsoft.dataprotection/backupvaults/backupinstances":
+			return fmt.Sprintf("%v", s.resources[k]["id"])
+		}
+	}
+	return ""
+}
+
+func (s *Server) sortedKeys() []string {
+	keys := make([]string, 0, len(s.resources))
+	for k := range s.resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// IDs returns every stored resource ID, sorted.
+func (s *Server) IDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.resources))
+	for _, doc := range s.resources {
+		ids = append(ids, fmt.Sprintf("%v", doc["id"]))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ─── Transport ────────────────────────────────────────────────────────────────
+
+// Do implements policy.Transporter.
+func (s *Server) Do(req *http.Request) (*http.Response, error) {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+
+	s.mu.Lock()
+	hooks := append([]hook(nil), s.hooks...)
+	s.mu.Unlock()
+	for _, h := range hooks {
+		if h.method == req.Method && strings.HasSuffix(strings.ToLower(path), h.suffix) {
+			resp, err := h.fn(s, req)
+			if resp != nil || err != nil {
+				return resp, err
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resp, ok := s.scopeLocked(req, path); ok {
+		return resp, nil
+	}
+	if resp, ok := s.recoveryServices(req, path); ok {
+		return resp, nil
+	}
+	if resp, ok := s.authorization(req, path); ok {
+		return resp, nil
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		if doc, ok := s.resources[strings.ToLower(path)]; ok {
+			return JSONResponse(req, http.StatusOK, doc), nil
+		}
+		if value, ok := s.listSpecial(path); ok {
+			return JSONResponse(req, http.StatusOK, map[string]interface{}{"value": value}), nil
+		}
+		if isCollection(path) {
+			return JSONResponse(req, http.StatusOK, map[string]interface{}{"value": s.list(path)}), nil
+		}
+		return NotFound(req, path), nil
+
+	case http.MethodPut:
+		doc, err := readBody(req)
+		if err != nil {
+			return ErrorResponse(req, http.StatusBadRequest, "InvalidRequestContent", err.Error()), nil
+		}
+		if props, ok := doc["properties"].(map[string]interface{}); ok {
+			if _, ok := props["provisioningState"]; !ok {
+				props["provisioningState"] = "Succeeded"
+			}
+		}
+		s.put(path, doc)
+		return JSONResponse(req, http.StatusOK, s.resources[strings.ToLower(path)]), nil
+
+	case http.MethodPatch:
+		existing, ok := s.resources[strings.ToLower(path)]
+		if !ok {
+			return NotFound(req, path), nil
+		}
+		patch, err := readBody(req)
+		if err != nil {
+			return ErrorResponse(req, http.StatusBadRequest, "InvalidRequestContent", err.Error()), nil
+		}
+		mergePatch(existing, patch)
+		return JSONResponse(req, http.StatusOK, existing), nil
+
+	case http.MethodDelete:
+		if item := s.protectedItemUnder(path); item != "" {
+			return ErrorResponse(req, http.StatusConflict, "ServiceResourceNotEmpty",
+				fmt.Sprintf("'%s' cannot be deleted because it contains protected item '%s'.", path, item)), nil
+		}
+		if !s.delete(path) {
+			return emptyResponse(req, http.StatusNoContent), nil
+		}
+		return emptyResponse(req, http.StatusOK), nil
+	}
+
+	return ErrorResponse(req, http.StatusMethodNotAllowed, "MethodNotAllowed",
+		fmt.Sprintf("fakearm does not support %s", req.Method)), nil
+}
+
+// JSONResponse builds a response carrying body as JSON.
+func JSONResponse(req *http.Request, status int, body interface{}) *http.Response {
+	raw, _ := json.Marshal(body)
+	resp := emptyResponse(req, status)
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	resp.ContentLength = int64(len(raw))
+	return resp
+}
+
+// ErrorResponse builds an ARM error envelope that azcore turns into a
+// *azcore.ResponseError with the given ErrorCode.
+func ErrorResponse(req *http.Request, status int, code, message string) *http.Response {
+	resp := JSONResponse(req, status, map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+	resp.Header.Set("x-ms-error-code", code)
+	return resp
+}
+
+// NotFound is the ARM 404 for a missing resource.
+func NotFound(req *http.Request, id string) *http.Response {
+	code := "ResourceNotFound"
+	if ResourceType(id) == "Microsoft.Resources/resourceGroups" {
+		code = "ResourceGroupNotFound"
+	}
+	return ErrorResponse(req, http.StatusNotFound, code,
+		fmt.Sprintf("The Resource '%s' was not found.", id))
+}
+
+func emptyResponse(req *http.Request, status int) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// readBody decodes the JSON request body, leaving it readable again for
+// whoever handles the request next.
+func readBody(req *http.Request) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	if req.Body == nil {
+		return doc, nil
+	}
+	raw, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return doc, nil
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ─── Resource IDs ─────────────────────────────────────────────────────────────
+
+// isCollection reports whether path addresses a collection rather than a
+// single resource. ARM IDs alternate type/name segments, so collections have
+// an odd number of segments.
+func isCollection(path string) bool {
+	return len(segments(path))%2 == 1
+}
+
+// ResourceType derives the ARM resource type (e.g.
+// "Microsoft.RecoveryServices/vaults/backupPolicies") from a resource ID.
+func ResourceType(id string) string {
+	segs := segments(id)
+	last := -1
+	for i, seg := range segs {
+		if strings.EqualFold(seg, "providers") && i+1 < len(segs) {
+			last = i
+		}
+	}
+	if last < 0 {
+		switch len(segs) {
+		case 2:
+			return "Microsoft.Resources/subscriptions"
+		default:
+			return "Microsoft.Resources/resourceGroups"
+		}
+	}
+	parts := []string{segs[last+1]}
+	for i := last + 2; i < len(segs); i += 2 {
+		parts = append(parts, segs[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+// isTopLevel reports whether id is a resource directly inside a resource
+// group, e.g. a vault but not one of its policies.
+func isTopLevel(id string) bool {
+	segs := segments(id)
+	return len(segs) == 8 && strings.EqualFold(segs[4], "providers")
+}
+
+// ParentID strips the last type/name pair from id.
+func ParentID(id string) string {
+	segs := segments(id)
+	if len(segs) < 2 {
+		return ""
+	}
+	segs = segs[:len(segs)-2]
+	if n := len(segs); n >= 2 && strings.EqualFold(segs[n-2], "providers") {
+		segs = segs[:n-2]
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+func segments(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func lastSegment(id string) string {
+	segs := segments(id)
+	return segs[len(segs)-1]
+}
+
+// ─── JSON helpers ─────────────────────────────────────────────────────────────
+
+func mergePatch(dst, patch map[string]interface{}) {
+	for k, v := range patch {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		if pm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergePatch(dm, pm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+func deepCopy(doc map[string]interface{}) map[string]interface{} {
+	raw, _ := json.Marshal(doc)
+	out := map[string]interface{}{}
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
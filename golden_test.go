@@ -0,0 +1,209 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// ─── Golden plan snapshots ───────────────────────────────────────────────────
+
+// updateGolden regenerates the golden files instead of comparing against them:
+//
+//	go test -v -run TestBackupPlanGolden -update
+var updateGolden = flag.Bool("update", false, "rewrite golden plan snapshots under testdata/golden")
+
+// goldenResourceTypes are the resources whose planned values are snapshotted.
+// They carry the retention, schedule and alerting settings that must not
+// change without review.
+var goldenResourceTypes = []string{
+	"azurerm_recovery_services_vault",
+	"azurerm_backup_policy_vm",
+	"azurerm_data_protection_backup_policy_disk",
+	"azurerm_monitor_scheduled_query_rules_alert_v2",
+	"azurerm_monitor_metric_alert",
+}
+
+var guidPattern = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// TestBackupPlanGolden renders the normalised planned values of the backup
+// module into testdata/golden/backup_plan.json and fails with a per-attribute
+// diff when a change alters them. With TEST_ARM_BACKEND=fake a plan saved with
+// terraform show -json in TEST_ARM_SEED is used instead of running terraform.
+// The synthetic seed is never used: the snapshot would only be checked
+// against the file it was taken from.
+func TestBackupPlanGolden(t *testing.T) {
+	t.Parallel()
+
+	plan, vars := goldenPlan(t)
+	got := goldenSnapshot(plan, strings.NewReplacer(
+		fmt.Sprint(vars["resource_group_name"]), "<resource_group>",
+		fmt.Sprint(vars["snapshot_resource_group_name"]), "<snapshot_resource_group>",
+		fmt.Sprint(vars["vault_name"]), "<vault_name>",
+	))
+
+	path := filepath.Join("testdata", "golden", "backup_plan.json")
+	if *updateGolden {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		require.NoError(t, enc.Encode(got))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+		t.Logf("updated %s", path)
+		return
+	}
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file; run with -update to create it")
+	var want map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &want))
+
+	// Round-trip so both sides have the types encoding/json decodes into.
+	var current map[string]interface{}
+	raw, err = json.Marshal(got)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &current))
+
+	if diff := goldenDiff("", want, current); len(diff) > 0 {
+		t.Errorf("planned values differ from %s (run with -update if the change is intended):\n%s",
+			path, strings.Join(diff, "\n"))
+	}
+}
+
+// goldenPlan returns the plan to snapshot and the input variables it was made
+// with.
+func goldenPlan(t *testing.T) (*terraform.PlanStruct, map[string]interface{}) {
+	t.Helper()
+
+	if useFakeARM() {
+		seed := os.Getenv("TEST_ARM_SEED")
+		if seed == "" {
+			t.Skip("TEST_ARM_SEED names no saved plan; the synthetic seed cannot check the snapshot")
+		}
+		raw, err := os.ReadFile(seed)
+		require.NoError(t, err)
+		plan, err := terraform.ParsePlanJSON(string(raw))
+		require.NoError(t, err)
+
+		vars := map[string]interface{}{}
+		for name, v := range plan.RawPlan.Variables {
+			vars[name] = v.Value
+		}
+		return plan, vars
+	}
+
+	opts := terraformOptions(t, uniqueSuffix())
+	return initAndPlanWithStruct(t, newRetryClassifier(t), opts), opts.Vars
+}
+
+// goldenSnapshot collects the planned values of goldenResourceTypes keyed by
+// address, with per-run names and any GUIDs masked.
+func goldenSnapshot(plan *terraform.PlanStruct, names *strings.Replacer) map[string]interface{} {
+	out := map[string]interface{}{}
+	for addr, r := range plan.ResourcePlannedValuesMap {
+		for _, typ := range goldenResourceTypes {
+			if r.Type == typ {
+				out[addr] = maskGolden(r.AttributeValues, names)
+			}
+		}
+	}
+	return out
+}
+
+// maskGolden replaces values that change between runs with placeholders.
+func maskGolden(v interface{}, names *strings.Replacer) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = maskGolden(e, names)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = maskGolden(e, names)
+		}
+		return out
+	case string:
+		return guidPattern.ReplaceAllString(names.Replace(v), "<guid>")
+	}
+	return v
+}
+
+// goldenDiff lists the attribute paths where got differs from want.
+func goldenDiff(path string, want, got interface{}) []string {
+	wantMap, wok := want.(map[string]interface{})
+	gotMap, gok := got.(map[string]interface{})
+	if wok && gok {
+		keys := map[string]bool{}
+		for k := range wantMap {
+			keys[k] = true
+		}
+		for k := range gotMap {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diff []string
+		for _, k := range sorted {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			w, inWant := wantMap[k]
+			g, inGot := gotMap[k]
+			switch {
+			case !inWant:
+				diff = append(diff, fmt.Sprintf("  + %s = %s", child, goldenValue(g)))
+			case !inGot:
+				diff = append(diff, fmt.Sprintf("  - %s = %s", child, goldenValue(w)))
+			default:
+				diff = append(diff, goldenDiff(child, w, g)...)
+			}
+		}
+		return diff
+	}
+
+	wantList, wok := want.([]interface{})
+	gotList, gok := got.([]interface{})
+	if wok && gok && len(wantList) == len(gotList) {
+		var diff []string
+		for i := range wantList {
+			diff = append(diff, goldenDiff(fmt.Sprintf("%s[%d]", path, i), wantList[i], gotList[i])...)
+		}
+		return diff
+	}
+
+	if reflect.DeepEqual(want, got) {
+		return nil
+	}
+	return []string{fmt.Sprintf("  ~ %s: %s => %s", path, goldenValue(want), goldenValue(got))}
+}
+
+func goldenValue(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,169 @@
+// Package iso8601 parses the ISO 8601 durations and repeating intervals the
+// Data Protection API uses in backup policies, such as the retention
+// duration "P7D" and the schedule "R/2024-01-01T02:00:00+00:00/PT4H".
+//
+// Only the forms Azure accepts are supported: durations with whole-number
+// components, and repeating intervals written as a start time and a period.
+package iso8601
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a nominal ISO 8601 duration. Years and months have no fixed
+// length, so the components are kept as written rather than folded into a
+// time.Duration.
+type Duration struct {
+	Years, Months, Weeks, Days int
+	Hours, Minutes, Seconds    int
+}
+
+var durationPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration parses a duration such as P7D, P4W, P1Y6M or PT4H.
+func ParseDuration(s string) (Duration, error) {
+	m := durationPattern.FindStringSubmatch(s)
+	// A bare P or a T with nothing after it matches the pattern but is not
+	// a duration.
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return Duration{}, fmt.Errorf("iso8601: %q is not a duration such as P7D or PT4H", s)
+	}
+	var n [7]int
+	for i, part := range m[1:] {
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return Duration{}, fmt.Errorf("iso8601: duration %q: %w", s, err)
+		}
+		n[i] = v
+	}
+	return Duration{
+		Years: n[0], Months: n[1], Weeks: n[2], Days: n[3],
+		Hours: n[4], Minutes: n[5], Seconds: n[6],
+	}, nil
+}
+
+// String formats d in the shortest ISO 8601 form; the zero duration is PT0S.
+func (d Duration) String() string {
+	var b strings.Builder
+	b.WriteByte('P')
+	for _, c := range []struct {
+		n    int
+		unit byte
+	}{{d.Years, 'Y'}, {d.Months, 'M'}, {d.Weeks, 'W'}, {d.Days, 'D'}} {
+		if c.n != 0 {
+			fmt.Fprintf(&b, "%d%c", c.n, c.unit)
+		}
+	}
+	if d.Hours != 0 || d.Minutes != 0 || d.Seconds != 0 {
+		b.WriteByte('T')
+		for _, c := range []struct {
+			n    int
+			unit byte
+		}{{d.Hours, 'H'}, {d.Minutes, 'M'}, {d.Seconds, 'S'}} {
+			if c.n != 0 {
+				fmt.Fprintf(&b, "%d%c", c.n, c.unit)
+			}
+		}
+	}
+	if b.Len() == 1 {
+		return "PT0S"
+	}
+	return b.String()
+}
+
+// Equal reports whether d and o are the same length. Weeks are counted as
+// seven days, so P1W equals P7D, but days are not converted to hours nor
+// months to days, since those vary.
+func (d Duration) Equal(o Duration) bool {
+	d.Days, d.Weeks = d.Days+7*d.Weeks, 0
+	o.Days, o.Weeks = o.Days+7*o.Weeks, 0
+	return d == o
+}
+
+// Fixed returns d as a time.Duration, counting a day as 24 hours. It fails
+// for durations with years or months.
+func (d Duration) Fixed() (time.Duration, bool) {
+	if d.Years != 0 || d.Months != 0 {
+		return 0, false
+	}
+	return time.Duration(7*d.Weeks+d.Days)*24*time.Hour +
+		time.Duration(d.Hours)*time.Hour +
+		time.Duration(d.Minutes)*time.Minute +
+		time.Duration(d.Seconds)*time.Second, true
+}
+
+// AddTo returns t moved forward by d, calendar components first.
+func (d Duration) AddTo(t time.Time) time.Time {
+	return d.add(t, 1)
+}
+
+// SubtractFrom returns t moved back by d, calendar components first.
+func (d Duration) SubtractFrom(t time.Time) time.Time {
+	return d.add(t, -1)
+}
+
+func (d Duration) add(t time.Time, sign int) time.Time {
+	t = t.AddDate(sign*d.Years, sign*d.Months, sign*(7*d.Weeks+d.Days))
+	return t.Add(time.Duration(sign) * (time.Duration(d.Hours)*time.Hour +
+		time.Duration(d.Minutes)*time.Minute +
+		time.Duration(d.Seconds)*time.Second))
+}
+
+// RepeatingInterval is an ISO 8601 repeating interval R[n]/start/period.
+type RepeatingInterval struct {
+	// Repetitions is the number of repeats, or -1 when unbounded.
+	Repetitions int
+	Start       time.Time
+	Period      Duration
+}
+
+// ParseRepeatingInterval parses an interval such as
+// R/2024-01-01T02:00:00+00:00/PT4H. The start keeps the offset it was
+// written with.
+func ParseRepeatingInterval(s string) (RepeatingInterval, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "R") {
+		return RepeatingInterval{}, fmt.Errorf("iso8601: %q is not a repeating interval R/start/period", s)
+	}
+
+	ri := RepeatingInterval{Repetitions: -1}
+	if n := parts[0][1:]; n != "" {
+		reps, err := strconv.Atoi(n)
+		if err != nil || reps < 0 {
+			return RepeatingInterval{}, fmt.Errorf("iso8601: %q has an invalid repeat count %q", s, n)
+		}
+		ri.Repetitions = reps
+	}
+
+	start, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return RepeatingInterval{}, fmt.Errorf("iso8601: %q has an invalid start: %w", s, err)
+	}
+	ri.Start = start
+
+	period, err := ParseDuration(parts[2])
+	if err != nil {
+		return RepeatingInterval{}, err
+	}
+	if period == (Duration{}) {
+		return RepeatingInterval{}, fmt.Errorf("iso8601: %q repeats every zero-length period", s)
+	}
+	ri.Period = period
+	return ri, nil
+}
+
+// String formats ri in the form ParseRepeatingInterval accepts.
+func (ri RepeatingInterval) String() string {
+	reps := ""
+	if ri.Repetitions >= 0 {
+		reps = strconv.Itoa(ri.Repetitions)
+	}
+	return fmt.Sprintf("R%s/%s/%s", reps, ri.Start.Format(time.RFC3339), ri.Period)
+}
@@ -0,0 +1,81 @@
+package iso8601_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/iso8601"
+)
+
+// TestISO8601 covers the durations and repeating intervals found in Data
+// Protection policies, and the near misses a substring match would accept.
+func TestISO8601(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Durations", func(t *testing.T) {
+		for _, tc := range []struct {
+			in   string
+			want iso8601.Duration
+		}{
+			{"P7D", iso8601.Duration{Days: 7}},
+			{"P17D", iso8601.Duration{Days: 17}},
+			{"P4W", iso8601.Duration{Weeks: 4}},
+			{"P1Y6M", iso8601.Duration{Years: 1, Months: 6}},
+			{"PT4H", iso8601.Duration{Hours: 4}},
+			{"P1DT12H30M", iso8601.Duration{Days: 1, Hours: 12, Minutes: 30}},
+		} {
+			got, err := iso8601.ParseDuration(tc.in)
+			require.NoError(t, err, tc.in)
+			assert.Equal(t, tc.want, got, tc.in)
+			assert.Equal(t, tc.in, got.String(), "round trip of %s", tc.in)
+		}
+
+		for _, bad := range []string{"", "P", "PT", "7D", "P7", "P-7D", "P1.5D", "PT4H2D", "P7d"} {
+			_, err := iso8601.ParseDuration(bad)
+			assert.Error(t, err, "%q should not parse", bad)
+		}
+	})
+
+	t.Run("Equal", func(t *testing.T) {
+		parse := func(s string) iso8601.Duration {
+			d, err := iso8601.ParseDuration(s)
+			require.NoError(t, err)
+			return d
+		}
+		assert.True(t, parse("P1W").Equal(parse("P7D")))
+		assert.True(t, parse("P4W").Equal(parse("P28D")))
+		assert.False(t, parse("P7D").Equal(parse("P17D")))
+		assert.False(t, parse("P1D").Equal(parse("PT24H")), "a day is not always 24 hours")
+		assert.False(t, parse("P1M").Equal(parse("P30D")))
+	})
+
+	t.Run("RepeatingIntervals", func(t *testing.T) {
+		ri, err := iso8601.ParseRepeatingInterval("R/2024-01-01T02:00:00+00:00/PT4H")
+		require.NoError(t, err)
+		assert.Equal(t, -1, ri.Repetitions)
+		assert.True(t, ri.Start.Equal(time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)))
+		assert.Equal(t, iso8601.Duration{Hours: 4}, ri.Period)
+		assert.Equal(t, "R/2024-01-01T02:00:00Z/PT4H", ri.String())
+
+		ri, err = iso8601.ParseRepeatingInterval("R3/2024-01-01T23:00:00+05:30/P1D")
+		require.NoError(t, err)
+		assert.Equal(t, 3, ri.Repetitions)
+		assert.Equal(t, "23:00", ri.Start.Format("15:04"), "start keeps its written offset")
+		assert.Equal(t, iso8601.Duration{Days: 1}, ri.Period)
+
+		for _, bad := range []string{
+			"2024-01-01T02:00:00+00:00/PT4H",
+			"R/2024-01-01 02:00/PT4H",
+			"R/2024-01-01T02:00:00+00:00/4H",
+			"R/2024-01-01T02:00:00+00:00/PT0S",
+			"Rx/2024-01-01T02:00:00+00:00/PT4H",
+			"R/2024-01-01T02:00:00+00:00/PT4H/extra",
+		} {
+			_, err := iso8601.ParseRepeatingInterval(bad)
+			assert.Error(t, err, "%q should not parse", bad)
+		}
+	})
+}
@@ -0,0 +1,1057 @@
+package kql
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ─── Tables ──────────────────────────────────────────────────────────────────
+
+// Row is one row of a table, by column name. Every row of a table has every
+// one of its columns, null ones as nil.
+type Row map[string]interface{}
+
+// Table is a tabular result: its columns in order, and its rows.
+type Table struct {
+	Columns []string
+	Rows    []Row
+}
+
+// Env is what Eval runs a query against.
+type Env struct {
+	// Now is the time ago() and now() count back from.
+	Now    time.Time
+	Tables map[string]Table
+}
+
+// EvalError is a query Eval parses but cannot run, with the offset of the
+// part of the query at fault.
+type EvalError struct {
+	Pos int
+	Msg string
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("kql: offset %d: %s", e.Pos, e.Msg)
+}
+
+// LoadFixture reads an Env from a JSON file of the form
+//
+//	{"now": "2024-01-01T12:00:00Z", "tables": {"Table": [{"Column": value}]}}
+//
+// Strings in RFC 3339 form are read as datetimes and every number as a
+// real. A table's columns are those of all its rows, sorted; a row without
+// one of them has it null. List an empty table as [] so queries can read it.
+func LoadFixture(path string) (Env, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Env{}, err
+	}
+	var doc struct {
+		Now    string                              `json:"now"`
+		Tables map[string][]map[string]interface{} `json:"tables"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return Env{}, fmt.Errorf("%s: %w", path, err)
+	}
+	now, err := time.Parse(time.RFC3339, doc.Now)
+	if err != nil {
+		return Env{}, fmt.Errorf("%s: now: %w", path, err)
+	}
+
+	env := Env{Now: now.UTC(), Tables: map[string]Table{}}
+	for name, rows := range doc.Tables {
+		seen := map[string]bool{}
+		var t Table
+		for _, r := range rows {
+			for c := range r {
+				if !seen[c] {
+					seen[c] = true
+					t.Columns = append(t.Columns, c)
+				}
+			}
+		}
+		sort.Strings(t.Columns)
+		for _, r := range rows {
+			row := Row{}
+			for _, c := range t.Columns {
+				v := r[c]
+				if s, ok := v.(string); ok {
+					if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+						v = ts.UTC()
+					}
+				}
+				row[c] = v
+			}
+			t.Rows = append(t.Rows, row)
+		}
+		env.Tables[name] = t
+	}
+	return env, nil
+}
+
+// ─── Eval ────────────────────────────────────────────────────────────────────
+
+// Eval runs a query against env and returns the table it produces.
+//
+// It runs the part of the language the alert rules use: let bindings of
+// tables and scalars; the operators where, project, project-away,
+// project-rename, project-keep, extend, summarize, count, distinct,
+// take, limit, sort, order, top, join and lookup; the scalar operators and
+// functions of expr.go; and the aggregations count, countif, dcount, sum,
+// avg, min, max, any, take_any, arg_max and arg_min. Anything else is an
+// EvalError rather than a guess. Tables are held in memory, so it suits
+// fixtures of a few rows, not real workspaces.
+func Eval(query string, env Env) (Table, error) {
+	stmts, err := Parse(query)
+	if err != nil {
+		return Table{}, err
+	}
+	ev := &evaluator{text: query, env: env, lets: map[string]Table{}, scalars: map[string]interface{}{}}
+	var (
+		out  Table
+		have bool
+	)
+	for _, s := range stmts {
+		if s.Let == "" {
+			if out, err = ev.pipeline(s.Stages); err != nil {
+				return Table{}, err
+			}
+			have = true
+			continue
+		}
+		if ev.tabular(s.Stages) {
+			t, err := ev.pipeline(s.Stages)
+			if err != nil {
+				return Table{}, err
+			}
+			ev.lets[s.Let] = t
+			continue
+		}
+		n, err := parseExpr(query, s.Stages[0].tokens)
+		if err != nil {
+			return Table{}, err
+		}
+		v, err := ev.scope(nil).eval(n)
+		if err != nil {
+			return Table{}, err
+		}
+		ev.scalars[s.Let] = v
+	}
+	if !have {
+		return Table{}, &EvalError{Pos: len(query), Msg: "the query returns no table"}
+	}
+	return out, nil
+}
+
+type evaluator struct {
+	text    string
+	env     Env
+	lets    map[string]Table
+	scalars map[string]interface{}
+}
+
+func (ev *evaluator) scope(row Row) scope {
+	return scope{row: row, scalars: ev.scalars, now: ev.env.Now}
+}
+
+func (ev *evaluator) errorf(pos int, format string, args ...interface{}) error {
+	return &EvalError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// tabular reports whether a let binds a table rather than a scalar.
+func (ev *evaluator) tabular(stages []Stage) bool {
+	if len(stages) > 1 {
+		return true
+	}
+	src := stages[0]
+	if src.Operator == "" {
+		return len(src.Args) > 0 && src.Args[0].Text == "("
+	}
+	_, isLet := ev.lets[src.Operator]
+	_, isTable := ev.env.Tables[src.Operator]
+	return len(src.Args) == 0 && (isLet || isTable)
+}
+
+// pipeline runs a statement's stages.
+func (ev *evaluator) pipeline(stages []Stage) (Table, error) {
+	t, err := ev.source(stages[0])
+	if err != nil {
+		return Table{}, err
+	}
+	for _, s := range stages[1:] {
+		if t, err = ev.stage(t, s); err != nil {
+			return Table{}, err
+		}
+	}
+	return t, nil
+}
+
+// source returns the table a pipeline starts from: a table, a let name or a
+// parenthesised subquery.
+func (ev *evaluator) source(s Stage) (Table, error) {
+	switch {
+	case s.Operator != "" && len(s.Args) == 0:
+		if t, ok := ev.lets[s.Operator]; ok {
+			return t, nil
+		}
+		if t, ok := ev.env.Tables[s.Operator]; ok {
+			return t, nil
+		}
+		return Table{}, ev.errorf(s.Pos, "unknown table %s", s.Operator)
+	case s.Operator == "" && len(s.Args) > 1 && s.Args[0].Text == "(" && s.Args[len(s.Args)-1].Text == ")":
+		return ev.subquery(s.Args[1 : len(s.Args)-1])
+	}
+	return Table{}, ev.errorf(s.Pos, "unsupported source")
+}
+
+// subquery runs the tokens of a parenthesised tabular expression.
+func (ev *evaluator) subquery(toks []Token) (Table, error) {
+	stmts, err := statements(toks)
+	if err != nil {
+		return Table{}, err
+	}
+	if len(stmts) != 1 || stmts[0].Let != "" {
+		return Table{}, ev.errorf(toks[0].Pos, "a subquery must be one tabular expression")
+	}
+	return ev.pipeline(stmts[0].Stages)
+}
+
+func (ev *evaluator) stage(t Table, s Stage) (Table, error) {
+	switch s.Operator {
+	case "where", "filter":
+		return ev.where(t, s)
+	case "project", "extend":
+		return ev.project(t, s)
+	case "project-away", "project-keep":
+		return ev.keep(t, s)
+	case "project-rename":
+		return ev.rename(t, s)
+	case "summarize":
+		return ev.summarize(t, s)
+	case "count":
+		return Table{Columns: []string{"Count"}, Rows: []Row{{"Count": float64(len(t.Rows))}}}, nil
+	case "distinct":
+		return ev.distinct(t, s)
+	case "take", "limit":
+		n, err := ev.count(s)
+		if err != nil {
+			return Table{}, err
+		}
+		return Table{Columns: t.Columns, Rows: t.Rows[:min(n, len(t.Rows))]}, nil
+	case "sort", "order":
+		if len(s.Args) == 0 || !isKeyword(s.Args[0], "by") {
+			return Table{}, ev.errorf(s.Pos, "%s needs by", s.Operator)
+		}
+		return ev.sort(t, s.Args[1:])
+	case "top":
+		return ev.top(t, s)
+	case "join", "lookup":
+		return ev.join(t, s)
+	}
+	return Table{}, ev.errorf(s.Pos, "unsupported operator %s", s.Operator)
+}
+
+// ── Filtering and projection ──────────────────────────────────────────────────
+
+func (ev *evaluator) where(t Table, s Stage) (Table, error) {
+	n, err := parseExpr(ev.text, s.Args)
+	if err != nil {
+		return Table{}, err
+	}
+	out := Table{Columns: t.Columns}
+	for _, row := range t.Rows {
+		keep, err := ev.scope(row).boolean(n)
+		if err != nil {
+			return Table{}, err
+		}
+		if keep {
+			out.Rows = append(out.Rows, row)
+		}
+	}
+	return out, nil
+}
+
+// column is a named expression: an operator argument such as Name = expr,
+// or a bare column.
+type column struct {
+	name string
+	expr *node
+}
+
+// columns parses the comma-separated arguments of an operator.
+func (ev *evaluator) columns(args []Token, pos int) ([]column, error) {
+	var out []column
+	for _, item := range SplitArgs(args) {
+		c := column{}
+		if len(item) > 2 && item[0].Kind == Ident && item[1].Text == "=" {
+			c.name, item = item[0].Text, item[2:]
+		}
+		n, err := parseExpr(ev.text, item)
+		if err != nil {
+			return nil, err
+		}
+		c.expr = n
+		if c.name == "" {
+			c.name = defaultName(n)
+		}
+		if c.name == "" {
+			return nil, ev.errorf(item[0].Pos, "name the expression, as Name = expression")
+		}
+		out = append(out, c)
+	}
+	if len(out) == 0 {
+		return nil, ev.errorf(pos, "expected columns")
+	}
+	return out, nil
+}
+
+// defaultName is the name KQL gives an unnamed expression that is a column,
+// or a bin of one.
+func defaultName(n *node) string {
+	switch {
+	case n.op == "col":
+		return n.name
+	case n.op == "call" && (n.name == "bin" || n.name == "floor") && len(n.args) == 2 && n.args[0].op == "col":
+		return n.args[0].name
+	}
+	return ""
+}
+
+// project runs project, which keeps only the listed columns, and extend,
+// which adds or replaces them.
+func (ev *evaluator) project(t Table, s Stage) (Table, error) {
+	cols, err := ev.columns(s.Args, s.Pos)
+	if err != nil {
+		return Table{}, err
+	}
+	out := Table{}
+	if s.Operator == "extend" {
+		out.Columns = append(out.Columns, t.Columns...)
+	}
+	for _, c := range cols {
+		out.Columns = appendColumn(out.Columns, c.name)
+	}
+	for _, row := range t.Rows {
+		next := Row{}
+		if s.Operator == "extend" {
+			for k, v := range row {
+				next[k] = v
+			}
+		}
+		for _, c := range cols {
+			v, err := ev.scope(row).eval(c.expr)
+			if err != nil {
+				return Table{}, err
+			}
+			next[c.name] = v
+		}
+		out.Rows = append(out.Rows, next)
+	}
+	return out, nil
+}
+
+// keep runs project-away and project-keep.
+func (ev *evaluator) keep(t Table, s Stage) (Table, error) {
+	listed := map[string]bool{}
+	for _, item := range SplitArgs(s.Args) {
+		if len(item) != 1 || item[0].Kind != Ident {
+			return Table{}, ev.errorf(s.Pos, "%s takes column names", s.Operator)
+		}
+		if !contains(t.Columns, item[0].Text) {
+			return Table{}, ev.errorf(item[0].Pos, "unknown column %s", item[0].Text)
+		}
+		listed[item[0].Text] = true
+	}
+	keep := func(c string) bool { return listed[c] == (s.Operator == "project-keep") }
+
+	out := Table{}
+	for _, c := range t.Columns {
+		if keep(c) {
+			out.Columns = append(out.Columns, c)
+		}
+	}
+	for _, row := range t.Rows {
+		next := Row{}
+		for _, c := range out.Columns {
+			next[c] = row[c]
+		}
+		out.Rows = append(out.Rows, next)
+	}
+	return out, nil
+}
+
+func (ev *evaluator) rename(t Table, s Stage) (Table, error) {
+	renames := map[string]string{}
+	for _, item := range SplitArgs(s.Args) {
+		if len(item) != 3 || item[0].Kind != Ident || item[1].Text != "=" || item[2].Kind != Ident {
+			return Table{}, ev.errorf(s.Pos, "project-rename takes New = Old")
+		}
+		if !contains(t.Columns, item[2].Text) {
+			return Table{}, ev.errorf(item[2].Pos, "unknown column %s", item[2].Text)
+		}
+		renames[item[2].Text] = item[0].Text
+	}
+	name := func(c string) string {
+		if n, ok := renames[c]; ok {
+			return n
+		}
+		return c
+	}
+
+	out := Table{}
+	for _, c := range t.Columns {
+		out.Columns = append(out.Columns, name(c))
+	}
+	for _, row := range t.Rows {
+		next := Row{}
+		for k, v := range row {
+			next[name(k)] = v
+		}
+		out.Rows = append(out.Rows, next)
+	}
+	return out, nil
+}
+
+func (ev *evaluator) distinct(t Table, s Stage) (Table, error) {
+	cols := t.Columns
+	if !(len(s.Args) == 1 && s.Args[0].Text == "*") {
+		cols = nil
+		for _, item := range SplitArgs(s.Args) {
+			if len(item) != 1 || item[0].Kind != Ident || !contains(t.Columns, item[0].Text) {
+				return Table{}, ev.errorf(s.Pos, "distinct takes * or column names")
+			}
+			cols = append(cols, item[0].Text)
+		}
+	}
+	out := Table{Columns: cols}
+	seen := map[string]bool{}
+	for _, row := range t.Rows {
+		next := Row{}
+		for _, c := range cols {
+			next[c] = row[c]
+		}
+		if k := key(next, cols); !seen[k] {
+			seen[k] = true
+			out.Rows = append(out.Rows, next)
+		}
+	}
+	return out, nil
+}
+
+// ── Ordering ──────────────────────────────────────────────────────────────────
+
+// count reads the row count of take, limit or top.
+func (ev *evaluator) count(s Stage) (int, error) {
+	if len(s.Args) == 0 {
+		return 0, ev.errorf(s.Pos, "%s needs a row count", s.Operator)
+	}
+	end := len(s.Args)
+	for i, t := range s.Args {
+		if isKeyword(t, "by") {
+			end = i
+			break
+		}
+	}
+	n, err := parseExpr(ev.text, s.Args[:end])
+	if err != nil {
+		return 0, err
+	}
+	v, err := ev.scope(nil).eval(n)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := v.(float64)
+	if !ok || f < 0 {
+		return 0, ev.errorf(n.pos, "%s needs a row count, got %s", s.Operator, typeName(v))
+	}
+	return int(f), nil
+}
+
+// sort orders rows by the expressions in args, each optionally followed by
+// asc or desc; descending is the default.
+func (ev *evaluator) sort(t Table, args []Token) (Table, error) {
+	type order struct {
+		expr *node
+		asc  bool
+	}
+	var orders []order
+	for _, item := range SplitArgs(args) {
+		o := order{}
+		if last := item[len(item)-1]; isKeyword(last, "asc") || isKeyword(last, "desc") {
+			o.asc, item = last.Text == "asc", item[:len(item)-1]
+		}
+		n, err := parseExpr(ev.text, item)
+		if err != nil {
+			return Table{}, err
+		}
+		o.expr = n
+		orders = append(orders, o)
+	}
+
+	keys := make([][]interface{}, len(t.Rows))
+	for i, row := range t.Rows {
+		for _, o := range orders {
+			v, err := ev.scope(row).eval(o.expr)
+			if err != nil {
+				return Table{}, err
+			}
+			keys[i] = append(keys[i], v)
+		}
+	}
+	var failed error
+	idx := make([]int, len(t.Rows))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		for j, o := range orders {
+			c, ok, err := compare(keys[idx[a]][j], keys[idx[b]][j])
+			if err != nil && failed == nil {
+				failed = ev.errorf(o.expr.pos, "%s", err)
+			}
+			if !ok || c == 0 {
+				continue
+			}
+			return (c < 0) == o.asc
+		}
+		return false
+	})
+	if failed != nil {
+		return Table{}, failed
+	}
+	out := Table{Columns: t.Columns}
+	for _, i := range idx {
+		out.Rows = append(out.Rows, t.Rows[i])
+	}
+	return out, nil
+}
+
+func (ev *evaluator) top(t Table, s Stage) (Table, error) {
+	n, err := ev.count(s)
+	if err != nil {
+		return Table{}, err
+	}
+	for i, tok := range s.Args {
+		if isKeyword(tok, "by") {
+			sorted, err := ev.sort(t, s.Args[i+1:])
+			if err != nil {
+				return Table{}, err
+			}
+			sorted.Rows = sorted.Rows[:min(n, len(sorted.Rows))]
+			return sorted, nil
+		}
+	}
+	return Table{}, ev.errorf(s.Pos, "top needs by")
+}
+
+// ── Aggregation ───────────────────────────────────────────────────────────────
+
+// aggregates are the aggregation functions summarize runs.
+var aggregates = map[string]bool{
+	"count": true, "countif": true, "dcount": true, "sum": true, "avg": true,
+	"min": true, "max": true, "any": true, "take_any": true,
+	"arg_max": true, "arg_min": true,
+}
+
+// aggregation is one aggregation of a summarize.
+type aggregation struct {
+	names []string // the output columns
+	call  *node
+}
+
+func (ev *evaluator) summarize(t Table, s Stage) (Table, error) {
+	aggArgs, byArgs := s.Args, []Token(nil)
+	depth := 0
+	for i, tok := range s.Args {
+		switch {
+		case closing[tok.Text] != "":
+			depth++
+		case tok.Text == ")" || tok.Text == "]" || tok.Text == "}":
+			depth--
+		case depth == 0 && isKeyword(tok, "by"):
+			aggArgs, byArgs = s.Args[:i], s.Args[i+1:]
+		}
+	}
+
+	var by []column
+	if byArgs != nil {
+		var err error
+		if by, err = ev.columns(byArgs, s.Pos); err != nil {
+			return Table{}, err
+		}
+	}
+	var aggs []aggregation
+	for _, item := range SplitArgs(aggArgs) {
+		name := ""
+		if len(item) > 2 && item[0].Kind == Ident && item[1].Text == "=" {
+			name, item = item[0].Text, item[2:]
+		}
+		n, err := parseExpr(ev.text, item)
+		if err != nil {
+			return Table{}, err
+		}
+		if n.op != "call" || !aggregates[n.name] {
+			return Table{}, ev.errorf(n.pos, "summarize takes aggregations such as count() or max(Column)")
+		}
+		a := aggregation{call: n}
+		switch {
+		case n.name == "arg_max" || n.name == "arg_min":
+			if len(n.args) < 2 {
+				return Table{}, ev.errorf(n.pos, "%s() takes an expression and the columns to return", n.name)
+			}
+			for _, arg := range n.args {
+				if arg.op == "col" && arg.name == "*" {
+					continue
+				}
+				c := defaultName(arg)
+				if c == "" {
+					return Table{}, ev.errorf(arg.pos, "%s() returns columns, not expressions", n.name)
+				}
+				a.names = append(a.names, c)
+			}
+			if name != "" {
+				a.names[0] = name
+			}
+		case name != "":
+			a.names = []string{name}
+		case len(n.args) == 1 && n.args[0].op == "col":
+			a.names = []string{n.name + "_" + n.args[0].name}
+		default:
+			a.names = []string{n.name + "_"}
+		}
+		aggs = append(aggs, a)
+	}
+
+	// Group the rows by their by values, in order of first appearance.
+	type group struct {
+		keys []interface{}
+		rows []Row
+	}
+	var groups []*group
+	index := map[string]*group{}
+	for _, row := range t.Rows {
+		var keys []interface{}
+		for _, c := range by {
+			v, err := ev.scope(row).eval(c.expr)
+			if err != nil {
+				return Table{}, err
+			}
+			keys = append(keys, v)
+		}
+		k := fmt.Sprintf("%#v", keys)
+		g, ok := index[k]
+		if !ok {
+			g = &group{keys: keys}
+			index[k] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, row)
+	}
+	if len(by) == 0 && len(groups) == 0 {
+		groups = []*group{{}} // summarize without by always returns one row
+	}
+
+	out := Table{}
+	for _, c := range by {
+		out.Columns = appendColumn(out.Columns, c.name)
+	}
+	for _, a := range aggs {
+		for _, c := range a.names {
+			out.Columns = appendColumn(out.Columns, c)
+		}
+	}
+	starred := false
+	for _, a := range aggs {
+		for _, arg := range a.call.args {
+			if (a.call.name == "arg_max" || a.call.name == "arg_min") && arg.op == "col" && arg.name == "*" {
+				starred = true
+			}
+		}
+	}
+	if starred {
+		for _, c := range t.Columns {
+			out.Columns = appendColumn(out.Columns, c)
+		}
+	}
+
+	for _, g := range groups {
+		row := Row{}
+		for _, c := range out.Columns {
+			row[c] = nil
+		}
+		for i, c := range by {
+			row[c.name] = g.keys[i]
+		}
+		for _, a := range aggs {
+			if err := ev.aggregate(a, g.rows, row); err != nil {
+				return Table{}, err
+			}
+		}
+		out.Rows = append(out.Rows, row)
+	}
+	return out, nil
+}
+
+// aggregate runs one aggregation over a group's rows and sets its columns
+// in out.
+func (ev *evaluator) aggregate(a aggregation, rows []Row, out Row) error {
+	n := a.call
+	values := func(arg *node) ([]interface{}, error) {
+		var vals []interface{}
+		for _, row := range rows {
+			v, err := ev.scope(row).eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+		}
+		return vals, nil
+	}
+	want := func(count int) error {
+		if len(n.args) != count {
+			return ev.errorf(n.pos, "%s() takes %d arguments, got %d", n.name, count, len(n.args))
+		}
+		return nil
+	}
+
+	switch n.name {
+	case "count":
+		out[a.names[0]] = float64(len(rows))
+		return nil
+	case "countif":
+		if err := want(1); err != nil {
+			return err
+		}
+		count := 0
+		for _, row := range rows {
+			ok, err := ev.scope(row).boolean(n.args[0])
+			if err != nil {
+				return err
+			}
+			if ok {
+				count++
+			}
+		}
+		out[a.names[0]] = float64(count)
+		return nil
+	case "arg_max", "arg_min":
+		vals, err := values(n.args[0])
+		if err != nil {
+			return err
+		}
+		best := -1
+		for i, v := range vals {
+			if v == nil {
+				continue
+			}
+			if best < 0 {
+				best = i
+				continue
+			}
+			c, _, err := compare(v, vals[best])
+			if err != nil {
+				return ev.errorf(n.pos, "%s", err)
+			}
+			if (n.name == "arg_max" && c > 0) || (n.name == "arg_min" && c < 0) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return nil
+		}
+		row := rows[best]
+		out[a.names[0]] = vals[best]
+		for i, arg := range n.args[1:] {
+			if arg.op == "col" && arg.name == "*" {
+				for k, v := range row {
+					if _, set := out[k]; !set || out[k] == nil {
+						out[k] = v
+					}
+				}
+				continue
+			}
+			v, err := ev.scope(row).eval(arg)
+			if err != nil {
+				return err
+			}
+			out[a.names[i+1]] = v
+		}
+		return nil
+	}
+
+	if err := want(1); err != nil {
+		return err
+	}
+	vals, err := values(n.args[0])
+	if err != nil {
+		return err
+	}
+	var result interface{}
+	switch n.name {
+	case "dcount":
+		seen := map[string]bool{}
+		for _, v := range vals {
+			if v != nil {
+				seen[fmt.Sprintf("%#v", v)] = true
+			}
+		}
+		result = float64(len(seen))
+	case "sum", "avg":
+		sum, count := 0.0, 0
+		for _, v := range vals {
+			switch f := v.(type) {
+			case nil:
+			case float64:
+				sum, count = sum+f, count+1
+			default:
+				return ev.errorf(n.pos, "%s() needs numbers, got %s", n.name, typeName(v))
+			}
+		}
+		switch {
+		case n.name == "sum":
+			result = sum
+		case count > 0:
+			result = sum / float64(count)
+		}
+	case "min", "max":
+		for _, v := range vals {
+			if v == nil {
+				continue
+			}
+			if result == nil {
+				result = v
+				continue
+			}
+			c, _, err := compare(v, result)
+			if err != nil {
+				return ev.errorf(n.pos, "%s", err)
+			}
+			if (n.name == "max" && c > 0) || (n.name == "min" && c < 0) {
+				result = v
+			}
+		}
+	case "any", "take_any":
+		for _, v := range vals {
+			if v != nil {
+				result = v
+				break
+			}
+		}
+	}
+	out[a.names[0]] = result
+	return nil
+}
+
+// ── Joins ─────────────────────────────────────────────────────────────────────
+
+// join runs join, whose kinds are innerunique (the default), inner,
+// leftouter, leftsemi and leftanti, and lookup, whose kinds are leftouter
+// (the default) and inner. The right side is a table, a let name or a
+// parenthesised subquery; the keys are the same-named columns listed after
+// on, or $left.A == $right.B.
+func (ev *evaluator) join(left Table, s Stage) (Table, error) {
+	args := s.Args
+	kind := map[string]string{"join": "innerunique", "lookup": "leftouter"}[s.Operator]
+	i := 0
+	for ; i+2 < len(args) && args[i].Kind == Ident && args[i+1].Text == "="; i += 3 {
+		if args[i].Text == "kind" {
+			kind = args[i+2].Text
+		}
+	}
+	supported := map[string][]string{
+		"join":   {"innerunique", "inner", "leftouter", "leftsemi", "leftanti", "anti"},
+		"lookup": {"leftouter", "inner"},
+	}[s.Operator]
+	if !contains(supported, kind) {
+		return Table{}, ev.errorf(s.Pos, "unsupported %s kind %s", s.Operator, kind)
+	}
+
+	// ── Right side ──
+	if i >= len(args) {
+		return Table{}, ev.errorf(s.Pos, "%s needs a right side", s.Operator)
+	}
+	var (
+		right Table
+		err   error
+	)
+	if args[i].Text == "(" {
+		end, depth := i, 0
+		for ; end < len(args); end++ {
+			if args[end].Text == "(" {
+				depth++
+			} else if args[end].Text == ")" {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		right, err = ev.subquery(args[i+1 : end])
+		i = end + 1
+	} else {
+		right, err = ev.source(Stage{Operator: args[i].Text, Pos: args[i].Pos})
+		i++
+	}
+	if err != nil {
+		return Table{}, err
+	}
+
+	// ── Keys ──
+	if i >= len(args) || !isKeyword(args[i], "on") {
+		return Table{}, ev.errorf(s.Pos, "%s needs on", s.Operator)
+	}
+	var leftKeys, rightKeys []string
+	for _, cond := range SplitArgs(args[i+1:]) {
+		switch {
+		case len(cond) == 1 && cond[0].Kind == Ident:
+			leftKeys = append(leftKeys, cond[0].Text)
+			rightKeys = append(rightKeys, cond[0].Text)
+		case len(cond) == 7 && cond[1].Text == "." && cond[3].Text == "==" && cond[5].Text == ".":
+			sides := map[string]string{cond[0].Text: cond[2].Text, cond[4].Text: cond[6].Text}
+			l, r := sides["$left"], sides["$right"]
+			if l == "" || r == "" {
+				return Table{}, ev.errorf(cond[0].Pos, "expected $left.Column == $right.Column")
+			}
+			leftKeys = append(leftKeys, l)
+			rightKeys = append(rightKeys, r)
+		default:
+			return Table{}, ev.errorf(cond[0].Pos, "expected a column or $left.Column == $right.Column")
+		}
+	}
+	for j := range leftKeys {
+		if !contains(left.Columns, leftKeys[j]) {
+			return Table{}, ev.errorf(s.Pos, "unknown left column %s", leftKeys[j])
+		}
+		if !contains(right.Columns, rightKeys[j]) && len(right.Rows) > 0 {
+			return Table{}, ev.errorf(s.Pos, "unknown right column %s", rightKeys[j])
+		}
+	}
+
+	// ── Matching ──
+	matches := map[string][]Row{}
+	for _, row := range right.Rows {
+		if k, ok := joinKey(row, rightKeys); ok {
+			matches[k] = append(matches[k], row)
+		}
+	}
+	if s.Operator == "lookup" {
+		for k, rows := range matches {
+			matches[k] = rows[:1]
+		}
+	}
+
+	out := Table{Columns: append([]string{}, left.Columns...)}
+	names := map[string]string{} // right column to output column
+	if kind != "leftsemi" && kind != "leftanti" && kind != "anti" {
+		for _, c := range right.Columns {
+			if s.Operator == "lookup" && contains(rightKeys, c) && contains(leftKeys, c) {
+				continue // lookup does not repeat same-named keys
+			}
+			name := c
+			for n := 1; contains(out.Columns, name); n++ {
+				name = fmt.Sprintf("%s%d", c, n)
+			}
+			names[c] = name
+			out.Columns = append(out.Columns, name)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, row := range left.Rows {
+		k, ok := joinKey(row, leftKeys)
+		if kind == "innerunique" && ok {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		found := matches[k]
+		if !ok {
+			found = nil
+		}
+		switch kind {
+		case "leftsemi":
+			if len(found) > 0 {
+				out.Rows = append(out.Rows, row)
+			}
+			continue
+		case "leftanti", "anti":
+			if len(found) == 0 {
+				out.Rows = append(out.Rows, row)
+			}
+			continue
+		}
+		if len(found) == 0 && kind == "leftouter" {
+			found = []Row{nil}
+		}
+		for _, r := range found {
+			next := Row{}
+			for k, v := range row {
+				next[k] = v
+			}
+			for c, name := range names {
+				next[name] = r[c] // nil when r is nil
+			}
+			out.Rows = append(out.Rows, next)
+		}
+	}
+	return out, nil
+}
+
+// joinKey is the key of a row for matching; rows with a null key match
+// nothing.
+func joinKey(row Row, cols []string) (string, bool) {
+	var vals []interface{}
+	for _, c := range cols {
+		if row[c] == nil {
+			return "", false
+		}
+		vals = append(vals, row[c])
+	}
+	return fmt.Sprintf("%#v", vals), true
+}
+
+// ─── Helpers ─────────────────────────────────────────────────────────────────
+
+func key(row Row, cols []string) string {
+	vals := make([]interface{}, len(cols))
+	for i, c := range cols {
+		vals[i] = row[c]
+	}
+	return fmt.Sprintf("%#v", vals)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func appendColumn(cols []string, c string) []string {
+	if contains(cols, c) {
+		return cols
+	}
+	return append(cols, c)
+}
+
+// String formats a table as rows of column=value pairs, for test failures.
+func (t Table) String() string {
+	var b strings.Builder
+	for _, row := range t.Rows {
+		for i, c := range t.Columns {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "%s=%s", c, text(row[c]))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
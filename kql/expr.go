@@ -0,0 +1,737 @@
+package kql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// node is a parsed scalar expression.
+type node struct {
+	// op is "lit", "col" or "call", a unary "neg", or a binary or in operator
+	// as written, such as "==", "and" or "!in".
+	op   string
+	val  interface{} // of a lit
+	name string      // of a col or call
+	args []*node     // operands, or the arguments of a call
+	raw  string      // the text between the parentheses of a call
+	pos  int
+}
+
+// precedence of the binary operators, lowest first.
+var precedence = map[string]int{
+	"or": 1, "and": 2,
+	"==": 3, "!=": 3, "<>": 3, "<": 3, "<=": 3, ">": 3, ">=": 3, "=~": 3, "!~": 3,
+	"contains": 3, "!contains": 3, "has": 3, "!has": 3,
+	"startswith": 3, "!startswith": 3, "endswith": 3, "!endswith": 3,
+	"in": 3, "!in": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+type exprParser struct {
+	text string
+	toks []Token
+	i    int
+}
+
+// parseExpr parses toks, taken from text, as one scalar expression.
+func parseExpr(text string, toks []Token) (*node, error) {
+	if len(toks) == 0 {
+		return nil, &SyntaxError{Msg: "expected an expression"}
+	}
+	p := &exprParser{text: text, toks: toks}
+	n, err := p.binary(1)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.toks) {
+		return nil, p.errorf("unexpected %q", p.toks[p.i].Text)
+	}
+	return n, nil
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	pos := p.toks[len(p.toks)-1].Pos
+	if p.i < len(p.toks) {
+		pos = p.toks[p.i].Pos
+	}
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// operator returns the binary operator at the parser's position and the
+// number of tokens it takes, or "" when there is none.
+func (p *exprParser) operator() (string, int) {
+	if p.i >= len(p.toks) {
+		return "", 0
+	}
+	t := p.toks[p.i]
+	if t.Text == "!" && p.i+1 < len(p.toks) && p.toks[p.i+1].Kind == Ident {
+		if op := "!" + p.toks[p.i+1].Text; precedence[op] > 0 {
+			return op, 2
+		}
+	}
+	if t.Kind == String || t.Kind == Number {
+		return "", 0
+	}
+	if precedence[t.Text] > 0 {
+		return t.Text, 1
+	}
+	return "", 0
+}
+
+func (p *exprParser) binary(level int) (*node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, width := p.operator()
+		if op == "" || precedence[op] < level {
+			return left, nil
+		}
+		pos := p.toks[p.i].Pos
+		p.i += width
+		if op == "in" || op == "!in" {
+			list, err := p.list(false)
+			if err != nil {
+				return nil, err
+			}
+			left = &node{op: op, args: append([]*node{left}, list...), pos: pos}
+			continue
+		}
+		right, err := p.binary(precedence[op] + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &node{op: op, args: []*node{left, right}, pos: pos}
+	}
+}
+
+func (p *exprParser) unary() (*node, error) {
+	if p.i < len(p.toks) && p.toks[p.i].Text == "-" {
+		pos := p.toks[p.i].Pos
+		p.i++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &node{op: "neg", args: []*node{x}, pos: pos}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (*node, error) {
+	if p.i >= len(p.toks) {
+		return nil, p.errorf("expected an operand")
+	}
+	t := p.toks[p.i]
+	p.i++
+	switch t.Kind {
+	case Number:
+		v, err := number(t.Text)
+		if err != nil {
+			return nil, &SyntaxError{Pos: t.Pos, Msg: err.Error()}
+		}
+		return &node{op: "lit", val: v, pos: t.Pos}, nil
+	case String:
+		return &node{op: "lit", val: unquote(t.Text), pos: t.Pos}, nil
+	case Ident:
+		switch t.Text {
+		case "true":
+			return &node{op: "lit", val: true, pos: t.Pos}, nil
+		case "false":
+			return &node{op: "lit", val: false, pos: t.Pos}, nil
+		}
+		if p.i < len(p.toks) && p.toks[p.i].Text == "(" {
+			open := p.toks[p.i]
+			args, err := p.list(true)
+			if err != nil {
+				return nil, err
+			}
+			raw := p.text[open.Pos+1 : p.toks[p.i-1].Pos]
+			return &node{op: "call", name: t.Text, args: args, raw: raw, pos: t.Pos}, nil
+		}
+		return &node{op: "col", name: t.Text, pos: t.Pos}, nil
+	}
+	if t.Text == "(" {
+		n, err := p.binary(1)
+		if err != nil {
+			return nil, err
+		}
+		if p.i >= len(p.toks) || p.toks[p.i].Text != ")" {
+			return nil, p.errorf("expected )")
+		}
+		p.i++
+		return n, nil
+	}
+	p.i--
+	return nil, p.errorf("unexpected %q", t.Text)
+}
+
+// list parses a parenthesised, comma-separated list of expressions. With
+// raw, arguments that are not expressions, such as those of
+// datetime(2024-01-01), give a nil list rather than an error, and the caller
+// reads the text between the parentheses instead.
+func (p *exprParser) list(raw bool) ([]*node, error) {
+	if p.i >= len(p.toks) || p.toks[p.i].Text != "(" {
+		return nil, p.errorf("expected (")
+	}
+	end, depth := p.i, 0
+	for ; end < len(p.toks); end++ {
+		if closing[p.toks[end].Text] != "" {
+			depth++
+		} else if t := p.toks[end].Text; t == ")" || t == "]" || t == "}" {
+			if depth--; depth == 0 {
+				break
+			}
+		}
+	}
+	inner := p.toks[p.i+1 : end]
+	p.i = end + 1
+
+	var out []*node
+	for _, arg := range SplitArgs(inner) {
+		if len(arg) == 1 && arg[0].Text == "*" {
+			// All columns, as in arg_max(TimeGenerated, *).
+			out = append(out, &node{op: "col", name: "*", pos: arg[0].Pos})
+			continue
+		}
+		n, err := parseExpr(p.text, arg)
+		if err != nil {
+			if raw {
+				return nil, nil
+			}
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// number parses a number literal, or a timespan such as 15m or 1.5d.
+func number(text string) (interface{}, error) {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"ms", time.Millisecond}, {"d", 24 * time.Hour}, {"h", time.Hour},
+		{"m", time.Minute}, {"s", time.Second},
+	}
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(text, u.suffix); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad timespan %q", text)
+			}
+			return time.Duration(f * float64(u.unit)), nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad number %q", text)
+	}
+	return f, nil
+}
+
+// unquote returns the value of a string literal.
+func unquote(text string) string {
+	if strings.HasPrefix(text, "@") {
+		return text[2 : len(text)-1]
+	}
+	body := text[1 : len(text)-1]
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' || i+1 == len(body) {
+			b.WriteByte(body[i])
+			continue
+		}
+		i++
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(body[i])
+		}
+	}
+	return b.String()
+}
+
+// ─── Evaluation ──────────────────────────────────────────────────────────────
+
+// scope is what an expression can refer to: the columns of the current row
+// and the scalars bound with let.
+type scope struct {
+	row     Row
+	scalars map[string]interface{}
+	now     time.Time
+}
+
+func evalError(n *node, format string, args ...interface{}) error {
+	return &EvalError{Pos: n.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (s scope) eval(n *node) (interface{}, error) {
+	switch n.op {
+	case "lit":
+		return n.val, nil
+	case "col":
+		if v, ok := s.row[n.name]; ok {
+			return v, nil
+		}
+		if v, ok := s.scalars[n.name]; ok {
+			return v, nil
+		}
+		return nil, evalError(n, "unknown column %s", n.name)
+	case "call":
+		return s.call(n)
+	case "and", "or":
+		l, err := s.boolean(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "and" && !l) || (n.op == "or" && l) {
+			return l, nil
+		}
+		return s.boolean(n.args[1])
+	case "in", "!in":
+		x, err := s.eval(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range n.args[1:] {
+			v, err := s.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			if c, ok, _ := compare(x, v); ok && c == 0 {
+				return n.op == "in", nil
+			}
+		}
+		return n.op == "!in", nil
+	}
+
+	var vals []interface{}
+	for _, a := range n.args {
+		v, err := s.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	if n.op == "neg" {
+		return arithmetic("-", 0.0, vals[0], n)
+	}
+	switch n.op {
+	case "+", "-", "*", "/", "%":
+		return arithmetic(n.op, vals[0], vals[1], n)
+	case "=~", "!~":
+		if vals[0] == nil || vals[1] == nil {
+			return false, nil
+		}
+		return strings.EqualFold(text(vals[0]), text(vals[1])) == (n.op == "=~"), nil
+	}
+	if f := stringOps[strings.TrimPrefix(n.op, "!")]; f != nil {
+		if vals[0] == nil || vals[1] == nil {
+			return false, nil
+		}
+		return f(strings.ToLower(text(vals[0])), strings.ToLower(text(vals[1]))) == !strings.HasPrefix(n.op, "!"), nil
+	}
+
+	c, ok, err := compare(vals[0], vals[1])
+	if err != nil {
+		return nil, evalError(n, "%s", err)
+	}
+	if !ok {
+		return false, nil // a comparison with null is false
+	}
+	switch n.op {
+	case "==":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, evalError(n, "unsupported operator %s", n.op)
+}
+
+// boolean evaluates n and requires a bool; null counts as false.
+func (s scope) boolean(n *node) (bool, error) {
+	v, err := s.eval(n)
+	if err != nil {
+		return false, err
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case nil:
+		return false, nil
+	}
+	return false, evalError(n, "expected a bool, got %s", typeName(v))
+}
+
+// stringOps are the case-insensitive string operators; each also has a
+// negated form written with a leading !.
+var stringOps = map[string]func(s, sub string) bool{
+	"contains":   strings.Contains,
+	"startswith": strings.HasPrefix,
+	"endswith":   strings.HasSuffix,
+	"has":        hasTerm,
+}
+
+// hasTerm reports whether sub is a whole term of s: bounded by the ends of s
+// or by characters that are not letters or digits.
+func hasTerm(s, sub string) bool {
+	isAlnum := func(c byte) bool {
+		return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i:i+len(sub)] != sub {
+			continue
+		}
+		if (i == 0 || !isAlnum(s[i-1])) && (i+len(sub) == len(s) || !isAlnum(s[i+len(sub)])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s scope) call(n *node) (interface{}, error) {
+	if aggregates[n.name] {
+		return nil, evalError(n, "%s() is an aggregation, only allowed in summarize", n.name)
+	}
+	if n.name == "datetime" {
+		t, err := parseTime(strings.Trim(strings.TrimSpace(n.raw), `"'`))
+		if err != nil {
+			return nil, evalError(n, "bad datetime %q", n.raw)
+		}
+		return t, nil
+	}
+	if n.args == nil && strings.TrimSpace(n.raw) != "" {
+		return nil, evalError(n, "cannot parse the arguments of %s()", n.name)
+	}
+
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := s.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	want := func(count int) error {
+		if len(args) != count {
+			return evalError(n, "%s() takes %d arguments, got %d", n.name, count, len(args))
+		}
+		return nil
+	}
+
+	switch n.name {
+	case "now":
+		if len(args) == 0 {
+			return s.now, nil
+		}
+		return arithmetic("+", s.now, args[0], n)
+	case "ago":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		return arithmetic("-", s.now, args[0], n)
+	case "bin", "floor":
+		if err := want(2); err != nil {
+			return nil, err
+		}
+		return bin(args[0], args[1], n)
+	case "not":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		b, err := s.boolean(n.args[0])
+		return !b, err
+	case "iff", "iif":
+		if err := want(3); err != nil {
+			return nil, err
+		}
+		b, err := s.boolean(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return args[1], nil
+		}
+		return args[2], nil
+	case "isnull", "isnotnull":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		return (args[0] == nil) == (n.name == "isnull"), nil
+	case "isempty", "isnotempty":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		return (args[0] == nil || args[0] == "") == (n.name == "isempty"), nil
+	case "tostring":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		if args[0] == nil {
+			return "", nil
+		}
+		return text(args[0]), nil
+	case "tolower", "toupper":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		if n.name == "tolower" {
+			return strings.ToLower(text(args[0])), nil
+		}
+		return strings.ToUpper(text(args[0])), nil
+	case "strcat":
+		var b strings.Builder
+		for _, a := range args {
+			if a != nil {
+				b.WriteString(text(a))
+			}
+		}
+		return b.String(), nil
+	case "toint", "tolong", "todouble", "toreal":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case float64:
+			if n.name == "toint" || n.name == "tolong" {
+				return math.Trunc(v), nil
+			}
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, nil
+			}
+			return f, nil
+		}
+		return nil, nil
+	}
+	return nil, evalError(n, "unsupported function %s()", n.name)
+}
+
+// ─── Values ──────────────────────────────────────────────────────────────────
+
+// Values are nil for null, bool, float64 for every number, string,
+// time.Time for a datetime and time.Duration for a timespan.
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case time.Time:
+		return "datetime"
+	case time.Duration:
+		return "timespan"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// text formats a value the way tostring() does.
+func text(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// parseTime parses the datetime forms fixtures and datetime() use.
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad datetime %q", s)
+}
+
+// compare orders two values. It reports false when either is null, and
+// fails for values of types that cannot be compared. A string compared with
+// a datetime is read as a datetime.
+func compare(a, b interface{}) (int, bool, error) {
+	if a == nil || b == nil {
+		return 0, false, nil
+	}
+	if s, ok := a.(string); ok {
+		if _, isTime := b.(time.Time); isTime {
+			if t, err := parseTime(s); err == nil {
+				a = t
+			}
+		}
+	}
+	if s, ok := b.(string); ok {
+		if _, isTime := a.(time.Time); isTime {
+			if t, err := parseTime(s); err == nil {
+				b = t
+			}
+		}
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return cmp3(x < y, x > y), true, nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true, nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			return cmp3(!x && y, x && !y), true, nil
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true, nil
+		}
+	case time.Duration:
+		if y, ok := b.(time.Duration); ok {
+			return cmp3(x < y, x > y), true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("cannot compare %s with %s", typeName(a), typeName(b))
+}
+
+func cmp3(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// arithmetic applies a binary arithmetic operator; null in gives null out.
+func arithmetic(op string, a, b interface{}, n *node) (interface{}, error) {
+	if a == nil || b == nil {
+		return nil, nil
+	}
+	switch x := a.(type) {
+	case float64:
+		switch y := b.(type) {
+		case float64:
+			switch op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			case "*":
+				return x * y, nil
+			case "/":
+				if y == 0 {
+					return nil, nil
+				}
+				return x / y, nil
+			case "%":
+				if y == 0 {
+					return nil, nil
+				}
+				return math.Mod(x, y), nil
+			}
+		case time.Duration:
+			switch op {
+			case "*":
+				return time.Duration(x * float64(y)), nil
+			case "-":
+				if x == 0 {
+					return -y, nil // unary minus
+				}
+			}
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return x.Add(y), nil
+			case "-":
+				return x.Add(-y), nil
+			}
+		case time.Time:
+			if op == "-" {
+				return x.Sub(y), nil
+			}
+		}
+	case time.Duration:
+		switch y := b.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			case "/":
+				if y == 0 {
+					return nil, nil
+				}
+				return float64(x) / float64(y), nil
+			}
+		case float64:
+			switch op {
+			case "*":
+				return time.Duration(float64(x) * y), nil
+			case "/":
+				if y == 0 {
+					return nil, nil
+				}
+				return time.Duration(float64(x) / y), nil
+			}
+		case time.Time:
+			if op == "+" {
+				return y.Add(x), nil
+			}
+		}
+	}
+	return nil, evalError(n, "cannot apply %s to %s and %s", op, typeName(a), typeName(b))
+}
+
+// bin rounds a number, datetime or timespan down to a multiple of size.
+func bin(v, size interface{}, n *node) (interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if s, ok := size.(float64); ok && s > 0 {
+			return math.Floor(x/s) * s, nil
+		}
+	case time.Time:
+		if s, ok := size.(time.Duration); ok && s > 0 {
+			return x.UTC().Truncate(s), nil
+		}
+	case time.Duration:
+		if s, ok := size.(time.Duration); ok && s > 0 {
+			return x.Truncate(s), nil
+		}
+	}
+	return nil, evalError(n, "cannot bin %s by %s", typeName(v), typeName(size))
+}
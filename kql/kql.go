@@ -0,0 +1,217 @@
+// Package kql reads just enough of the Kusto Query Language to check the
+// queries of alert rules, and to run them over fixture tables.
+//
+// Tokenize splits a query into tokens, and Parse splits those into
+// statements and pipeline stages without checking the operators' arguments.
+// Tables walks the tokens and picks out the identifiers that start a tabular
+// expression: at the start of a statement or of a let binding, inside the
+// parentheses of a join or lookup, and in a union's operand list. Names
+// bound with let are not tables. Eval runs a query in memory against the
+// tables of an Env, such as one read by LoadFixture.
+package kql
+
+import (
+	"strings"
+)
+
+// Kind is the kind of a token.
+type Kind int
+
+// Token kinds.
+const (
+	Ident Kind = iota
+	Number
+	String
+	Punct
+)
+
+// Token is one lexical token of a query.
+type Token struct {
+	Kind Kind
+	Text string
+	// Pos is the byte offset of the token in the query.
+	Pos int
+}
+
+// twoCharPuncts are the operators lexed as one token.
+var twoCharPuncts = []string{"==", "!=", "<>", "<=", ">=", "=~", "!~", "..", "=>"}
+
+// Tokenize splits a query into tokens, dropping whitespace and // comments.
+// A number keeps its unit suffix, so a timespan such as 24h or 1.5d is one
+// token. An unterminated string runs to the end of the query.
+func Tokenize(query string) []Token {
+	var out []Token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '/' && i+1 < len(query) && query[i+1] == '/':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '\'' || (c == '@' && i+1 < len(query) && (query[i+1] == '"' || query[i+1] == '\'')):
+			start := i
+			verbatim := c == '@'
+			if verbatim {
+				i++
+			}
+			quote := query[i]
+			i++
+			for i < len(query) && query[i] != quote {
+				if query[i] == '\\' && !verbatim {
+					i++
+				}
+				i++
+			}
+			if i < len(query) {
+				i++
+			}
+			out = append(out, Token{Kind: String, Text: query[start:min(i, len(query))], Pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(query) && isIdentPart(query[i]) {
+				i++
+			}
+			out = append(out, Token{Kind: Ident, Text: query[start:i], Pos: start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(query) && (isIdentPart(query[i]) || (query[i] == '.' && i+1 < len(query) && query[i+1] != '.')) {
+				i++
+			}
+			out = append(out, Token{Kind: Number, Text: query[start:i], Pos: start})
+		default:
+			text := query[i : i+1]
+			for _, p := range twoCharPuncts {
+				if strings.HasPrefix(query[i:], p) {
+					text = p
+					break
+				}
+			}
+			out = append(out, Token{Kind: Punct, Text: text, Pos: i})
+			i += len(text)
+		}
+	}
+	return out
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// Tables returns the tables the query reads, in order of first appearance.
+func Tables(query string) []string {
+	toks := Tokenize(query)
+	bound := map[string]bool{}
+	for i := 0; i+2 < len(toks); i++ {
+		if isKeyword(toks[i], "let") && toks[i+1].Kind == Ident && toks[i+2].Text == "=" {
+			bound[toks[i+1].Text] = true
+		}
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	add := func(t Token) {
+		if t.Kind == Ident && !bound[t.Text] && !seen[t.Text] {
+			seen[t.Text] = true
+			out = append(out, t.Text)
+		}
+	}
+
+	source := true // the next token starts a tabular expression
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch {
+		case source:
+			source = false
+			switch {
+			case isKeyword(t, "let"):
+				// let name = <expression>: the expression is a source.
+				if i+2 < len(toks) && toks[i+2].Text == "=" {
+					i += 2
+					source = true
+				}
+			case isKeyword(t, "union"):
+				i = unionTables(toks, i+1, add)
+			case t.Text == "(":
+				source = true
+			case isKeyword(t, "print") || isKeyword(t, "range"):
+				// These make their own rows and read no table.
+			default:
+				if i+1 < len(toks) && toks[i+1].Text == "(" {
+					continue // a function call such as datatable(...) or range(...)
+				}
+				add(t)
+			}
+		case t.Text == ";":
+			source = true
+		case isKeyword(t, "union") && i > 0 && toks[i-1].Text == "|":
+			i = unionTables(toks, i+1, add)
+		case (isKeyword(t, "join") || isKeyword(t, "lookup")) && i > 0 && toks[i-1].Text == "|":
+			j := skipParams(toks, i+1)
+			if j < len(toks) {
+				if toks[j].Text == "(" {
+					i = j
+					source = true
+				} else {
+					add(toks[j])
+					i = j
+				}
+			}
+		}
+	}
+	return out
+}
+
+// unionTables adds the tables of a union operand list starting at toks[i]
+// and returns the index of the last token it consumed. Of a parenthesised
+// operand only the source is read.
+func unionTables(toks []Token, i int, add func(Token)) int {
+	i = skipParams(toks, i)
+	for i < len(toks) {
+		t := toks[i]
+		if t.Text == "(" {
+			if i+1 < len(toks) {
+				add(toks[i+1])
+			}
+			depth := 0
+			for ; i < len(toks); i++ {
+				if toks[i].Text == "(" {
+					depth++
+				} else if toks[i].Text == ")" {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+		} else if t.Kind == Ident {
+			add(t)
+		} else {
+			return i - 1
+		}
+		if i+1 < len(toks) && toks[i+1].Text == "," {
+			i += 2
+			continue
+		}
+		return i
+	}
+	return i
+}
+
+// skipParams skips operator parameters such as kind=leftouter or
+// withsource=SourceTable and returns the index of the first other token.
+func skipParams(toks []Token, i int) int {
+	for i+2 < len(toks) && toks[i].Kind == Ident && toks[i+1].Text == "=" {
+		i += 3
+	}
+	return i
+}
+
+func isKeyword(t Token, kw string) bool {
+	return t.Kind == Ident && t.Text == kw
+}
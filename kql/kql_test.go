@@ -0,0 +1,133 @@
+package kql_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/kql"
+)
+
+// TestEval runs the operators the alert queries use over a small table, and
+// checks that unsupported or malformed queries fail at the right offset.
+func TestEval(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	env := kql.Env{Now: now, Tables: map[string]kql.Table{
+		"Jobs": {
+			Columns: []string{"TimeGenerated", "Item", "Status", "Duration"},
+			Rows: []kql.Row{
+				{"TimeGenerated": now.Add(-10 * time.Minute), "Item": "a", "Status": "Failed", "Duration": 30.0},
+				{"TimeGenerated": now.Add(-50 * time.Minute), "Item": "a", "Status": "Completed", "Duration": 90.0},
+				{"TimeGenerated": now.Add(-70 * time.Minute), "Item": "b", "Status": "Failed", "Duration": nil},
+				{"TimeGenerated": now.Add(-3 * time.Hour), "Item": "c", "Status": "Completed", "Duration": 60.0},
+			},
+		},
+		"Items": {
+			Columns: []string{"Item", "Owner"},
+			Rows: []kql.Row{
+				{"Item": "a", "Owner": "app"},
+				{"Item": "b", "Owner": "db"},
+				{"Item": "b", "Owner": "dba"},
+			},
+		},
+	}}
+
+	for _, tc := range []struct{ name, query, want string }{
+		{
+			name:  "where and project",
+			query: `Jobs | where TimeGenerated > ago(1h) and Status =~ "failed" | project Item, Status`,
+			want:  "Item=a Status=Failed\n",
+		},
+		{
+			name:  "summarize by bin",
+			query: `Jobs | summarize Jobs = count(), Failed = countif(Status == "Failed"), avg(Duration) by bin(TimeGenerated, 1h) | sort by TimeGenerated asc`,
+			want: "TimeGenerated=2026-03-02T09:00:00Z Jobs=1 Failed=0 avg_Duration=60\n" +
+				"TimeGenerated=2026-03-02T10:00:00Z Jobs=1 Failed=1 avg_Duration=\n" +
+				"TimeGenerated=2026-03-02T11:00:00Z Jobs=2 Failed=1 avg_Duration=60\n",
+		},
+		{
+			name:  "summarize without rows",
+			query: `Jobs | where Status == "Running" | summarize count(), max(TimeGenerated)`,
+			want:  "count_=0 max_TimeGenerated=\n",
+		},
+		{
+			name:  "arg_max",
+			query: `Jobs | summarize arg_max(TimeGenerated, Status) by Item | project Item, Status`,
+			want:  "Item=a Status=Failed\nItem=b Status=Failed\nItem=c Status=Completed\n",
+		},
+		{
+			name:  "let and count",
+			query: "let window = 1h;\nlet failed = Jobs | where Status == \"Failed\";\nfailed | where TimeGenerated > now() - window | count",
+			want:  "Count=1\n",
+		},
+		{
+			name:  "join innerunique",
+			query: `Jobs | where Item == "b" | join Items on Item | project Item, Item1, Owner`,
+			want:  "Item=b Item1=b Owner=db\nItem=b Item1=b Owner=dba\n",
+		},
+		{
+			name:  "join leftouter",
+			query: `Jobs | join kind=leftouter (Items | where Owner != "dba") on $left.Item == $right.Item | project Item, Owner | top 2 by Item asc`,
+			want:  "Item=a Owner=app\nItem=a Owner=app\n",
+		},
+		{
+			name:  "join leftanti",
+			query: `Jobs | join kind=leftanti Items on Item | project Item`,
+			want:  "Item=c\n",
+		},
+		{
+			name:  "lookup",
+			query: `Jobs | where Status == "Failed" | lookup Items on Item | project-away TimeGenerated, Duration`,
+			want:  "Item=a Status=Failed Owner=app\nItem=b Status=Failed Owner=db\n",
+		},
+		{
+			name:  "extend and rename",
+			query: `Jobs | where Item in ("b", "c") | extend Age = now() - TimeGenerated | where Age > 1h and isnotnull(Duration) | project-rename Backup = Item | project Backup, Age`,
+			want:  "Backup=c Age=3h0m0s\n",
+		},
+		{
+			name:  "string operators",
+			query: `Jobs | where Status !contains "complete" and Status has "failed" | distinct Item`,
+			want:  "Item=a\nItem=b\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := kql.Eval(tc.query, env)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.String())
+		})
+	}
+
+	for query, want := range map[string]string{
+		`Heartbeat | take 1`:                           "kql: offset 0: unknown table Heartbeat",
+		`Jobs | where Statu == "Failed"`:               "kql: offset 13: unknown column Statu",
+		`Jobs | where Duration > "long"`:               "kql: offset 22: cannot compare number with string",
+		`Jobs | mv-expand Item`:                        "kql: offset 7: unsupported operator mv-expand",
+		`Jobs | summarize percentile(Duration, 95)`:    "kql: offset 17: summarize takes aggregations such as count() or max(Column)",
+		`Jobs | join kind=fullouter Items on Item`:     "kql: offset 7: unsupported join kind fullouter",
+		`Jobs | extend Duration * 2`:                   "kql: offset 14: name the expression, as Name = expression",
+		`let window = 1h;`:                             "kql: offset 16: the query returns no table",
+		`Jobs | where TimeGenerated > datetime(bogus)`: "kql: offset 29: bad datetime \"bogus\"",
+	} {
+		_, err := kql.Eval(query, env)
+		if assert.Error(t, err, query) {
+			assert.Equal(t, want, err.Error(), query)
+		}
+	}
+}
+
+// TestTables checks the tables a query reads are found through let, join,
+// lookup and union, and not in strings or comments.
+func TestTables(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"AddonAzureBackupJobs", "AddonAzureBackupAlerts"}, kql.Tables(`let jobs = AddonAzureBackupJobs | where JobStatus == "Failed";
+jobs | lookup kind=leftouter (AddonAzureBackupAlerts | project AlertJobUniqueId) on $left.JobUniqueId == $right.AlertJobUniqueId`))
+	assert.Equal(t, []string{"CoreAzureBackup", "AddonAzureBackupPolicy", "AddonAzureBackupStorage"},
+		kql.Tables(`union withsource=Source CoreAzureBackup, (AddonAzureBackupPolicy | take 5) | join AddonAzureBackupStorage on VaultUniqueId`))
+	assert.Empty(t, kql.Tables(`print "Heartbeat | take 1" // Heartbeat`), "strings and comments are not tables")
+}
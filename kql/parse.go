@@ -0,0 +1,164 @@
+package kql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statement is one statement of a query: a let binding or the tabular
+// expression the query returns.
+type Statement struct {
+	// Let is the name the statement binds; empty for the query's result.
+	Let string
+	// Stages is the statement's pipeline, source first.
+	Stages []Stage
+}
+
+// Stage is one step of a pipeline. The first stage is the source: its
+// Operator is the table, let name or function it starts from, or empty when
+// it starts with anything else, such as a parenthesised expression. Every
+// other stage is a tabular operator, with hyphenated names such as
+// project-away kept whole.
+type Stage struct {
+	Operator string
+	Args     []Token
+	// Pos is the byte offset of the stage in the query.
+	Pos int
+
+	tokens []Token // the stage as written, operator included
+}
+
+// SyntaxError is a query Parse cannot split into statements and stages.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("kql: offset %d: %s", e.Pos, e.Msg)
+}
+
+var closing = map[string]string{"(": ")", "[": "]", "{": "}"}
+
+// Parse splits a query into its statements and their pipelines. It checks
+// that strings end, brackets balance and no stage is empty; it does not
+// check the arguments of the operators.
+func Parse(query string) ([]Statement, error) {
+	toks := Tokenize(query)
+	if err := checkTokens(toks); err != nil {
+		return nil, err
+	}
+	return statements(toks)
+}
+
+// statements splits checked tokens into statements.
+func statements(toks []Token) ([]Statement, error) {
+	var out []Statement
+	for _, stmt := range splitTop(toks, ";") {
+		if len(stmt) == 0 {
+			continue
+		}
+		var s Statement
+		if isKeyword(stmt[0], "let") {
+			if len(stmt) < 4 || stmt[1].Kind != Ident || stmt[2].Text != "=" {
+				return nil, &SyntaxError{Pos: stmt[0].Pos, Msg: "let needs a name, = and a value"}
+			}
+			s.Let = stmt[1].Text
+			stmt = stmt[3:]
+		}
+		start := 0 // index in stmt of the current part
+		for i, part := range splitTop(stmt, "|") {
+			if len(part) == 0 {
+				// Point at the | that ends the empty stage, or at the one
+				// before it when the statement ends in a |.
+				pos := stmt[len(stmt)-1].Pos
+				if start < len(stmt) {
+					pos = stmt[start].Pos
+				}
+				return nil, &SyntaxError{Pos: pos, Msg: "empty pipeline stage"}
+			}
+			start += len(part) + 1
+			stage := Stage{Args: part, Pos: part[0].Pos, tokens: part}
+			if part[0].Kind == Ident {
+				stage.Operator, stage.Args = operatorName(part)
+			} else if i > 0 {
+				return nil, &SyntaxError{Pos: part[0].Pos, Msg: fmt.Sprintf("expected an operator after |, got %q", part[0].Text)}
+			}
+			s.Stages = append(s.Stages, stage)
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		pos := 0
+		if len(toks) > 0 {
+			pos = toks[0].Pos
+		}
+		return nil, &SyntaxError{Pos: pos, Msg: "empty query"}
+	}
+	return out, nil
+}
+
+// checkTokens rejects unterminated strings and unbalanced brackets.
+func checkTokens(toks []Token) error {
+	var open []Token
+	for _, t := range toks {
+		switch {
+		case t.Kind == String:
+			text := strings.TrimPrefix(t.Text, "@")
+			if len(text) < 2 || text[len(text)-1] != text[0] {
+				return &SyntaxError{Pos: t.Pos, Msg: "unterminated string"}
+			}
+		case closing[t.Text] != "":
+			open = append(open, t)
+		case t.Text == ")" || t.Text == "]" || t.Text == "}":
+			if len(open) == 0 || closing[open[len(open)-1].Text] != t.Text {
+				return &SyntaxError{Pos: t.Pos, Msg: fmt.Sprintf("unexpected %q", t.Text)}
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		t := open[len(open)-1]
+		return &SyntaxError{Pos: t.Pos, Msg: fmt.Sprintf("%q is never closed", t.Text)}
+	}
+	return nil
+}
+
+// splitTop splits toks at the separator where it is outside any brackets.
+func splitTop(toks []Token, sep string) [][]Token {
+	var out [][]Token
+	depth, start := 0, 0
+	for i, t := range toks {
+		switch {
+		case closing[t.Text] != "":
+			depth++
+		case t.Text == ")" || t.Text == "]" || t.Text == "}":
+			depth--
+		case depth == 0 && t.Text == sep:
+			out = append(out, toks[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, toks[start:])
+}
+
+// SplitArgs splits the arguments of an operator at the commas outside any
+// brackets.
+func SplitArgs(args []Token) [][]Token {
+	if len(args) == 0 {
+		return nil
+	}
+	return splitTop(args, ",")
+}
+
+// operatorName joins a hyphenated operator name written without spaces and
+// returns it with the tokens that follow.
+func operatorName(part []Token) (string, []Token) {
+	name, i := part[0].Text, 1
+	for i+1 < len(part) && part[i].Text == "-" && part[i+1].Kind == Ident &&
+		part[i-1].Pos+len(part[i-1].Text) == part[i].Pos && part[i].Pos+1 == part[i+1].Pos {
+		name += "-" + part[i+1].Text
+		i += 2
+	}
+	return name, part[i:]
+}
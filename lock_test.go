@@ -0,0 +1,113 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/teardown"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Management lock ───────────────────────────────────────────────────
+
+// TestManagementLockAgainstFake seeds the fake backend from the plan, whose
+// vault carries the module's CanNotDelete lock, and checks that deleting a
+// policy, the vault or its resource group is refused while the lock is in
+// place, and that the teardown purge removes the lock before deleting the
+// vault.
+func TestManagementLockAgainstFake(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "the management lock refuses deletion of the vault and its policies", "MINITRUE-9348")
+
+	fake := seededFake(t)
+	sub := fake.SubscriptionID()
+	rg, vaultID, vault := fake.ResourceGroup, fake.VaultID, fake.Vault
+	policyID := fake.Attr("azurerm_backup_policy_vm.standard", "id")
+	lockID := fake.Attr("azurerm_management_lock.rsv_vault", "id")
+	cfg := teardown.Config{SubscriptionID: sub, Credential: fake.Credential(), Options: fake.ClientOptions(), PollInterval: 1}
+
+	// ── The planned lock ──────────────────────────────────────────────────
+	locks, err := teardown.Locks(t.Context(), cfg, vaultID)
+	require.NoError(t, err)
+	assert.Equal(t, []teardown.Lock{{ID: lockID, Name: "rsv-vault-delete-lock", Level: "CanNotDelete", Scope: vaultID}}, locks)
+	locks, err = teardown.Locks(t.Context(), cfg, policyID)
+	require.NoError(t, err)
+	assert.Len(t, locks, 1, "the lock on the vault applies to its policies")
+
+	// ── Deletes are refused ───────────────────────────────────────────────
+	policies, err := armrecoveryservicesbackup.NewProtectionPoliciesClient(sub, fake.Credential(), fake.ClientOptions())
+	require.NoError(t, err)
+	_, err = policies.BeginDelete(t.Context(), vault, rg, resourceName(policyID), nil)
+	assertScopeLocked(t, err, "deleting the standard policy")
+
+	vaults, err := armrecoveryservices.NewVaultsClient(sub, fake.Credential(), fake.ClientOptions())
+	require.NoError(t, err)
+	_, err = vaults.Delete(t.Context(), rg, vault, nil)
+	assertScopeLocked(t, err, "deleting the vault")
+
+	groups, err := armresources.NewResourceGroupsClient(sub, fake.Credential(), fake.ClientOptions())
+	require.NoError(t, err)
+	_, err = groups.BeginDelete(t.Context(), rg, nil)
+	assertScopeLocked(t, err, "deleting the resource group holding the vault")
+
+	for _, id := range []string{policyID, vaultID, lockID} {
+		_, ok := fake.Get(id)
+		assert.True(t, ok, "%s survives", id)
+	}
+
+	// ── Teardown removes the lock ─────────────────────────────────────────
+	report, err := teardown.PurgeVault(t.Context(), cfg, rg, vault)
+	t.Log(report)
+	require.NoError(t, err)
+	if assert.NotEmpty(t, report.Steps) {
+		assert.Equal(t, "remove lock rsv-vault-delete-lock", report.Steps[0].Action+" "+report.Steps[0].Target)
+	}
+	for _, id := range []string{lockID, policyID, vaultID} {
+		_, ok := fake.Get(id)
+		assert.False(t, ok, "%s is gone after the purge", id)
+	}
+
+	// A lock inherited from the resource group is not the purge's to remove.
+	fake.Put(vaultID, map[string]interface{}{"location": "eastus", "properties": map[string]interface{}{}})
+	groupLock := "/subscriptions/" + sub + "/resourceGroups/" + rg + "/providers/Microsoft.Authorization/locks/rg-lock"
+	fake.Put(groupLock, map[string]interface{}{"properties": map[string]interface{}{"level": "CanNotDelete"}})
+	report, err = teardown.PurgeVault(t.Context(), cfg, rg, vault)
+	t.Log(report)
+	require.Error(t, err)
+	_, ok := fake.Get(groupLock)
+	assert.True(t, ok, "the resource group's lock is left alone")
+	_, ok = fake.Get(vaultID)
+	assert.True(t, ok, "the vault is not deleted under an inherited lock")
+}
+
+// assertScopeLocked asserts that err is ARM refusing an operation because of
+// a management lock.
+func assertScopeLocked(t *testing.T, err error, msgAndArgs ...interface{}) {
+	t.Helper()
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		assert.Fail(t, fmt.Sprintf("want a ScopeLocked error, got %v", err), msgAndArgs...)
+		return
+	}
+	assert.Equal(t, "ScopeLocked", respErr.ErrorCode, msgAndArgs...)
+}
+
+// assertNotFound asserts that err is ARM reporting the resource as missing.
+func assertNotFound(t *testing.T, err error, msgAndArgs ...interface{}) {
+	t.Helper()
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		assert.Fail(t, fmt.Sprintf("want a 404, got %v", err), msgAndArgs...)
+		return
+	}
+	assert.Equal(t, http.StatusNotFound, respErr.StatusCode, msgAndArgs...)
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/alerteval"
+	"github.com/SwastikaAryal/azure_terraform/alertlint"
+	"github.com/SwastikaAryal/azure_terraform/commonalert"
+	"github.com/SwastikaAryal/azure_terraform/kql"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Alert notifications (Sprint 3) ────────────────────────────────────
+
+// TestAlertNotifications fires the planned scheduled query rules over the
+// fixture logs, posts the notifications their action group would send to a
+// local webhook receiver, and checks what arrives: the severity, the rule,
+// the affected vault and the custom properties. It then checks the receiver
+// turns away payloads Azure would not send.
+func TestAlertNotifications(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "backup alerts notify webhooks with Common Alert Schema payloads naming the rule, severity and vault", "Sprint 3")
+
+	raw, err := os.ReadFile("testdata/synthetic_plan.json")
+	require.NoError(t, err)
+	plan, err := terraform.ParsePlanJSON(string(raw))
+	require.NoError(t, err)
+
+	// The rules' scopes are unknown until apply; the fake assigns the
+	// workspace its ID.
+	fake := seededFake(t)
+	lawID := fake.Attr("azurerm_log_analytics_workspace.backup[0]", "id")
+
+	rules := map[string]alertlint.Rule{}
+	for _, r := range alertlint.FromPlan(plan) {
+		r.Scopes = []string{lawID}
+		rules[r.Name] = r
+	}
+
+	// ── Action group ──────────────────────────────────────────────────────
+	t.Run("ActionGroup", func(t *testing.T) {
+		ag, ok := plan.ResourcePlannedValuesMap["azurerm_monitor_action_group.backup_alerts"]
+		require.True(t, ok, "the plan has no action group")
+		receivers, _ := ag.AttributeValues["webhook_receiver"].([]interface{})
+		require.NotEmpty(t, receivers, "the action group should have a webhook receiver")
+		for _, r := range receivers {
+			r, _ := r.(map[string]interface{})
+			assert.Equal(t, true, r["use_common_alert_schema"], "webhook receiver %v should use the common alert schema", r["name"])
+			assert.True(t, strings.HasPrefix(fmt.Sprint(r["service_uri"]), "https://"), "webhook receiver %v should post over https", r["name"])
+		}
+	})
+
+	// ── Delivered ─────────────────────────────────────────────────────────
+	t.Run("Delivered", func(t *testing.T) {
+		receiver := commonalert.NewReceiver()
+		defer receiver.Close()
+
+		for _, tc := range []struct{ rule, fixture string }{
+			{"alert-backup-job-failure", "jobs_failed.json"},
+			{"alert-backup-not-run-24h", "items_stale.json"},
+		} {
+			r, ok := rules[tc.rule]
+			require.True(t, ok, "%s is not in the plan", tc.rule)
+			env, err := kql.LoadFixture("testdata/logs/" + tc.fixture)
+			require.NoError(t, err)
+			alerts, err := alerteval.Evaluate(r, env)
+			require.NoError(t, err)
+			require.Len(t, alerts, 1, "%s on %s", tc.rule, tc.fixture)
+			require.NoError(t, commonalert.Post(t.Context(), receiver.URL, alerts[0].Payload(r, env.Now)))
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		defer cancel()
+		deliveries, err := receiver.Wait(ctx, 2)
+		require.NoError(t, err)
+		got := map[string]commonalert.Payload{}
+		for _, d := range deliveries {
+			require.NoError(t, d.Err)
+			got[d.Payload.Data.Essentials.AlertRule] = d.Payload
+		}
+
+		failure := got["alert-backup-job-failure"].Data
+		assert.Equal(t, "Sev1", failure.Essentials.Severity)
+		assert.Equal(t, "Log", failure.Essentials.SignalType)
+		assert.Equal(t, "Fired", failure.Essentials.MonitorCondition)
+		assert.Equal(t, []string{fmt.Sprint(lawID)}, failure.Essentials.AlertTargetIDs)
+		vault, ok := got["alert-backup-job-failure"].Dimension("_ResourceId")
+		assert.True(t, ok, "the job failure notification should carry the vault")
+		assert.Equal(t, fixtureVault, vault)
+		assert.Equal(t, []string{"rsv-backup"}, failure.Essentials.ConfigurationItems)
+		assert.Equal(t, "BackupJobFailure", failure.CustomProperties["AlertType"])
+		if assert.Len(t, failure.AlertContext.Condition.AllOf, 1) {
+			assert.Equal(t, 1.0, failure.AlertContext.Condition.AllOf[0].MetricValue)
+		}
+
+		stale := got["alert-backup-not-run-24h"]
+		assert.Equal(t, "Sev2", stale.Data.Essentials.Severity)
+		item, _ := stale.Dimension("BackupItemUniqueId")
+		assert.Equal(t, "vm-app-01", item)
+	})
+
+	// ── Rejected ──────────────────────────────────────────────────────────
+	t.Run("Rejected", func(t *testing.T) {
+		receiver := commonalert.NewReceiver()
+		defer receiver.Close()
+
+		r := rules["alert-backup-job-failure"]
+		env, err := kql.LoadFixture("testdata/logs/jobs_failed.json")
+		require.NoError(t, err)
+		alerts, err := alerteval.Evaluate(r, env)
+		require.NoError(t, err)
+		require.NotEmpty(t, alerts)
+		valid := func() commonalert.Payload { return alerts[0].Payload(r, env.Now) }
+
+		for _, tc := range []struct {
+			name   string
+			mutate func(*commonalert.Payload)
+			want   string
+		}{
+			{"schema", func(p *commonalert.Payload) { p.SchemaID = "AzureMonitorMetricAlert" }, "schemaId:"},
+			{"rule", func(p *commonalert.Payload) { p.Data.Essentials.AlertRule = "" }, "data.essentials.alertRule: is required"},
+			{"severity", func(p *commonalert.Payload) { p.Data.Essentials.Severity = "Critical" }, `data.essentials.severity: "Critical" is not one of`},
+			{"resolved", func(p *commonalert.Payload) { p.Data.Essentials.MonitorCondition = "Resolved" }, "data.essentials.resolvedDateTime:"},
+			{"target", func(p *commonalert.Payload) { p.Data.Essentials.AlertTargetIDs = []string{"law-minitrue"} }, "data.essentials.alertTargetIDs[0]:"},
+			{"query", func(p *commonalert.Payload) { p.Data.AlertContext.Condition.AllOf[0].SearchQuery = "" }, "data.alertContext.condition.allOf[0].searchQuery: is required"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				p := valid()
+				p.Data.AlertContext.Condition.AllOf = append([]commonalert.Criterion{}, p.Data.AlertContext.Condition.AllOf...)
+				tc.mutate(&p)
+				err := commonalert.Post(t.Context(), receiver.URL, p)
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), "400 Bad Request")
+					assert.Contains(t, err.Error(), tc.want)
+				}
+			})
+		}
+
+		for _, tc := range []struct {
+			name, method, contentType, body string
+			want                            int
+		}{
+			{"GET", http.MethodGet, "application/json", "", http.StatusMethodNotAllowed},
+			{"form", http.MethodPost, "application/x-www-form-urlencoded", "a=b", http.StatusUnsupportedMediaType},
+			{"not JSON", http.MethodPost, "application/json", "alert!", http.StatusBadRequest},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				req, err := http.NewRequestWithContext(t.Context(), tc.method, receiver.URL, strings.NewReader(tc.body))
+				require.NoError(t, err)
+				req.Header.Set("Content-Type", tc.contentType)
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				resp.Body.Close()
+				assert.Equal(t, tc.want, resp.StatusCode)
+			})
+		}
+
+		for _, d := range receiver.Deliveries() {
+			assert.Error(t, d.Err, "the receiver should have accepted nothing")
+		}
+	})
+}
@@ -151,6 +151,16 @@ run "diagnostics_cross_reference" {
     condition     = azurerm_monitor_diagnostic_setting.vault_diagnostics.log_analytics_workspace_id == local.law_id
     error_message = "diagnostic setting must send logs to the LAW (Sprint 3)"
   }
+
+  assert {
+    condition     = azurerm_monitor_diagnostic_setting.vault_diagnostics.log_analytics_destination_type == "Dedicated"
+    error_message = "diagnostic setting must write the resource-specific tables the alert queries read (Sprint 3)"
+  }
+
+  assert {
+    condition     = azurerm_monitor_diagnostic_setting.disk_vault_diagnostics.target_resource_id == azurerm_data_protection_backup_vault.disk_vault.id
+    error_message = "disk vault diagnostic setting must target the Data Protection vault (Sprint 3)"
+  }
 }
 
 ###############################################################################
@@ -192,6 +202,22 @@ run "alerts_use_action_group" {
     error_message = "stale backup alert must use the backup_alerts action group (Sprint 3)"
   }
 
+  assert {
+    condition = contains(
+      azurerm_monitor_scheduled_query_rules_alert_v2.restore_failure.action[0].action_groups,
+      azurerm_monitor_action_group.backup_alerts.id
+    )
+    error_message = "restore failure alert must use the backup_alerts action group (Sprint 3)"
+  }
+
+  assert {
+    condition = contains(
+      azurerm_monitor_scheduled_query_rules_alert_v2.protection_stopped.action[0].action_groups,
+      azurerm_monitor_action_group.backup_alerts.id
+    )
+    error_message = "protection stopped alert must use the backup_alerts action group (Sprint 3)"
+  }
+
   assert {
     condition     = azurerm_monitor_metric_alert.vault_health.action[0].action_group_id == azurerm_monitor_action_group.backup_alerts.id
     error_message = "vault health metric alert must use the backup_alerts action group (Sprint 3)"
@@ -260,3 +286,38 @@ run "secondary_location_wired" {
     error_message = "cross-region snapshot copy policy must reference the secondary_location variable"
   }
 }
+
+###############################################################################
+# Run 11 – Webhook receivers get the Common Alert Schema
+###############################################################################
+run "webhook_receivers" {
+  command = plan
+
+  variables {
+    alert_webhook_receivers = [
+      { name = "webhook-ops", service_uri = "https://alerts.example.com/backup" },
+    ]
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_action_group.backup_alerts.webhook_receiver) == 1
+    error_message = "each alert_webhook_receivers entry must become a webhook receiver (Sprint 3)"
+  }
+
+  assert {
+    condition     = alltrue([for r in azurerm_monitor_action_group.backup_alerts.webhook_receiver : r.use_common_alert_schema])
+    error_message = "webhook receivers must use the Common Alert Schema (Sprint 3)"
+  }
+}
+
+run "webhook_receivers_need_https" {
+  command = plan
+
+  variables {
+    alert_webhook_receivers = [
+      { name = "webhook-ops", service_uri = "http://alerts.example.com/backup" },
+    ]
+  }
+
+  expect_failures = [var.alert_webhook_receivers]
+}
@@ -0,0 +1,304 @@
+// Package planassert is a small typed assertion library on top of
+// terraform.PlanStruct, so plan-only tests can be as exact as the SDK ones:
+//
+//	plan := planassert.New(t, terraform.InitAndPlanAndShowWithStruct(t, opts))
+//	plan.Resource("azurerm_backup_policy_vm.standard").
+//		Count(1).
+//		Action(planassert.Create).
+//		Attr("retention_daily.count", 30)
+//
+// Attribute paths are dotted and may index lists with [n]. A nested block
+// that holds exactly one element (retention_daily, backup, ...) is entered
+// without an explicit [0]. Expected values are compared after a JSON round
+// trip, so Go ints and slices compare equal to the float64s and
+// []interface{}s the plan decodes into.
+package planassert
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+// Action is a planned change to a resource instance.
+type Action string
+
+const (
+	Create  Action = "create"
+	Update  Action = "update"
+	Replace Action = "replace"
+	Delete  Action = "delete"
+	NoOp    Action = "no-op"
+	Read    Action = "read"
+)
+
+func (a Action) matches(actions tfjson.Actions) bool {
+	switch a {
+	case Create:
+		return actions.Create()
+	case Update:
+		return actions.Update()
+	case Replace:
+		return actions.Replace()
+	case Delete:
+		return actions.Delete()
+	case NoOp:
+		return actions.NoOp()
+	case Read:
+		return actions.Read()
+	}
+	return false
+}
+
+// Selector picks resource changes out of a plan. Empty Type or Name match
+// anything; Module is the module path ("module.backup") and empty selects
+// the root module.
+type Selector struct {
+	Module string
+	Type   string
+	Name   string
+}
+
+func (s Selector) String() string {
+	parts := []string{}
+	if s.Module != "" {
+		parts = append(parts, s.Module)
+	}
+	parts = append(parts, orAny(s.Type), orAny(s.Name))
+	return strings.Join(parts, ".")
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+func (s Selector) matches(rc *tfjson.ResourceChange) bool {
+	return rc.Mode == tfjson.ManagedResourceMode &&
+		rc.ModuleAddress == s.Module &&
+		(s.Type == "" || rc.Type == s.Type) &&
+		(s.Name == "" || rc.Name == s.Name)
+}
+
+// Plan wraps a parsed plan for assertions.
+type Plan struct {
+	t    testing.TB
+	plan *terraform.PlanStruct
+}
+
+// New returns assertions over plan that report failures to t.
+func New(t testing.TB, plan *terraform.PlanStruct) *Plan {
+	return &Plan{t: t, plan: plan}
+}
+
+// Resources returns the resource instances selected by sel, ordered by
+// address.
+func (p *Plan) Resources(sel Selector) *Resources {
+	var changes []*tfjson.ResourceChange
+	for _, rc := range p.plan.RawPlan.ResourceChanges {
+		if sel.matches(rc) {
+			changes = append(changes, rc)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Address < changes[j].Address })
+	return &Resources{t: p.t, desc: sel.String(), changes: changes}
+}
+
+// Resource selects every instance of a resource by its config address, e.g.
+// "azurerm_backup_protected_vm.app_vms_selective" or
+// "module.backup.azurerm_recovery_services_vault.main".
+func (p *Plan) Resource(address string) *Resources {
+	parts := strings.Split(address, ".")
+	var sel Selector
+	if n := len(parts); n >= 2 {
+		sel = Selector{Module: strings.Join(parts[:n-2], "."), Type: parts[n-2], Name: parts[n-1]}
+	} else {
+		sel = Selector{Type: address}
+	}
+	return p.Resources(sel)
+}
+
+// Resources is a set of planned resource instances. Every assertion applies
+// to each instance in the set and returns the set so calls can be chained.
+type Resources struct {
+	t       testing.TB
+	desc    string
+	changes []*tfjson.ResourceChange
+}
+
+// Len returns the number of instances in the set.
+func (r *Resources) Len() int {
+	return len(r.changes)
+}
+
+// Changes returns the underlying resource changes.
+func (r *Resources) Changes() []*tfjson.ResourceChange {
+	return r.changes
+}
+
+// Count asserts the number of instances, e.g. of a for_each resource.
+func (r *Resources) Count(want int) *Resources {
+	r.t.Helper()
+	assert.Equal(r.t, want, len(r.changes), "%s: number of planned instances\naddresses: %v", r.desc, r.addresses())
+	return r
+}
+
+// Keys asserts the for_each keys (or count indexes) of the set, in any order.
+func (r *Resources) Keys(want ...interface{}) *Resources {
+	r.t.Helper()
+	wantKeys := make([]string, 0, len(want))
+	for _, k := range want {
+		wantKeys = append(wantKeys, fmt.Sprint(k))
+	}
+	gotKeys := make([]string, 0, len(r.changes))
+	for _, rc := range r.changes {
+		gotKeys = append(gotKeys, fmt.Sprint(rc.Index))
+	}
+	sort.Strings(wantKeys)
+	sort.Strings(gotKeys)
+	assert.Equal(r.t, wantKeys, gotKeys, "%s: instance keys", r.desc)
+	return r
+}
+
+// Key narrows the set to the instance with the given for_each key or count
+// index.
+func (r *Resources) Key(key interface{}) *Resources {
+	out := &Resources{t: r.t, desc: fmt.Sprintf("%s[%v]", r.desc, key)}
+	for _, rc := range r.changes {
+		if fmt.Sprint(rc.Index) == fmt.Sprint(key) {
+			out.changes = append(out.changes, rc)
+		}
+	}
+	return out
+}
+
+// Action asserts every instance in the set is planned for action.
+func (r *Resources) Action(want Action) *Resources {
+	r.t.Helper()
+	if !r.nonEmpty() {
+		return r
+	}
+	for _, rc := range r.changes {
+		assert.True(r.t, want.matches(rc.Change.Actions),
+			"%s: expected action %q, plan has %v", rc.Address, want, rc.Change.Actions)
+	}
+	return r
+}
+
+// Attr asserts the planned value at path on every instance in the set.
+func (r *Resources) Attr(path string, want interface{}) *Resources {
+	r.t.Helper()
+	if !r.nonEmpty() {
+		return r
+	}
+	want = normalise(want)
+	for _, rc := range r.changes {
+		if unknown, _ := lookup(rc.Change.AfterUnknown, path); unknown == true {
+			r.t.Errorf("%s: %s is (known after apply), expected %v", rc.Address, path, want)
+			continue
+		}
+		got, err := lookup(rc.Change.After, path)
+		if err != nil {
+			r.t.Errorf("%s: %v", rc.Address, err)
+			continue
+		}
+		assert.Equal(r.t, want, got, "%s: %s", rc.Address, path)
+	}
+	return r
+}
+
+// AttrUnknown asserts the value at path is only known after apply.
+func (r *Resources) AttrUnknown(path string) *Resources {
+	r.t.Helper()
+	if !r.nonEmpty() {
+		return r
+	}
+	for _, rc := range r.changes {
+		unknown, _ := lookup(rc.Change.AfterUnknown, path)
+		assert.Equal(r.t, true, unknown, "%s: %s should be (known after apply)", rc.Address, path)
+	}
+	return r
+}
+
+// nonEmpty fails the test when an assertion would otherwise pass vacuously.
+func (r *Resources) nonEmpty() bool {
+	r.t.Helper()
+	if len(r.changes) == 0 {
+		r.t.Errorf("%s: no planned resources match", r.desc)
+		return false
+	}
+	return true
+}
+
+func (r *Resources) addresses() []string {
+	out := make([]string, 0, len(r.changes))
+	for _, rc := range r.changes {
+		out = append(out, rc.Address)
+	}
+	return out
+}
+
+var pathStep = regexp.MustCompile(`^([^\[\]]+)((?:\[\d+\])*)$`)
+
+// lookup walks path through a decoded plan value. A single-element list is
+// entered implicitly when more steps follow, which is how nested blocks are
+// encoded.
+func lookup(v interface{}, path string) (interface{}, error) {
+	steps := strings.Split(path, ".")
+	cur := v
+	for i, step := range steps {
+		m := pathStep.FindStringSubmatch(step)
+		if m == nil {
+			return nil, fmt.Errorf("invalid attribute path %q", path)
+		}
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is not an object", path, strings.Join(steps[:i], "."))
+		}
+		if cur, ok = obj[m[1]]; !ok {
+			return nil, fmt.Errorf("%s: no attribute %q", path, m[1])
+		}
+		indexes := strings.Split(strings.Trim(m[2], "[]"), "][")
+		if m[2] == "" {
+			indexes = nil
+		}
+		for _, idx := range indexes {
+			n, _ := strconv.Atoi(idx)
+			list, ok := cur.([]interface{})
+			if !ok || n >= len(list) {
+				return nil, fmt.Errorf("%s: index [%d] out of range", path, n)
+			}
+			cur = list[n]
+		}
+		if list, ok := cur.([]interface{}); ok && indexes == nil && i < len(steps)-1 {
+			if len(list) != 1 {
+				return nil, fmt.Errorf("%s: %s has %d blocks, index it explicitly", path, m[1], len(list))
+			}
+			cur = list[0]
+		}
+	}
+	return cur, nil
+}
+
+// normalise converts want into the shape encoding/json decodes plans into.
+func normalise(v interface{}) interface{} {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package planassert
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder collects the failures an assertion reports instead of failing the
+// test it wraps.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+const planJSON = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {
+      "address": "azurerm_backup_policy_vm.standard",
+      "mode": "managed",
+      "type": "azurerm_backup_policy_vm",
+      "name": "standard",
+      "change": {
+        "actions": ["create"],
+        "after": {
+          "name": "bkpol-vm-standard",
+          "backup": [{"frequency": "Daily", "time": "23:00"}],
+          "retention_weekly": [{"count": 12}, {"count": 4}],
+          "instant_restore_resource_group": null,
+          "id": null
+        },
+        "after_unknown": {"id": true, "backup": [{}], "retention_weekly": [{}, {}]}
+      }
+    },
+    {
+      "address": "azurerm_recovery_services_vault.main",
+      "mode": "managed",
+      "type": "azurerm_recovery_services_vault",
+      "name": "main",
+      "change": {"actions": ["update"], "after": {"name": "rsv"}, "after_unknown": {}}
+    }
+  ]
+}`
+
+func newPlan(t *testing.T) (*Plan, *recorder) {
+	t.Helper()
+	parsed, err := terraform.ParsePlanJSON(planJSON)
+	require.NoError(t, err)
+	rec := &recorder{TB: t}
+	return New(rec, parsed), rec
+}
+
+// TestLookup checks attribute paths through nested blocks, explicit indexes
+// and the errors for paths the plan does not have.
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	after := map[string]interface{}{
+		"backup":           []interface{}{map[string]interface{}{"time": "23:00"}},
+		"retention_weekly": []interface{}{map[string]interface{}{"count": 12.0}, map[string]interface{}{"count": 4.0}},
+		"name":             "bkpol",
+	}
+	for _, tc := range []struct {
+		path    string
+		want    interface{}
+		wantErr string
+	}{
+		{path: "backup.time", want: "23:00"},
+		{path: "backup[0].time", want: "23:00"},
+		{path: "retention_weekly[1].count", want: 4.0},
+		{path: "retention_weekly.count", wantErr: "retention_weekly.count: retention_weekly has 2 blocks, index it explicitly"},
+		{path: "retention_weekly[2].count", wantErr: "retention_weekly[2].count: index [2] out of range"},
+		{path: "retention_daily.count", wantErr: `retention_daily.count: no attribute "retention_daily"`},
+		{path: "name.first", wantErr: "name.first: name is not an object"},
+		{path: "backup..time", wantErr: `invalid attribute path "backup..time"`},
+	} {
+		got, err := lookup(after, tc.path)
+		if tc.wantErr != "" {
+			assert.EqualError(t, err, tc.wantErr, tc.path)
+			continue
+		}
+		require.NoError(t, err, tc.path)
+		assert.Equal(t, tc.want, got, tc.path)
+	}
+}
+
+// TestAssertions checks which failures each assertion reports.
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		assert func(p *Plan)
+		want   []string
+	}{
+		{
+			name: "passing",
+			assert: func(p *Plan) {
+				p.Resource("azurerm_backup_policy_vm.standard").
+					Count(1).
+					Action(Create).
+					Attr("backup.frequency", "Daily").
+					Attr("retention_weekly[0].count", 12).
+					Attr("instant_restore_resource_group", nil).
+					AttrUnknown("id")
+			},
+		},
+		{
+			name:   "missing address",
+			assert: func(p *Plan) { p.Resource("azurerm_backup_policy_vm.missing").Action(Create).Attr("name", "x") },
+			want: []string{
+				"azurerm_backup_policy_vm.missing: no planned resources match",
+				"azurerm_backup_policy_vm.missing: no planned resources match",
+			},
+		},
+		{
+			name:   "block with several elements",
+			assert: func(p *Plan) { p.Resource("azurerm_backup_policy_vm.standard").Attr("retention_weekly.count", 12) },
+			want:   []string{"azurerm_backup_policy_vm.standard: retention_weekly.count: retention_weekly has 2 blocks, index it explicitly"},
+		},
+		{
+			name:   "unknown is not null",
+			assert: func(p *Plan) { p.Resource("azurerm_backup_policy_vm.standard").Attr("id", nil) },
+			want:   []string{"azurerm_backup_policy_vm.standard: id is (known after apply), expected <nil>"},
+		},
+		{
+			name: "null is not unknown",
+			assert: func(p *Plan) {
+				p.Resource("azurerm_backup_policy_vm.standard").AttrUnknown("instant_restore_resource_group")
+			},
+			want: []string{"azurerm_backup_policy_vm.standard: instant_restore_resource_group should be (known after apply)"},
+		},
+		{
+			name:   "action mismatch",
+			assert: func(p *Plan) { p.Resource("azurerm_recovery_services_vault.main").Action(Create) },
+			want:   []string{`azurerm_recovery_services_vault.main: expected action "create", plan has [update]`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, rec := newPlan(t)
+			tc.assert(p)
+			require.Len(t, rec.errors, len(tc.want), "%q", rec.errors)
+			for i, want := range tc.want {
+				assert.Contains(t, rec.errors[i], want)
+			}
+		})
+	}
+}
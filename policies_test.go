@@ -0,0 +1,190 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+)
+
+// ─── Backup policy spec ───────────────────────────────────────────────────────
+
+// policySpecPath is the spec the checked-in profiles point at.
+var policySpecPath = filepath.Join("specs", "backup_policies.yaml")
+
+// failureModesPath is the catalog of failures the alert rules must cover.
+var failureModesPath = filepath.Join("specs", "failure_modes.yaml")
+
+// assertPolicySpec fails the test once per deviation from the policy spec,
+// so a run lists every field that is off rather than stopping at the first.
+func assertPolicySpec(t *testing.T, devs []policyspec.Deviation) {
+	t.Helper()
+	for _, d := range devs {
+		t.Errorf("backup policy deviates from spec: %s", d)
+	}
+}
+
+// TestPolicySpec checks the policy spec against the seed plan and the fake
+// ARM backend, and that the checker reports each drifted field.
+func TestPolicySpec(t *testing.T) {
+	t.Parallel()
+
+	raw, err := os.ReadFile("testdata/synthetic_plan.json")
+	require.NoError(t, err)
+	plan, err := terraform.ParsePlanJSON(string(raw))
+	require.NoError(t, err)
+
+	t.Run("Plan", func(t *testing.T) {
+		spec, err := policyspec.Load(policySpecPath)
+		require.NoError(t, err)
+		assert.Empty(t, policyspec.CheckPlan(spec, plan))
+		assert.Empty(t, policyspec.CheckDiskPlan(spec, plan))
+
+		spec.Policies[0].Retention.Daily.Count = 14
+		spec.Policies[0].Retention.Yearly = nil
+		spec.Policies[1].Schedule.HourInterval = 6
+		spec.Policies = append(spec.Policies, policyspec.Policy{Name: "bkpol-archive-monthly", Address: "azurerm_backup_policy_vm.archive"})
+		assert.ElementsMatch(t, []string{
+			"bkpol-standard-daily-30d: retention.daily.count is 30, want 14",
+			"bkpol-standard-daily-30d: retention.yearly is configured with count 3, want not configured",
+			"bkpol-enhanced-daily-30d: schedule.hour_interval is 4, want 6",
+			"bkpol-archive-monthly: address is not configured, want azurerm_backup_policy_vm.archive",
+		}, deviations(policyspec.CheckPlan(spec, plan)))
+
+		spec.DiskPolicies[0].Retention.Default = "P17D"
+		spec.DiskPolicies[0].Retention.Rules[0].Duration = "P28D" // same as P4W
+		spec.DiskPolicies[1].Schedule.Start = "22:00"
+		spec.DiskPolicies[1].Schedule.Every = "PT12H"
+		assert.ElementsMatch(t, []string{
+			"dpbpol-os-disk-7d: retention.default is P7D, want P17D",
+			"dpbpol-data-disk-7d: schedule.start is 23:00, want 22:00",
+			"dpbpol-data-disk-7d: schedule.every is P1D, want PT12H",
+		}, deviations(policyspec.CheckDiskPlan(spec, plan)))
+	})
+
+	t.Run("Live", func(t *testing.T) {
+		spec, err := policyspec.Load(policySpecPath)
+		require.NoError(t, err)
+
+		fake := seededFake(t)
+		client, err := armrecoveryservicesbackup.NewProtectionPoliciesClient(fake.SubscriptionID(), fake.Credential(), fake.ClientOptions())
+		require.NoError(t, err)
+
+		devs, err := policyspec.CheckLive(t.Context(), client, fake.ResourceGroup, fake.Vault, spec)
+		require.NoError(t, err)
+		assert.Empty(t, devs)
+
+		diskVault := fake.Attr("azurerm_data_protection_backup_vault.disk_vault", "name")
+		diskClient, err := armdataprotection.NewBackupPoliciesClient(fake.SubscriptionID(), fake.Credential(), fake.ClientOptions())
+		require.NoError(t, err)
+		devs, err = policyspec.CheckDiskLive(t.Context(), diskClient, fake.ResourceGroup, diskVault, spec)
+		require.NoError(t, err)
+		assert.Empty(t, devs)
+
+		// Drift the enhanced policy behind Terraform's back.
+		id, ok := fake.Attribute("azurerm_backup_policy_vm.enhanced", "id")
+		require.True(t, ok)
+		doc, ok := fake.Get(fmt.Sprint(id))
+		require.True(t, ok)
+		props := doc["properties"].(map[string]interface{})
+		props["instantRpRetentionRangeInDays"] = 2
+		ltr := props["retentionPolicy"].(map[string]interface{})
+		delete(ltr, "weeklySchedule")
+		ltr["monthlySchedule"].(map[string]interface{})["retentionDuration"].(map[string]interface{})["count"] = 6
+		fake.Put(fmt.Sprint(id), doc)
+
+		spec.Policies = append(spec.Policies, policyspec.Policy{Name: "bkpol-archive-monthly"})
+		devs, err = policyspec.CheckLive(t.Context(), client, fake.ResourceGroup, fake.Vault, spec)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"bkpol-enhanced-daily-30d: retention.weekly is not configured, want count 12",
+			"bkpol-enhanced-daily-30d: retention.monthly.count is 6, want 12",
+			"bkpol-enhanced-daily-30d: instant_restore_days is 2, want 7",
+			"bkpol-archive-monthly: name is not configured, want present in vault " + fake.Vault,
+		}, deviations(devs))
+
+		// P17D contains "P7D"; the checker must still tell them apart.
+		id, ok = fake.Attribute("azurerm_data_protection_backup_policy_disk.os_disk", "id")
+		require.True(t, ok)
+		doc, ok = fake.Get(fmt.Sprint(id))
+		require.True(t, ok)
+		rules := doc["properties"].(map[string]interface{})["policyRules"].([]interface{})
+		schedule := rules[0].(map[string]interface{})["trigger"].(map[string]interface{})["schedule"].(map[string]interface{})
+		schedule["repeatingTimeIntervals"] = []interface{}{"R/2024-01-01T03:00:00+00:00/PT6H"}
+		schedule["timeZone"] = "W. Europe Standard Time"
+		lifecycle := rules[1].(map[string]interface{})["lifecycles"].([]interface{})[0].(map[string]interface{})
+		lifecycle["deleteAfter"].(map[string]interface{})["duration"] = "P17D"
+		fake.Put(fmt.Sprint(id), doc)
+
+		devs, err = policyspec.CheckDiskLive(t.Context(), diskClient, fake.ResourceGroup, diskVault, spec)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"dpbpol-os-disk-7d: schedule.start is 03:00, want 02:00",
+			"dpbpol-os-disk-7d: schedule.every is PT6H, want PT4H",
+			"dpbpol-os-disk-7d: schedule.time_zone is W. Europe Standard Time, want UTC",
+			"dpbpol-os-disk-7d: retention.default is P17D, want P7D",
+		}, deviations(devs))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		spec, err := policyspec.Parse([]byte(`
+policies:
+  - name: bkpol-hourly
+    address: azurerm_backup_policy_vm.hourly
+    type: V1
+    schedule: {frequency: Hourly, time: "06:15", hour_interval: 5, hour_duration: 12}
+    retention:
+      weekly: {count: 0}
+    instant_restore_days: 7
+  - name: bkpol-hourly
+    address: module.other.azurerm_backup_policy_vm.x
+    type: V2
+    schedule: {frequency: Daily, time: "23:00"}
+    retention:
+      daily: {count: 3}
+    instant_restore_days: 7
+`))
+		require.NoError(t, err)
+
+		var verr *policyspec.ValidationError
+		require.True(t, errors.As(spec.Validate(), &verr))
+		var got []string
+		for _, f := range verr.Fields {
+			got = append(got, f.Field)
+		}
+		assert.ElementsMatch(t, []string{
+			"policies[0].schedule.frequency",
+			"policies[0].schedule.hour_interval",
+			"policies[0].schedule.time",
+			"policies[0].retention.daily",
+			"policies[0].retention.weekly.count",
+			"policies[0].instant_restore_days",
+			"policies[1].name",
+			"policies[1].address",
+			"policies[1].retention.daily.count",
+		}, got)
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		_, err := policyspec.Parse([]byte("policies:\n  - name: typo\n    retention_daily: 30\n"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "field retention_daily not found")
+	})
+}
+
+func deviations(devs []policyspec.Deviation) []string {
+	out := make([]string, len(devs))
+	for i, d := range devs {
+		out[i] = d.String()
+	}
+	return out
+}
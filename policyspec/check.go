@@ -0,0 +1,155 @@
+package policyspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// notConfigured stands in for a missing policy, block or tier.
+const notConfigured = "not configured"
+
+// Deviation is one field of a policy that does not match the spec.
+type Deviation struct {
+	Policy string
+	// Field is the spec path, e.g. retention.weekly.count.
+	Field string
+	Want  string
+	Got   string
+}
+
+func (d Deviation) String() string {
+	return fmt.Sprintf("%s: %s is %s, want %s", d.Policy, d.Field, d.Got, d.Want)
+}
+
+// Diff compares an observed policy with its spec and returns every
+// deviation, in spec field order.
+func Diff(want, got Policy) []Deviation {
+	var devs []Deviation
+	check := func(field, w, g string) {
+		if w != g {
+			devs = append(devs, Deviation{Policy: want.Name, Field: field, Want: w, Got: g})
+		}
+	}
+
+	check("type", want.Type, got.Type)
+	if want.Timezone != "" {
+		check("timezone", want.Timezone, got.Timezone)
+	}
+
+	check("schedule.frequency", want.Schedule.Frequency, got.Schedule.Frequency)
+	check("schedule.time", want.Schedule.Time, got.Schedule.Time)
+	if len(want.Schedule.Weekdays) > 0 {
+		check("schedule.weekdays", set(want.Schedule.Weekdays), set(got.Schedule.Weekdays))
+	}
+	if want.Schedule.Frequency == "Hourly" {
+		check("schedule.hour_interval", strconv.Itoa(want.Schedule.HourInterval), strconv.Itoa(got.Schedule.HourInterval))
+		check("schedule.hour_duration", strconv.Itoa(want.Schedule.HourDuration), strconv.Itoa(got.Schedule.HourDuration))
+	}
+
+	gotTiers := got.Retention.tiers()
+	for i, w := range want.Retention.tiers() {
+		field := "retention." + w.name
+		g := gotTiers[i].tier
+		switch {
+		case w.tier == nil && g == nil:
+		case w.tier == nil:
+			check(field, notConfigured, fmt.Sprintf("configured with count %d", g.Count))
+		case g == nil:
+			check(field, fmt.Sprintf("count %d", w.tier.Count), notConfigured)
+		default:
+			check(field+".count", strconv.Itoa(w.tier.Count), strconv.Itoa(g.Count))
+			if len(w.tier.Weekdays) > 0 {
+				check(field+".weekdays", set(w.tier.Weekdays), set(g.Weekdays))
+			}
+			if len(w.tier.Weeks) > 0 {
+				check(field+".weeks", set(w.tier.Weeks), set(g.Weeks))
+			}
+			if len(w.tier.Months) > 0 {
+				check(field+".months", set(w.tier.Months), set(g.Months))
+			}
+			if len(w.tier.Days) > 0 {
+				check(field+".days", intSet(w.tier.Days), intSet(g.Days))
+			}
+			if w.tier.IncludeLastDays {
+				check(field+".include_last_days", "true", strconv.FormatBool(g.IncludeLastDays))
+			}
+		}
+	}
+
+	check("instant_restore_days", strconv.Itoa(want.InstantRestoreDays), strconv.Itoa(got.InstantRestoreDays))
+	return devs
+}
+
+// set renders a list order-insensitively, for comparison and messages.
+func set(list []string) string {
+	if len(list) == 0 {
+		return "[]"
+	}
+	sorted := append([]string(nil), list...)
+	sort.Strings(sorted)
+	return "[" + strings.Join(sorted, ", ") + "]"
+}
+
+func intSet(list []int) string {
+	s := make([]string, len(list))
+	for i, n := range list {
+		s[i] = strconv.Itoa(n)
+	}
+	return set(s)
+}
+
+// CheckPlan compares every policy in the spec with the planned values of its
+// Terraform address.
+func CheckPlan(spec *Spec, plan *terraform.PlanStruct) []Deviation {
+	var devs []Deviation
+	for _, want := range spec.Policies {
+		res, ok := plan.ResourcePlannedValuesMap[want.Address]
+		if !ok {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "address", Want: want.Address, Got: notConfigured})
+			continue
+		}
+		got := FromPlan(want.Address, res.AttributeValues)
+		if got.Name != want.Name {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "name", Want: want.Name, Got: got.Name})
+		}
+		devs = append(devs, Diff(want, got)...)
+	}
+	return devs
+}
+
+// CheckLive fetches every policy in the spec from the vault and compares it.
+// A policy missing from the vault is a deviation; any other API failure is
+// returned as an error.
+func CheckLive(ctx context.Context, client *armrecoveryservicesbackup.ProtectionPoliciesClient, resourceGroup, vault string, spec *Spec) ([]Deviation, error) {
+	var devs []Deviation
+	for _, want := range spec.Policies {
+		resp, err := client.Get(ctx, vault, resourceGroup, want.Name, nil)
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "name", Want: "present in vault " + vault, Got: notConfigured})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get backup policy %s: %w", want.Name, err)
+		}
+		props, ok := resp.Properties.(*armrecoveryservicesbackup.AzureIaaSVMProtectionPolicy)
+		if !ok {
+			return nil, fmt.Errorf("backup policy %s: properties are %T, want an Azure VM policy", want.Name, resp.Properties)
+		}
+		got, err := FromARM(want.Name, props)
+		if err != nil {
+			return nil, err
+		}
+		devs = append(devs, Diff(want, got)...)
+	}
+	return devs, nil
+}
@@ -0,0 +1,354 @@
+package policyspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+
+	"github.com/SwastikaAryal/azure_terraform/iso8601"
+)
+
+// DiskPolicy describes one azurerm_data_protection_backup_policy_disk. As
+// with Policy, the same type holds the expectation and the observation.
+type DiskPolicy struct {
+	Name    string `yaml:"name" json:"name"`
+	Address string `yaml:"address" json:"address"`
+
+	Schedule  DiskSchedule  `yaml:"schedule" json:"schedule"`
+	Retention DiskRetention `yaml:"retention" json:"retention"`
+}
+
+// DiskSchedule is a single repeating backup interval.
+type DiskSchedule struct {
+	// Start is the time of day of the first backup, HH:MM in TimeZone.
+	Start string `yaml:"start" json:"start"`
+	// Every is the ISO 8601 period between backups, e.g. PT4H or P1D.
+	Every    string `yaml:"every" json:"every"`
+	TimeZone string `yaml:"time_zone" json:"time_zone"`
+}
+
+// DiskRetention is the default retention and any tagged retention rules.
+type DiskRetention struct {
+	// Default is the ISO 8601 duration untagged snapshots are kept.
+	Default string     `yaml:"default" json:"default"`
+	Rules   []DiskRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// DiskRule keeps snapshots matching Criteria, e.g. FirstOfWeek, for Duration.
+type DiskRule struct {
+	Name     string `yaml:"name" json:"name"`
+	Duration string `yaml:"duration" json:"duration"`
+	Priority int    `yaml:"priority" json:"priority"`
+	Criteria string `yaml:"criteria" json:"criteria"`
+}
+
+// defaultTimeZone is what the Data Protection API applies when a policy
+// leaves the time zone unset.
+const defaultTimeZone = "UTC"
+
+// DiskPolicy returns the spec for the named disk policy.
+func (s *Spec) DiskPolicy(name string) (DiskPolicy, bool) {
+	for _, p := range s.DiskPolicies {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return DiskPolicy{}, false
+}
+
+// validateDisk reports bad disk policy fields through fail.
+func (s *Spec) validateDisk(fail func(field, format string, args ...interface{})) {
+	seen := map[string]bool{}
+	for i, p := range s.DiskPolicies {
+		field := fmt.Sprintf("disk_policies[%d]", i)
+
+		if p.Name == "" {
+			fail(field+".name", "is required")
+		} else if seen[p.Name] {
+			fail(field+".name", "duplicate policy %q", p.Name)
+		}
+		seen[p.Name] = true
+		if !strings.HasPrefix(p.Address, "azurerm_data_protection_backup_policy_disk.") {
+			fail(field+".address", "%q is not an azurerm_data_protection_backup_policy_disk address", p.Address)
+		}
+
+		if !timePattern.MatchString(p.Schedule.Start) {
+			fail(field+".schedule.start", "%q is not a half-hour HH:MM time", p.Schedule.Start)
+		}
+		if d, err := iso8601.ParseDuration(p.Schedule.Every); err != nil {
+			fail(field+".schedule.every", "%v", err)
+		} else if !validDiskPeriod(d) {
+			fail(field+".schedule.every", "%q is not one of PT1H, PT2H, PT4H, PT6H, PT8H, PT12H, P1D", p.Schedule.Every)
+		}
+		if p.Schedule.TimeZone == "" {
+			fail(field+".schedule.time_zone", "is required")
+		}
+
+		if _, err := iso8601.ParseDuration(p.Retention.Default); err != nil {
+			fail(field+".retention.default", "%v", err)
+		}
+		for j, r := range p.Retention.Rules {
+			rf := fmt.Sprintf("%s.retention.rules[%d]", field, j)
+			if r.Name == "" {
+				fail(rf+".name", "is required")
+			}
+			if _, err := iso8601.ParseDuration(r.Duration); err != nil {
+				fail(rf+".duration", "%v", err)
+			}
+			if r.Criteria == "" {
+				fail(rf+".criteria", "is required")
+			}
+		}
+	}
+}
+
+// validDiskPeriod reports whether the API accepts d as a disk backup period:
+// a whole number of hours that divides a day, or one day.
+func validDiskPeriod(d iso8601.Duration) bool {
+	if d.Equal(iso8601.Duration{Days: 1}) {
+		return true
+	}
+	if d != (iso8601.Duration{Hours: d.Hours}) {
+		return false
+	}
+	switch d.Hours {
+	case 1, 2, 4, 6, 8, 12:
+		return true
+	}
+	return false
+}
+
+// ─── Observed disk policies ───────────────────────────────────────────────────
+
+// DiskFromPlan reads a disk policy from the planned attribute values of an
+// azurerm_data_protection_backup_policy_disk. A schedule that is not exactly
+// one repeating interval is returned as an error.
+func DiskFromPlan(address string, attrs map[string]interface{}) (DiskPolicy, error) {
+	p := DiskPolicy{
+		Name:      str(attrs["name"]),
+		Address:   address,
+		Retention: DiskRetention{Default: str(attrs["default_retention_duration"])},
+	}
+	var err error
+	p.Schedule, err = diskSchedule(strList(attrs["backup_repeating_time_intervals"]), str(attrs["time_zone"]))
+
+	rules, _ := attrs["retention_rule"].([]interface{})
+	for _, r := range rules {
+		rule, _ := r.(map[string]interface{})
+		p.Retention.Rules = append(p.Retention.Rules, DiskRule{
+			Name:     str(rule["name"]),
+			Duration: str(rule["duration"]),
+			Priority: num(rule["priority"]),
+			Criteria: str(block(rule["criteria"])["absolute_criteria"]),
+		})
+	}
+	return p, err
+}
+
+// DiskFromARM reads a disk policy from the BackupPolicy the Data Protection
+// BackupPoliciesClient returns: one AzureBackupRule carrying the schedule and
+// tagging criteria, and one AzureRetentionRule per tag.
+func DiskFromARM(name string, props *armdataprotection.BackupPolicy) (DiskPolicy, error) {
+	p := DiskPolicy{Name: name}
+	var (
+		schedErr  error
+		foundRule bool
+		tags      = map[string]*armdataprotection.TaggingCriteria{}
+	)
+	for _, rule := range props.PolicyRules {
+		r, ok := rule.(*armdataprotection.AzureBackupRule)
+		if !ok {
+			continue
+		}
+		trigger, ok := r.Trigger.(*armdataprotection.ScheduleBasedTriggerContext)
+		if !ok || trigger.Schedule == nil {
+			return p, fmt.Errorf("disk policy %s: backup rule has no schedule", name)
+		}
+		foundRule = true
+		p.Schedule, schedErr = diskSchedule(derefAll(trigger.Schedule.RepeatingTimeIntervals), deref(trigger.Schedule.TimeZone))
+		for _, tc := range trigger.TaggingCriteria {
+			if tc != nil && tc.TagInfo != nil {
+				tags[deref(tc.TagInfo.TagName)] = tc
+			}
+		}
+	}
+	if !foundRule {
+		return p, fmt.Errorf("disk policy %s: no AzureBackupRule", name)
+	}
+
+	for _, rule := range props.PolicyRules {
+		r, ok := rule.(*armdataprotection.AzureRetentionRule)
+		if !ok {
+			continue
+		}
+		duration := ""
+		for _, lc := range r.Lifecycles {
+			if del, ok := lc.DeleteAfter.(*armdataprotection.AbsoluteDeleteOption); ok {
+				duration = deref(del.Duration)
+			}
+		}
+		if deref(r.IsDefault) {
+			p.Retention.Default = duration
+			continue
+		}
+		dr := DiskRule{Name: deref(r.Name), Duration: duration}
+		if tc := tags[dr.Name]; tc != nil {
+			dr.Priority = int(deref(tc.TaggingPriority))
+			for _, c := range tc.Criteria {
+				if sc, ok := c.(*armdataprotection.ScheduleBasedBackupCriteria); ok && len(sc.AbsoluteCriteria) > 0 {
+					dr.Criteria = string(deref(sc.AbsoluteCriteria[0]))
+				}
+			}
+		}
+		p.Retention.Rules = append(p.Retention.Rules, dr)
+	}
+	return p, schedErr
+}
+
+// diskSchedule parses the repeating intervals of a disk policy, which must
+// be exactly one.
+func diskSchedule(intervals []string, timeZone string) (DiskSchedule, error) {
+	if timeZone == "" {
+		timeZone = defaultTimeZone
+	}
+	s := DiskSchedule{TimeZone: timeZone}
+	if len(intervals) != 1 {
+		return s, fmt.Errorf("want one repeating interval, got %q", intervals)
+	}
+	ri, err := iso8601.ParseRepeatingInterval(intervals[0])
+	if err != nil {
+		return s, err
+	}
+	s.Start = ri.Start.Format("15:04")
+	s.Every = ri.Period.String()
+	return s, nil
+}
+
+// ─── Comparison ───────────────────────────────────────────────────────────────
+
+// DiffDisk compares an observed disk policy with its spec. Durations are
+// compared by value, so P1W matches P7D but P17D does not match P7D.
+func DiffDisk(want, got DiskPolicy) []Deviation {
+	var devs []Deviation
+	check := func(field, w, g string) {
+		if w != g {
+			devs = append(devs, Deviation{Policy: want.Name, Field: field, Want: w, Got: g})
+		}
+	}
+	checkDuration := func(field, w, g string) {
+		if !sameDuration(w, g) {
+			devs = append(devs, Deviation{Policy: want.Name, Field: field, Want: w, Got: orNotConfigured(g)})
+		}
+	}
+
+	check("schedule.start", want.Schedule.Start, got.Schedule.Start)
+	checkDuration("schedule.every", want.Schedule.Every, got.Schedule.Every)
+	check("schedule.time_zone", want.Schedule.TimeZone, got.Schedule.TimeZone)
+	checkDuration("retention.default", want.Retention.Default, got.Retention.Default)
+
+	gotRules := map[string]DiskRule{}
+	for _, r := range got.Retention.Rules {
+		gotRules[r.Name] = r
+	}
+	for _, w := range want.Retention.Rules {
+		field := "retention.rules." + w.Name
+		g, ok := gotRules[w.Name]
+		if !ok {
+			check(field, "duration "+w.Duration, notConfigured)
+			continue
+		}
+		delete(gotRules, w.Name)
+		checkDuration(field+".duration", w.Duration, g.Duration)
+		check(field+".priority", strconv.Itoa(w.Priority), strconv.Itoa(g.Priority))
+		check(field+".criteria", w.Criteria, g.Criteria)
+	}
+	for _, g := range got.Retention.Rules {
+		if _, extra := gotRules[g.Name]; extra {
+			check("retention.rules."+g.Name, notConfigured, "configured with duration "+g.Duration)
+		}
+	}
+	return devs
+}
+
+func sameDuration(want, got string) bool {
+	w, werr := iso8601.ParseDuration(want)
+	g, gerr := iso8601.ParseDuration(got)
+	if werr != nil || gerr != nil {
+		return want == got
+	}
+	return w.Equal(g)
+}
+
+func orNotConfigured(s string) string {
+	if s == "" {
+		return notConfigured
+	}
+	return s
+}
+
+// CheckDiskPlan compares every disk policy in the spec with the planned
+// values of its Terraform address.
+func CheckDiskPlan(spec *Spec, plan *terraform.PlanStruct) []Deviation {
+	var devs []Deviation
+	for _, want := range spec.DiskPolicies {
+		res, ok := plan.ResourcePlannedValuesMap[want.Address]
+		if !ok {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "address", Want: want.Address, Got: notConfigured})
+			continue
+		}
+		got, err := DiskFromPlan(want.Address, res.AttributeValues)
+		if err != nil {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "schedule", Want: "a valid backup schedule", Got: err.Error()})
+		}
+		if got.Name != want.Name {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "name", Want: want.Name, Got: got.Name})
+		}
+		devs = append(devs, DiffDisk(want, got)...)
+	}
+	return devs
+}
+
+// CheckDiskLive fetches every disk policy in the spec from the Data
+// Protection vault and compares it. As with CheckLive, a missing policy is a
+// deviation and any other API failure an error.
+func CheckDiskLive(ctx context.Context, client *armdataprotection.BackupPoliciesClient, resourceGroup, vault string, spec *Spec) ([]Deviation, error) {
+	var devs []Deviation
+	for _, want := range spec.DiskPolicies {
+		resp, err := client.Get(ctx, resourceGroup, vault, want.Name, nil)
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "name", Want: "present in vault " + vault, Got: notConfigured})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get disk backup policy %s: %w", want.Name, err)
+		}
+		props, ok := resp.Properties.(*armdataprotection.BackupPolicy)
+		if !ok {
+			return nil, fmt.Errorf("disk backup policy %s: properties are %T, want a BackupPolicy", want.Name, resp.Properties)
+		}
+		got, err := DiskFromARM(want.Name, props)
+		if err != nil {
+			devs = append(devs, Deviation{Policy: want.Name, Field: "schedule", Want: "a valid backup schedule", Got: err.Error()})
+		}
+		devs = append(devs, DiffDisk(want, got)...)
+	}
+	return devs, nil
+}
+
+func derefAll(in []*string) []string {
+	var out []string
+	for _, v := range in {
+		if v != nil {
+			out = append(out, *v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,217 @@
+package policyspec
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+)
+
+// ─── Terraform plan ───────────────────────────────────────────────────────────
+
+// FromPlan reads a policy from the planned attribute values of an
+// azurerm_backup_policy_vm, as found in terraform show -json.
+func FromPlan(address string, attrs map[string]interface{}) Policy {
+	p := Policy{
+		Name:               str(attrs["name"]),
+		Address:            address,
+		Type:               str(attrs["policy_type"]),
+		Timezone:           str(attrs["timezone"]),
+		InstantRestoreDays: num(attrs["instant_restore_retention_days"]),
+	}
+	if p.Type == "" {
+		// The provider defaults policy_type to V1.
+		p.Type = "V1"
+	}
+	if b := block(attrs["backup"]); b != nil {
+		p.Schedule = Schedule{
+			Frequency:    str(b["frequency"]),
+			Time:         str(b["time"]),
+			Weekdays:     strList(b["weekdays"]),
+			HourInterval: num(b["hour_interval"]),
+			HourDuration: num(b["hour_duration"]),
+		}
+	}
+	p.Retention = Retention{
+		Daily:   planTier(attrs["retention_daily"]),
+		Weekly:  planTier(attrs["retention_weekly"]),
+		Monthly: planTier(attrs["retention_monthly"]),
+		Yearly:  planTier(attrs["retention_yearly"]),
+	}
+	return p
+}
+
+func planTier(v interface{}) *Tier {
+	b := block(v)
+	if b == nil {
+		return nil
+	}
+	t := &Tier{
+		Count:    num(b["count"]),
+		Weekdays: strList(b["weekdays"]),
+		Weeks:    strList(b["weeks"]),
+		Months:   strList(b["months"]),
+	}
+	if days, ok := b["days"].([]interface{}); ok {
+		for _, d := range days {
+			t.Days = append(t.Days, num(d))
+		}
+	}
+	t.IncludeLastDays, _ = b["include_last_days"].(bool)
+	return t
+}
+
+// block returns the single element of a nested block list, or nil when the
+// block is absent.
+func block(v interface{}) map[string]interface{} {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	m, _ := list[0].(map[string]interface{})
+	return m
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func num(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+func strList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var out []string
+	for _, s := range list {
+		out = append(out, str(s))
+	}
+	return out
+}
+
+// ─── Recovery Services API ────────────────────────────────────────────────────
+
+// FromARM reads a policy from the properties the ProtectionPoliciesClient
+// returns for an Azure VM backup policy.
+func FromARM(name string, props *armrecoveryservicesbackup.AzureIaaSVMProtectionPolicy) (Policy, error) {
+	p := Policy{
+		Name:               name,
+		Type:               string(armrecoveryservicesbackup.IAASVMPolicyTypeV1),
+		Timezone:           deref(props.TimeZone),
+		InstantRestoreDays: int(deref(props.InstantRpRetentionRangeInDays)),
+	}
+	if props.PolicyType != nil {
+		p.Type = string(*props.PolicyType)
+	}
+
+	// V1 policies use SimpleSchedulePolicy, V2 the V2 variant.
+	switch s := props.SchedulePolicy.(type) {
+	case *armrecoveryservicesbackup.SimpleSchedulePolicy:
+		p.Schedule.Frequency = string(deref(s.ScheduleRunFrequency))
+		p.Schedule.Time = firstTime(s.ScheduleRunTimes)
+		p.Schedule.Weekdays = names(s.ScheduleRunDays)
+	case *armrecoveryservicesbackup.SimpleSchedulePolicyV2:
+		p.Schedule.Frequency = string(deref(s.ScheduleRunFrequency))
+		switch {
+		case s.HourlySchedule != nil:
+			p.Schedule.Time = clock(s.HourlySchedule.ScheduleWindowStartTime)
+			p.Schedule.HourInterval = int(deref(s.HourlySchedule.Interval))
+			p.Schedule.HourDuration = int(deref(s.HourlySchedule.ScheduleWindowDuration))
+		case s.WeeklySchedule != nil:
+			p.Schedule.Time = firstTime(s.WeeklySchedule.ScheduleRunTimes)
+			p.Schedule.Weekdays = names(s.WeeklySchedule.ScheduleRunDays)
+		case s.DailySchedule != nil:
+			p.Schedule.Time = firstTime(s.DailySchedule.ScheduleRunTimes)
+		}
+	default:
+		return p, fmt.Errorf("policy %s: unexpected schedule policy %T", name, props.SchedulePolicy)
+	}
+
+	ltr, ok := props.RetentionPolicy.(*armrecoveryservicesbackup.LongTermRetentionPolicy)
+	if !ok {
+		return p, fmt.Errorf("policy %s: unexpected retention policy %T", name, props.RetentionPolicy)
+	}
+	if d := ltr.DailySchedule; d != nil {
+		p.Retention.Daily = &Tier{Count: count(d.RetentionDuration)}
+	}
+	if w := ltr.WeeklySchedule; w != nil {
+		p.Retention.Weekly = &Tier{Count: count(w.RetentionDuration), Weekdays: names(w.DaysOfTheWeek)}
+	}
+	if m := ltr.MonthlySchedule; m != nil {
+		p.Retention.Monthly = armTier(m.RetentionDuration, m.RetentionScheduleWeekly, m.RetentionScheduleDaily)
+	}
+	if y := ltr.YearlySchedule; y != nil {
+		p.Retention.Yearly = armTier(y.RetentionDuration, y.RetentionScheduleWeekly, y.RetentionScheduleDaily)
+		p.Retention.Yearly.Months = names(y.MonthsOfYear)
+	}
+	return p, nil
+}
+
+func armTier(d *armrecoveryservicesbackup.RetentionDuration, weekly *armrecoveryservicesbackup.WeeklyRetentionFormat, daily *armrecoveryservicesbackup.DailyRetentionFormat) *Tier {
+	t := &Tier{Count: count(d)}
+	if weekly != nil {
+		t.Weekdays = names(weekly.DaysOfTheWeek)
+		t.Weeks = names(weekly.WeeksOfTheMonth)
+	}
+	if daily != nil {
+		for _, day := range daily.DaysOfTheMonth {
+			if day == nil {
+				continue
+			}
+			if deref(day.IsLast) {
+				t.IncludeLastDays = true
+				continue
+			}
+			t.Days = append(t.Days, int(deref(day.Date)))
+		}
+	}
+	return t
+}
+
+func count(d *armrecoveryservicesbackup.RetentionDuration) int {
+	if d == nil {
+		return 0
+	}
+	return int(deref(d.Count))
+}
+
+// clock formats a schedule time as HH:MM. The API expresses run times as a
+// UTC timestamp whose clock reading is local to the policy's time zone.
+func clock(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("15:04")
+}
+
+func firstTime(times []*time.Time) string {
+	if len(times) == 0 {
+		return ""
+	}
+	return clock(times[0])
+}
+
+func names[T ~string](in []*T) []string {
+	var out []string
+	for _, v := range in {
+		if v != nil {
+			out = append(out, string(*v))
+		}
+	}
+	return out
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
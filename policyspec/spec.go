@@ -0,0 +1,269 @@
+// Package policyspec checks backup policies against a declarative spec.
+//
+// The spec is a YAML file listing, per named VM policy, the schedule,
+// retention tiers, policy type and instant restore window it must have. A
+// policy is read either from a Terraform plan (FromPlan) or from the Recovery
+// Services API (FromARM) into the same Policy shape, and Diff lists every
+// field where it deviates from the spec. Fields the spec leaves empty are not
+// checked, but a retention tier the spec does not list must not be
+// configured.
+//
+// Disk snapshot policies of the Data Protection vault are described the same
+// way (DiskPolicy), with their ISO 8601 schedules and retention durations
+// parsed by the iso8601 package rather than compared as text.
+package policyspec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the set of policies the vaults must hold.
+type Spec struct {
+	// Policies are the VM backup policies of the Recovery Services vault.
+	Policies []Policy `yaml:"policies" json:"policies"`
+	// DiskPolicies are the disk snapshot policies of the Data Protection
+	// backup vault.
+	DiskPolicies []DiskPolicy `yaml:"disk_policies" json:"disk_policies"`
+}
+
+// Policy describes one azurerm_backup_policy_vm. The same type holds both
+// the expectation and what was observed.
+type Policy struct {
+	// Name is the policy name in the vault.
+	Name string `yaml:"name" json:"name"`
+	// Address is the Terraform resource address that creates it.
+	Address string `yaml:"address" json:"address"`
+	// Type is V1 (standard) or V2 (enhanced).
+	Type     string `yaml:"type" json:"type"`
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	Schedule           Schedule  `yaml:"schedule" json:"schedule"`
+	Retention          Retention `yaml:"retention" json:"retention"`
+	InstantRestoreDays int       `yaml:"instant_restore_days" json:"instant_restore_days"`
+}
+
+// Schedule is when backups are taken.
+type Schedule struct {
+	Frequency string `yaml:"frequency" json:"frequency"`
+	// Time is the daily start time, or the start of the hourly window, HH:MM.
+	Time string `yaml:"time" json:"time"`
+	// Weekdays apply to Weekly schedules.
+	Weekdays     []string `yaml:"weekdays,omitempty" json:"weekdays,omitempty"`
+	HourInterval int      `yaml:"hour_interval,omitempty" json:"hour_interval,omitempty"`
+	HourDuration int      `yaml:"hour_duration,omitempty" json:"hour_duration,omitempty"`
+}
+
+// Retention holds one entry per configured tier; nil means not configured.
+type Retention struct {
+	Daily   *Tier `yaml:"daily,omitempty" json:"daily,omitempty"`
+	Weekly  *Tier `yaml:"weekly,omitempty" json:"weekly,omitempty"`
+	Monthly *Tier `yaml:"monthly,omitempty" json:"monthly,omitempty"`
+	Yearly  *Tier `yaml:"yearly,omitempty" json:"yearly,omitempty"`
+}
+
+// Tier is a retention tier: how many points are kept and which backups
+// become them.
+type Tier struct {
+	Count           int      `yaml:"count" json:"count"`
+	Weekdays        []string `yaml:"weekdays,omitempty" json:"weekdays,omitempty"`
+	Weeks           []string `yaml:"weeks,omitempty" json:"weeks,omitempty"`
+	Months          []string `yaml:"months,omitempty" json:"months,omitempty"`
+	Days            []int    `yaml:"days,omitempty" json:"days,omitempty"`
+	IncludeLastDays bool     `yaml:"include_last_days,omitempty" json:"include_last_days,omitempty"`
+}
+
+// tiers lists the retention tiers with their plan block and ARM names.
+func (r Retention) tiers() []struct {
+	name string
+	tier *Tier
+} {
+	return []struct {
+		name string
+		tier *Tier
+	}{
+		{"daily", r.Daily},
+		{"weekly", r.Weekly},
+		{"monthly", r.Monthly},
+		{"yearly", r.Yearly},
+	}
+}
+
+// Policy returns the spec for the named policy.
+func (s *Spec) Policy(name string) (Policy, bool) {
+	for _, p := range s.Policies {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Policy{}, false
+}
+
+// Load reads and validates the spec at path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse decodes a spec, rejecting fields it does not know.
+func Parse(data []byte) (*Spec, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var s Spec
+	if err := dec.Decode(&s); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("invalid policy spec:\n  %s", strings.Join(typeErr.Errors, "\n  "))
+		}
+		return nil, fmt.Errorf("invalid policy spec: %w", err)
+	}
+	return &s, nil
+}
+
+// ─── Validation ───────────────────────────────────────────────────────────────
+
+// FieldError is a validation failure of one spec field, named by its YAML
+// path, e.g. policies[1].retention.daily.count.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError lists every invalid field of a spec.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		lines[i] = "  " + f.Error()
+	}
+	return "policy spec is invalid:\n" + strings.Join(lines, "\n")
+}
+
+var timePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):(00|30)$`)
+
+// Limits the Recovery Services API enforces on VM backup policies.
+var (
+	validHourIntervals = []int{4, 6, 8, 12}
+	tierLimits         = map[string][2]int{
+		"daily":   {7, 9999},
+		"weekly":  {1, 5163},
+		"monthly": {1, 1188},
+		"yearly":  {1, 99},
+	}
+)
+
+// Validate checks the spec against the limits Azure puts on VM backup
+// policies, reporting every bad field at once.
+func (s *Spec) Validate() error {
+	var errs []FieldError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Policies) == 0 {
+		fail("policies", "at least one policy is required")
+	}
+	seen := map[string]bool{}
+	for i, p := range s.Policies {
+		field := fmt.Sprintf("policies[%d]", i)
+
+		if p.Name == "" {
+			fail(field+".name", "is required")
+		} else if seen[p.Name] {
+			fail(field+".name", "duplicate policy %q", p.Name)
+		}
+		seen[p.Name] = true
+		if !strings.HasPrefix(p.Address, "azurerm_backup_policy_vm.") {
+			fail(field+".address", "%q is not an azurerm_backup_policy_vm address", p.Address)
+		}
+		if p.Type != "V1" && p.Type != "V2" {
+			fail(field+".type", "%q is not one of V1, V2", p.Type)
+		}
+
+		sched := p.Schedule
+		switch sched.Frequency {
+		case "Daily", "Weekly":
+			if sched.HourInterval != 0 || sched.HourDuration != 0 {
+				fail(field+".schedule.hour_interval", "only applies to Hourly schedules")
+			}
+		case "Hourly":
+			if p.Type != "V2" {
+				fail(field+".schedule.frequency", "Hourly backups need an enhanced (V2) policy")
+			}
+			if !containsInt(validHourIntervals, sched.HourInterval) {
+				fail(field+".schedule.hour_interval", "%d is not one of 4, 6, 8, 12", sched.HourInterval)
+			}
+			if sched.HourDuration < 4 || sched.HourDuration > 24 {
+				fail(field+".schedule.hour_duration", "%d is outside 4..24", sched.HourDuration)
+			}
+		default:
+			fail(field+".schedule.frequency", "%q is not one of Daily, Weekly, Hourly", sched.Frequency)
+		}
+		if sched.Frequency == "Weekly" && len(sched.Weekdays) == 0 {
+			fail(field+".schedule.weekdays", "a Weekly schedule needs at least one weekday")
+		}
+		if !timePattern.MatchString(sched.Time) {
+			fail(field+".schedule.time", "%q is not a half-hour HH:MM time", sched.Time)
+		}
+
+		if p.Retention.Daily == nil && sched.Frequency != "Weekly" {
+			fail(field+".retention.daily", "is required for %s schedules", sched.Frequency)
+		}
+		for _, t := range p.Retention.tiers() {
+			if t.tier == nil {
+				continue
+			}
+			limits := tierLimits[t.name]
+			if t.tier.Count < limits[0] || t.tier.Count > limits[1] {
+				fail(fmt.Sprintf("%s.retention.%s.count", field, t.name), "%d is outside %d..%d", t.tier.Count, limits[0], limits[1])
+			}
+		}
+
+		maxInstant := 5
+		if p.Type == "V2" {
+			maxInstant = 30
+		}
+		if p.InstantRestoreDays < 1 || p.InstantRestoreDays > maxInstant {
+			fail(field+".instant_restore_days", "%d is outside 1..%d for a %s policy", p.InstantRestoreDays, maxInstant, p.Type)
+		}
+	}
+	s.validateDisk(fail)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: errs}
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
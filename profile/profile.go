@@ -0,0 +1,236 @@
+// Package profile loads the environment profile a test run targets.
+//
+// A profile is a YAML file describing one landing zone: the regions to deploy
+// to, resources that already exist there, the VMs whose backup is exercised
+// and the values the deployed vault is expected to have, with the backup
+// policies in a separate policy spec (see the policyspec package). The
+// fields are documented by profiles/schema.json. Load validates the file
+// after applying the TEST_* environment overrides listed in Overrides, so a
+// single profile can be nudged per run without editing it.
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+)
+
+// Profile is one environment the suite can target.
+type Profile struct {
+	// Name identifies the profile in logs and errors.
+	Name string `yaml:"name" json:"name"`
+	// Environment is passed to the module's environment variable.
+	Environment string `yaml:"environment" json:"environment"`
+
+	Regions        Regions        `yaml:"regions" json:"regions"`
+	ResourceGroups ResourceGroups `yaml:"resource_groups" json:"resource_groups"`
+
+	// LogAnalyticsWorkspaceID reuses an existing workspace; empty lets the
+	// module create one.
+	LogAnalyticsWorkspaceID string   `yaml:"log_analytics_workspace_id" json:"log_analytics_workspace_id"`
+	AlertEmailAddresses     []string `yaml:"alert_email_addresses" json:"alert_email_addresses"`
+	// AlertWebhooks are posted every backup alert in the Common Alert
+	// Schema, alongside the email receivers.
+	AlertWebhooks []Webhook `yaml:"alert_webhook_receivers" json:"alert_webhook_receivers"`
+	// ResourceGuardID links the vault to an existing Resource Guard for
+	// multi-user authorization; empty leaves the vault unguarded.
+	ResourceGuardID string `yaml:"resource_guard_id" json:"resource_guard_id"`
+	// Encryption selects the customer-managed key the vaults encrypt with.
+	Encryption Encryption `yaml:"encryption" json:"encryption"`
+
+	VMs    VMs          `yaml:"vms" json:"vms"`
+	Expect Expectations `yaml:"expect" json:"expect"`
+
+	// overridden maps fields set by ApplyEnv to their variable, so that
+	// validation errors point at the variable rather than the file.
+	overridden map[string]string
+}
+
+// Regions are the primary deployment region and the paired region used for
+// cross-region restore.
+type Regions struct {
+	Primary   string `yaml:"primary" json:"primary"`
+	Secondary string `yaml:"secondary" json:"secondary"`
+}
+
+// ResourceGroups name existing groups to deploy into. They are reused and
+// never deleted; an empty name makes the harness create a temporary group.
+type ResourceGroups struct {
+	Main     string `yaml:"main" json:"main"`
+	Snapshot string `yaml:"snapshot" json:"snapshot"`
+}
+
+// Webhook is a webhook receiver of the backup alert action group.
+type Webhook struct {
+	Name       string `yaml:"name" json:"name"`
+	ServiceURI string `yaml:"service_uri" json:"service_uri"`
+}
+
+// Encryption is the customer-managed key (CMK) of the backup vaults. An
+// empty KeyID leaves the vaults on platform-managed keys and nothing is
+// checked.
+type Encryption struct {
+	// KeyID is the Key Vault key URL. Without a version the vaults follow
+	// rotations of the key.
+	KeyID string `yaml:"key_id" json:"key_id"`
+	// KeyVersionPolicy is versionless or pinned, and must agree with KeyID.
+	KeyVersionPolicy string `yaml:"key_version_policy" json:"key_version_policy"`
+	// KeyVaultID is the resource ID of the Key Vault holding the key.
+	KeyVaultID string `yaml:"key_vault_id" json:"key_vault_id"`
+	// IdentityID is the user-assigned identity the vaults reach the key
+	// with.
+	IdentityID string `yaml:"identity_id" json:"identity_id"`
+	// Vaults are the vaults that must use the key: recovery_services,
+	// data_protection or both.
+	Vaults []string `yaml:"vaults" json:"vaults"`
+}
+
+// VMs are the virtual machines the module protects, per tier.
+type VMs struct {
+	App VMSet `yaml:"app" json:"app"`
+	Web VMSet `yaml:"web" json:"web"`
+}
+
+// VMSet lists the resource IDs of a tier's VMs and their managed disks.
+type VMSet struct {
+	IDs         []string `yaml:"ids" json:"ids"`
+	OSDiskIDs   []string `yaml:"os_disk_ids" json:"os_disk_ids"`
+	DataDiskIDs []string `yaml:"data_disk_ids" json:"data_disk_ids"`
+}
+
+// Expectations are the values the assertions check the deployment against.
+type Expectations struct {
+	Vault VaultExpectation `yaml:"vault" json:"vault"`
+	// PolicySpec is the backup policy spec file, relative to the profile.
+	PolicySpec string `yaml:"policy_spec" json:"policy_spec"`
+	// Policies is the spec PolicySpec names, read by Load.
+	Policies *policyspec.Spec `yaml:"-" json:"policies"`
+}
+
+// VaultExpectation describes the Recovery Services vault.
+type VaultExpectation struct {
+	SKU                string `yaml:"sku" json:"sku"`
+	StorageMode        string `yaml:"storage_mode" json:"storage_mode"`
+	CrossRegionRestore bool   `yaml:"cross_region_restore" json:"cross_region_restore"`
+	SoftDelete         bool   `yaml:"soft_delete" json:"soft_delete"`
+
+	// Immutability is Disabled, Unlocked or Locked; empty is not checked.
+	Immutability string `yaml:"immutability" json:"immutability"`
+	// SoftDeleteRetentionDays is the least soft-delete retention accepted;
+	// zero is not checked.
+	SoftDeleteRetentionDays int `yaml:"soft_delete_retention_days" json:"soft_delete_retention_days"`
+	// EnhancedSecurity requires the vault's enhanced security setting, which
+	// stops soft delete from being switched off without MUA.
+	EnhancedSecurity bool `yaml:"enhanced_security" json:"enhanced_security"`
+	// MultiUserAuthorization requires the vault to be linked to the
+	// profile's resource_guard_id.
+	MultiUserAuthorization bool `yaml:"multi_user_authorization" json:"multi_user_authorization"`
+}
+
+// DefaultDir is where named profiles live, relative to the test directory.
+const DefaultDir = "profiles"
+
+// Resolve turns a profile reference into a path: a bare name such as "uat"
+// means profiles/uat.yaml, anything with a separator or extension is a path.
+func Resolve(ref string) string {
+	if strings.ContainsRune(ref, filepath.Separator) || filepath.Ext(ref) != "" {
+		return ref
+	}
+	return filepath.Join(DefaultDir, ref+".yaml")
+}
+
+// Load reads the profile at path, applies the overrides found through
+// getenv (usually os.Getenv) and validates the result.
+func Load(path string, getenv func(string) string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.ApplyEnv(getenv)
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	specPath := p.Expect.PolicySpec
+	if !filepath.IsAbs(specPath) {
+		specPath = filepath.Join(filepath.Dir(path), specPath)
+	}
+	if p.Expect.Policies, err = policyspec.Load(specPath); err != nil {
+		return nil, fmt.Errorf("%s: expect.policy_spec: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse decodes a profile. Fields the schema does not know are an error, so
+// a misspelt key cannot silently fall back to a default.
+func Parse(data []byte) (*Profile, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var p Profile
+	if err := dec.Decode(&p); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("invalid profile:\n  %s", strings.Join(typeErr.Errors, "\n  "))
+		}
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+	return &p, nil
+}
+
+// Override maps an environment variable onto a profile field.
+type Override struct {
+	Env   string
+	Field string
+	set   func(p *Profile, v string)
+}
+
+// Overrides are applied in order by ApplyEnv. List values are
+// comma-separated.
+var Overrides = []Override{
+	{"TEST_LOCATION", "regions.primary", func(p *Profile, v string) { p.Regions.Primary = v }},
+	{"TEST_SECONDARY_LOCATION", "regions.secondary", func(p *Profile, v string) { p.Regions.Secondary = v }},
+	{"TEST_RESOURCE_GROUP", "resource_groups.main", func(p *Profile, v string) { p.ResourceGroups.Main = v }},
+	{"TEST_SNAPSHOT_RESOURCE_GROUP", "resource_groups.snapshot", func(p *Profile, v string) { p.ResourceGroups.Snapshot = v }},
+	{"TEST_LOG_ANALYTICS_WORKSPACE_ID", "log_analytics_workspace_id", func(p *Profile, v string) { p.LogAnalyticsWorkspaceID = v }},
+	{"TEST_RESOURCE_GUARD_ID", "resource_guard_id", func(p *Profile, v string) { p.ResourceGuardID = v }},
+	{"TEST_CMK_KEY_ID", "encryption.key_id", func(p *Profile, v string) { p.Encryption.KeyID = v }},
+	{"TEST_CMK_KEY_VAULT_ID", "encryption.key_vault_id", func(p *Profile, v string) { p.Encryption.KeyVaultID = v }},
+	{"TEST_CMK_IDENTITY_ID", "encryption.identity_id", func(p *Profile, v string) { p.Encryption.IdentityID = v }},
+	{"TEST_ALERT_EMAILS", "alert_email_addresses", func(p *Profile, v string) { p.AlertEmailAddresses = splitList(v) }},
+	{"TEST_APP_VM_IDS", "vms.app.ids", func(p *Profile, v string) { p.VMs.App.IDs = splitList(v) }},
+	{"TEST_WEB_VM_IDS", "vms.web.ids", func(p *Profile, v string) { p.VMs.Web.IDs = splitList(v) }},
+}
+
+// ApplyEnv applies every override whose variable is set and non-empty.
+func (p *Profile) ApplyEnv(getenv func(string) string) {
+	for _, o := range Overrides {
+		if v := strings.TrimSpace(getenv(o.Env)); v != "" {
+			o.set(p, v)
+			if p.overridden == nil {
+				p.overridden = map[string]string{}
+			}
+			p.overridden[o.Field] = o.Env
+		}
+	}
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
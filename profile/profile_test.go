@@ -0,0 +1,67 @@
+package profile_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/profile"
+)
+
+// TestValidate checks that an invalid profile is rejected with errors naming
+// each bad field, and where an override came from.
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	p, err := profile.Parse([]byte(`
+name: broken
+environment: test
+regions: {primary: eastus, secondary: eastus}
+alert_email_addresses: [not-an-address]
+alert_webhook_receivers:
+  - {name: hook, service_uri: "http://alerts.example.com/backup"}
+  - {name: hook, service_uri: "https://alerts.example.com/backup"}
+vms:
+  app:
+    ids: [/subscriptions/0/resourceGroups/rg/providers/Microsoft.Compute/disks/d0]
+expect:
+  vault: {sku: Premium, storage_mode: LocallyRedundant, cross_region_restore: true}
+`))
+	require.NoError(t, err)
+	p.ApplyEnv(func(key string) string {
+		if key == "TEST_LOCATION" {
+			return "East US"
+		}
+		return ""
+	})
+
+	var verr *profile.ValidationError
+	require.True(t, errors.As(p.Validate(), &verr))
+	var got []string
+	for _, f := range verr.Fields {
+		got = append(got, f.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"regions.primary",
+		"alert_email_addresses[0]",
+		"alert_webhook_receivers[0].service_uri",
+		"alert_webhook_receivers[1].name",
+		"vms.app.ids[0]",
+		"expect.vault.sku",
+		"expect.vault.cross_region_restore",
+		"expect.policy_spec",
+	}, got)
+	assert.Contains(t, verr.Error(), `regions.primary (from TEST_LOCATION): "East US" is not an Azure region name`)
+}
+
+// TestParseUnknownField checks that a misspelt field is an error rather than
+// silently ignored.
+func TestParseUnknownField(t *testing.T) {
+	t.Parallel()
+
+	_, err := profile.Parse([]byte("name: typo\nregion:\n  primary: eastus\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field region not found")
+}
@@ -0,0 +1,257 @@
+package profile
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+// FieldError is a validation failure of one field, named by its YAML path,
+// e.g. vms.app.ids[1].
+type FieldError struct {
+	Field   string
+	Message string
+	// Env is the variable the value came from, when it was overridden.
+	Env string
+}
+
+func (e FieldError) Error() string {
+	if e.Env != "" {
+		return fmt.Sprintf("%s (from %s): %s", e.Field, e.Env, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError lists every invalid field of a profile.
+type ValidationError struct {
+	Profile string
+	Fields  []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		lines[i] = "  " + f.Error()
+	}
+	return fmt.Sprintf("profile %q is invalid:\n%s", e.Profile, strings.Join(lines, "\n"))
+}
+
+var (
+	regionPattern        = regexp.MustCompile(`^[a-z][a-z0-9]+$`)
+	resourceGroupPattern = regexp.MustCompile(`^[-\w.()]{1,90}$`)
+)
+
+// Values the Recovery Services API accepts for a vault.
+var (
+	validSKUs         = []string{"Standard", "RS0"}
+	validStorageModes = []string{"GeoRedundant", "LocallyRedundant", "ZoneRedundant"}
+	validImmutability = []string{"Disabled", "Unlocked", "Locked"}
+)
+
+// Values of the encryption section.
+var (
+	validKeyVersionPolicies = []string{"versionless", "pinned"}
+	validEncryptedVaults    = []string{"recovery_services", "data_protection"}
+)
+
+// Soft-delete retention limits, in days.
+const (
+	minSoftDeleteRetentionDays = 14
+	maxSoftDeleteRetentionDays = 180
+)
+
+// Validate checks the profile against the schema and the limits Azure puts
+// on the values, reporting every bad field at once.
+func (p *Profile) Validate() error {
+	v := &validator{p: p}
+
+	v.require("name", p.Name)
+	v.require("environment", p.Environment)
+
+	v.region("regions.primary", p.Regions.Primary)
+	v.region("regions.secondary", p.Regions.Secondary)
+	if p.Regions.Primary != "" && p.Regions.Primary == p.Regions.Secondary {
+		v.fail("regions.secondary", "must differ from regions.primary for cross-region restore")
+	}
+
+	v.resourceGroup("resource_groups.main", p.ResourceGroups.Main)
+	v.resourceGroup("resource_groups.snapshot", p.ResourceGroups.Snapshot)
+
+	if p.LogAnalyticsWorkspaceID != "" {
+		v.resourceID("log_analytics_workspace_id", p.LogAnalyticsWorkspaceID, "Microsoft.OperationalInsights/workspaces")
+	}
+
+	if p.ResourceGuardID != "" {
+		v.resourceID("resource_guard_id", p.ResourceGuardID, "Microsoft.DataProtection/resourceGuards")
+	}
+
+	v.encryption("encryption", p.Encryption)
+
+	if len(p.AlertEmailAddresses) == 0 {
+		v.fail("alert_email_addresses", "at least one address is required")
+	}
+	for i, addr := range p.AlertEmailAddresses {
+		if a, err := mail.ParseAddress(addr); err != nil || a.Address != addr {
+			v.fail(fmt.Sprintf("alert_email_addresses[%d]", i), fmt.Sprintf("%q is not a plain email address", addr))
+		}
+	}
+	names := map[string]bool{}
+	for i, w := range p.AlertWebhooks {
+		field := fmt.Sprintf("alert_webhook_receivers[%d]", i)
+		if v.require(field+".name", w.Name) && names[w.Name] {
+			v.fail(field+".name", fmt.Sprintf("%q is used by another receiver", w.Name))
+		}
+		names[w.Name] = true
+		if u, err := url.Parse(w.ServiceURI); err != nil || u.Scheme != "https" || u.Host == "" {
+			v.fail(field+".service_uri", fmt.Sprintf("%q is not an https:// URL", w.ServiceURI))
+		}
+	}
+
+	v.vmSet("vms.app", p.VMs.App)
+	v.vmSet("vms.web", p.VMs.Web)
+
+	v.vault("expect.vault", p.Expect.Vault)
+	v.require("expect.policy_spec", p.Expect.PolicySpec)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Profile: p.Name, Fields: v.errs}
+}
+
+type validator struct {
+	p    *Profile
+	errs []FieldError
+}
+
+func (v *validator) fail(field, msg string) {
+	env := ""
+	for f, e := range v.p.overridden {
+		if field == f || strings.HasPrefix(field, f+"[") {
+			env = e
+		}
+	}
+	v.errs = append(v.errs, FieldError{Field: field, Message: msg, Env: env})
+}
+
+func (v *validator) require(field, val string) bool {
+	if strings.TrimSpace(val) == "" {
+		v.fail(field, "is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) oneOf(field, val string, allowed []string) {
+	for _, a := range allowed {
+		if val == a {
+			return
+		}
+	}
+	v.fail(field, fmt.Sprintf("%q is not one of %s", val, strings.Join(allowed, ", ")))
+}
+
+func (v *validator) region(field, val string) {
+	if v.require(field, val) && !regionPattern.MatchString(val) {
+		v.fail(field, fmt.Sprintf("%q is not an Azure region name such as eastus", val))
+	}
+}
+
+func (v *validator) resourceGroup(field, val string) {
+	if val != "" && (!resourceGroupPattern.MatchString(val) || strings.HasSuffix(val, ".")) {
+		v.fail(field, fmt.Sprintf("%q is not a valid resource group name", val))
+	}
+}
+
+func (v *validator) resourceID(field, val, wantType string) {
+	id, err := arm.ParseResourceID(val)
+	if err != nil {
+		v.fail(field, fmt.Sprintf("%q is not a resource ID: %v", val, err))
+		return
+	}
+	if !strings.EqualFold(id.ResourceType.String(), wantType) {
+		v.fail(field, fmt.Sprintf("%q is a %s, want a %s", val, id.ResourceType, wantType))
+	}
+}
+
+func (v *validator) encryption(field string, e Encryption) {
+	if e.KeyID == "" {
+		if e.KeyVaultID != "" || e.IdentityID != "" {
+			v.fail(field+".key_id", "is required with key_vault_id or identity_id")
+		}
+		return
+	}
+	version, ok := keyVersion(e.KeyID)
+	if !ok {
+		v.fail(field+".key_id", fmt.Sprintf("%q is not a Key Vault key URL such as https://<vault>.vault.azure.net/keys/<key>", e.KeyID))
+	}
+	v.oneOf(field+".key_version_policy", e.KeyVersionPolicy, validKeyVersionPolicies)
+	switch {
+	case ok && e.KeyVersionPolicy == "versionless" && version != "":
+		v.fail(field+".key_version_policy", "versionless requires a key_id without a version")
+	case ok && e.KeyVersionPolicy == "pinned" && version == "":
+		v.fail(field+".key_version_policy", "pinned requires a key_id with a version")
+	}
+	if v.require(field+".key_vault_id", e.KeyVaultID) {
+		v.resourceID(field+".key_vault_id", e.KeyVaultID, "Microsoft.KeyVault/vaults")
+	}
+	if v.require(field+".identity_id", e.IdentityID) {
+		v.resourceID(field+".identity_id", e.IdentityID, "Microsoft.ManagedIdentity/userAssignedIdentities")
+	}
+	if len(e.Vaults) == 0 {
+		v.fail(field+".vaults", "at least one vault is required")
+	}
+	for i, vault := range e.Vaults {
+		v.oneOf(fmt.Sprintf("%s.vaults[%d]", field, i), vault, validEncryptedVaults)
+	}
+}
+
+// keyVersion splits the version off a Key Vault key URL, reporting whether
+// the URL names a key at all.
+func keyVersion(keyID string) (string, bool) {
+	u, err := url.Parse(keyID)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "keys":
+		return "", true
+	case len(parts) == 3 && parts[0] == "keys":
+		return parts[2], true
+	}
+	return "", false
+}
+
+func (v *validator) vmSet(field string, set VMSet) {
+	for i, id := range set.IDs {
+		v.resourceID(fmt.Sprintf("%s.ids[%d]", field, i), id, "Microsoft.Compute/virtualMachines")
+	}
+	for i, id := range set.OSDiskIDs {
+		v.resourceID(fmt.Sprintf("%s.os_disk_ids[%d]", field, i), id, "Microsoft.Compute/disks")
+	}
+	for i, id := range set.DataDiskIDs {
+		v.resourceID(fmt.Sprintf("%s.data_disk_ids[%d]", field, i), id, "Microsoft.Compute/disks")
+	}
+}
+
+func (v *validator) vault(field string, e VaultExpectation) {
+	v.oneOf(field+".sku", e.SKU, validSKUs)
+	v.oneOf(field+".storage_mode", e.StorageMode, validStorageModes)
+	if e.CrossRegionRestore && e.StorageMode != "GeoRedundant" {
+		v.fail(field+".cross_region_restore", "requires storage_mode GeoRedundant")
+	}
+	if e.Immutability != "" {
+		v.oneOf(field+".immutability", e.Immutability, validImmutability)
+	}
+	if d := e.SoftDeleteRetentionDays; d != 0 && (d < minSoftDeleteRetentionDays || d > maxSoftDeleteRetentionDays) {
+		v.fail(field+".soft_delete_retention_days", fmt.Sprintf("%d is outside %d-%d", d, minSoftDeleteRetentionDays, maxSoftDeleteRetentionDays))
+	}
+	if e.MultiUserAuthorization && v.p.ResourceGuardID == "" {
+		v.fail(field+".multi_user_authorization", "requires resource_guard_id")
+	}
+}
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/profile"
+)
+
+// ─── Environment profile ──────────────────────────────────────────────────────
+
+// testProfile loads the profile named by TEST_PROFILE (default dev), either
+// a name under profiles/ or a path, with the TEST_* overrides applied.
+func testProfile(t *testing.T) *profile.Profile {
+	t.Helper()
+	path := profile.Resolve(envOrDefault("TEST_PROFILE", "dev"))
+	p, err := profile.Load(path, os.Getenv)
+	require.NoError(t, err, "failed to load test profile")
+	return p
+}
+
+// softDeleteState is the vault soft-delete state a profile expects.
+func softDeleteState(enabled bool) armrecoveryservices.SoftDeleteState {
+	if enabled {
+		return armrecoveryservices.SoftDeleteStateEnabled
+	}
+	return armrecoveryservices.SoftDeleteStateDisabled
+}
+
+// crossRegionRestoreState is the vault CRR state a profile expects.
+func crossRegionRestoreState(enabled bool) armrecoveryservices.CrossRegionRestore {
+	if enabled {
+		return armrecoveryservices.CrossRegionRestoreEnabled
+	}
+	return armrecoveryservices.CrossRegionRestoreDisabled
+}
+
+// TestProfiles checks that every checked-in profile is valid.
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	paths, err := filepath.Glob(filepath.Join(profile.DefaultDir, "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			_, err := profile.Load(path, func(string) string { return "" })
+			assert.NoError(t, err)
+		})
+	}
+}
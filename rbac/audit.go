@@ -0,0 +1,283 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Need is an action a principal needs at a scope, and what needs it.
+type Need struct {
+	Action string
+	Scope  string
+	For    string
+}
+
+// Grant is a role assignment with the actions its role allows. The audit
+// only looks at the control-plane Actions; DataActions are for checks on
+// data access, such as a vault identity's use of a key.
+type Grant struct {
+	Assignment
+	Actions        []string
+	NotActions     []string
+	DataActions    []string
+	NotDataActions []string
+}
+
+// Allows reports whether the grant allows the control-plane action at scope.
+func (g Grant) Allows(action, scope string) bool {
+	return allows(g.Scope, g.Actions, g.NotActions, action, scope)
+}
+
+// AllowsData reports whether the grant allows the data action at scope.
+func (g Grant) AllowsData(action, scope string) bool {
+	return allows(g.Scope, g.DataActions, g.NotDataActions, action, scope)
+}
+
+func allows(granted string, actions, notActions []string, action, scope string) bool {
+	if !within(granted, scope) {
+		return false
+	}
+	for _, p := range notActions {
+		if matchAction(p, action) {
+			return false
+		}
+	}
+	for _, p := range actions {
+		if matchAction(p, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindingKind classifies an audit finding.
+type FindingKind string
+
+const (
+	// MissingAction: no assignment allows a needed action at its scope.
+	MissingAction FindingKind = "missing"
+	// UnusedAssignment: an assignment allows nothing the principal needs.
+	UnusedAssignment FindingKind = "unused"
+	// BroadScope: an assignment is made above every scope it is needed at.
+	BroadScope FindingKind = "scope"
+	// BroadRole: an assignment's role allows actions nothing needs.
+	BroadRole FindingKind = "role"
+)
+
+// Finding is one way a principal's permissions differ from its needs.
+type Finding struct {
+	Kind FindingKind
+	// Assignment is the assignment at fault; for MissingAction only the
+	// principal is set.
+	Assignment Assignment
+	// Need is the need nothing grants, for MissingAction.
+	Need Need
+	// NeededAt is the narrowest scope covering the needs the assignment
+	// serves, for BroadScope.
+	NeededAt string
+	// Excess are the role's action patterns no need asks for, for BroadRole.
+	Excess []string
+}
+
+func (f Finding) String() string {
+	switch f.Kind {
+	case MissingAction:
+		return fmt.Sprintf("%s needs %s on %s for %s", f.Assignment.Principal, f.Need.Action, f.Need.Scope, f.Need.For)
+	case UnusedAssignment:
+		return fmt.Sprintf("%s, which allows nothing it needs", f.Assignment)
+	case BroadScope:
+		return fmt.Sprintf("%s, but needs it only on %s", f.Assignment, f.NeededAt)
+	}
+	return fmt.Sprintf("%s, which allows actions it does not need: %s", f.Assignment, strings.Join(f.Excess, ", "))
+}
+
+// AuditReport is the effective permissions of one principal and how they
+// exceed or fall short of its needs.
+type AuditReport struct {
+	Principal string
+	Grants    []Grant
+	Findings  []Finding
+}
+
+// OK reports whether the principal holds exactly what it needs.
+func (r *AuditReport) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// Grants returns the assignments principalID holds in the subscription with
+// the actions of their roles.
+func (c *Checker) Grants(ctx context.Context, principal, principalID string) ([]Grant, error) {
+	assignments, err := c.Assignments(ctx, principal, principalID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(assignments, func(i, j int) bool { return assignments[i].key() < assignments[j].key() })
+	grants := make([]Grant, 0, len(assignments))
+	for _, a := range assignments {
+		r, err := c.role(ctx, a.RoleDefinitionID)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, Grant{
+			Assignment:     a,
+			Actions:        r.actions,
+			NotActions:     r.notActions,
+			DataActions:    r.dataActions,
+			NotDataActions: r.notDataActions,
+		})
+	}
+	return grants, nil
+}
+
+// CallerGrants returns what the calling identity may do in resourceGroup, as
+// the permissions API reports it: one grant per role, scoped to the group,
+// with neither the role's name nor its assignment.
+func (c *Checker) CallerGrants(ctx context.Context, resourceGroup string) ([]Grant, error) {
+	scope := "/subscriptions/" + c.subscriptionID + "/resourceGroups/" + resourceGroup
+	pager := c.permissions.NewListForResourceGroupPager(resourceGroup, nil)
+	var grants []Grant
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rbac: listing the caller's permissions in %s: %w", resourceGroup, err)
+		}
+		for _, p := range page.Value {
+			if p == nil {
+				continue
+			}
+			grants = append(grants, Grant{
+				Assignment:     Assignment{Principal: "caller", Scope: scope},
+				Actions:        derefAll(p.Actions),
+				NotActions:     derefAll(p.NotActions),
+				DataActions:    derefAll(p.DataActions),
+				NotDataActions: derefAll(p.NotDataActions),
+			})
+		}
+	}
+	return grants, nil
+}
+
+// Audit collects the effective permissions of principalID and compares them
+// with needs: every need must be allowed, and every assignment must serve
+// some need, at no scope above the needs it serves, with a role allowing
+// nothing beyond them. Wildcard action patterns are only accepted where a
+// need spells out the same pattern.
+func (c *Checker) Audit(ctx context.Context, principal, principalID string, needs []Need) (*AuditReport, error) {
+	grants, err := c.Grants(ctx, principal, principalID)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditReport{Principal: principal, Grants: grants, Findings: audit(principal, grants, needs)}, nil
+}
+
+func audit(principal string, grants []Grant, needs []Need) []Finding {
+	var findings []Finding
+	for _, g := range grants {
+		var served []Need
+		for _, n := range needs {
+			if g.Allows(n.Action, n.Scope) {
+				served = append(served, n)
+			}
+		}
+		if len(served) == 0 {
+			findings = append(findings, Finding{Kind: UnusedAssignment, Assignment: g.Assignment})
+			continue
+		}
+
+		scopes := make([]string, len(served))
+		for i, n := range served {
+			scopes[i] = n.Scope
+		}
+		if common := commonScope(scopes); !within(common, g.Scope) {
+			findings = append(findings, Finding{Kind: BroadScope, Assignment: g.Assignment, NeededAt: common})
+		}
+
+		var excess []string
+		for _, p := range g.Actions {
+			if !needed(p, served) {
+				excess = append(excess, p)
+			}
+		}
+		if len(excess) > 0 {
+			findings = append(findings, Finding{Kind: BroadRole, Assignment: g.Assignment, Excess: excess})
+		}
+	}
+
+	for _, n := range needs {
+		allowed := false
+		for _, g := range grants {
+			if g.Allows(n.Action, n.Scope) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			findings = append(findings, Finding{Kind: MissingAction, Assignment: Assignment{Principal: principal}, Need: n})
+		}
+	}
+	return findings
+}
+
+func needed(pattern string, needs []Need) bool {
+	for _, n := range needs {
+		if strings.EqualFold(pattern, n.Action) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAction reports whether an action pattern, in which * matches any run
+// of characters, covers action. Actions are case-insensitive.
+func matchAction(pattern, action string) bool {
+	pattern, action = strings.ToLower(pattern), strings.ToLower(action)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == action
+	}
+	if !strings.HasPrefix(action, parts[0]) {
+		return false
+	}
+	rest := action[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return strings.HasSuffix(rest, parts[len(parts)-1])
+}
+
+// within reports whether inner is scope itself or a scope below it.
+func within(scope, inner string) bool {
+	scope, inner = strings.ToLower(strings.TrimSuffix(scope, "/")), strings.ToLower(strings.TrimSuffix(inner, "/"))
+	return inner == scope || strings.HasPrefix(inner, scope+"/")
+}
+
+// commonScope is the narrowest scope that all of scopes are within.
+func commonScope(scopes []string) string {
+	common := strings.Split(strings.TrimSuffix(scopes[0], "/"), "/")
+	for _, s := range scopes[1:] {
+		parts := strings.Split(strings.TrimSuffix(s, "/"), "/")
+		n := 0
+		for n < len(common) && n < len(parts) && strings.EqualFold(common[n], parts[n]) {
+			n++
+		}
+		common = common[:n]
+	}
+	// Stop at a scope rather than a collection (".../resourceGroups") or a
+	// provider namespace (".../providers/Microsoft.Compute").
+	if len(common)%2 == 0 {
+		common = common[:len(common)-1]
+	}
+	if n := len(common); n >= 2 && strings.EqualFold(common[n-2], "providers") {
+		common = common[:n-2]
+	}
+	if len(common) <= 1 {
+		return "/"
+	}
+	return strings.Join(common, "/")
+}
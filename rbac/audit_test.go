@@ -0,0 +1,187 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMatchAction checks action patterns with * at either end, in the middle
+// and on its own, matched without regard to case.
+func TestMatchAction(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		pattern, action string
+		want            bool
+	}{
+		{"Microsoft.RecoveryServices/vaults/read", "microsoft.recoveryservices/VAULTS/read", true},
+		{"Microsoft.RecoveryServices/vaults/read", "Microsoft.RecoveryServices/vaults/write", false},
+		{"Microsoft.RecoveryServices/vaults/read", "Microsoft.RecoveryServices/vaults/readX", false},
+		{"Microsoft.RecoveryServices/*", "Microsoft.RecoveryServices/vaults/backupPolicies/write", true},
+		{"Microsoft.RecoveryServices/*", "Microsoft.DataProtection/backupVaults/read", false},
+		{"*/read", "Microsoft.Compute/virtualMachines/read", true},
+		{"*/read", "Microsoft.Compute/virtualMachines/write", false},
+		{"*", "Microsoft.Compute/virtualMachines/delete", true},
+		{"Microsoft.RecoveryServices/vaults/*/read", "Microsoft.RecoveryServices/vaults/backupPolicies/read", true},
+		{"Microsoft.RecoveryServices/vaults/*/read", "Microsoft.RecoveryServices/vaults/backupPolicies/write", false},
+		{"Microsoft.RecoveryServices/vaults/*/read", "Microsoft.RecoveryServices/vaults/read", false},
+		{"Microsoft.*/vaults/*/read", "Microsoft.RecoveryServices/vaults/backupJobs/read", true},
+		{"Microsoft.*/vaults/*/read", "Microsoft.KeyVault/vaults/read", false},
+		{"ab*ba", "aba", false},
+	} {
+		assert.Equal(t, tc.want, matchAction(tc.pattern, tc.action), "%q matching %q", tc.pattern, tc.action)
+	}
+}
+
+// TestCommonScope checks that the common scope stops at a scope rather than
+// at a collection or a provider namespace.
+func TestCommonScope(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sub = "/subscriptions/s"
+		rg  = sub + "/resourceGroups/rg-backup"
+	)
+	for _, tc := range []struct {
+		name   string
+		scopes []string
+		want   string
+	}{
+		{
+			name:   "one scope",
+			scopes: []string{rg + "/"},
+			want:   rg,
+		},
+		{
+			name:   "two resource groups",
+			scopes: []string{rg, sub + "/resourceGroups/rg-snapshots"},
+			want:   sub,
+		},
+		{
+			name:   "group and a resource in it",
+			scopes: []string{rg, rg + "/providers/Microsoft.RecoveryServices/vaults/rsv"},
+			want:   rg,
+		},
+		{
+			name:   "across providers",
+			scopes: []string{rg + "/providers/Microsoft.RecoveryServices/vaults/rsv", rg + "/providers/Microsoft.Compute/virtualMachines/vm"},
+			want:   rg,
+		},
+		{
+			name:   "across resources of one provider",
+			scopes: []string{rg + "/providers/Microsoft.RecoveryServices/vaults/rsv-a", rg + "/providers/Microsoft.RecoveryServices/vaults/rsv-b"},
+			want:   rg,
+		},
+		{
+			name:   "children of one resource",
+			scopes: []string{rg + "/providers/Microsoft.RecoveryServices/vaults/rsv/backupPolicies/a", rg + "/providers/Microsoft.RecoveryServices/vaults/rsv/backupPolicies/b"},
+			want:   rg + "/providers/Microsoft.RecoveryServices/vaults/rsv",
+		},
+		{
+			name:   "case differs",
+			scopes: []string{rg, "/SUBSCRIPTIONS/S/resourcegroups/RG-BACKUP/providers/Microsoft.Compute/disks/d"},
+			want:   rg,
+		},
+		{
+			name:   "two subscriptions",
+			scopes: []string{sub, "/subscriptions/t"},
+			want:   "/",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, commonScope(tc.scopes))
+		})
+	}
+}
+
+// TestAudit checks each kind of finding, that NotActions take a pattern's
+// actions back, and that a scope is not within another it only shares a
+// prefix with.
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sub   = "/subscriptions/x"
+		rg    = sub + "/resourceGroups/rg-backup"
+		vault = rg + "/providers/Microsoft.RecoveryServices/vaults/rsv"
+		read  = "Microsoft.RecoveryServices/vaults/read"
+		write = "Microsoft.RecoveryServices/vaults/write"
+		del   = "Microsoft.RecoveryServices/vaults/delete"
+	)
+	grant := func(role, scope string, actions ...string) Grant {
+		return Grant{Assignment: Assignment{Principal: "automation", Role: role, Scope: scope}, Actions: actions}
+	}
+	for _, tc := range []struct {
+		name   string
+		grants []Grant
+		needs  []Need
+		want   []string
+	}{
+		{
+			name:   "exact",
+			grants: []Grant{grant("Reader", vault, read)},
+			needs:  []Need{{Action: read, Scope: vault, For: "posture"}},
+			want:   nil,
+		},
+		{
+			name:  "missing",
+			needs: []Need{{Action: read, Scope: vault, For: "posture"}},
+			want:  []string{"missing: automation needs " + read + " on " + vault + " for posture"},
+		},
+		{
+			name:   "unused",
+			grants: []Grant{grant("Reader", vault, read), grant("Contributor", sub+"/resourceGroups/rg-other", write)},
+			needs:  []Need{{Action: read, Scope: vault, For: "posture"}},
+			want:   []string{"unused: automation has Contributor on " + sub + "/resourceGroups/rg-other, which allows nothing it needs"},
+		},
+		{
+			name:   "scope",
+			grants: []Grant{grant("Reader", sub, read)},
+			needs:  []Need{{Action: read, Scope: vault, For: "posture"}, {Action: read, Scope: rg + "/providers/Microsoft.RecoveryServices/vaults/rsv-2", For: "posture"}},
+			want:   []string{"scope: automation has Reader on " + sub + ", but needs it only on " + rg},
+		},
+		{
+			name:   "role",
+			grants: []Grant{grant("Contributor", vault, read, write, "Microsoft.RecoveryServices/*")},
+			needs:  []Need{{Action: read, Scope: vault, For: "posture"}},
+			want:   []string{"role: automation has Contributor on " + vault + ", which allows actions it does not need: " + write + ", Microsoft.RecoveryServices/*"},
+		},
+		{
+			name:   "wildcard spelt out by a need",
+			grants: []Grant{grant("Contributor", vault, "Microsoft.RecoveryServices/*")},
+			needs:  []Need{{Action: "Microsoft.RecoveryServices/*", Scope: vault, For: "restore"}},
+			want:   nil,
+		},
+		{
+			name: "NotActions",
+			grants: []Grant{{
+				Assignment: Assignment{Principal: "automation", Role: "Backup Contributor", Scope: vault},
+				Actions:    []string{"Microsoft.RecoveryServices/*"},
+				NotActions: []string{"Microsoft.RecoveryServices/vaults/delete"},
+			}},
+			needs: []Need{{Action: del, Scope: vault, For: "teardown"}},
+			want: []string{
+				"unused: automation has Backup Contributor on " + vault + ", which allows nothing it needs",
+				"missing: automation needs " + del + " on " + vault + " for teardown",
+			},
+		},
+		{
+			name:   "scope sharing a prefix",
+			grants: []Grant{grant("Reader", sub, read)},
+			needs:  []Need{{Action: read, Scope: "/subscriptions/xy/resourceGroups/rg-backup", For: "posture"}},
+			want: []string{
+				"unused: automation has Reader on " + sub + ", which allows nothing it needs",
+				"missing: automation needs " + read + " on /subscriptions/xy/resourceGroups/rg-backup for posture",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, f := range audit("automation", tc.grants, tc.needs) {
+				got = append(got, string(f.Kind)+": "+f.String())
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package rbac checks role assignments against an expected matrix of
+// principal, role and scope.
+//
+// Roles are matched by name: the role definition ID of every assignment is
+// resolved through the authorization API, so a matrix reads "Backup
+// Contributor on the vault" rather than a GUID, and custom roles work the
+// same way as built-in ones. Check lists everything each principal in the
+// matrix holds in the subscription and reports both the expected assignments
+// that are missing and the ones nobody asked for.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+)
+
+// Config selects the subscription whose assignments are read.
+type Config struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+}
+
+// Assignment is a role held by a principal at a scope.
+type Assignment struct {
+	// Principal names the principal in reports, e.g. "automation account".
+	Principal   string
+	PrincipalID string
+	Role        string
+	Scope       string
+	// ID and RoleDefinitionID identify an actual assignment and its role;
+	// both are empty for expected ones.
+	ID               string
+	RoleDefinitionID string
+}
+
+func (a Assignment) String() string {
+	return fmt.Sprintf("%s has %s on %s", a.Principal, a.Role, a.Scope)
+}
+
+func (a Assignment) key() string {
+	return strings.ToLower(a.PrincipalID + "|" + a.Role + "|" + strings.TrimSuffix(a.Scope, "/"))
+}
+
+// Result is the difference between the expected and actual assignments.
+type Result struct {
+	Missing    []Assignment
+	Unexpected []Assignment
+}
+
+// OK reports whether the assignments match the matrix exactly.
+func (r *Result) OK() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+// Checker reads role assignments and resolves their role names.
+type Checker struct {
+	subscriptionID string
+	assignments    *armauthorization.RoleAssignmentsClient
+	definitions    *armauthorization.RoleDefinitionsClient
+	permissions    *armauthorization.PermissionsClient
+	roles          map[string]*role
+}
+
+// role is the part of a role definition the checks use.
+type role struct {
+	name           string
+	actions        []string
+	notActions     []string
+	dataActions    []string
+	notDataActions []string
+}
+
+// New returns a Checker for cfg.
+func New(cfg Config) (*Checker, error) {
+	assignments, err := armauthorization.NewRoleAssignmentsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := armauthorization.NewRoleDefinitionsClient(cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := armauthorization.NewPermissionsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{
+		subscriptionID: cfg.SubscriptionID,
+		assignments:    assignments,
+		definitions:    definitions,
+		permissions:    permissions,
+		roles:          map[string]*role{},
+	}, nil
+}
+
+// RoleName resolves a role definition ID to the role's name.
+func (c *Checker) RoleName(ctx context.Context, roleDefinitionID string) (string, error) {
+	r, err := c.role(ctx, roleDefinitionID)
+	if err != nil {
+		return "", err
+	}
+	return r.name, nil
+}
+
+func (c *Checker) role(ctx context.Context, roleDefinitionID string) (*role, error) {
+	key := strings.ToLower(roleDefinitionID)
+	if r, ok := c.roles[key]; ok {
+		return r, nil
+	}
+	resp, err := c.definitions.GetByID(ctx, roleDefinitionID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: resolving role definition %s: %w", roleDefinitionID, err)
+	}
+	if resp.Properties == nil || resp.Properties.RoleName == nil {
+		return nil, fmt.Errorf("rbac: role definition %s has no name", roleDefinitionID)
+	}
+	r := &role{name: *resp.Properties.RoleName}
+	for _, p := range resp.Properties.Permissions {
+		for _, a := range p.Actions {
+			r.actions = append(r.actions, deref(a))
+		}
+		for _, a := range p.NotActions {
+			r.notActions = append(r.notActions, deref(a))
+		}
+		for _, a := range p.DataActions {
+			r.dataActions = append(r.dataActions, deref(a))
+		}
+		for _, a := range p.NotDataActions {
+			r.notDataActions = append(r.notDataActions, deref(a))
+		}
+	}
+	c.roles[key] = r
+	return r, nil
+}
+
+// Assignments returns every role assignment principalID holds in the
+// subscription, at any scope, plus those inherited from above it. principal
+// labels the result.
+func (c *Checker) Assignments(ctx context.Context, principal, principalID string) ([]Assignment, error) {
+	pager := c.assignments.NewListForScopePager("/subscriptions/"+c.subscriptionID, &armauthorization.RoleAssignmentsClientListForScopeOptions{
+		Filter: to.Ptr(fmt.Sprintf("principalId eq '%s'", principalID)),
+	})
+	var out []Assignment
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rbac: listing role assignments of %s: %w", principal, err)
+		}
+		for _, ra := range page.Value {
+			p := ra.Properties
+			if p == nil || !strings.EqualFold(deref(p.PrincipalID), principalID) {
+				continue
+			}
+			name, err := c.RoleName(ctx, deref(p.RoleDefinitionID))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, Assignment{
+				Principal:        principal,
+				PrincipalID:      principalID,
+				Role:             name,
+				Scope:            deref(p.Scope),
+				ID:               deref(ra.ID),
+				RoleDefinitionID: deref(p.RoleDefinitionID),
+			})
+		}
+	}
+	return out, nil
+}
+
+// Check compares the assignments of every principal in want with want.
+func (c *Checker) Check(ctx context.Context, want []Assignment) (*Result, error) {
+	expected := map[string]bool{}
+	principals := map[string]string{}
+	var order []string
+	for _, a := range want {
+		expected[a.key()] = true
+		if _, ok := principals[a.PrincipalID]; !ok {
+			order = append(order, a.PrincipalID)
+		}
+		principals[a.PrincipalID] = a.Principal
+	}
+
+	res := &Result{}
+	actual := map[string]bool{}
+	for _, id := range order {
+		got, err := c.Assignments(ctx, principals[id], id)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range got {
+			actual[a.key()] = true
+			if !expected[a.key()] {
+				res.Unexpected = append(res.Unexpected, a)
+			}
+		}
+	}
+	for _, a := range want {
+		if !actual[a.key()] {
+			res.Missing = append(res.Missing, a)
+		}
+	}
+	sort.SliceStable(res.Unexpected, func(i, j int) bool { return res.Unexpected[i].key() < res.Unexpected[j].key() })
+	return res, nil
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+func derefAll(ps []*string) []string {
+	out := make([]string, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, deref(p))
+	}
+	return out
+}
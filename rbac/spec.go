@@ -0,0 +1,80 @@
+package rbac
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec lists the minimal permissions of each managed identity, as loaded
+// from specs/least_privilege.yaml.
+type Spec struct {
+	Identities []IdentitySpec `yaml:"identities"`
+}
+
+// IdentitySpec is what one identity needs.
+type IdentitySpec struct {
+	Name  string     `yaml:"name"`
+	Needs []NeedSpec `yaml:"needs"`
+}
+
+// NeedSpec is a set of actions needed at a named scope.
+type NeedSpec struct {
+	// For says what needs the actions, e.g. a runbook.
+	For     string   `yaml:"for"`
+	Scope   string   `yaml:"scope"`
+	Actions []string `yaml:"actions"`
+}
+
+// LoadSpec reads a least-privilege spec.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var spec Spec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, id := range spec.Identities {
+		for _, n := range id.Needs {
+			if n.Scope == "" || len(n.Actions) == 0 {
+				return nil, fmt.Errorf("%s: %s: a need of %s has no scope or no actions", path, id.Name, n.For)
+			}
+		}
+	}
+	return &spec, nil
+}
+
+// Needs returns the needs of the named identity, with scope names resolved
+// through scopes (e.g. "vault" to the vault's resource ID).
+func (s *Spec) Needs(identity string, scopes map[string]string) ([]Need, error) {
+	for _, id := range s.Identities {
+		if id.Name != identity {
+			continue
+		}
+		var needs []Need
+		for _, n := range id.Needs {
+			scope, ok := scopes[n.Scope]
+			if !ok {
+				known := make([]string, 0, len(scopes))
+				for k := range scopes {
+					known = append(known, k)
+				}
+				sort.Strings(known)
+				return nil, fmt.Errorf("rbac: %s: unknown scope %q (known: %s)", identity, n.Scope, strings.Join(known, ", "))
+			}
+			for _, a := range n.Actions {
+				needs = append(needs, Need{Action: a, Scope: scope, For: n.For})
+			}
+		}
+		return needs, nil
+	}
+	return nil, fmt.Errorf("rbac: no identity %q in the spec", identity)
+}
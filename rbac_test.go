@@ -0,0 +1,192 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/fakearm"
+	"github.com/SwastikaAryal/azure_terraform/rbac"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Role assignment matrix ────────────────────────────────────────────
+
+// TestRBACAgainstFake checks the module's role matrix against the fake
+// backend seeded from the plan, then grants one role too many and revokes one
+// the runbook needs, and checks both are reported by role name and scope.
+func TestRBACAgainstFake(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "role assignments are checked by role name and scope in both directions", "MINITRUE-9414")
+
+	fake := seededFake(t)
+	sub := fake.SubscriptionID()
+	rg, vaultID := fake.ResourceGroup, fake.VaultID
+	automation := fake.Attr("azurerm_automation_account.backup_restore", "identity[0].principal_id")
+	diskVault := fake.Attr("azurerm_data_protection_backup_vault.disk_vault", "identity[0].principal_id")
+	matrix := roleMatrix(sub, rg, vaultID, automation, diskVault)
+
+	checker, err := rbac.New(rbac.Config{SubscriptionID: sub, Credential: fake.Credential(), Options: fake.ClientOptions()})
+	require.NoError(t, err)
+
+	name, err := checker.RoleName(t.Context(),
+		"/subscriptions/"+sub+"/providers/Microsoft.Authorization/roleDefinitions/"+fakearm.BuiltInRoleID("Backup Contributor"))
+	require.NoError(t, err)
+	assert.Equal(t, "Backup Contributor", name)
+
+	result, err := checker.Check(t.Context(), matrix)
+	require.NoError(t, err)
+	assert.True(t, result.OK(), "the planned assignments match the matrix: missing %v, unexpected %v", result.Missing, result.Unexpected)
+
+	// ── Drift from the matrix ─────────────────────────────────────────────
+	owner := "/subscriptions/" + sub + "/providers/Microsoft.Authorization/roleAssignments/00000000-0000-0000-0000-0000000000aa"
+	fake.Put(owner, map[string]interface{}{"properties": map[string]interface{}{
+		"scope":            "/subscriptions/" + sub,
+		"principalId":      automation,
+		"principalType":    "ServicePrincipal",
+		"roleDefinitionId": "/subscriptions/" + sub + "/providers/Microsoft.Authorization/roleDefinitions/" + fakearm.BuiltInRoleID("Owner"),
+	}})
+	require.True(t, fake.Delete(fake.Attr("azurerm_role_assignment.disk_vault_reader", "id")))
+
+	result, err = checker.Check(t.Context(), matrix)
+	require.NoError(t, err)
+	require.False(t, result.OK())
+
+	if assert.Len(t, result.Missing, 1) {
+		assert.Equal(t, "disk backup vault has Disk Backup Reader on /subscriptions/"+sub+"/resourceGroups/"+rg, result.Missing[0].String())
+	}
+	if assert.Len(t, result.Unexpected, 1) {
+		got := result.Unexpected[0]
+		assert.Equal(t, "automation account has Owner on /subscriptions/"+sub, got.String())
+		assert.Equal(t, owner, got.ID)
+	}
+}
+
+// ─── Test: Least-privilege audit ─────────────────────────────────────────────
+
+// TestLeastPrivilegeAgainstFake audits the planned role assignments against
+// specs/least_privilege.yaml and checks that the broad grants the security
+// review keeps flagging are reported, then replaces them with custom roles
+// scoped to exactly what the runbooks need and checks the audit passes, and
+// that widening the scope of one of them fails it again.
+func TestLeastPrivilegeAgainstFake(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "managed identities hold no role or scope broader than the runbooks need", "MINITRUE-9414")
+
+	spec, err := rbac.LoadSpec("specs/least_privilege.yaml")
+	require.NoError(t, err)
+
+	fake := seededFake(t)
+	sub := fake.SubscriptionID()
+	snapshotRG, ok := fake.Variable("snapshot_resource_group_name")
+	require.True(t, ok)
+	scopes := identityScopes(sub, fake.ResourceGroup, fmt.Sprintf("%v", snapshotRG), fake.VaultID)
+	principals := map[string]string{
+		"automation account": fake.Attr("azurerm_automation_account.backup_restore", "identity[0].principal_id"),
+		"disk backup vault":  fake.Attr("azurerm_data_protection_backup_vault.disk_vault", "identity[0].principal_id"),
+	}
+
+	checker, err := rbac.New(rbac.Config{SubscriptionID: sub, Credential: fake.Credential(), Options: fake.ClientOptions()})
+	require.NoError(t, err)
+	audit := func(identity string) *rbac.AuditReport {
+		needs, err := spec.Needs(identity, scopes)
+		require.NoError(t, err)
+		report, err := checker.Audit(t.Context(), identity, principals[identity], needs)
+		require.NoError(t, err)
+		return report
+	}
+	findings := func(report *rbac.AuditReport) map[string]rbac.Finding {
+		out := map[string]rbac.Finding{}
+		for _, f := range report.Findings {
+			t.Log(f)
+			key := string(f.Kind) + " " + f.Assignment.Role
+			if f.Kind == rbac.MissingAction {
+				key = string(f.Kind) + " " + f.Need.Action + " " + f.Need.Scope
+			}
+			out[key] = f
+		}
+		return out
+	}
+
+	// ── The module as planned ─────────────────────────────────────────────
+	report := audit("automation account")
+	assert.Len(t, report.Grants, 2, "Backup Contributor and Virtual Machine Contributor")
+	got := findings(report)
+	if f, ok := got["scope Virtual Machine Contributor"]; assert.True(t, ok, "Virtual Machine Contributor on the subscription is flagged") {
+		assert.Equal(t, "/subscriptions/"+sub, f.Assignment.Scope)
+		assert.Equal(t, scopes["resource_group"], f.NeededAt)
+	}
+	if f, ok := got["role Virtual Machine Contributor"]; assert.True(t, ok) {
+		assert.Contains(t, f.Excess, "Microsoft.Compute/virtualMachines/*")
+	}
+	if f, ok := got["role Backup Contributor"]; assert.True(t, ok) {
+		assert.Contains(t, f.Excess, "Microsoft.RecoveryServices/Vaults/backupPolicies/*")
+	}
+	assert.Contains(t, got, "missing Microsoft.Storage/storageAccounts/write "+scopes["resource_group"],
+		"neither role lets Invoke-FullVMRestore create its staging storage account")
+	assert.False(t, report.OK())
+
+	report = audit("disk backup vault")
+	got = findings(report)
+	if f, ok := got["role Contributor"]; assert.True(t, ok, "Contributor on the resource group is flagged") {
+		assert.Equal(t, []string{"*"}, f.Excess)
+	}
+	assert.Contains(t, got, "role Disk Snapshot Contributor")
+	assert.Contains(t, got, "missing Microsoft.Compute/snapshots/write "+scopes["snapshot_resource_group"],
+		"snapshot roles are granted on the resource group, not the snapshot resource group")
+
+	// ── Custom roles with exactly what is needed ──────────────────────────
+	for _, identity := range []string{"automation account", "disk backup vault"} {
+		for _, g := range audit(identity).Grants {
+			require.True(t, fake.Delete(g.ID))
+		}
+	}
+	assign := func(identity, scopeName string, actions []string) string {
+		name := identity + " on " + scopeName
+		defID := "/subscriptions/" + sub + "/providers/Microsoft.Authorization/roleDefinitions/" + fakearm.BuiltInRoleID(name)
+		fake.Put(defID, map[string]interface{}{"properties": map[string]interface{}{
+			"roleName":    name,
+			"type":        "CustomRole",
+			"permissions": []interface{}{map[string]interface{}{"actions": actions}},
+		}})
+		id := scopes[scopeName] + "/providers/Microsoft.Authorization/roleAssignments/" + fakearm.BuiltInRoleID("assignment/"+name)
+		fake.Put(id, map[string]interface{}{"properties": map[string]interface{}{
+			"scope":            scopes[scopeName],
+			"principalId":      principals[identity],
+			"roleDefinitionId": defID,
+		}})
+		return id
+	}
+	var widen []string
+	for _, id := range spec.Identities {
+		byScope := map[string][]string{}
+		var order []string
+		for _, n := range id.Needs {
+			if _, ok := byScope[n.Scope]; !ok {
+				order = append(order, n.Scope)
+			}
+			byScope[n.Scope] = append(byScope[n.Scope], n.Actions...)
+		}
+		for _, scope := range order {
+			assign(id.Name, scope, byScope[scope])
+		}
+		if id.Name == "automation account" {
+			widen = byScope["resource_group"]
+		}
+	}
+	for identity := range principals {
+		report := audit(identity)
+		assert.True(t, report.OK(), "%s holds exactly what it needs: %v", identity, report.Findings)
+	}
+
+	// ── The same role on the subscription ─────────────────────────────────
+	require.True(t, fake.Delete(assign("automation account", "resource_group", widen)))
+	assign("automation account", "subscription", widen)
+	got = findings(audit("automation account"))
+	assert.Len(t, got, 1)
+	if f, ok := got["scope automation account on subscription"]; assert.True(t, ok) {
+		assert.Equal(t, scopes["resource_group"], f.NeededAt)
+	}
+}
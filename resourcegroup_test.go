@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/profile"
+)
+
+// ─── Ephemeral resource groups ───────────────────────────────────────────────
+
+// Tags put on every resource group the harness creates, so a group leaked by
+// an interrupted run can be traced to its owner and swept once it expires.
+const (
+	tagOwner     = "Owner"
+	tagExpiresOn = "ExpiresOn"
+	tagCreatedBy = "CreatedBy"
+
+	createdByTerratest = "terratest"
+)
+
+// resourceGroupTTL is how long a harness-created group is expected to live,
+// from TEST_RESOURCE_GROUP_TTL (default 6h).
+func resourceGroupTTL(t *testing.T) time.Duration {
+	t.Helper()
+	ttl, err := time.ParseDuration(envOrDefault("TEST_RESOURCE_GROUP_TTL", "6h"))
+	require.NoError(t, err, "TEST_RESOURCE_GROUP_TTL must be a Go duration such as 6h")
+	return ttl
+}
+
+// resourceGroupOwner identifies whoever started the run: TEST_OWNER, falling
+// back to the OS user.
+func resourceGroupOwner() string {
+	if owner := os.Getenv("TEST_OWNER"); owner != "" {
+		return owner
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return createdByTerratest
+}
+
+// provisionResourceGroups makes sure the resource and snapshot groups named
+// in vars exist. A group named by the profile must already exist and is
+// reused untouched; any other group is created with owner and expiry tags.
+// It returns the groups the harness created, which are the only ones it may
+// delete.
+func provisionResourceGroups(t *testing.T, backend *armBackend, prof *profile.Profile, vars map[string]interface{}) []string {
+	t.Helper()
+
+	client, err := armresources.NewResourceGroupsClient(backend.SubscriptionID, backend.Credential, backend.Options)
+	require.NoError(t, err)
+
+	location := fmt.Sprintf("%v", vars["location"])
+	expires := time.Now().UTC().Add(resourceGroupTTL(t))
+
+	var created []string
+	for _, rg := range []struct{ varName, existing string }{
+		{"resource_group_name", prof.ResourceGroups.Main},
+		{"snapshot_resource_group_name", prof.ResourceGroups.Snapshot},
+	} {
+		name := fmt.Sprintf("%v", vars[rg.varName])
+
+		if rg.existing != "" {
+			_, err := client.Get(t.Context(), name, nil)
+			require.NoError(t, err, "profile %q names resource group %q, which must already exist", prof.Name, name)
+			t.Logf("Reusing existing resource group %q", name)
+			continue
+		}
+
+		_, err := client.CreateOrUpdate(t.Context(), name, armresources.ResourceGroup{
+			Location: to.Ptr(location),
+			Tags: map[string]*string{
+				tagOwner:     to.Ptr(resourceGroupOwner()),
+				tagExpiresOn: to.Ptr(expires.Format(time.RFC3339)),
+				tagCreatedBy: to.Ptr(createdByTerratest),
+			},
+		}, nil)
+		require.NoError(t, err, "failed to create resource group %q", name)
+		t.Logf("Created resource group %q (expires %s)", name, expires.Format(time.RFC3339))
+		created = append(created, name)
+	}
+	return created
+}
+
+// deleteResourceGroupsOnCleanup registers deletion of the given groups with
+// t.Cleanup, so they go even when the test fails before reaching its end.
+func deleteResourceGroupsOnCleanup(t *testing.T, backend *armBackend, names []string) {
+	t.Helper()
+	if len(names) == 0 {
+		return
+	}
+
+	client, err := armresources.NewResourceGroupsClient(backend.SubscriptionID, backend.Credential, backend.Options)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		// t.Context() is already cancelled by the time cleanups run.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
+		for _, name := range names {
+			poller, err := client.BeginDelete(ctx, name, nil)
+			if err == nil {
+				_, err = poller.PollUntilDone(ctx, nil)
+			}
+			if err != nil {
+				t.Errorf("failed to delete resource group %q: %v", name, err)
+				continue
+			}
+			t.Logf("Deleted resource group %q", name)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SwastikaAryal/azure_terraform/iso8601"
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+)
+
+// defaultTag is the tag of disk snapshots no retention rule claims; the Data
+// Protection API gives it the lowest priority, 99.
+const (
+	defaultTag      Tier = "Default"
+	defaultPriority      = 99
+)
+
+// diskTag is a retention tag with its parsed duration.
+type diskTag struct {
+	tier     Tier
+	priority int
+	criteria string
+	duration iso8601.Duration
+}
+
+// Disk returns the snapshots a disk backup policy retains at at. Every point
+// lives in the operational store, so all of them are Snapshot points.
+func Disk(p policyspec.DiskPolicy, at time.Time) (Points, error) {
+	loc, err := location(p.Schedule.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	at = at.In(loc)
+	hour, minute, err := clock(p.Schedule.Start)
+	if err != nil {
+		return nil, err
+	}
+	every, err := iso8601.ParseDuration(p.Schedule.Every)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := every.Fixed()
+	if !ok || step <= 0 {
+		return nil, fmt.Errorf("retention: policy %s: backup period %s has no fixed length", p.Name, p.Schedule.Every)
+	}
+
+	def, err := iso8601.ParseDuration(p.Retention.Default)
+	if err != nil {
+		return nil, err
+	}
+	tags := []diskTag{{tier: defaultTag, priority: defaultPriority, criteria: "AllBackup", duration: def}}
+	from := def.SubtractFrom(at)
+	for _, r := range p.Retention.Rules {
+		d, err := iso8601.ParseDuration(r.Duration)
+		if err != nil {
+			return nil, err
+		}
+		switch r.Criteria {
+		case "AllBackup", "FirstOfDay", "FirstOfWeek", "FirstOfMonth", "FirstOfYear":
+		default:
+			return nil, fmt.Errorf("retention: policy %s: unsupported criteria %q", p.Name, r.Criteria)
+		}
+		tags = append(tags, diskTag{tier: Tier(r.Name), priority: r.Priority, criteria: r.Criteria, duration: d})
+		if f := d.SubtractFrom(at); f.Before(from) {
+			from = f
+		}
+	}
+
+	// Replay from the start of the year before the horizon, so the first
+	// backup of each week, month and year is known. Anchoring on the start
+	// time of that day matches Azure for periods that divide a day, which
+	// are the only ones the API accepts.
+	start := time.Date(from.Year()-1, 1, 1, hour, minute, 0, 0, loc)
+	var (
+		points Points
+		prev   time.Time
+	)
+	for b := start; !b.After(at); prev, b = b, b.Add(step) {
+		if b.Before(from) {
+			continue
+		}
+		tag := tags[0]
+		for _, t := range tags[1:] {
+			if t.priority < tag.priority && firstOf(t.criteria, prev, b) {
+				tag = t
+			}
+		}
+		k := newKeep(at)
+		k.add(tag.tier, tag.duration.AddTo(b))
+		if pt, ok := k.point(b, []Tier{tag.tier}); ok {
+			pt.Snapshot = true
+			points = append(points, pt)
+		}
+	}
+	return points, nil
+}
+
+// firstOf reports whether b, following prev, is the first backup of the
+// period criteria names.
+func firstOf(criteria string, prev, b time.Time) bool {
+	if prev.IsZero() {
+		return true
+	}
+	switch criteria {
+	case "AllBackup":
+		return true
+	case "FirstOfDay":
+		return prev.YearDay() != b.YearDay() || prev.Year() != b.Year()
+	case "FirstOfWeek":
+		// Weeks start on Sunday.
+		sunday := func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+		}
+		return !sunday(prev).Equal(sunday(b))
+	case "FirstOfMonth":
+		return prev.Month() != b.Month() || prev.Year() != b.Year()
+	case "FirstOfYear":
+		return prev.Year() != b.Year()
+	}
+	return false
+}
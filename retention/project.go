@@ -0,0 +1,61 @@
+package retention
+
+import "time"
+
+// Churn describes the data behind one protected VM or disk.
+type Churn struct {
+	// ProtectedGB is the used size of the disks, stored once in full.
+	ProtectedGB float64
+	// DailyChangeGB is how much of that data changes per day.
+	DailyChangeGB float64
+}
+
+// Projection is the steady-state storage of one protected VM or disk.
+type Projection struct {
+	VaultPoints, SnapshotPoints int
+	VaultGB, SnapshotGB         float64
+}
+
+// Scale returns p for n identical VMs or disks.
+func (p Projection) Scale(n int) Projection {
+	return Projection{
+		VaultPoints:    p.VaultPoints * n,
+		SnapshotPoints: p.SnapshotPoints * n,
+		VaultGB:        p.VaultGB * float64(n),
+		SnapshotGB:     p.SnapshotGB * float64(n),
+	}
+}
+
+// Project estimates the storage the points take. Each store holds the oldest
+// point in full and every later point as the change since the one before
+// it, which is never more than the full size.
+func Project(points Points, c Churn) Projection {
+	var (
+		p                 Projection
+		lastVault, lastSn time.Time
+	)
+	for _, pt := range points {
+		if pt.Vault {
+			p.VaultPoints++
+			p.VaultGB += incremental(lastVault, pt.Time, c)
+			lastVault = pt.Time
+		}
+		if pt.Snapshot {
+			p.SnapshotPoints++
+			p.SnapshotGB += incremental(lastSn, pt.Time, c)
+			lastSn = pt.Time
+		}
+	}
+	return p
+}
+
+func incremental(prev, t time.Time, c Churn) float64 {
+	if prev.IsZero() {
+		return c.ProtectedGB
+	}
+	gb := c.DailyChangeGB * t.Sub(prev).Hours() / 24
+	if gb > c.ProtectedGB {
+		return c.ProtectedGB
+	}
+	return gb
+}
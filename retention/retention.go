@@ -0,0 +1,287 @@
+// Package retention works out which recovery points a backup policy keeps.
+//
+// VM and Disk replay a policy's schedule up to a given instant, as if it had
+// always been running, and return every recovery point still retained then:
+// its time in the policy's time zone, which tiers keep it and when it
+// expires. Project turns those points into a storage estimate from a per-VM
+// churn figure, so the cost of a retention change can be seen before the
+// policy is edited.
+//
+// The model follows the Azure Backup rules the module relies on:
+//   - every scheduled backup is kept as an instant restore snapshot for the
+//     policy's instant restore days;
+//   - the first backup of each day is the daily vault point, and is also the
+//     weekly, monthly and yearly point when its date matches those tiers;
+//   - an hourly window of D hours every I hours runs at start, start+I, ...
+//     while the offset is below D;
+//   - disk snapshot points carry the highest-priority retention tag whose
+//     criteria they match (lowest priority number first), and weeks start on
+//     Sunday.
+package retention
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+)
+
+// Tier names a reason a recovery point is kept. For disk policies the tier
+// is the retention tag, e.g. Default or Weekly.
+type Tier string
+
+// Tiers of a VM backup policy.
+const (
+	Snapshot Tier = "snapshot"
+	Daily    Tier = "daily"
+	Weekly   Tier = "weekly"
+	Monthly  Tier = "monthly"
+	Yearly   Tier = "yearly"
+)
+
+// Point is one retained recovery point.
+type Point struct {
+	// Time is when the backup ran, in the policy's time zone.
+	Time time.Time
+	// Expires is when the last tier keeping the point lets it go.
+	Expires time.Time
+	// Tiers are the tiers still keeping the point.
+	Tiers []Tier
+	// Vault is set for points in the vault, Snapshot for points kept as
+	// disk snapshots in the customer's subscription.
+	Vault, Snapshot bool
+}
+
+// In reports whether tier keeps p.
+func (p Point) In(tier Tier) bool {
+	for _, t := range p.Tiers {
+		if t == tier {
+			return true
+		}
+	}
+	return false
+}
+
+// Points are retained recovery points, oldest first.
+type Points []Point
+
+// In returns the points tier keeps.
+func (ps Points) In(tier Tier) Points {
+	var out Points
+	for _, p := range ps {
+		if p.In(tier) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Count is the number of points tier keeps.
+func (ps Points) Count(tier Tier) int {
+	return len(ps.In(tier))
+}
+
+// Dates returns the calendar date of each point, as YYYY-MM-DD.
+func (ps Points) Dates() []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.Time.Format(time.DateOnly)
+	}
+	return out
+}
+
+// location resolves a policy time zone. Azure accepts Windows zone names,
+// which only resolve here when they are also IANA names such as UTC.
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("retention: time zone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// clock parses an HH:MM time of day.
+func clock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("retention: %q is not an HH:MM time", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func hasWeekday(days []string, t time.Time) bool {
+	for _, d := range days {
+		if d == t.Weekday().String() {
+			return true
+		}
+	}
+	return false
+}
+
+// keep collects a backup's tier expiries and turns them into a point when
+// any of them is after at.
+type keep struct {
+	at      time.Time
+	expires map[Tier]time.Time
+}
+
+func newKeep(at time.Time) *keep {
+	return &keep{at: at, expires: map[Tier]time.Time{}}
+}
+
+func (k *keep) add(tier Tier, until time.Time) {
+	k.expires[tier] = until
+}
+
+func (k *keep) point(t time.Time, order []Tier) (Point, bool) {
+	p := Point{Time: t}
+	for _, tier := range order {
+		until, ok := k.expires[tier]
+		if !ok || !until.After(k.at) {
+			continue
+		}
+		p.Tiers = append(p.Tiers, tier)
+		if until.After(p.Expires) {
+			p.Expires = until
+		}
+	}
+	return p, len(p.Tiers) > 0
+}
+
+// ─── VM policies ─────────────────────────────────────────────────────────────
+
+// VM returns the recovery points a VM backup policy retains at at.
+func VM(p policyspec.Policy, at time.Time) (Points, error) {
+	loc, err := location(p.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	at = at.In(loc)
+	hour, minute, err := clock(p.Schedule.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	r := p.Retention
+	weeklyDays := p.Schedule.Weekdays
+	if r.Weekly != nil && len(r.Weekly.Weekdays) > 0 {
+		weeklyDays = r.Weekly.Weekdays
+	}
+	if r.Weekly != nil && len(weeklyDays) == 0 {
+		return nil, fmt.Errorf("retention: policy %s: weekly retention needs weekdays", p.Name)
+	}
+	for _, t := range []*policyspec.Tier{r.Monthly, r.Yearly} {
+		if t != nil && len(t.Days) == 0 && !t.IncludeLastDays && (len(t.Weekdays) == 0 || len(t.Weeks) == 0) {
+			return nil, fmt.Errorf("retention: policy %s: monthly and yearly retention need days or weekdays and weeks", p.Name)
+		}
+	}
+
+	// Nothing older than the longest tier can still be retained.
+	from := at.AddDate(0, 0, -p.InstantRestoreDays)
+	for _, c := range []struct {
+		tier       *policyspec.Tier
+		y, m, days int
+	}{
+		{r.Daily, 0, 0, 1}, {r.Weekly, 0, 0, 7}, {r.Monthly, 0, 1, 0}, {r.Yearly, 1, 0, 0},
+	} {
+		if c.tier != nil {
+			if f := at.AddDate(-c.y*c.tier.Count, -c.m*c.tier.Count, -c.days*c.tier.Count); f.Before(from) {
+				from = f
+			}
+		}
+	}
+
+	order := []Tier{Snapshot, Daily, Weekly, Monthly, Yearly}
+	var points Points
+	for day := time.Date(from.Year(), from.Month(), from.Day()-1, 0, 0, 0, 0, loc); !day.After(at); day = day.AddDate(0, 0, 1) {
+		for i, b := range vmBackups(p, day, hour, minute) {
+			if b.Before(from) || b.After(at) {
+				continue
+			}
+			k := newKeep(at)
+			k.add(Snapshot, b.AddDate(0, 0, p.InstantRestoreDays))
+			// Only the first backup of the day goes to the vault tiers.
+			if i == 0 {
+				if r.Daily != nil {
+					k.add(Daily, b.AddDate(0, 0, r.Daily.Count))
+				}
+				if r.Weekly != nil && hasWeekday(weeklyDays, b) {
+					k.add(Weekly, b.AddDate(0, 0, 7*r.Weekly.Count))
+				}
+				if r.Monthly != nil && onDay(r.Monthly, b) {
+					k.add(Monthly, b.AddDate(0, r.Monthly.Count, 0))
+				}
+				if r.Yearly != nil && inMonth(r.Yearly.Months, b) && onDay(r.Yearly, b) {
+					k.add(Yearly, b.AddDate(r.Yearly.Count, 0, 0))
+				}
+			}
+			if pt, ok := k.point(b, order); ok {
+				pt.Snapshot = pt.In(Snapshot)
+				pt.Vault = len(pt.Tiers) > 1 || !pt.Snapshot
+				points = append(points, pt)
+			}
+		}
+	}
+	return points, nil
+}
+
+// vmBackups returns the backups a VM policy takes on day, in order.
+func vmBackups(p policyspec.Policy, day time.Time, hour, minute int) []time.Time {
+	start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+	switch p.Schedule.Frequency {
+	case "Weekly":
+		if !hasWeekday(p.Schedule.Weekdays, start) {
+			return nil
+		}
+	case "Hourly":
+		var out []time.Time
+		for h := 0; h < p.Schedule.HourDuration; h += p.Schedule.HourInterval {
+			out = append(out, start.Add(time.Duration(h)*time.Hour))
+		}
+		return out
+	}
+	return []time.Time{start}
+}
+
+// onDay reports whether t falls on the days a monthly or yearly tier keeps:
+// listed days of the month (and the last day with IncludeLastDays), or
+// listed weekdays in listed weeks of the month.
+func onDay(tier *policyspec.Tier, t time.Time) bool {
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	if len(tier.Days) > 0 || tier.IncludeLastDays {
+		if tier.IncludeLastDays && t.Day() == lastDay {
+			return true
+		}
+		for _, d := range tier.Days {
+			if d == t.Day() {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasWeekday(tier.Weekdays, t) {
+		return false
+	}
+	ordinal := []string{"First", "Second", "Third", "Fourth"}
+	for _, w := range tier.Weeks {
+		if w == "Last" && t.Day()+7 > lastDay {
+			return true
+		}
+		if i := (t.Day() - 1) / 7; i < len(ordinal) && w == ordinal[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func inMonth(months []string, t time.Time) bool {
+	for _, m := range months {
+		if m == t.Month().String() {
+			return true
+		}
+	}
+	return false
+}
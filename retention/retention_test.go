@@ -0,0 +1,116 @@
+package retention_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/policyspec"
+	"github.com/SwastikaAryal/azure_terraform/retention"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// TestRetention replays the policies in the policy spec and checks the
+// steady-state recovery points against counts worked out by hand, plus the
+// guarantees the requirements are phrased in.
+func TestRetention(t *testing.T) {
+	t.Parallel()
+	traceability.Verifies(t, "VM policies keep a monthly point for each of the last 12 months", "MINITRUE-9418")
+
+	spec, err := policyspec.Load(filepath.Join("..", "specs", "backup_policies.yaml"))
+	require.NoError(t, err)
+	// A Monday, so the last weekly point is yesterday's.
+	at := time.Date(2025, time.June, 16, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Standard", func(t *testing.T) {
+		pol, ok := spec.Policy("bkpol-standard-daily-30d")
+		require.True(t, ok)
+		points, err := retention.VM(pol, at)
+		require.NoError(t, err)
+
+		assert.Equal(t, 30, points.Count(retention.Daily))
+		assert.Equal(t, 12, points.Count(retention.Weekly))
+		assert.Equal(t, 12, points.Count(retention.Monthly))
+		assert.Equal(t, 3, points.Count(retention.Yearly))
+		assert.Equal(t, 5, points.Count(retention.Snapshot))
+		// 30 daily, plus 7 weekly, 9 monthly and 2 yearly points older than
+		// any daily one.
+		assert.Len(t, points, 48)
+
+		// At least one monthly point for each of the last 12 months, on the
+		// first Sunday at 23:00.
+		months := map[string]bool{}
+		for _, p := range points.In(retention.Monthly) {
+			months[p.Time.Format("2006-01")] = true
+			assert.Equal(t, time.Sunday, p.Time.Weekday())
+			assert.LessOrEqual(t, p.Time.Day(), 7)
+			assert.Equal(t, "23:00", p.Time.Format("15:04"))
+		}
+		// June 2024's point expired on 2025-06-02, so the twelve months run
+		// up to and including this one.
+		for m := 0; m < 12; m++ {
+			month := at.AddDate(0, -m, 0).Format("2006-01")
+			assert.True(t, months[month], "no monthly point in %s", month)
+		}
+		assert.Equal(t, []string{"2023-01-01", "2024-01-07", "2025-01-05"}, points.In(retention.Yearly).Dates())
+	})
+
+	t.Run("Enhanced", func(t *testing.T) {
+		pol, ok := spec.Policy("bkpol-enhanced-daily-30d")
+		require.True(t, ok)
+		points, err := retention.VM(pol, at)
+		require.NoError(t, err)
+
+		assert.Equal(t, 30, points.Count(retention.Daily))
+		// 06:00, 10:00 and 14:00 each day since 2025-06-09 14:00.
+		assert.Equal(t, 21, points.Count(retention.Snapshot))
+		for _, p := range points.In(retention.Daily) {
+			assert.Equal(t, "06:00", p.Time.Format("15:04"), "the first backup of the day goes to the vault")
+		}
+	})
+
+	t.Run("Disks", func(t *testing.T) {
+		os, ok := spec.DiskPolicy("dpbpol-os-disk-7d")
+		require.True(t, ok)
+		points, err := retention.Disk(os, at)
+		require.NoError(t, err)
+		// Six snapshots a day for 7 days, and the first of each of the last
+		// four weeks.
+		assert.Len(t, points, 45)
+		assert.Equal(t, []string{"2025-05-25", "2025-06-01", "2025-06-08", "2025-06-15"}, points.In("Weekly").Dates())
+
+		data, ok := spec.DiskPolicy("dpbpol-data-disk-7d")
+		require.True(t, ok)
+		points, err = retention.Disk(data, at)
+		require.NoError(t, err)
+		assert.Len(t, points, 10)
+
+		// 64 GB in full, then a day's change per day of gap: 7+7 between the
+		// weekly points, 1 to the first daily one and 6 more after it.
+		proj := retention.Project(points, retention.Churn{ProtectedGB: 64, DailyChangeGB: 1})
+		assert.Equal(t, 10, proj.SnapshotPoints)
+		assert.Zero(t, proj.VaultPoints)
+		assert.InDelta(t, 85, proj.SnapshotGB, 1e-9)
+		assert.InDelta(t, 850, proj.Scale(10).SnapshotGB, 1e-9)
+	})
+
+	t.Run("Projection", func(t *testing.T) {
+		pol, ok := spec.Policy("bkpol-standard-daily-30d")
+		require.True(t, ok)
+		points, err := retention.VM(pol, at)
+		require.NoError(t, err)
+
+		// With no churn only the full copy is stored; with churn above the
+		// disk size every point is a full copy.
+		proj := retention.Project(points, retention.Churn{ProtectedGB: 100})
+		assert.Equal(t, 48, proj.VaultPoints)
+		assert.InDelta(t, 100, proj.VaultGB, 1e-9)
+		assert.InDelta(t, 100, proj.SnapshotGB, 1e-9)
+		proj = retention.Project(points, retention.Churn{ProtectedGB: 100, DailyChangeGB: 1000})
+		assert.InDelta(t, 4800, proj.VaultGB, 1e-9)
+		assert.InDelta(t, 500, proj.SnapshotGB, 1e-9)
+	})
+}
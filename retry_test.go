@@ -0,0 +1,58 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/azretry"
+)
+
+// ─── Retry policy shared by Terraform and SDK calls ──────────────────────────
+
+// newRetryClassifier returns the classifier the test retries Azure
+// operations with. Every retry is logged as it happens, and the full history
+// is summarised when the test ends.
+func newRetryClassifier(t testing.TB) *azretry.Classifier {
+	t.Helper()
+
+	c := azretry.New()
+	c.OnRetry = func(a azretry.Attempt) {
+		t.Logf("retry: %s", a)
+	}
+	t.Cleanup(func() {
+		if history := c.History(); len(history) > 0 {
+			lines := make([]string, len(history))
+			for i, a := range history {
+				lines[i] = "  " + a.String()
+			}
+			t.Logf("%d Azure operations were retried:\n%s", len(history), strings.Join(lines, "\n"))
+		}
+	})
+	return c
+}
+
+// runTerraform runs a terratest *E command, retrying it while the classifier
+// considers its diagnostics transient, and fails the test on a final error.
+func runTerraform(t *testing.T, c *azretry.Classifier, op string, fn func() (string, error)) string {
+	t.Helper()
+	out, err := c.Terraform(t.Context(), op, fn)
+	require.NoError(t, err, "%s failed", op)
+	return out
+}
+
+// initAndPlanWithStruct is terraform.InitAndPlanAndShowWithStruct with the
+// classifier's retries.
+func initAndPlanWithStruct(t *testing.T, c *azretry.Classifier, opts *terraform.Options) *terraform.PlanStruct {
+	t.Helper()
+	var plan *terraform.PlanStruct
+	err := c.Do(t.Context(), "terraform plan", func() error {
+		var err error
+		plan, err = terraform.InitAndPlanAndShowWithStructE(t, opts)
+		return err
+	})
+	require.NoError(t, err, "terraform plan failed")
+	return plan
+}
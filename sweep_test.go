@@ -0,0 +1,124 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/sweeper"
+)
+
+// ─── Test: Orphaned resource sweeper ─────────────────────────────────────────
+
+// TestSweeperAgainstFake runs the sweeper against the fake ARM backend seeded
+// with a deployment plus a few groups that must survive, and checks that
+// only expired harness resources are deleted, protected items and backup
+// instances first. Groups are the harness's by their CreatedBy tag or, when
+// they carry no tags, by their name prefix.
+func TestSweeperAgainstFake(t *testing.T) {
+	t.Parallel()
+
+	fake := seededFake(t)
+	sub := fake.SubscriptionID()
+
+	rgID := func(name string) string {
+		return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", sub, name)
+	}
+	putGroup := func(name string, expires time.Time) {
+		doc := map[string]interface{}{"location": "eastus"}
+		if !expires.IsZero() {
+			doc["tags"] = map[string]interface{}{"ExpiresOn": expires.UTC().Format(time.RFC3339)}
+		}
+		fake.Put(rgID(name), doc)
+	}
+	protect := func(vaultID, vm string) {
+		fake.Put(vaultID+"/backupFabrics/Azure/protectionContainers/iaasvmcontainerv2;rg-app;"+vm+
+			"/protectedItems/vm;iaasvmcontainerv2;rg-app;"+vm,
+			map[string]interface{}{"properties": map[string]interface{}{"protectedItemType": "Microsoft.Compute/virtualMachines"}})
+	}
+
+	now := time.Now()
+	deployedRG := "rg-minitrue-test-abc123"
+	deployedVault := rgID(deployedRG) + "/providers/Microsoft.RecoveryServices/vaults/rsv-minitrue-abc123"
+	strayVault := rgID("rg-shared") + "/providers/Microsoft.RecoveryServices/vaults/rsv-minitrue-old111"
+
+	// The seeded deployment: harness RG without tags, aged by its contents,
+	// with a disk backed up to the Data Protection vault.
+	putGroup(deployedRG, time.Time{})
+	protect(deployedVault, "vm-app-0")
+	diskVault := fake.Attr("azurerm_data_protection_backup_vault.disk_vault", "id")
+	fake.Put(diskVault+"/backupInstances/disk-app-0", map[string]interface{}{"properties": map[string]interface{}{
+		"dataSourceInfo": map[string]interface{}{"datasourceType": "Microsoft.Compute/disks"},
+	}})
+	// A run that died before tagging its group or deploying into it, and
+	// a tagged group whose name is not the harness's.
+	putGroup("rg-minitrue-test-dead01", time.Time{})
+	fake.Put(rgID("rg-adhoc-run"), map[string]interface{}{"location": "eastus", "tags": map[string]interface{}{
+		"CreatedBy": "terratest",
+		"ExpiresOn": now.Add(-time.Hour).UTC().Format(time.RFC3339),
+	}})
+	// Snapshot RG whose expiry tag has passed, and a fresh one that has not.
+	putGroup("rg-minitrue-snaps-abc123", now.Add(-time.Hour))
+	putGroup("rg-minitrue-test-zzz999", now.Add(72*time.Hour))
+	// A stray harness vault in a shared group that must itself survive.
+	putGroup("rg-shared", time.Time{})
+	fake.Put(strayVault, map[string]interface{}{"location": "eastus", "properties": map[string]interface{}{}})
+	protect(strayVault, "vm-app-1")
+	// Not harness named at all.
+	putGroup("rg-prod", time.Time{})
+
+	cfg := sweeper.Config{
+		SubscriptionID: sub,
+		Credential:     fake.Credential(),
+		Options:        fake.ClientOptions(),
+		TTL:            24 * time.Hour,
+		Now:            func() time.Time { return now.Add(48 * time.Hour) },
+	}
+
+	plan, err := sweeper.Find(t.Context(), cfg)
+	require.NoError(t, err)
+
+	var report bytes.Buffer
+	require.NoError(t, plan.Report(&report))
+	t.Log("\n" + report.String())
+
+	orphans := map[string]bool{}
+	for _, c := range plan.Orphans() {
+		orphans[c.Name] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"rg-minitrue-test-abc123":      true,
+		"rg-minitrue-snaps-abc123":     true,
+		"rg-minitrue-test-dead01":      true,
+		"rg-adhoc-run":                 true,
+		"rsv-minitrue-abc123":          true,
+		"dpbv-minitrue-disk-snapshots": true,
+		"law-minitrue-abc123":          true,
+		"aa-minitrue-backup-restore":   true,
+		"rsv-minitrue-old111":          true,
+	}, orphans, "orphans selected by the sweeper")
+
+	// A dry run must not touch anything.
+	_, ok := fake.Get(deployedVault)
+	require.True(t, ok, "Find must not delete anything")
+
+	var log bytes.Buffer
+	require.NoError(t, sweeper.Execute(t.Context(), cfg, plan, &log))
+	t.Log("\n" + log.String())
+
+	for _, gone := range []string{
+		rgID(deployedRG), rgID("rg-minitrue-snaps-abc123"), rgID("rg-minitrue-test-dead01"), rgID("rg-adhoc-run"),
+		deployedVault, strayVault, diskVault,
+	} {
+		_, ok := fake.Get(gone)
+		assert.False(t, ok, "%s should have been swept", gone)
+	}
+	for _, kept := range []string{rgID("rg-minitrue-test-zzz999"), rgID("rg-shared"), rgID("rg-prod")} {
+		_, ok := fake.Get(kept)
+		assert.True(t, ok, "%s should have been kept", kept)
+	}
+}
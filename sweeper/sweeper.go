@@ -0,0 +1,415 @@
+// Package sweeper finds and deletes Azure resources left behind by aborted
+// Terratest runs of the backup module.
+//
+// A resource group is the harness's when it carries the CreatedBy tag the
+// harness sets or, failing that, when its name has the harness prefix, which
+// also covers groups of runs that died before tagging them. Other resources
+// are the harness's when their name follows the harness naming scheme (see
+// namePatterns). Either is an orphan when it is older than the configured
+// TTL; the ExpiresOn tag of a group takes precedence over its age, and a
+// group with neither tag nor contents is an orphan straight away.
+//
+// Deletion happens in dependency order: Recovery Services vaults are purged
+// and Data Protection backup vaults emptied first (see package teardown),
+// then other stray resources and finally the resource groups, which ARM
+// would otherwise refuse to delete while a vault in them still protects
+// items.
+package sweeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+
+	"github.com/SwastikaAryal/azure_terraform/teardown"
+)
+
+// DefaultTTL is how old a harness resource must be before it is swept.
+const DefaultTTL = 24 * time.Hour
+
+const (
+	typeResourceGroup = "Microsoft.Resources/resourceGroups"
+	typeVault         = "Microsoft.RecoveryServices/vaults"
+	typeBackupVault   = "Microsoft.DataProtection/backupVaults"
+
+	// The harness sets these on the resource groups it creates.
+	tagExpiresOn = "ExpiresOn"
+	tagCreatedBy = "CreatedBy"
+
+	createdByTerratest = "terratest"
+
+	// groupPrefix starts the name of every resource group the harness
+	// creates (rg-minitrue-test-<suffix>, rg-minitrue-snaps-<suffix>).
+	groupPrefix = "rg-minitrue-"
+)
+
+// namePatterns is the harness naming scheme: uniqueSuffix() is a lower-cased
+// six character random.UniqueId.
+var namePatterns = map[string]*regexp.Regexp{
+	typeVault:       regexp.MustCompile(`^rsv-minitrue-[a-z0-9]{6}$`),
+	typeBackupVault: regexp.MustCompile(`^dpbv-minitrue-disk-snapshots$`),
+	"Microsoft.OperationalInsights/workspaces": regexp.MustCompile(`^law-minitrue-[a-z0-9]{6}$`),
+	"Microsoft.Automation/automationAccounts":  regexp.MustCompile(`^aa-minitrue-backup-restore$`),
+}
+
+// fixedNameTags guards types whose harness name has no random suffix and so
+// could also belong to a real deployment: outside an orphaned resource group
+// they are only swept when tagged as a test environment.
+var fixedNameTags = map[string][2]string{
+	typeBackupVault: {"Environment", "test"},
+	"Microsoft.Automation/automationAccounts": {"Environment", "test"},
+}
+
+// apiVersions are used for generic deletes by resource ID.
+var apiVersions = map[string]string{
+	"Microsoft.OperationalInsights/workspaces": "2022-10-01",
+	"Microsoft.Automation/automationAccounts":  "2023-11-01",
+}
+
+// Config selects the subscription to sweep and how.
+type Config struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+
+	// TTL is the minimum age of a resource before it is swept; DefaultTTL
+	// when zero.
+	TTL time.Duration
+
+	// Now returns the current time; time.Now when nil.
+	Now func() time.Time
+}
+
+func (c Config) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return DefaultTTL
+}
+
+func (c Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Candidate is a resource matching the harness naming scheme.
+type Candidate struct {
+	ID            string
+	Name          string
+	Type          string
+	ResourceGroup string
+
+	// Created is when the resource was created; zero if ARM did not say.
+	Created time.Time
+	// Expires is the ExpiresOn tag of a harness resource group, if any.
+	Expires time.Time
+
+	// Orphaned is set when the candidate is due for deletion; Reason says
+	// why it is or is not.
+	Orphaned bool
+	Reason   string
+
+	// InOrphanedGroup is set when the candidate goes with its resource group
+	// rather than being deleted on its own.
+	InOrphanedGroup bool
+}
+
+// Plan is the outcome of Find.
+type Plan struct {
+	Candidates []Candidate
+}
+
+// Orphans returns the candidates due for deletion.
+func (p *Plan) Orphans() []Candidate {
+	var out []Candidate
+	for _, c := range p.Candidates {
+		if c.Orphaned {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Report writes a dry-run report of the plan to w.
+func (p *Plan) Report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tTYPE\tNAME\tRESOURCE GROUP\tREASON")
+	for _, c := range p.Candidates {
+		action := "keep"
+		if c.Orphaned {
+			action = "delete"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", action, c.Type, c.Name, c.ResourceGroup, c.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d of %d harness resources due for deletion\n", len(p.Orphans()), len(p.Candidates))
+	return err
+}
+
+// Find lists the subscription and returns every resource that matches the
+// harness naming scheme, marking those older than the TTL as orphaned.
+// Resources inside an orphaned resource group are left to the group's
+// deletion, except vaults, which must be purged first.
+func Find(ctx context.Context, cfg Config) (*Plan, error) {
+	groups, err := armresources.NewResourceGroupsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := armresources.NewClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Creation times of everything in the subscription; a resource group's
+	// age is that of the oldest resource in it.
+	var all []*armresources.GenericResourceExpanded
+	oldest := map[string]time.Time{}
+	pager := resources.NewListPager(&armresources.ClientListOptions{Expand: to.Ptr("createdTime")})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing resources: %w", err)
+		}
+		for _, r := range page.Value {
+			all = append(all, r)
+			if r.ID == nil || r.CreatedTime == nil {
+				continue
+			}
+			rg := strings.ToLower(resourceGroupOf(*r.ID))
+			if t, ok := oldest[rg]; !ok || r.CreatedTime.Before(t) {
+				oldest[rg] = *r.CreatedTime
+			}
+		}
+	}
+
+	now, ttl := cfg.now(), cfg.ttl()
+	plan := &Plan{}
+	orphanGroups := map[string]bool{}
+
+	rgPager := groups.NewListPager(nil)
+	for rgPager.More() {
+		page, err := rgPager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing resource groups: %w", err)
+		}
+		for _, rg := range page.Value {
+			if rg.Name == nil || !harnessGroup(rg) {
+				continue
+			}
+			c := Candidate{
+				ID:            *rg.ID,
+				Name:          *rg.Name,
+				Type:          typeResourceGroup,
+				ResourceGroup: *rg.Name,
+				Created:       oldest[strings.ToLower(*rg.Name)],
+			}
+			if v := rg.Tags[tagExpiresOn]; v != nil {
+				c.Expires, _ = time.Parse(time.RFC3339, *v)
+			}
+			if c.Expires.IsZero() && c.Created.IsZero() {
+				// Nothing dates it: the run died before tagging the group
+				// or deploying into it.
+				c.Orphaned = true
+				c.Reason = "empty and untagged"
+			} else {
+				judge(&c, now, ttl)
+			}
+			if c.Orphaned {
+				orphanGroups[strings.ToLower(c.Name)] = true
+			}
+			plan.Candidates = append(plan.Candidates, c)
+		}
+	}
+
+	for _, r := range all {
+		if r.ID == nil || r.Name == nil || r.Type == nil {
+			continue
+		}
+		inOrphanedGroup := orphanGroups[strings.ToLower(resourceGroupOf(*r.ID))]
+		// Any vault in an orphaned group is emptied, whatever its name:
+		// the group cannot go while it holds backups.
+		if !(inOrphanedGroup && isVault(*r.Type)) && !harnessResource(r) {
+			continue
+		}
+		c := Candidate{
+			ID:            *r.ID,
+			Name:          *r.Name,
+			Type:          *r.Type,
+			ResourceGroup: resourceGroupOf(*r.ID),
+		}
+		if r.CreatedTime != nil {
+			c.Created = *r.CreatedTime
+		}
+		if inOrphanedGroup {
+			c.Orphaned = true
+			c.InOrphanedGroup = true
+			c.Reason = "in orphaned resource group"
+		} else {
+			judge(&c, now, ttl)
+		}
+		plan.Candidates = append(plan.Candidates, c)
+	}
+
+	sort.SliceStable(plan.Candidates, func(i, j int) bool {
+		return deleteOrder(plan.Candidates[i]) < deleteOrder(plan.Candidates[j])
+	})
+	return plan, nil
+}
+
+// harnessGroup reports whether the harness created rg: by its CreatedBy tag
+// or, for a group the tags are missing from, by its name.
+func harnessGroup(rg *armresources.ResourceGroup) bool {
+	if v := rg.Tags[tagCreatedBy]; v != nil && *v == createdByTerratest {
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(*rg.Name), groupPrefix)
+}
+
+// harnessResource reports whether r follows the harness naming scheme.
+func harnessResource(r *armresources.GenericResourceExpanded) bool {
+	pattern, ok := namePatterns[*r.Type]
+	if !ok || !pattern.MatchString(*r.Name) {
+		return false
+	}
+	if tag, ok := fixedNameTags[*r.Type]; ok {
+		if v := r.Tags[tag[0]]; v == nil || !strings.EqualFold(*v, tag[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// judge decides whether c is orphaned from its expiry tag or its age.
+func judge(c *Candidate, now time.Time, ttl time.Duration) {
+	switch {
+	case !c.Expires.IsZero() && now.After(c.Expires):
+		c.Orphaned = true
+		c.Reason = fmt.Sprintf("expired %s", c.Expires.Format(time.RFC3339))
+	case !c.Expires.IsZero():
+		c.Reason = fmt.Sprintf("expires %s", c.Expires.Format(time.RFC3339))
+	case c.Created.IsZero():
+		c.Reason = "age unknown"
+	case now.Sub(c.Created) > ttl:
+		c.Orphaned = true
+		c.Reason = fmt.Sprintf("age %s > ttl %s", now.Sub(c.Created).Round(time.Minute), ttl)
+	default:
+		c.Reason = fmt.Sprintf("age %s <= ttl %s", now.Sub(c.Created).Round(time.Minute), ttl)
+	}
+}
+
+// isVault reports whether resources of type typ hold backups.
+func isVault(typ string) bool {
+	return typ == typeVault || typ == typeBackupVault
+}
+
+// deleteOrder ranks candidates so dependents go before what they live in.
+func deleteOrder(c Candidate) int {
+	switch {
+	case isVault(c.Type):
+		return 0
+	case c.Type == typeResourceGroup:
+		return 2
+	}
+	return 1
+}
+
+// Execute deletes the orphans in plan, logging every step to log. It keeps
+// going after a failure and returns all errors joined.
+func Execute(ctx context.Context, cfg Config, plan *Plan, log io.Writer) error {
+	groups, err := armresources.NewResourceGroupsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return err
+	}
+	resources, err := armresources.NewClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, c := range plan.Orphans() {
+		switch c.Type {
+		case typeVault:
+			// Even inside an orphaned group: the group cannot go while the
+			// vault holds protected items, soft-deleted ones included.
+			report, err := teardown.PurgeVault(ctx, teardown.Config{
+				SubscriptionID: cfg.SubscriptionID,
+				Credential:     cfg.Credential,
+				Options:        cfg.Options,
+			}, c.ResourceGroup, c.Name)
+			fmt.Fprint(log, report)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("purging vault %s: %w", c.Name, err))
+			}
+
+		case typeBackupVault:
+			report, err := teardown.PurgeBackupVault(ctx, teardown.Config{
+				SubscriptionID: cfg.SubscriptionID,
+				Credential:     cfg.Credential,
+				Options:        cfg.Options,
+			}, c.ResourceGroup, c.Name)
+			fmt.Fprint(log, report)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("purging backup vault %s: %w", c.Name, err))
+			}
+
+		case typeResourceGroup:
+			fmt.Fprintf(log, "deleting resource group %s\n", c.Name)
+			poller, err := groups.BeginDelete(ctx, c.Name, nil)
+			if err == nil {
+				_, err = poller.PollUntilDone(ctx, nil)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("deleting resource group %s: %w", c.Name, err))
+			}
+
+		default:
+			if c.InOrphanedGroup {
+				continue
+			}
+			errs = append(errs, deleteByID(ctx, resources, c, log))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func deleteByID(ctx context.Context, client *armresources.Client, c Candidate, log io.Writer) error {
+	version, ok := apiVersions[c.Type]
+	if !ok {
+		return fmt.Errorf("no API version known for %s", c.Type)
+	}
+	fmt.Fprintf(log, "deleting %s %s\n", c.Type, c.ID)
+	poller, err := client.BeginDeleteByID(ctx, c.ID, version, nil)
+	if err == nil {
+		_, err = poller.PollUntilDone(ctx, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", c.ID, err)
+	}
+	return nil
+}
+
+// resourceGroupOf extracts the resource group name from a resource ID.
+func resourceGroupOf(id string) string {
+	segs := strings.Split(strings.Trim(id, "/"), "/")
+	for i := 0; i+1 < len(segs); i++ {
+		if strings.EqualFold(segs[i], "resourceGroups") {
+			return segs[i+1]
+		}
+	}
+	return ""
+}
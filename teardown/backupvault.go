@@ -0,0 +1,71 @@
+package teardown
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection"
+)
+
+// PurgeBackupVault deletes the backup instances of a Data Protection backup
+// vault and then the vault, which ARM refuses to delete, along with its
+// resource group, while it still holds instances. A vault that does not
+// exist is not an error. The report is returned even when a step fails.
+func PurgeBackupVault(ctx context.Context, cfg Config, resourceGroup, vault string) (*Report, error) {
+	report := &Report{Vault: vault}
+
+	vaults, err := armdataprotection.NewBackupVaultsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return report, err
+	}
+	instances, err := armdataprotection.NewBackupInstancesClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return report, err
+	}
+
+	resp, err := vaults.Get(ctx, resourceGroup, vault, nil)
+	if isNotFound(err) {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("reading backup vault %s: %w", vault, err)
+	}
+	if resp.ID != nil {
+		if err := removeLocks(ctx, cfg, report, *resp.ID); err != nil {
+			return report, err
+		}
+	}
+
+	var names []string
+	pager := instances.NewListPager(resourceGroup, vault, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return report, fmt.Errorf("listing backup instances of vault %s: %w", vault, err)
+		}
+		for _, bi := range page.Value {
+			if bi.Name != nil {
+				names = append(names, *bi.Name)
+			}
+		}
+	}
+	for _, name := range names {
+		err := report.do("delete backup instance", name, func() error {
+			poller, err := instances.BeginDelete(ctx, resourceGroup, vault, name, nil)
+			if err != nil {
+				return err
+			}
+			_, err = poller.PollUntilDone(ctx, nil)
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	err = report.do("delete vault", vault, func() error {
+		_, err := vaults.Delete(ctx, resourceGroup, vault, nil)
+		return err
+	})
+	return report, err
+}
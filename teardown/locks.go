@@ -0,0 +1,116 @@
+package teardown
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+// LocksAPIVersion is the Microsoft.Authorization/locks API version. The
+// module depends on no locks client, so locks are read and deleted through
+// the generic ARM pipeline.
+const LocksAPIVersion = "2020-05-01"
+
+const locksPath = "/providers/Microsoft.Authorization/locks/"
+
+// Lock is a management lock that applies to a resource.
+type Lock struct {
+	ID    string
+	Name  string
+	Level string
+	// Scope is the resource, group or subscription the lock is on: the
+	// locked resource itself, a scope above it or one below it.
+	Scope string
+}
+
+// Locks lists the management locks on the resource with the given ID,
+// including those inherited from the scopes above it.
+func Locks(ctx context.Context, cfg Config, id string) ([]Lock, error) {
+	client, err := arm.NewClient("github.com/SwastikaAryal/azure_terraform/teardown", "v1.0.0", cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Lock
+	next := runtime.JoinPaths(client.Endpoint(), id, "providers/Microsoft.Authorization/locks") + "?api-version=" + LocksAPIVersion
+	for next != "" {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, next)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Pipeline().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("listing locks on %s: %w", id, err)
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return nil, fmt.Errorf("listing locks on %s: %w", id, runtime.NewResponseError(resp))
+		}
+		var page struct {
+			Value []struct {
+				ID         string `json:"id"`
+				Name       string `json:"name"`
+				Properties struct {
+					Level string `json:"level"`
+				} `json:"properties"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, fmt.Errorf("listing locks on %s: %w", id, err)
+		}
+		for _, l := range page.Value {
+			scope := l.ID
+			if i := strings.LastIndex(strings.ToLower(l.ID), strings.ToLower(locksPath)); i >= 0 {
+				scope = l.ID[:i]
+			}
+			out = append(out, Lock{ID: l.ID, Name: l.Name, Level: l.Properties.Level, Scope: scope})
+		}
+		next = page.NextLink
+	}
+	return out, nil
+}
+
+// removeLocks deletes the locks on the vault and below it, which would
+// otherwise refuse every delete that follows. A lock inherited from the
+// resource group or subscription is not the harness's to remove; it is
+// reported as a failed step instead.
+func removeLocks(ctx context.Context, cfg Config, report *Report, vaultID string) error {
+	locks, err := Locks(ctx, cfg, vaultID)
+	if err != nil {
+		return err
+	}
+	resources, err := armresources.NewClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return err
+	}
+	for _, l := range locks {
+		if !within(vaultID, l.Scope) {
+			return report.do("check lock", l.Name, func() error {
+				return fmt.Errorf("%s lock is inherited from %s; remove it there", l.Level, l.Scope)
+			})
+		}
+		err := report.do("remove lock", l.Name, func() error {
+			poller, err := resources.BeginDeleteByID(ctx, l.ID, LocksAPIVersion, nil)
+			if err != nil {
+				return err
+			}
+			_, err = poller.PollUntilDone(ctx, nil)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// within reports whether inner is scope itself or a scope below it.
+func within(scope, inner string) bool {
+	scope, inner = strings.ToLower(strings.TrimSuffix(scope, "/")), strings.ToLower(strings.TrimSuffix(inner, "/"))
+	return inner == scope || strings.HasPrefix(inner, scope+"/")
+}
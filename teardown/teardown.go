@@ -0,0 +1,274 @@
+// Package teardown removes a Recovery Services vault that soft delete would
+// otherwise keep alive.
+//
+// terraform destroy cannot delete a vault that still holds protected items,
+// and with soft delete on, stopping protection only moves the items into a
+// 14-day soft-deleted state that keeps blocking the vault, so the next run
+// with the same name fails with VaultAlreadySoftDeletedOrExists. PurgeVault
+// walks the documented way out: remove the management locks on the vault,
+// make it mutable, turn soft delete off, stop protection with data
+// deletion, undelete and delete again any item that is already
+// soft-deleted, and only then delete the vault.
+//
+// PurgeBackupVault does the same for a Data Protection backup vault, whose
+// backup instances keep it and its resource group from being deleted.
+package teardown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+)
+
+// maxRounds bounds how often the protected items are re-listed while
+// waiting for asynchronous stop-protection and undelete operations.
+const maxRounds = 30
+
+// Config selects the subscription and how to reach it.
+type Config struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+
+	// PollInterval is the wait between rounds of protected item operations;
+	// 10s when zero.
+	PollInterval time.Duration
+}
+
+func (c Config) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return 10 * time.Second
+}
+
+// Step is one action a purge took.
+type Step struct {
+	Action string
+	Target string
+	Err    error
+}
+
+func (s Step) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("FAILED %s %s: %v", s.Action, s.Target, s.Err)
+	}
+	return fmt.Sprintf("ok     %s %s", s.Action, s.Target)
+}
+
+// Report lists every step a purge took, in order.
+type Report struct {
+	Vault string
+	Steps []Step
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "purge of vault %s:\n", r.Vault)
+	if len(r.Steps) == 0 {
+		b.WriteString("  nothing to do\n")
+	}
+	for _, s := range r.Steps {
+		fmt.Fprintf(&b, "  %s\n", s)
+	}
+	return b.String()
+}
+
+func (r *Report) do(action, target string, fn func() error) error {
+	err := fn()
+	r.Steps = append(r.Steps, Step{Action: action, Target: target, Err: err})
+	return err
+}
+
+// PurgeVault empties and deletes the vault. A vault that does not exist is
+// not an error. The report is returned even when a step fails.
+func PurgeVault(ctx context.Context, cfg Config, resourceGroup, vault string) (*Report, error) {
+	report := &Report{Vault: vault}
+
+	vaults, err := armrecoveryservices.NewVaultsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return report, err
+	}
+	list, err := armrecoveryservicesbackup.NewBackupProtectedItemsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return report, err
+	}
+	items, err := armrecoveryservicesbackup.NewProtectedItemsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return report, err
+	}
+
+	resp, err := vaults.Get(ctx, resourceGroup, vault, nil)
+	if isNotFound(err) {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("reading vault %s: %w", vault, err)
+	}
+
+	if resp.ID != nil {
+		if err := removeLocks(ctx, cfg, report, *resp.ID); err != nil {
+			return report, err
+		}
+	}
+
+	var security *armrecoveryservices.SecuritySettings
+	if resp.Properties != nil {
+		security = resp.Properties.SecuritySettings
+	}
+	if err := makeMutable(ctx, vaults, report, resourceGroup, vault, security); err != nil {
+		return report, err
+	}
+
+	for round := 0; ; round++ {
+		pending, err := protectedItems(ctx, list, resourceGroup, vault)
+		if err != nil {
+			return report, err
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if round == maxRounds {
+			return report, fmt.Errorf("vault %s still holds %d protected items after %d rounds", vault, len(pending), maxRounds)
+		}
+		if round > 0 {
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			case <-time.After(cfg.pollInterval()):
+			}
+		}
+
+		for _, item := range pending {
+			if err := removeItem(ctx, items, report, resourceGroup, vault, item); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	err = report.do("delete vault", vault, func() error {
+		_, err := vaults.Delete(ctx, resourceGroup, vault, nil)
+		return err
+	})
+	return report, err
+}
+
+// makeMutable lifts the vault settings that stop its items from being
+// deleted for good: an unlocked immutability policy and soft delete.
+func makeMutable(ctx context.Context, vaults *armrecoveryservices.VaultsClient, report *Report, resourceGroup, vault string, security *armrecoveryservices.SecuritySettings) error {
+	if security == nil {
+		return nil
+	}
+
+	if im := security.ImmutabilitySettings; im != nil && im.State != nil {
+		switch *im.State {
+		case armrecoveryservices.ImmutabilityStateLocked:
+			return report.do("check immutability", vault, func() error {
+				return errors.New("immutability is locked; backup data cannot be deleted before it expires")
+			})
+		case armrecoveryservices.ImmutabilityStateUnlocked:
+			err := report.do("disable immutability", vault, func() error {
+				return patchSecurity(ctx, vaults, resourceGroup, vault, &armrecoveryservices.SecuritySettings{
+					ImmutabilitySettings: &armrecoveryservices.ImmutabilitySettings{
+						State: to.Ptr(armrecoveryservices.ImmutabilityStateDisabled),
+					},
+				})
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if sd := security.SoftDeleteSettings; sd != nil && sd.SoftDeleteState != nil {
+		switch *sd.SoftDeleteState {
+		case armrecoveryservices.SoftDeleteStateAlwaysON:
+			return report.do("check soft delete", vault, func() error {
+				return errors.New("soft delete is always on; soft-deleted items cannot be purged early")
+			})
+		case armrecoveryservices.SoftDeleteStateEnabled:
+			return report.do("disable soft delete", vault, func() error {
+				return patchSecurity(ctx, vaults, resourceGroup, vault, &armrecoveryservices.SecuritySettings{
+					SoftDeleteSettings: &armrecoveryservices.SoftDeleteSettings{
+						SoftDeleteState: to.Ptr(armrecoveryservices.SoftDeleteStateDisabled),
+					},
+				})
+			})
+		}
+	}
+	return nil
+}
+
+func patchSecurity(ctx context.Context, vaults *armrecoveryservices.VaultsClient, resourceGroup, vault string, security *armrecoveryservices.SecuritySettings) error {
+	poller, err := vaults.BeginUpdate(ctx, resourceGroup, vault, armrecoveryservices.PatchVault{
+		Properties: &armrecoveryservices.VaultProperties{SecuritySettings: security},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+func protectedItems(ctx context.Context, list *armrecoveryservicesbackup.BackupProtectedItemsClient, resourceGroup, vault string) ([]*armrecoveryservicesbackup.ProtectedItemResource, error) {
+	var out []*armrecoveryservicesbackup.ProtectedItemResource
+	pager := list.NewListPager(vault, resourceGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing protected items of vault %s: %w", vault, err)
+		}
+		for _, item := range page.Value {
+			if item.ID != nil && item.Properties != nil {
+				out = append(out, item)
+			}
+		}
+	}
+	return out, nil
+}
+
+// removeItem stops protection of item and deletes its data. A soft-deleted
+// item is only undeleted: that completes asynchronously, so the delete is
+// left to the next round, once the item shows up as active again.
+func removeItem(ctx context.Context, items *armrecoveryservicesbackup.ProtectedItemsClient, report *Report, resourceGroup, vault string, item *armrecoveryservicesbackup.ProtectedItemResource) error {
+	id, err := arm.ParseResourceID(*item.ID)
+	if err != nil {
+		return fmt.Errorf("parsing protected item ID %s: %w", *item.ID, err)
+	}
+	container := id.Parent
+	fabric := container.Parent
+	props := item.Properties.GetProtectedItem()
+
+	if props.IsScheduledForDeferredDelete != nil && *props.IsScheduledForDeferredDelete {
+		return report.do("undelete soft-deleted item", id.Name, func() error {
+			_, err := items.CreateOrUpdate(ctx, vault, resourceGroup, fabric.Name, container.Name, id.Name,
+				armrecoveryservicesbackup.ProtectedItemResource{
+					Properties: &armrecoveryservicesbackup.ProtectedItem{
+						ProtectedItemType: props.ProtectedItemType,
+						SourceResourceID:  props.SourceResourceID,
+						PolicyID:          props.PolicyID,
+						IsRehydrate:       to.Ptr(true),
+					},
+				}, nil)
+			return err
+		})
+	}
+
+	return report.do("stop protection and delete data", id.Name, func() error {
+		_, err := items.Delete(ctx, vault, resourceGroup, fabric.Name, container.Name, id.Name, nil)
+		return err
+	})
+}
+
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == 404
+}
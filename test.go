@@ -1,6 +1,7 @@
 package test
 
 import (
+    "os"
     "testing"
 
     "github.com/gruntwork-io/terratest/modules/terraform"
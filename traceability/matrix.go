@@ -0,0 +1,245 @@
+package traceability
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of the test or subtest that declared an assertion.
+type Result string
+
+const (
+	Pass Result = "pass"
+	Fail Result = "fail"
+	Skip Result = "skip"
+	// NoResult means the run ended before the test reported an outcome,
+	// e.g. on a timeout or panic.
+	NoResult Result = "unknown"
+)
+
+// Row is one line of the matrix: a ticket verified by an assertion of a test.
+type Row struct {
+	Ticket    string
+	Title     string
+	Package   string
+	Test      string
+	Assertion string
+	Result    Result
+	// Time is when the test finished.
+	Time time.Time
+}
+
+// Matrix is the traceability matrix of one test run.
+type Matrix struct {
+	Rows []Row
+	// Uncovered are catalog requirements no test declares.
+	Uncovered []Requirement
+	// Unknown are tickets declared by tests but missing from the catalog.
+	Unknown []string
+}
+
+// event is the subset of a test2json event Parse needs.
+type event struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+type testKey struct{ pkg, test string }
+
+// Parse reads `go test -json` output and builds the matrix against catalog.
+// Each row takes the outcome of the subtest that declared it, not of its
+// parent, whose failure may come from a sibling.
+func Parse(r io.Reader, catalog []Requirement) (*Matrix, error) {
+	type pending struct {
+		key  testKey
+		decl declaration
+	}
+	var (
+		decls    []pending
+		outcomes = map[testKey]event{}
+	)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		raw := sc.Bytes()
+		if len(raw) == 0 || raw[0] != '{' {
+			// go test prints build failures as plain text.
+			continue
+		}
+		var ev event
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ev.Test == "" {
+			continue
+		}
+		key := testKey{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			if d, ok := parseMarker(ev.Output); ok {
+				decls = append(decls, pending{key, d})
+			}
+		case "pass", "fail", "skip":
+			outcomes[key] = ev
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	titles := map[string]string{}
+	for _, req := range catalog {
+		titles[req.ID] = req.Title
+	}
+
+	m := &Matrix{}
+	covered := map[string]bool{}
+	unknown := map[string]bool{}
+	for _, p := range decls {
+		res, at := NoResult, time.Time{}
+		if ev, ok := outcomes[p.key]; ok {
+			res, at = Result(ev.Action), ev.Time
+		}
+		for _, ticket := range p.decl.Tickets {
+			title, known := titles[ticket]
+			if !known && !unknown[ticket] {
+				unknown[ticket] = true
+				m.Unknown = append(m.Unknown, ticket)
+			}
+			covered[ticket] = true
+			m.Rows = append(m.Rows, Row{
+				Ticket:    ticket,
+				Title:     title,
+				Package:   p.key.pkg,
+				Test:      p.key.test,
+				Assertion: p.decl.Assertion,
+				Result:    res,
+				Time:      at,
+			})
+		}
+	}
+	for _, req := range catalog {
+		if !covered[req.ID] {
+			m.Uncovered = append(m.Uncovered, req)
+		}
+	}
+
+	sort.SliceStable(m.Rows, func(i, j int) bool {
+		if m.Rows[i].Ticket != m.Rows[j].Ticket {
+			return m.Rows[i].Ticket < m.Rows[j].Ticket
+		}
+		return m.Rows[i].Test < m.Rows[j].Test
+	})
+	sort.Strings(m.Unknown)
+	return m, nil
+}
+
+// parseMarker extracts a declaration from a line of test output, which
+// t.Log prefixes with the indented file:line of the call.
+func parseMarker(output string) (declaration, bool) {
+	_, payload, ok := strings.Cut(output, marker)
+	if !ok {
+		return declaration{}, false
+	}
+	var d declaration
+	if err := json.Unmarshal([]byte(strings.TrimSpace(payload)), &d); err != nil {
+		return declaration{}, false
+	}
+	return d, true
+}
+
+// Failed reports whether any assertion in the matrix failed.
+func (m *Matrix) Failed() bool {
+	for _, r := range m.Rows {
+		if r.Result == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+var csvHeader = []string{"ticket", "title", "package", "test", "assertion", "result", "timestamp"}
+
+// WriteCSV writes one record per row, with an RFC 3339 UTC timestamp.
+// Uncovered requirements are written as rows without a test.
+func (m *Matrix) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range m.Rows {
+		if err := cw.Write([]string{r.Ticket, r.Title, r.Package, r.Test, r.Assertion, string(r.Result), timestamp(r.Time)}); err != nil {
+			return err
+		}
+	}
+	for _, req := range m.Uncovered {
+		if err := cw.Write([]string{req.ID, req.Title, "", "", "", "uncovered", ""}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown writes the matrix as a Markdown table, followed by the
+// uncovered requirements and the tickets missing from the catalog.
+func (m *Matrix) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Requirement traceability matrix\n\n")
+	b.WriteString("| Ticket | Requirement | Test | Assertion | Result | Timestamp |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, r := range m.Rows {
+		fmt.Fprintf(&b, "| %s | %s | `%s` | %s | %s | %s |\n",
+			mdEscape(r.Ticket), mdEscape(r.Title), r.Test, mdEscape(r.Assertion), resultLabel(r.Result), timestamp(r.Time))
+	}
+
+	b.WriteString("\n## Uncovered requirements\n\n")
+	if len(m.Uncovered) == 0 {
+		b.WriteString("Every requirement in the catalog is verified by at least one test.\n")
+	}
+	for _, req := range m.Uncovered {
+		fmt.Fprintf(&b, "- **%s** %s\n", mdEscape(req.ID), mdEscape(req.Title))
+	}
+
+	if len(m.Unknown) > 0 {
+		b.WriteString("\n## Tickets missing from the catalog\n\n")
+		for _, id := range m.Unknown {
+			fmt.Fprintf(&b, "- %s\n", mdEscape(id))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func resultLabel(r Result) string {
+	switch r {
+	case Pass:
+		return "✅ pass"
+	case Fail:
+		return "❌ fail"
+	case Skip:
+		return "⏭ skip"
+	}
+	return "⚠ " + string(r)
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,118 @@
+package traceability_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+)
+
+// ─── Test: Requirement traceability matrix ───────────────────────────────────
+
+// TestTraceabilityMatrix feeds the matrix builder a recorded `go test -json`
+// stream and checks that declarations are paired with their test's outcome
+// and that undeclared catalog tickets are flagged.
+func TestTraceabilityMatrix(t *testing.T) {
+	t.Parallel()
+
+	const run = `{"Time":"2026-10-18T09:00:00Z","Action":"start","Package":"github.com/SwastikaAryal/azure_terraform"}
+{"Time":"2026-10-18T09:00:01Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/RSVVaultCreation"}
+{"Time":"2026-10-18T09:00:01Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/RSVVaultCreation","Output":"    backup.go:348: traceability: {\"assertion\":\"vault is Standard SKU with soft delete enabled\",\"tickets\":[\"MINITRUE-9348\"]}\n"}
+{"Time":"2026-10-18T09:00:01Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/RSVVaultCreation","Output":"    backup.go:349: traceability: {\"assertion\":\"vault has cross-region restore on GeoRedundant storage\",\"tickets\":[\"MINITRUE-9418\",\"MINITRUE-0001\"]}\n"}
+{"Time":"2026-10-18T09:00:02Z","Action":"pass","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/RSVVaultCreation","Elapsed":1}
+{"Time":"2026-10-18T09:00:02Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/DiskSnapshotVault"}
+{"Time":"2026-10-18T09:00:02Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/DiskSnapshotVault","Output":"    backup.go:492: traceability: {\"assertion\":\"disk snapshot backup vault exists with geo-redundant storage\",\"tickets\":[\"MINITRUE-9416\"]}\n"}
+{"Time":"2026-10-18T09:00:02Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/DiskSnapshotVault","Output":"    backup.go:495: assertion failed | see above\n"}
+{"Time":"2026-10-18T09:00:03Z","Action":"fail","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/DiskSnapshotVault","Elapsed":1}
+{"Time":"2026-10-18T09:00:04Z","Action":"fail","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule","Elapsed":4}
+`
+
+	m, err := traceability.Parse(strings.NewReader(run), traceability.Catalog)
+	require.NoError(t, err)
+
+	type row struct{ ticket, test, result, time string }
+	var got []row
+	for _, r := range m.Rows {
+		got = append(got, row{r.Ticket, r.Test, string(r.Result), r.Time.Format("15:04:05")})
+	}
+	assert.Equal(t, []row{
+		{"MINITRUE-0001", "TestBackupModule/RSVVaultCreation", "pass", "09:00:02"},
+		{"MINITRUE-9348", "TestBackupModule/RSVVaultCreation", "pass", "09:00:02"},
+		{"MINITRUE-9416", "TestBackupModule/DiskSnapshotVault", "fail", "09:00:03"},
+		{"MINITRUE-9418", "TestBackupModule/RSVVaultCreation", "pass", "09:00:02"},
+	}, got)
+	assert.True(t, m.Failed())
+
+	var uncovered []string
+	for _, req := range m.Uncovered {
+		uncovered = append(uncovered, req.ID)
+	}
+	assert.Equal(t, []string{"MINITRUE-9414", "Sprint 3"}, uncovered, "tickets no test declared")
+	assert.Equal(t, []string{"MINITRUE-0001"}, m.Unknown, "tickets missing from the catalog")
+
+	var md bytes.Buffer
+	require.NoError(t, m.WriteMarkdown(&md))
+	assert.Contains(t, md.String(),
+		"| MINITRUE-9416 | Disk snapshot backup vault and policy | `TestBackupModule/DiskSnapshotVault` | disk snapshot backup vault exists with geo-redundant storage | ❌ fail | 2026-10-18T09:00:03Z |")
+	assert.Contains(t, md.String(), "- **MINITRUE-9414** Automation account, restore runbooks and RBAC")
+
+	var out bytes.Buffer
+	require.NoError(t, m.WriteCSV(&out))
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 1+len(m.Rows)+len(m.Uncovered))
+	assert.Equal(t, []string{"ticket", "title", "package", "test", "assertion", "result", "timestamp"}, records[0])
+	assert.Equal(t, []string{"Sprint 3", "Backup monitoring and alerting", "", "", "", "uncovered", ""}, records[len(records)-1])
+}
+
+// TestParseOutcomes checks each row takes the outcome of the subtest that
+// declared it: a failing sibling or parent does not fail it, a skipped one is
+// not verified, and one the run never finished has no result.
+func TestParseOutcomes(t *testing.T) {
+	t.Parallel()
+
+	const run = `{"Time":"2026-10-18T09:00:00Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan"}
+{"Time":"2026-10-18T09:00:01Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/SoftDelete"}
+{"Time":"2026-10-18T09:00:01Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/SoftDelete","Output":"        backup_test.go:390: traceability: {\"assertion\":\"planned vault enables soft delete\",\"tickets\":[\"MINITRUE-9348\"]}\n"}
+{"Time":"2026-10-18T09:00:01Z","Action":"pass","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/SoftDelete","Elapsed":0}
+{"Time":"2026-10-18T09:00:01Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/ManagementLock"}
+{"Time":"2026-10-18T09:00:01Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/ManagementLock","Output":"        backup_test.go:420: traceability: {\"assertion\":\"planned vault carries a CanNotDelete management lock\",\"tickets\":[\"MINITRUE-9348\"]}\n"}
+{"Time":"2026-10-18T09:00:01Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/ManagementLock","Output":"        planassert.go:152: azurerm_management_lock.backup_lock: want 1 resources, got 0\n"}
+{"Time":"2026-10-18T09:00:02Z","Action":"fail","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/ManagementLock","Elapsed":1}
+{"Time":"2026-10-18T09:00:02Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/Diagnostics"}
+{"Time":"2026-10-18T09:00:02Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/Diagnostics","Output":"        backup_test.go:430: traceability: {\"assertion\":\"planned vault diagnostics write the resource-specific tables\",\"tickets\":[\"Sprint 3\"]}\n"}
+{"Time":"2026-10-18T09:00:02Z","Action":"skip","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan/Diagnostics","Elapsed":0}
+{"Time":"2026-10-18T09:00:03Z","Action":"fail","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupRecoveryPlan","Elapsed":3}
+{"Time":"2026-10-18T09:00:03Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule"}
+{"Time":"2026-10-18T09:00:04Z","Action":"run","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/DiskSnapshotVault"}
+{"Time":"2026-10-18T09:00:04Z","Action":"output","Package":"github.com/SwastikaAryal/azure_terraform","Test":"TestBackupModule/DiskSnapshotVault","Output":"        backup_test.go:570: traceability: {\"assertion\":\"disk snapshot backup vault exists with geo-redundant storage\",\"tickets\":[\"MINITRUE-9416\"]}\n"}
+`
+
+	m, err := traceability.Parse(strings.NewReader(run), traceability.Catalog)
+	require.NoError(t, err)
+
+	type row struct{ ticket, test, result string }
+	var got []row
+	for _, r := range m.Rows {
+		got = append(got, row{r.Ticket, r.Test, string(r.Result)})
+	}
+	assert.Equal(t, []row{
+		{"MINITRUE-9348", "TestBackupRecoveryPlan/ManagementLock", "fail"},
+		{"MINITRUE-9348", "TestBackupRecoveryPlan/SoftDelete", "pass"},
+		{"MINITRUE-9416", "TestBackupModule/DiskSnapshotVault", "unknown"},
+		{"Sprint 3", "TestBackupRecoveryPlan/Diagnostics", "skip"},
+	}, got)
+	assert.True(t, m.Failed())
+
+	// The vault assertions of TestBackupModule never ran.
+	var uncovered []string
+	for _, req := range m.Uncovered {
+		uncovered = append(uncovered, req.ID)
+	}
+	assert.Equal(t, []string{"MINITRUE-9414", "MINITRUE-9418"}, uncovered)
+}
@@ -0,0 +1,58 @@
+// Package traceability links tests to the requirement tickets they verify and
+// builds the audit matrix from a `go test -json` run.
+//
+// A test declares what it verifies with Verifies, which logs a structured
+// marker. The marker travels through the ordinary test output, so nothing
+// has to be collected at run time: Parse reads the JSON event stream, pairs
+// every marker with the outcome of the test or subtest that logged it and
+// returns a Matrix, which can be written as Markdown or CSV. Tickets in the Catalog
+// that no test declares are reported as uncovered.
+package traceability
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// marker prefixes the log line Verifies writes; Parse looks for it.
+const marker = "traceability: "
+
+// Requirement is a ticket the module has to satisfy.
+type Requirement struct {
+	ID    string
+	Title string
+}
+
+// Catalog lists every requirement the backup module is delivered against.
+// A ticket that is not declared by any test shows up as uncovered.
+var Catalog = []Requirement{
+	{ID: "MINITRUE-9348", Title: "Recovery Services vault with soft delete"},
+	{ID: "MINITRUE-9414", Title: "Automation account, restore runbooks and RBAC"},
+	{ID: "MINITRUE-9416", Title: "Disk snapshot backup vault and policy"},
+	{ID: "MINITRUE-9418", Title: "VM backup policies, cross-region restore and disk exclusion"},
+	{ID: "Sprint 3", Title: "Backup monitoring and alerting"},
+}
+
+// declaration is the payload of a marker.
+type declaration struct {
+	Assertion string   `json:"assertion"`
+	Tickets   []string `json:"tickets"`
+}
+
+// Verifies records that the calling test checks assertion for the given
+// tickets. The row it produces in the matrix passes, fails or is skipped with
+// that test, so call it from the test or subtest that performs the check: a
+// test checking several assertions runs each in its own subtest, and one that
+// stops before reaching a subtest leaves its assertion uncovered rather than
+// verified.
+func Verifies(t testing.TB, assertion string, tickets ...string) {
+	t.Helper()
+	if len(tickets) == 0 {
+		t.Fatalf("traceability: %q declares no ticket", assertion)
+	}
+	raw, err := json.Marshal(declaration{Assertion: assertion, Tickets: tickets})
+	if err != nil {
+		t.Fatalf("traceability: %v", err)
+	}
+	t.Log(marker + string(raw))
+}
@@ -254,6 +254,16 @@ run "unit_alert_rules" {
     error_message = "[UNIT] stale backup alert severity must be 2 (Warning)"
   }
 
+  assert {
+    condition     = azurerm_monitor_scheduled_query_rules_alert_v2.restore_failure.severity == 2
+    error_message = "[UNIT] restore failure alert severity must be 2 (Warning)"
+  }
+
+  assert {
+    condition     = azurerm_monitor_scheduled_query_rules_alert_v2.protection_stopped.severity == 2
+    error_message = "[UNIT] protection stopped alert severity must be 2 (Warning)"
+  }
+
   assert {
     condition     = azurerm_monitor_metric_alert.vault_health.criteria[0].metric_name == "BackupHealthEvent"
     error_message = "[UNIT] vault health alert must target BackupHealthEvent metric"
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/teardown"
+)
+
+// ─── Teardown: soft-deleted vault purge ──────────────────────────────────────
+
+// purgeVault empties and deletes the deployment's Recovery Services vault
+// ahead of terraform destroy and logs every step taken. The vault's
+// management locks go first; once the purge is done, the locks and the
+// vault must be gone. A failure is reported but does not stop the teardown.
+func purgeVault(t *testing.T, backend *armBackend, opts *terraform.Options) {
+	t.Helper()
+
+	rg := fmt.Sprintf("%v", opts.Vars["resource_group_name"])
+	vault := fmt.Sprintf("%v", opts.Vars["vault_name"])
+	vaultID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.RecoveryServices/vaults/%s", backend.SubscriptionID, rg, vault)
+	cfg := teardown.Config{
+		SubscriptionID: backend.SubscriptionID,
+		Credential:     backend.Credential,
+		Options:        backend.Options,
+	}
+
+	// A vault that was never created has no locks to check.
+	locks, err := teardown.Locks(t.Context(), cfg, vaultID)
+	if err != nil {
+		t.Logf("not checking the locks on vault %s: %v", vault, err)
+	}
+
+	report, err := teardown.PurgeVault(t.Context(), cfg, rg, vault)
+	t.Log(report)
+	if !assert.NoError(t, err, "failed to purge vault %s", vault) {
+		return
+	}
+
+	resources, err := armresources.NewClient(backend.SubscriptionID, backend.Credential, backend.Options)
+	require.NoError(t, err)
+	for _, l := range locks {
+		_, err := resources.GetByID(t.Context(), l.ID, teardown.LocksAPIVersion, nil)
+		assertNotFound(t, err, "lock %s after the purge", l.Name)
+	}
+	vaults, err := armrecoveryservices.NewVaultsClient(backend.SubscriptionID, backend.Credential, backend.Options)
+	require.NoError(t, err)
+	_, err = vaults.Get(t.Context(), rg, vault, nil)
+	assertNotFound(t, err, "vault %s after the purge", vault)
+}
+
+// TestVaultPurgeAgainstFake checks the purge against the fake ARM backend: a
+// locked vault with soft delete on, one active and one already soft-deleted
+// item must end up deleted, with each step reported.
+func TestVaultPurgeAgainstFake(t *testing.T) {
+	t.Parallel()
+
+	fake := seededFake(t)
+	rg, vaultID, vault := fake.ResourceGroup, fake.VaultID, fake.Vault
+
+	itemID := func(vm string) string {
+		return fmt.Sprintf("%v/backupFabrics/Azure/protectionContainers/iaasvmcontainerv2;rg-app;%s/protectedItems/vm;iaasvmcontainerv2;rg-app;%s", vaultID, vm, vm)
+	}
+	fake.Put(itemID("vm-app-0"), map[string]interface{}{"properties": map[string]interface{}{
+		"protectedItemType": "Microsoft.Compute/virtualMachines",
+		"protectionState":   "Protected",
+	}})
+	fake.Put(itemID("vm-app-1"), map[string]interface{}{"properties": map[string]interface{}{
+		"protectedItemType":            "Microsoft.Compute/virtualMachines",
+		"protectionState":              "ProtectionStopped",
+		"isScheduledForDeferredDelete": true,
+	}})
+
+	report, err := teardown.PurgeVault(t.Context(), teardown.Config{
+		SubscriptionID: fake.SubscriptionID(),
+		Credential:     fake.Credential(),
+		Options:        fake.ClientOptions(),
+		PollInterval:   1,
+	}, fmt.Sprintf("%v", rg), vault)
+	t.Log(report)
+	require.NoError(t, err)
+
+	var actions []string
+	for _, step := range report.Steps {
+		actions = append(actions, step.Action+" "+step.Target)
+	}
+	assert.Equal(t, []string{
+		"remove lock rsv-vault-delete-lock",
+		"disable immutability " + vault,
+		"disable soft delete " + vault,
+		"stop protection and delete data vm;iaasvmcontainerv2;rg-app;vm-app-0",
+		"undelete soft-deleted item vm;iaasvmcontainerv2;rg-app;vm-app-1",
+		"stop protection and delete data vm;iaasvmcontainerv2;rg-app;vm-app-1",
+		"delete vault " + vault,
+	}, actions, "purge steps")
+
+	for _, id := range fake.IDs() {
+		assert.False(t, strings.HasPrefix(strings.ToLower(id), strings.ToLower(fmt.Sprintf("%v", vaultID))),
+			"%s should be gone with the vault", id)
+	}
+
+	// A second purge finds nothing to do.
+	report, err = teardown.PurgeVault(t.Context(), teardown.Config{
+		SubscriptionID: fake.SubscriptionID(),
+		Credential:     fake.Credential(),
+		Options:        fake.ClientOptions(),
+	}, fmt.Sprintf("%v", rg), vault)
+	require.NoError(t, err)
+	assert.Empty(t, report.Steps)
+}
@@ -0,0 +1,172 @@
+package vaultsecurity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+)
+
+// Probe is the outcome of attempting an operation the vault should refuse.
+type Probe struct {
+	Operation string `json:"operation"`
+	Target    string `json:"target"`
+	// Blocked is true when Azure refused the operation.
+	Blocked bool `json:"blocked"`
+	// Code and Message are Azure's reason for refusing it.
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (p Probe) String() string {
+	if p.Blocked {
+		return fmt.Sprintf("%s of %s was blocked: %s", p.Operation, p.Target, p.Code)
+	}
+	return fmt.Sprintf("%s of %s was allowed", p.Operation, p.Target)
+}
+
+// refused fills in the probe from a failed request and reports whether Azure
+// refused it, as opposed to the request failing for another reason.
+func (p *Probe) refused(err error) bool {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	switch respErr.StatusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusConflict:
+	default:
+		return false
+	}
+	p.Blocked, p.Code, p.Message = true, respErr.ErrorCode, err.Error()
+	return true
+}
+
+// ProbeRetentionReduction tries to keep the daily recovery points of a VM
+// backup policy one day less, which an immutable vault must refuse. When
+// the change goes through, the policy is put back as it was.
+func ProbeRetentionReduction(ctx context.Context, cfg Config, resourceGroup, vault, policy string) (Probe, error) {
+	probe := Probe{Operation: "reduce daily retention", Target: policy}
+	policies, err := armrecoveryservicesbackup.NewProtectionPoliciesClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return probe, err
+	}
+	resp, err := policies.Get(ctx, vault, resourceGroup, policy, nil)
+	if err != nil {
+		return probe, fmt.Errorf("vaultsecurity: reading policy %s: %w", policy, err)
+	}
+	original := resp.ProtectionPolicyResource
+
+	// Reduce a copy, so that original stays intact to restore.
+	reduced := resp.ProtectionPolicyResource
+	props, ok := original.Properties.(*armrecoveryservicesbackup.AzureIaaSVMProtectionPolicy)
+	if !ok {
+		return probe, fmt.Errorf("vaultsecurity: policy %s: properties are %T, want an Azure VM policy", policy, original.Properties)
+	}
+	retention, ok := props.RetentionPolicy.(*armrecoveryservicesbackup.LongTermRetentionPolicy)
+	if !ok || retention.DailySchedule == nil || retention.DailySchedule.RetentionDuration == nil {
+		return probe, fmt.Errorf("vaultsecurity: policy %s keeps no daily recovery points", policy)
+	}
+	days := deref(retention.DailySchedule.RetentionDuration.Count)
+	if days < 2 {
+		return probe, fmt.Errorf("vaultsecurity: policy %s keeps daily recovery points for %d days, too few to reduce", policy, days)
+	}
+	propsCopy, retentionCopy := *props, *retention
+	dailyCopy, durationCopy := *retention.DailySchedule, *retention.DailySchedule.RetentionDuration
+	durationCopy.Count = to.Ptr(days - 1)
+	dailyCopy.RetentionDuration = &durationCopy
+	retentionCopy.DailySchedule = &dailyCopy
+	propsCopy.RetentionPolicy = &retentionCopy
+	reduced.Properties = &propsCopy
+
+	_, err = policies.CreateOrUpdate(ctx, vault, resourceGroup, policy, reduced, nil)
+	if err == nil {
+		if _, err := policies.CreateOrUpdate(ctx, vault, resourceGroup, policy, original, nil); err != nil {
+			return probe, fmt.Errorf("vaultsecurity: restoring policy %s after the probe: %w", policy, err)
+		}
+		return probe, nil
+	}
+	if !probe.refused(err) {
+		return probe, fmt.Errorf("vaultsecurity: updating policy %s: %w", policy, err)
+	}
+	return probe, nil
+}
+
+// ProbeStopProtection tries to stop protection of a protected item and
+// delete its data, which an immutable vault must refuse. When the delete
+// goes through, soft delete keeps the item and its recovery points, and the
+// probe undeletes it. The probe refuses to run unless soft delete is on, and
+// fails if the delete went through without soft delete keeping the item or
+// the item could not be undeleted, since its recovery points may then be
+// lost.
+func ProbeStopProtection(ctx context.Context, cfg Config, resourceGroup, vault, itemID string) (Probe, error) {
+	probe := Probe{Operation: "stop protection and delete data", Target: itemID}
+	posture, err := Read(ctx, cfg, resourceGroup, vault)
+	if err != nil {
+		return probe, err
+	}
+	if !softDeleteOn(posture.SoftDelete) {
+		return probe, fmt.Errorf("vaultsecurity: soft delete is %s on vault %s; not probing %s, whose recovery points a delete would destroy", posture.SoftDelete, vault, itemID)
+	}
+	id, err := arm.ParseResourceID(itemID)
+	if err != nil {
+		return probe, fmt.Errorf("vaultsecurity: parsing protected item ID %s: %w", itemID, err)
+	}
+	container := id.Parent
+	fabric := container.Parent
+	probe.Target = id.Name
+
+	items, err := armrecoveryservicesbackup.NewProtectedItemsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return probe, err
+	}
+	resp, err := items.Get(ctx, vault, resourceGroup, fabric.Name, container.Name, id.Name, nil)
+	if err != nil {
+		return probe, fmt.Errorf("vaultsecurity: reading protected item %s: %w", id.Name, err)
+	}
+	if resp.Properties == nil {
+		return probe, fmt.Errorf("vaultsecurity: protected item %s has no properties", id.Name)
+	}
+	props := resp.Properties.GetProtectedItem()
+
+	_, err = items.Delete(ctx, vault, resourceGroup, fabric.Name, container.Name, id.Name, nil)
+	if err != nil {
+		if !probe.refused(err) {
+			return probe, fmt.Errorf("vaultsecurity: deleting protected item %s: %w", id.Name, err)
+		}
+		return probe, nil
+	}
+
+	after, err := items.Get(ctx, vault, resourceGroup, fabric.Name, container.Name, id.Name, nil)
+	switch {
+	case isNotFound(err):
+		return probe, fmt.Errorf("vaultsecurity: protected item %s was deleted despite soft delete; its recovery points are lost", id.Name)
+	case err != nil:
+		return probe, fmt.Errorf("vaultsecurity: reading protected item %s after the probe: %w", id.Name, err)
+	case after.Properties == nil || !deref(after.Properties.GetProtectedItem().IsScheduledForDeferredDelete):
+		return probe, fmt.Errorf("vaultsecurity: protected item %s was deleted but is not soft-deleted", id.Name)
+	}
+
+	restore := armrecoveryservicesbackup.ProtectedItem{
+		ProtectedItemType: props.ProtectedItemType,
+		SourceResourceID:  props.SourceResourceID,
+		PolicyID:          props.PolicyID,
+		IsRehydrate:       to.Ptr(true),
+	}
+	if _, err := items.CreateOrUpdate(ctx, vault, resourceGroup, fabric.Name, container.Name, id.Name,
+		armrecoveryservicesbackup.ProtectedItemResource{Properties: &restore}, nil); err != nil {
+		return probe, fmt.Errorf("vaultsecurity: undeleting protected item %s after the probe: %w", id.Name, err)
+	}
+	after, err = items.Get(ctx, vault, resourceGroup, fabric.Name, container.Name, id.Name, nil)
+	if err != nil {
+		return probe, fmt.Errorf("vaultsecurity: reading protected item %s after undeleting it: %w", id.Name, err)
+	}
+	if after.Properties == nil || deref(after.Properties.GetProtectedItem().IsScheduledForDeferredDelete) {
+		return probe, fmt.Errorf("vaultsecurity: protected item %s is still soft-deleted after undeleting it", id.Name)
+	}
+	return probe, nil
+}
@@ -0,0 +1,339 @@
+// Package vaultsecurity reads the settings that keep a Recovery Services
+// vault's backups from being destroyed, and checks them against the profile.
+//
+// Read collects the vault's immutability state, soft delete and its
+// retention, the enhanced security setting of the vault's backup
+// configuration and the Resource Guard the vault is linked to for multi-user
+// authorization (MUA), if any. Check compares that posture with an
+// Expectation built from the profile.
+//
+// Settings only matter if Azure enforces them, so the package also has
+// probes that attempt an operation immutability or MUA must refuse and
+// report whether it was blocked; see ProbeRetentionReduction and
+// ProbeStopProtection.
+package vaultsecurity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservices"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/recoveryservices/armrecoveryservicesbackup"
+
+	"github.com/SwastikaAryal/azure_terraform/profile"
+)
+
+// resourceGuardProxyName is the only name a vault's Resource Guard proxy can
+// have.
+const resourceGuardProxyName = "VaultProxy"
+
+// Config selects the subscription the vault lives in.
+type Config struct {
+	SubscriptionID string
+	Credential     azcore.TokenCredential
+	Options        *arm.ClientOptions
+
+	// Now returns the current time; time.Now when nil.
+	Now func() time.Time
+}
+
+func (c Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Posture is the security configuration of one vault as Azure reports it.
+type Posture struct {
+	VaultID string `json:"vault_id"`
+	// Immutability is Disabled, Unlocked or Locked.
+	Immutability string `json:"immutability"`
+	// SoftDelete is Enabled, AlwaysON or Disabled.
+	SoftDelete              string `json:"soft_delete"`
+	SoftDeleteRetentionDays int    `json:"soft_delete_retention_days"`
+	EnhancedSecurity        string `json:"enhanced_security"`
+	// MultiUserAuthorization is the vault's own MUA state.
+	MultiUserAuthorization string `json:"multi_user_authorization"`
+	// ResourceGuardID is the Resource Guard the vault is linked to; empty
+	// when it is not linked.
+	ResourceGuardID string `json:"resource_guard_id"`
+	// GuardedOperations are the critical operations the guard protects.
+	GuardedOperations []string `json:"guarded_operations"`
+}
+
+// Read reads the security posture of the vault.
+func Read(ctx context.Context, cfg Config, resourceGroup, vault string) (*Posture, error) {
+	vaults, err := armrecoveryservices.NewVaultsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := armrecoveryservicesbackup.NewBackupResourceVaultConfigsClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := armrecoveryservicesbackup.NewResourceGuardProxyClient(cfg.SubscriptionID, cfg.Credential, cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := vaults.Get(ctx, resourceGroup, vault, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vaultsecurity: reading vault %s: %w", vault, err)
+	}
+	p := &Posture{
+		VaultID:                deref(v.ID),
+		Immutability:           string(armrecoveryservices.ImmutabilityStateDisabled),
+		SoftDelete:             string(armrecoveryservices.SoftDeleteStateDisabled),
+		MultiUserAuthorization: string(armrecoveryservices.MultiUserAuthorizationDisabled),
+		GuardedOperations:      []string{},
+	}
+	if v.Properties != nil && v.Properties.SecuritySettings != nil {
+		s := v.Properties.SecuritySettings
+		if s.ImmutabilitySettings != nil && s.ImmutabilitySettings.State != nil {
+			p.Immutability = string(*s.ImmutabilitySettings.State)
+		}
+		if s.SoftDeleteSettings != nil {
+			if s.SoftDeleteSettings.SoftDeleteState != nil {
+				p.SoftDelete = string(*s.SoftDeleteSettings.SoftDeleteState)
+			}
+			p.SoftDeleteRetentionDays = int(deref(s.SoftDeleteSettings.SoftDeleteRetentionPeriodInDays))
+		}
+		if s.MultiUserAuthorization != nil {
+			p.MultiUserAuthorization = string(*s.MultiUserAuthorization)
+		}
+	}
+
+	c, err := configs.Get(ctx, vault, resourceGroup, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vaultsecurity: reading backup configuration of vault %s: %w", vault, err)
+	}
+	p.EnhancedSecurity = string(armrecoveryservicesbackup.EnhancedSecurityStateDisabled)
+	if c.Properties != nil && c.Properties.EnhancedSecurityState != nil {
+		p.EnhancedSecurity = string(*c.Properties.EnhancedSecurityState)
+	}
+
+	proxy, err := proxies.Get(ctx, vault, resourceGroup, resourceGuardProxyName, nil)
+	if isNotFound(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vaultsecurity: reading Resource Guard proxy of vault %s: %w", vault, err)
+	}
+	if proxy.Properties != nil {
+		p.ResourceGuardID = deref(proxy.Properties.ResourceGuardResourceID)
+		for _, op := range proxy.Properties.ResourceGuardOperationDetails {
+			if op != nil && op.VaultCriticalOperation != nil {
+				p.GuardedOperations = append(p.GuardedOperations, *op.VaultCriticalOperation)
+			}
+		}
+	}
+	return p, nil
+}
+
+// softDeleteOn reports whether a soft delete state keeps deleted items.
+func softDeleteOn(state string) bool {
+	return strings.EqualFold(state, string(armrecoveryservices.SoftDeleteStateEnabled)) ||
+		strings.EqualFold(state, string(armrecoveryservices.SoftDeleteStateAlwaysON))
+}
+
+// ─── Expectations ────────────────────────────────────────────────────────────
+
+// Expectation is the posture a vault must have. Zero values are not checked,
+// except that SoftDelete, EnhancedSecurity and MultiUserAuthorization are
+// always compared.
+type Expectation struct {
+	Immutability            string
+	SoftDelete              bool
+	SoftDeleteRetentionDays int
+	EnhancedSecurity        bool
+	MultiUserAuthorization  bool
+	// ResourceGuardID is the guard the vault must be linked to; when empty
+	// with MultiUserAuthorization set, any guard will do.
+	ResourceGuardID string
+}
+
+// ExpectationFor builds the expectation of the profile's vault.
+func ExpectationFor(p *profile.Profile) Expectation {
+	v := p.Expect.Vault
+	return Expectation{
+		Immutability:            v.Immutability,
+		SoftDelete:              v.SoftDelete,
+		SoftDeleteRetentionDays: v.SoftDeleteRetentionDays,
+		EnhancedSecurity:        v.EnhancedSecurity,
+		MultiUserAuthorization:  v.MultiUserAuthorization,
+		ResourceGuardID:         p.ResourceGuardID,
+	}
+}
+
+// Finding is one setting that does not meet the expectation.
+type Finding struct {
+	Setting string `json:"setting"`
+	Want    string `json:"want"`
+	Got     string `json:"got"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s is %s, want %s", f.Setting, f.Got, f.Want)
+}
+
+// Check compares a posture with an expectation.
+func Check(p *Posture, want Expectation) []Finding {
+	var out []Finding
+	add := func(setting, want, got string) {
+		out = append(out, Finding{Setting: setting, Want: want, Got: orUnset(got)})
+	}
+	if want.Immutability != "" && !strings.EqualFold(want.Immutability, p.Immutability) {
+		add("immutability", want.Immutability, p.Immutability)
+	}
+	if want.SoftDelete != softDeleteOn(p.SoftDelete) {
+		add("soft delete", onOff(want.SoftDelete), p.SoftDelete)
+	}
+	if want.SoftDeleteRetentionDays > 0 && p.SoftDeleteRetentionDays < want.SoftDeleteRetentionDays {
+		add("soft delete retention", fmt.Sprintf("at least %d days", want.SoftDeleteRetentionDays), fmt.Sprintf("%d days", p.SoftDeleteRetentionDays))
+	}
+	if enabled := strings.EqualFold(p.EnhancedSecurity, "Enabled"); want.EnhancedSecurity != enabled {
+		add("enhanced security", onOff(want.EnhancedSecurity), p.EnhancedSecurity)
+	}
+	switch {
+	case want.MultiUserAuthorization && p.ResourceGuardID == "":
+		add("resource guard", orAny(want.ResourceGuardID), "")
+	case want.MultiUserAuthorization && want.ResourceGuardID != "" && !strings.EqualFold(want.ResourceGuardID, p.ResourceGuardID):
+		add("resource guard", want.ResourceGuardID, p.ResourceGuardID)
+	case !want.MultiUserAuthorization && p.ResourceGuardID != "":
+		add("resource guard", "none", p.ResourceGuardID)
+	}
+	return out
+}
+
+func onOff(on bool) string {
+	if on {
+		return "Enabled"
+	}
+	return "Disabled"
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "any Resource Guard"
+	}
+	return s
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return "unset"
+	}
+	return s
+}
+
+// ─── Reports ─────────────────────────────────────────────────────────────────
+
+// Report is the outcome of one posture check.
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Posture   *Posture  `json:"posture"`
+	Findings  []Finding `json:"findings"`
+	// Probes are the blocked-operation probes run with the check, if any.
+	Probes []Probe `json:"probes,omitempty"`
+}
+
+// NewReport reads the vault's posture and checks it against want.
+func NewReport(ctx context.Context, cfg Config, resourceGroup, vault string, want Expectation) (*Report, error) {
+	p, err := Read(ctx, cfg, resourceGroup, vault)
+	if err != nil {
+		return nil, err
+	}
+	findings := Check(p, want)
+	if findings == nil {
+		findings = []Finding{}
+	}
+	return &Report{CheckedAt: cfg.now().UTC(), Posture: p, Findings: findings}, nil
+}
+
+// OK reports whether the vault meets the expectation and refused every
+// probe.
+func (r *Report) OK() bool {
+	for _, p := range r.Probes {
+		if !p.Blocked {
+			return false
+		}
+	}
+	return len(r.Findings) == 0
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the posture as a Markdown table followed by the
+// findings.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	p := r.Posture
+	var b strings.Builder
+	b.WriteString("# Vault security posture\n\n")
+	fmt.Fprintf(&b, "Checked `%s` at %s.\n\n", p.VaultID, r.CheckedAt.Format(time.RFC3339))
+	b.WriteString("| Setting | Value |\n")
+	b.WriteString("|---|---|\n")
+	fmt.Fprintf(&b, "| Immutability | %s |\n", p.Immutability)
+	fmt.Fprintf(&b, "| Soft delete | %s |\n", p.SoftDelete)
+	fmt.Fprintf(&b, "| Soft delete retention | %d days |\n", p.SoftDeleteRetentionDays)
+	fmt.Fprintf(&b, "| Enhanced security | %s |\n", p.EnhancedSecurity)
+	fmt.Fprintf(&b, "| Multi-user authorization | %s |\n", p.MultiUserAuthorization)
+	fmt.Fprintf(&b, "| Resource Guard | %s |\n", orNone(p.ResourceGuardID))
+	fmt.Fprintf(&b, "| Guarded operations | %s |\n", orNone(strings.Join(p.GuardedOperations, "<br>")))
+	b.WriteString("\n")
+	if len(r.Findings) == 0 {
+		b.WriteString("Every setting meets the profile.\n")
+	} else {
+		b.WriteString("## Findings\n\n")
+		for _, f := range r.Findings {
+			fmt.Fprintf(&b, "- %s\n", f)
+		}
+	}
+	if len(r.Probes) > 0 {
+		b.WriteString("\n## Probes\n\n")
+		b.WriteString("| Operation | Target | Result |\n")
+		b.WriteString("|---|---|---|\n")
+		for _, p := range r.Probes {
+			result := "**allowed**"
+			if p.Blocked {
+				result = "blocked (" + p.Code + ")"
+			}
+			fmt.Fprintf(&b, "| %s | `%s` | %s |\n", p.Operation, p.Target, result)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SwastikaAryal/azure_terraform/fakearm"
+	"github.com/SwastikaAryal/azure_terraform/traceability"
+	"github.com/SwastikaAryal/azure_terraform/vaultsecurity"
+)
+
+// ─── Test: Immutability and multi-user authorization ─────────────────────────
+
+// TestVaultSecurityAgainstFake reads the posture of the planned vault from
+// the fake backend and checks it against the dev profile, then checks that
+// the immutable vault refuses to shorten retention or delete backup data,
+// that both go through once immutability is disabled and are undone by the
+// probes, and that linking a Resource Guard shows up as multi-user
+// authorization.
+func TestVaultSecurityAgainstFake(t *testing.T) {
+	t.Parallel()
+
+	fake := seededFake(t)
+	rg, vaultID, vault := fake.ResourceGroup, fake.VaultID, fake.Vault
+	policyID := fake.Attr("azurerm_backup_policy_vm.standard", "id")
+	policy := resourceName(policyID)
+
+	cfg := vaultsecurity.Config{SubscriptionID: fake.SubscriptionID(), Credential: fake.Credential(), Options: fake.ClientOptions()}
+	want := vaultsecurity.ExpectationFor(testProfile(t))
+
+	// ── The module as planned ─────────────────────────────────────────────
+	t.Run("Posture", func(t *testing.T) {
+		traceability.Verifies(t, "vault immutability, soft-delete retention, enhanced security and MUA match the profile", "MINITRUE-9348")
+
+		report, err := vaultsecurity.NewReport(t.Context(), cfg, rg, vault, want)
+		require.NoError(t, err)
+		assert.Equal(t, "Unlocked", report.Posture.Immutability)
+		assert.Equal(t, "Enabled", report.Posture.SoftDelete)
+		assert.Equal(t, 14, report.Posture.SoftDeleteRetentionDays)
+		assert.Equal(t, "Enabled", report.Posture.EnhancedSecurity)
+		assert.Equal(t, "Disabled", report.Posture.MultiUserAuthorization)
+		assert.Empty(t, report.Posture.ResourceGuardID)
+		assert.True(t, report.OK(), "the planned vault meets the profile: %v", report.Findings)
+	})
+
+	// ── Immutability probes ───────────────────────────────────────────────
+	t.Run("Immutability", func(t *testing.T) {
+		traceability.Verifies(t, "an immutable vault refuses to reduce backup retention", "MINITRUE-9348")
+
+		// The module's management lock would refuse the delete probes before
+		// immutability is consulted; it has its own test.
+		require.True(t, fake.Delete(fake.Attr("azurerm_management_lock.rsv_vault", "id")))
+
+		itemID := vaultID + "/backupFabrics/Azure/protectionContainers/iaasvmcontainerv2;rg-app;vm-app-0/protectedItems/vm;iaasvmcontainerv2;rg-app;vm-app-0"
+		fake.Put(itemID, map[string]interface{}{"properties": map[string]interface{}{
+			"protectedItemType": "Microsoft.Compute/virtualMachines",
+			"protectionState":   "Protected",
+			"sourceResourceId":  "/subscriptions/" + fake.SubscriptionID() + "/resourceGroups/rg-app/providers/Microsoft.Compute/virtualMachines/vm-app-0",
+			"policyId":          policyID,
+		}})
+		dailyCount := func() interface{} {
+			doc, ok := fake.Get(policyID)
+			require.True(t, ok)
+			props := doc["properties"].(map[string]interface{})
+			daily := props["retentionPolicy"].(map[string]interface{})["dailySchedule"].(map[string]interface{})
+			return fmt.Sprintf("%v", daily["retentionDuration"].(map[string]interface{})["count"])
+		}
+		days := dailyCount()
+
+		// Immutable: protected operations are refused.
+		probe, err := vaultsecurity.ProbeRetentionReduction(t.Context(), cfg, rg, vault, policy)
+		require.NoError(t, err)
+		assert.True(t, probe.Blocked, probe.String())
+		assert.Equal(t, "UserErrorOperationNotAllowedOnImmutableVault", probe.Code)
+		assert.Equal(t, days, dailyCount(), "the refused update leaves the policy as it was")
+
+		probe, err = vaultsecurity.ProbeStopProtection(t.Context(), cfg, rg, vault, itemID)
+		require.NoError(t, err)
+		assert.True(t, probe.Blocked, probe.String())
+		item, ok := fake.Get(itemID)
+		require.True(t, ok, "the protected item survives")
+		assert.NotEqual(t, true, item["properties"].(map[string]interface{})["isScheduledForDeferredDelete"])
+
+		// Mutable: the probes go through and undo themselves.
+		doc, ok := fake.Get(vaultID)
+		require.True(t, ok)
+		security := doc["properties"].(map[string]interface{})["securitySettings"].(map[string]interface{})
+		security["immutabilitySettings"] = map[string]interface{}{"state": "Disabled"}
+		fake.Put(vaultID, doc)
+
+		report, err := vaultsecurity.NewReport(t.Context(), cfg, rg, vault, want)
+		require.NoError(t, err)
+		if assert.Len(t, report.Findings, 1) {
+			assert.Equal(t, "immutability is Disabled, want Unlocked", report.Findings[0].String())
+		}
+
+		probe, err = vaultsecurity.ProbeRetentionReduction(t.Context(), cfg, rg, vault, policy)
+		require.NoError(t, err)
+		assert.False(t, probe.Blocked)
+		assert.Equal(t, days, dailyCount(), "the probe puts the policy back")
+
+		probe, err = vaultsecurity.ProbeStopProtection(t.Context(), cfg, rg, vault, itemID)
+		require.NoError(t, err)
+		assert.False(t, probe.Blocked)
+		item, ok = fake.Get(itemID)
+		require.True(t, ok, "the probe undeletes the soft-deleted item")
+		assert.NotEqual(t, true, item["properties"].(map[string]interface{})["isScheduledForDeferredDelete"])
+
+		// Without soft delete the delete would destroy the recovery points,
+		// so the probe refuses to run.
+		softDelete := security["softDeleteSettings"]
+		security["softDeleteSettings"] = map[string]interface{}{"softDeleteState": "Disabled"}
+		fake.Put(vaultID, doc)
+		_, err = vaultsecurity.ProbeStopProtection(t.Context(), cfg, rg, vault, itemID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "soft delete is Disabled")
+		after, ok := fake.Get(itemID)
+		require.True(t, ok, "the refused probe leaves the item alone")
+		assert.Equal(t, item, after)
+		security["softDeleteSettings"] = softDelete
+		fake.Put(vaultID, doc)
+	})
+
+	// ── Resource Guard ────────────────────────────────────────────────────
+	t.Run("ResourceGuard", func(t *testing.T) {
+		traceability.Verifies(t, "vault immutability, soft-delete retention, enhanced security and MUA match the profile", "MINITRUE-9348")
+
+		guardID := "/subscriptions/" + fake.SubscriptionID() + "/resourceGroups/rg-security/providers/Microsoft.DataProtection/resourceGuards/rg-backup"
+		fake.Put(vaultID+"/backupResourceGuardProxies/VaultProxy", fakearm.ResourceGuardProxy(vaultID+"/backupResourceGuardProxies/VaultProxy", guardID))
+
+		posture, err := vaultsecurity.Read(t.Context(), cfg, rg, vault)
+		require.NoError(t, err)
+		assert.Equal(t, guardID, posture.ResourceGuardID)
+		assert.Contains(t, posture.GuardedOperations, "Microsoft.RecoveryServices/vaults/backupPolicies/write")
+
+		want.MultiUserAuthorization, want.ResourceGuardID = true, guardID
+		want.Immutability = ""
+		assert.Empty(t, vaultsecurity.Check(posture, want))
+		want.ResourceGuardID = guardID + "-other"
+		if findings := vaultsecurity.Check(posture, want); assert.Len(t, findings, 1) {
+			assert.Equal(t, "resource guard", findings[0].Setting)
+		}
+	})
+}